@@ -0,0 +1,78 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ActionAbort is the action attribute set on abort messages. Subscribers should halt
+// any in-progress onboarding for the tenantName attribute when they receive one.
+const ActionAbort = "abort"
+
+// abortMessage is the minimal body of an abort message
+type abortMessage struct {
+	Action      string `json:"action"`
+	TenantName  string `json:"tenantName"`
+	AbortReason string `json:"abortReason"`
+}
+
+// Abort is an abort message received by a Subscriber, see OnAbort
+type Abort struct {
+	// TenantName is the tenant whose onboarding is to be halted
+	TenantName string
+	// Reason is the abortReason given to PublishAbort
+	Reason string
+	// Attributes are the attributes the abort was published with
+	Attributes *TinyHomeMessageAttributes
+}
+
+// PublishAbort publishes an abort message so downstream subscribers halt a partially
+// completed onboarding of tenantName. reason is required and is published as the
+// abortReason attribute. messageAttributes are those the aborted instructions were
+// published with, their routing attributes are set on the abort so subscriptions
+// filtering on them, such as a stage's, receive it. With nil messageAttributes only
+// unfiltered subscriptions receive it.
+func (p *Publisher) PublishAbort(ctx context.Context, tenantName, reason string, messageAttributes *TinyHomeMessageAttributes) (string, error) {
+	if err := (TinyHomeInstructions{TenantName: tenantName}).validateTenantName(p.cfg.MinTenantNameLength, p.cfg.MaxTenantNameLength); err != nil {
+		return "", fmt.Errorf("PublishAbort: %w", err)
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return "", fmt.Errorf("PublishAbort: %w", &RoutingError{Field: "abortReason", Message: "abortReason is required to abort an onboarding"})
+	}
+
+	attributes := map[string]string{
+		"action":       ActionAbort,
+		AttrTenantName: tenantName,
+		"abortReason":  reason,
+	}
+	if messageAttributes != nil {
+		if _, err := messageAttributes.subscriptionWith(p.cfg); err != nil {
+			return "", fmt.Errorf("PublishAbort: %w", err)
+		}
+		attributes[AttrGroupsCreated] = messageAttributes.GroupsCreated
+		attributes[AttrWorkspaceCreated] = messageAttributes.WorkspaceCreated
+		attributes[AttrTenantCreated] = messageAttributes.TenantCreated
+		attributes[AttrFluxCreated] = messageAttributes.FluxCreated
+		attributes[AttrDeliveredFrom] = messageAttributes.DeliveredFrom
+	}
+
+	byteMessage, err := json.Marshal(abortMessage{
+		Action:      ActionAbort,
+		TenantName:  tenantName,
+		AbortReason: reason,
+	})
+	if err != nil {
+		return "", fmt.Errorf("PublishAbort: %v", err)
+	}
+
+	id, err := p.publishMessage(ctx, byteMessage, attributes)
+	if err != nil {
+		return "", fmt.Errorf("PublishAbort: %w", err)
+	}
+
+	p.logger().InfoContext(ctx, "published abort", "tenantName", tenantName, "messageId", id)
+	return id, nil
+}
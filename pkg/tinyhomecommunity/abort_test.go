@@ -0,0 +1,142 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestPublishAbort(t *testing.T) {
+	tests := []struct {
+		name       string
+		tenantName string
+		reason     string
+		// attributes are those of the aborted instructions
+		attributes *TinyHomeMessageAttributes
+		wantField  string
+	}{
+		{name: "abort", tenantName: "contract-tenant", reason: "requested by the tenant owner"},
+		{name: "abort with routing attributes", tenantName: "contract-tenant", reason: "requested by the tenant owner", attributes: attributesFor(t, "createTenant")},
+		{name: "invalid routing attributes", tenantName: "contract-tenant", reason: "duplicate request", attributes: &TinyHomeMessageAttributes{GroupsCreated: "yes"}, wantField: AttrGroupsCreated},
+		{name: "invalid tenant name", tenantName: "Not Valid", reason: "duplicate request", wantField: "tenantName"},
+		{name: "empty tenant name", tenantName: "", reason: "duplicate request", wantField: "tenantName"},
+		{name: "empty reason", tenantName: "contract-tenant", reason: "", wantField: "abortReason"},
+		{name: "blank reason", tenantName: "contract-tenant", reason: " \t", wantField: "abortReason"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil)
+
+			id, err := p.PublishAbort(context.Background(), tt.tenantName, tt.reason, tt.attributes)
+			if tt.wantField != "" {
+				wantFieldError(t, err, tt.wantField)
+				if got := len(topic.published()); got != 0 {
+					t.Errorf("published %d messages, want none", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishAbort: %v", err)
+			}
+			if id != "1" {
+				t.Errorf("PublishAbort = %q, want message ID 1", id)
+			}
+
+			msg := topic.published()[0]
+			for key, want := range map[string]string{"action": ActionAbort, AttrTenantName: tt.tenantName, "abortReason": tt.reason} {
+				if got := msg.Attributes[key]; got != want {
+					t.Errorf("%s attribute = %q, want %q", key, got, want)
+				}
+			}
+			// Abort messages carry the routing attributes of the aborted instructions,
+			// or none without them
+			routing := map[string]string{}
+			if tt.attributes != nil {
+				routing = map[string]string{
+					AttrGroupsCreated:    tt.attributes.GroupsCreated,
+					AttrWorkspaceCreated: tt.attributes.WorkspaceCreated,
+					AttrTenantCreated:    tt.attributes.TenantCreated,
+					AttrFluxCreated:      tt.attributes.FluxCreated,
+					AttrDeliveredFrom:    tt.attributes.DeliveredFrom,
+				}
+			}
+			for _, key := range []string{AttrGroupsCreated, AttrWorkspaceCreated, AttrTenantCreated, AttrFluxCreated, AttrDeliveredFrom} {
+				got, ok := msg.Attributes[key]
+				if want, wantOK := routing[key]; got != want || ok != wantOK {
+					t.Errorf("%s attribute = %q, set %t, want %q", key, got, ok, want)
+				}
+			}
+
+			// The body is only the action, tenant and reason
+			var body map[string]string
+			if err := json.Unmarshal(msg.Data, &body); err != nil {
+				t.Fatalf("abort body %s: %v", msg.Data, err)
+			}
+			want := map[string]string{"action": ActionAbort, "tenantName": tt.tenantName, "abortReason": tt.reason}
+			if !maps.Equal(body, want) {
+				t.Errorf("abort body %v, want %v", body, want)
+			}
+		})
+	}
+}
+
+// stageFilter returns the subscription filter of a stage subscription for rule that
+// also only takes messages delivered from deliveredFrom
+func stageFilter(rule SubscriptionRule, deliveredFrom string) string {
+	return fmt.Sprintf(`attributes.%s = "%t" AND attributes.%s = "%t" AND attributes.%s = "%t" AND attributes.%s = "%t" AND attributes.%s = "%s"`,
+		AttrGroupsCreated, rule.GroupsCreated, AttrWorkspaceCreated, rule.WorkspaceCreated,
+		AttrTenantCreated, rule.TenantCreated, AttrFluxCreated, rule.FluxCreated,
+		AttrDeliveredFrom, deliveredFrom)
+}
+
+// matchesFilter reports whether attributes pass filter, a conjunction of
+// attributes.<key> = "<value>" comparisons like stageFilter writes. pstest does not
+// apply subscription filters, so tests evaluate them with it.
+func matchesFilter(t *testing.T, filter string, attributes map[string]string) bool {
+	t.Helper()
+	for _, comparison := range strings.Split(filter, " AND ") {
+		key, value, ok := strings.Cut(comparison, " = ")
+		if !ok || !strings.HasPrefix(key, "attributes.") {
+			t.Fatalf("unsupported filter comparison %q", comparison)
+		}
+		if attributes[strings.TrimPrefix(key, "attributes.")] != strings.Trim(value, `"`) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPublishAbortFilteredSubscription(t *testing.T) {
+	// The abort reaches the stage subscription the aborted instructions were routed to
+	// and no other
+	filters := map[string]string{}
+	for _, rule := range DefaultSubscriptionRules {
+		filters[rule.Subscription] = stageFilter(rule, "galaxy")
+	}
+
+	for _, aborted := range []string{"createGroups", "createTenant", "deliverEmail"} {
+		t.Run(aborted, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil)
+			if _, err := p.PublishAbort(context.Background(), "contract-tenant", "requested by the tenant owner", attributesFor(t, aborted)); err != nil {
+				t.Fatalf("PublishAbort: %v", err)
+			}
+
+			msg := topic.published()[0]
+			for subscription, filter := range filters {
+				if got, want := matchesFilter(t, filter, msg.Attributes), subscription == aborted; got != want {
+					t.Errorf("abort passes the %s filter %t, want %t", subscription, got, want)
+				}
+			}
+			// A subscription for another upstream system does not receive it
+			if matchesFilter(t, stageFilter(DefaultSubscriptionRules[0], "manual"), msg.Attributes) {
+				t.Error("abort delivered from galaxy passes a filter on deliveredFrom manual")
+			}
+		})
+	}
+}
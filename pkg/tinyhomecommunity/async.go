@@ -0,0 +1,159 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PendingPublish is a message PublishAsync handed to the topic whose result has not
+// been waited on yet. It is safe to call Get from several goroutines.
+type PendingPublish struct {
+	p        *Publisher
+	prepared *preparedMessage
+	flight   *inFlight
+	span     trace.Span
+	logLine  PublishLogLine
+	start    time.Time
+
+	// done is closed once publish and err hold the server's result
+	done    chan struct{}
+	publish *PublishResult
+	err     error
+}
+
+// PublishAsync validates the instructions and attributes like PublishWithResult and
+// hands the message to the topic without waiting for the server to accept it, so
+// many publishes can be in flight and awaited together. Validation failures are
+// returned here, publish failures from PendingPublish.Get.
+//
+// Async publishes take a per-topic publish slot like any other, so PublishAsync
+// blocks while MaxConcurrentPublishes messages are in flight on the topic, and hold it
+// until their result is in. Transient failures are retried in the background as for
+// PublishContext. PublishTimeout runs from PublishAsync to the server's result, while
+// ctx only bounds validation and the wait for a slot, as the message is in flight once
+// PublishAsync returns. With AsyncCallback set the result is also handed to it, so the
+// PendingPublish can be dropped.
+func (p *Publisher) PublishAsync(ctx context.Context, message *TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes) (*PendingPublish, error) {
+	ctx, span := p.tracer().Start(ctx, publishSpanName, trace.WithAttributes(
+		attribute.String("tinyhome.topic", p.cfg.TopicID),
+	))
+
+	// The background wait keeps the span but not the caller's cancellation
+	waitCtx, cancelWait := p.withTimeout(context.WithoutCancel(ctx))
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	pending := &PendingPublish{
+		p:       p,
+		span:    span,
+		logLine: PublishLogLine{Topic: p.cfg.TopicID},
+		start:   time.Now(),
+		done:    make(chan struct{}),
+	}
+
+	if p.cfg.CloudEventsTopicID != "" {
+		err := errDualPublishUnsupported
+		cancelWait()
+		endSpan(span, err)
+		return nil, fmt.Errorf("PublishAsync: %w", err)
+	}
+
+	prepared, err := p.prepare(ctx, message, messageAttributes, &pending.logLine)
+	if err != nil {
+		cancelWait()
+		p.quarantine(ctx, message, messageAttributes, err)
+		pending.logLine.Tenant = message.TenantName
+		p.recordPublish(pending.logLine, pending.start, err)
+		endSpan(span, err)
+		return nil, fmt.Errorf("PublishAsync: %w", err)
+	}
+	pending.prepared = prepared
+	pending.logLine.Tenant = prepared.tenantName
+
+	pending.flight, err = p.issue(ctx, prepared.topicID, prepared.topic, prepared.data, prepared.attributes)
+	if err != nil {
+		cancelWait()
+		p.recordPublish(pending.logLine, pending.start, err)
+		endSpan(span, err)
+		return nil, fmt.Errorf("PublishAsync: %w", err)
+	}
+
+	p.settling.Add(1)
+	go func() {
+		defer p.settling.Done()
+		defer cancelWait()
+		pending.settle(waitCtx)
+	}()
+	return pending, nil
+}
+
+// settle waits once for the server's result, whether or not Get is ever called,
+// records it and ends the span, then hands it to AsyncCallback when one is set
+func (pp *PendingPublish) settle(ctx context.Context) {
+	pp.publish, pp.err = pp.wait(ctx)
+	if pp.publish != nil {
+		pp.logLine.MessageID = pp.publish.MessageID
+	}
+	pp.p.recordPublish(pp.logLine, pp.start, pp.err)
+	endSpan(pp.span, pp.err)
+	close(pp.done)
+
+	if pp.publish != nil {
+		pp.p.delivered(ctx, pp.publish)
+	}
+	if pp.p.cfg.AsyncCallback != nil {
+		pp.notify(ctx, pp.p.cfg.AsyncCallback)
+	}
+}
+
+// notify hands the result to callback, logging rather than propagating a panic in
+// it. A failed publish still reports the routing, tenant name and correlation ID,
+// with no MessageID.
+func (pp *PendingPublish) notify(ctx context.Context, callback func(PublishResult, error)) {
+	defer pp.p.recoverCallback(ctx, "AsyncCallback")
+
+	if pp.err != nil {
+		result := pp.prepared.result("")
+		result.Err = pp.err
+		callback(*result, pp.err)
+		return
+	}
+	callback(*pp.publish, nil)
+}
+
+// Get blocks until the server accepts or rejects the message, or ctx is done. The
+// result is awaited once in the background, so every call returns the same result and
+// a call whose ctx is done returns that ctx's error without affecting the others. A
+// message still in flight when ctx is done may yet be delivered. Messages that fail
+// are handed to the RetryQueue, if one is set.
+func (pp *PendingPublish) Get(ctx context.Context) (*PublishResult, error) {
+	select {
+	case <-pp.done:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("PendingPublish.Get: waiting for publish result: %w", ctx.Err())
+	}
+
+	if pp.err != nil {
+		return nil, fmt.Errorf("PendingPublish.Get: %w", pp.err)
+	}
+	return pp.publish, nil
+}
+
+// wait awaits the publish result like sendTo does and logs the published message ID
+func (pp *PendingPublish) wait(ctx context.Context) (*PublishResult, error) {
+	p, prepared := pp.p, pp.prepared
+
+	id, err := p.await(ctx, pp.flight)
+	if err != nil {
+		return nil, err
+	}
+
+	pp.span.SetAttributes(attribute.String("tinyhome.message_id", id))
+	p.logger().InfoContext(ctx, "published message", "tenantName", prepared.tenantName, "messageId", id, "correlationId", prepared.attributes["correlationId"], "attributes", prepared.messageAttributes)
+	p.logger().DebugContext(ctx, prepared.attrMessage)
+	return prepared.result(id), nil
+}
@@ -0,0 +1,220 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPublishAsync(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	never := make(chan struct{})
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		mutate func(m *TinyHomeInstructions)
+		result func(n int, msg *pubsub.Message) publishResult
+		// wantValidation is whether PublishAsync itself fails
+		wantValidation bool
+		wantID         string
+		wantErr        error
+	}{
+		{name: "published", wantID: "1"},
+		{name: "invalid instructions", mutate: func(m *TinyHomeInstructions) { m.TenantName = "Not Valid" }, wantValidation: true},
+		{
+			name:    "publish failure",
+			result:  func(n int, msg *pubsub.Message) publishResult { return fakeResult{err: errPubSub} },
+			wantErr: errPubSub,
+		},
+		{
+			name: "transient failure retried",
+			opts: []Option{WithRetry(3, time.Millisecond)},
+			result: func(n int, msg *pubsub.Message) publishResult {
+				if n == 1 {
+					return fakeResult{err: status.Error(codes.Unavailable, "try again")}
+				}
+				return fakeResult{id: "2"}
+			},
+			wantID: "2",
+		},
+		{
+			name:    "publish timeout",
+			opts:    []Option{WithTimeout(20 * time.Millisecond)},
+			result:  func(n int, msg *pubsub.Message) publishResult { return fakeResult{ready: never} },
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{result: tt.result}
+			p := newTestPublisher(t, topic, nil, tt.opts...)
+			message := validInstructions()
+			if tt.mutate != nil {
+				tt.mutate(&message)
+			}
+
+			pending, err := p.PublishAsync(context.Background(), &message, validAttributes())
+			if tt.wantValidation {
+				wantFieldError(t, err, "tenantName")
+				if got := len(topic.published()); got != 0 {
+					t.Errorf("published %d messages, want none", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishAsync: %v", err)
+			}
+
+			// The timeout applies although Get waits without a deadline
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			result, err := pending.Get(ctx)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Get error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if result.MessageID != tt.wantID || result.TenantName != message.TenantName {
+				t.Errorf("Get = %+v, want message %s for %s", result, tt.wantID, message.TenantName)
+			}
+		})
+	}
+}
+
+func TestPublishAsyncLimiter(t *testing.T) {
+	release := make(chan struct{})
+	topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+		return fakeResult{id: "1", ready: release}
+	}}
+	p := newTestPublisher(t, topic, nil, WithMaxConcurrentPublishes(1))
+
+	message := validInstructions()
+	first, err := p.PublishAsync(context.Background(), &message, validAttributes())
+	if err != nil {
+		t.Fatalf("PublishAsync: %v", err)
+	}
+
+	// The only slot is held until the first result is in, so a second publish waits
+	// for it and gives up with its ctx
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	second := validInstructions()
+	if _, err := p.PublishAsync(ctx, &second, validAttributes()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("PublishAsync with no free slot error = %v, want context.DeadlineExceeded", err)
+	}
+	if got := len(topic.published()); got != 1 {
+		t.Fatalf("published %d messages, want 1", got)
+	}
+
+	close(release)
+	if _, err := first.Get(context.Background()); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	third, err := p.PublishAsync(context.Background(), &second, validAttributes())
+	if err != nil {
+		t.Fatalf("PublishAsync after the slot freed: %v", err)
+	}
+	if _, err := third.Get(context.Background()); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+}
+
+func TestPendingPublishGet(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+
+	tests := []struct {
+		name string
+		// timedOut is whether a Get times out before the result is in
+		timedOut bool
+		// getAfter is whether Get is called once the result is in
+		getAfter bool
+		err      error
+	}{
+		{name: "delivered after a timed out Get", timedOut: true, getAfter: true},
+		{name: "failed after a timed out Get", timedOut: true, getAfter: true, err: errPubSub},
+		{name: "delivered without a Get"},
+		{name: "failed without a Get", err: errPubSub},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+				return fakeResult{id: "1", err: tt.err, ready: release}
+			}}
+			type callbackResult struct {
+				result PublishResult
+				err    error
+			}
+			callbacks := make(chan callbackResult, 1)
+			p := newTestPublisher(t, topic, nil, WithAsyncCallback(func(result PublishResult, err error) {
+				callbacks <- callbackResult{result, err}
+			}))
+			message := validInstructions()
+
+			pending, err := p.PublishAsync(context.Background(), &message, validAttributes())
+			if err != nil {
+				t.Fatalf("PublishAsync: %v", err)
+			}
+
+			if tt.timedOut {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				_, err := pending.Get(ctx)
+				cancel()
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("Get before the result error = %v, want context.DeadlineExceeded", err)
+				}
+			}
+			close(release)
+
+			// The callback reports the server's result, not an earlier Get's ctx error
+			var got callbackResult
+			select {
+			case got = <-callbacks:
+			case <-time.After(time.Second):
+				t.Fatal("AsyncCallback was not called")
+			}
+			if tt.err != nil {
+				if !errors.Is(got.err, tt.err) || !errors.Is(got.result.Err, tt.err) {
+					t.Errorf("AsyncCallback error = %v, result Err %v, want %v", got.err, got.result.Err, tt.err)
+				}
+			} else if got.err != nil || got.result.MessageID != "1" {
+				t.Errorf("AsyncCallback = %+v, %v, want message 1", got.result, got.err)
+			}
+
+			if tt.getAfter {
+				result, err := pending.Get(context.Background())
+				switch {
+				case tt.err != nil && !errors.Is(err, tt.err):
+					t.Errorf("Get after the result error = %v, want %v", err, tt.err)
+				case tt.err == nil && (err != nil || result.MessageID != "1"):
+					t.Errorf("Get after the result = %+v, %v, want message 1", result, err)
+				}
+			}
+
+			// The publish is recorded once, whether or not Get was called
+			if err := p.Flush(context.Background()); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+			stats := p.Stats()
+			want := PublishStats{Published: 1}
+			if tt.err != nil {
+				want = PublishStats{TransportFailures: 1}
+			}
+			if stats.Published != want.Published || stats.TransportFailures != want.TransportFailures {
+				t.Errorf("Stats = %+v, want %d published and %d transport failures", stats, want.Published, want.TransportFailures)
+			}
+		})
+	}
+}
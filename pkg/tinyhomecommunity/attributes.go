@@ -0,0 +1,272 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// The routing attribute keys of every instructions message. Consumers can use them
+// in subscription filters such as attributes.groupsCreated = "true".
+const (
+	AttrGroupsCreated    = "groupsCreated"
+	AttrWorkspaceCreated = "workspaceCreated"
+	AttrTenantCreated    = "tenantCreated"
+	AttrFluxCreated      = "fluxCreated"
+	AttrDeliveredFrom    = "deliveredFrom"
+	AttrTenantName       = "tenantName"
+)
+
+const (
+	// maxMessageAttributes is the most attributes Pub/Sub accepts on a single message
+	maxMessageAttributes = 100
+
+	// maxAttributeValueBytes is the longest attribute value Pub/Sub accepts
+	maxAttributeValueBytes = 1024
+
+	// truncatedMarker ends attribute values shortened by AttributeValueTruncate
+	truncatedMarker = "..."
+)
+
+// AttributeValuePolicy decides what happens to attribute values longer than the
+// 1024 byte Pub/Sub limit
+type AttributeValuePolicy int
+
+const (
+	// AttributeValueReject fails the publish, naming the attribute and its length
+	AttributeValueReject AttributeValuePolicy = iota
+	// AttributeValueTruncate shortens the value to the limit, ending it with "..."
+	AttributeValueTruncate
+)
+
+// buildAttributes returns the Pub/Sub attributes published with the instructions.
+// tenantName is the routing key subscribers group by, so it is never left empty even
+// when validation upstream has been skipped.
+func (message *TinyHomeInstructions) buildAttributes(messageAttributes *TinyHomeMessageAttributes) (map[string]string, error) {
+	if message.TenantName == "" {
+		return nil, &NamingError{Field: "tenantName", Message: "tenantName attribute can not be empty"}
+	}
+
+	hash, err := message.InstructionHash()
+	if err != nil {
+		return nil, fmt.Errorf("instructionHash: %v", err)
+	}
+
+	attributes := map[string]string{
+		AttrGroupsCreated:    messageAttributes.GroupsCreated,    // true or false
+		AttrWorkspaceCreated: messageAttributes.WorkspaceCreated, // true or false
+		AttrTenantCreated:    messageAttributes.TenantCreated,    // true or false
+		AttrFluxCreated:      messageAttributes.FluxCreated,      // true or false
+		AttrDeliveredFrom:    messageAttributes.DeliveredFrom,    // manual or galaxy
+		AttrTenantName:       message.TenantName,
+		"publisherVersion":   publisherVersion(),
+		"schemaVersion":      SchemaVersion,
+		"instructionHash":    hash,
+		"priority":           message.priority(), // low, normal or high
+		"ownerAccepted":      boolAttribute(message.OwnerAccepted),
+	}
+
+	if messageAttributes.CorrelationID != "" {
+		attributes["correlationId"] = messageAttributes.CorrelationID
+	}
+
+	// A retried publish of the same instructions gets the same key
+	attributes["idempotencyKey"] = hash
+	if messageAttributes.IdempotencyKey != "" {
+		attributes["idempotencyKey"] = messageAttributes.IdempotencyKey
+	}
+
+	if message.Region != "" {
+		attributes["region"] = message.Region
+	}
+
+	if message.BreakglassTicket != "" {
+		attributes["breakglassTicket"] = message.BreakglassTicket
+	}
+
+	if err := addLabelAttributes(message.Labels, attributes); err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+// reservedAttributes are the routing attributes AdditionalAttributes can never set
+// and AttributesFromMap requires. The four created flags come first.
+var reservedAttributes = []string{AttrGroupsCreated, AttrWorkspaceCreated, AttrTenantCreated, AttrFluxCreated, AttrDeliveredFrom, AttrTenantName}
+
+// publisherAttributes are every attribute key the publisher sets itself, on some
+// message under some config: the routing attributes, those of the optional features
+// such as compression and checksums, and those of republished, abort, heartbeat and
+// quarantined messages. AdditionalAttributes can use none of them, nor a key starting
+// with labelAttributePrefix, whether or not the feature is enabled, so they can never
+// replace or forge one. See PublisherAttributeKeys.
+var publisherAttributes = append(append([]string(nil), reservedAttributes...),
+	"publisherVersion", "schemaVersion", "instructionHash", "priority", "ownerAccepted",
+	"correlationId", "idempotencyKey", "region", "breakglassTicket", "publishedAt",
+	"compatibility", "maxDeliveryAttempts", "deadLetterTopic", "namespace", "compact",
+	"content-type", "contentEncoding", "bodyChecksum", "republished", "action",
+	"abortReason", "messageType", "validationError", "retryAttempt", "messageKey",
+)
+
+// PublisherAttributeKeys returns the attribute keys reserved for the publisher, which
+// AdditionalAttributes can not use, sorted
+func PublisherAttributeKeys() []string {
+	keys := append([]string(nil), publisherAttributes...)
+	sort.Strings(keys)
+	return keys
+}
+
+// validateAdditionalAttributes rejects AdditionalAttributes keys reserved for the
+// publisher, naming every one used and listing the reserved keys
+func validateAdditionalAttributes(additional map[string]string) error {
+	var collisions []string
+	for key := range additional {
+		if contains(publisherAttributes, key) || strings.HasPrefix(key, labelAttributePrefix) {
+			collisions = append(collisions, key)
+		}
+	}
+	if len(collisions) == 0 {
+		return nil
+	}
+
+	sort.Strings(collisions)
+	return fmt.Errorf("additional attributes can not use keys reserved for the publisher: %s (reserved keys are %s and any starting with %q)", strings.Join(collisions, ", "), strings.Join(PublisherAttributeKeys(), ", "), labelAttributePrefix)
+}
+
+// addConfigAttributes adds the attributes that come from the Publisher config rather
+// than the message. AdditionalAttributes can not replace an attribute already set,
+// which validateAdditionalAttributes has ruled out at construction.
+func (p *Publisher) addConfigAttributes(attributes map[string]string) error {
+	if p.cfg.PublisherVersion != "" {
+		attributes["publisherVersion"] = p.cfg.PublisherVersion
+	}
+
+	if p.cfg.Compatibility != "" {
+		attributes["compatibility"] = p.cfg.Compatibility
+	}
+
+	if p.cfg.DeliveryPolicy != nil {
+		for key, value := range p.cfg.DeliveryPolicy.attributes() {
+			attributes[key] = value
+		}
+	}
+
+	keys := make([]string, 0, len(p.cfg.AdditionalAttributes))
+	for key := range p.cfg.AdditionalAttributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, ok := attributes[key]; ok {
+			return fmt.Errorf("additional attribute %s collides with an attribute set by the publisher", key)
+		}
+		attributes[key] = p.cfg.AdditionalAttributes[key]
+	}
+	return nil
+}
+
+// validateAttributeCount rejects attribute maps Pub/Sub would refuse server side
+func validateAttributeCount(attributes map[string]string) error {
+	if len(attributes) > maxMessageAttributes {
+		return fmt.Errorf("message has %d attributes, Pub/Sub allows at most %d", len(attributes), maxMessageAttributes)
+	}
+	return nil
+}
+
+// applyAttributeValuePolicy rejects or truncates, in place, attribute values over the
+// Pub/Sub limit according to policy
+func applyAttributeValuePolicy(attributes map[string]string, policy AttributeValuePolicy) error {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := attributes[key]
+		if len(value) <= maxAttributeValueBytes {
+			continue
+		}
+
+		if policy != AttributeValueTruncate {
+			return fmt.Errorf("attribute %s is %d bytes, Pub/Sub allows at most %d", key, len(value), maxAttributeValueBytes)
+		}
+		attributes[key] = truncateAttributeValue(value)
+	}
+	return nil
+}
+
+// truncateAttributeValue shortens value to maxAttributeValueBytes including the
+// truncatedMarker, without splitting a multi-byte character
+func truncateAttributeValue(value string) string {
+	cut := maxAttributeValueBytes - len(truncatedMarker)
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut] + truncatedMarker
+}
+
+// AttributesFromMap rebuilds TinyHomeMessageAttributes from the attributes of a
+// received message, the inverse of what PublishTinyHomeInstructions writes. Each of
+// reservedAttributes is required, the created flags must be true or false and
+// correlationId and idempotencyKey, when present, must be valid. The error is a
+// ValidationErrors of RoutingError, one for each bad key.
+func AttributesFromMap(attributes map[string]string) (*TinyHomeMessageAttributes, error) {
+	var errs ValidationErrors
+	for _, key := range reservedAttributes {
+		if _, ok := attributes[key]; !ok {
+			errs = append(errs, &RoutingError{Field: key, Message: fmt.Sprintf("message attribute %s is missing", key)})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	messageAttributes := attributesFromMessage(attributes)
+	for _, key := range reservedAttributes[:4] {
+		if value := attributes[key]; value != "true" && value != "false" {
+			errs = append(errs, &RoutingError{Field: key, Message: fmt.Sprintf("message attribute %s %q does not equal true or false", key, value)})
+		}
+	}
+
+	for _, key := range []string{AttrDeliveredFrom, AttrTenantName} {
+		if attributes[key] == "" {
+			errs = append(errs, &RoutingError{Field: key, Message: fmt.Sprintf("message attribute %s can not be empty", key)})
+		}
+	}
+
+	if err := validateCorrelationID(messageAttributes.CorrelationID); err != nil {
+		errs = append(errs, err.(ValidationError))
+	}
+
+	if err := validateIdempotencyKey(messageAttributes.IdempotencyKey); err != nil {
+		errs = append(errs, err.(ValidationError))
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return messageAttributes, nil
+}
+
+// ApplyDefaults sets any of GroupsCreated, WorkspaceCreated, TenantCreated and
+// FluxCreated left as an empty string to "false", so a minimal attributes struct with
+// only DeliveredFrom set routes to createGroups. Validation is strict about empty
+// booleans, call ApplyDefaults before publishing, or enable DefaultEmptyAttributes, to
+// opt in to the defaulting.
+func (messageAttributes *TinyHomeMessageAttributes) ApplyDefaults() {
+	fillEmpty(&messageAttributes.GroupsCreated, "false")
+	fillEmpty(&messageAttributes.WorkspaceCreated, "false")
+	fillEmpty(&messageAttributes.TenantCreated, "false")
+	fillEmpty(&messageAttributes.FluxCreated, "false")
+}
+
+// normalized returns a copy of the attributes with DeliveredFrom trimmed and lower
+// cased, leaving the caller's attributes untouched
+func (messageAttributes *TinyHomeMessageAttributes) normalized() *TinyHomeMessageAttributes {
+	n := *messageAttributes
+	n.DeliveredFrom = strings.ToLower(strings.TrimSpace(n.DeliveredFrom))
+	return &n
+}
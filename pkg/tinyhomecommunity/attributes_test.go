@@ -0,0 +1,539 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// additionalAttributes returns n extra attributes extra-0, extra-1 and so on
+func additionalAttributes(n int) map[string]string {
+	attributes := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		attributes[fmt.Sprintf("extra-%d", i)] = "value"
+	}
+	return attributes
+}
+
+func TestAttributeCountLimit(t *testing.T) {
+	// The attributes a publish of validInstructions sets before any extra ones
+	message := validInstructions()
+	result, err := message.DryRun(validAttributes())
+	if err != nil {
+		t.Fatalf("DryRun: %v", err)
+	}
+	base := len(result.Attributes)
+
+	tests := []struct {
+		name    string
+		extra   int
+		wantErr bool
+	}{
+		{"under the limit", 10, false},
+		{"at the limit", maxMessageAttributes - base, false},
+		{"over the limit", maxMessageAttributes - base + 1, true},
+		{"far over the limit", 150, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			result, err := message.DryRun(validAttributes(), WithAdditionalAttributes(additionalAttributes(tt.extra)))
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("DryRun: %v", err)
+				}
+				if got := len(result.Attributes); got != base+tt.extra {
+					t.Errorf("%d attributes, want %d", got, base+tt.extra)
+				}
+				return
+			}
+
+			want := fmt.Sprintf("message has %d attributes, Pub/Sub allows at most %d", base+tt.extra, maxMessageAttributes)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Fatalf("DryRun error = %v, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestAttributeValuePolicy(t *testing.T) {
+	long := strings.Repeat("a", 2000)
+	// A 3 byte character straddling the cut, which must not be split
+	multibyte := strings.Repeat("a", maxAttributeValueBytes-len(truncatedMarker)-1) + strings.Repeat("€", 10)
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		value string
+		// want is the published value, empty when the publish is rejected
+		want string
+	}{
+		{name: "reject by default", value: long},
+		{name: "reject", opts: []Option{WithAttributeValuePolicy(AttributeValueReject)}, value: long},
+		{
+			name:  "truncate",
+			opts:  []Option{WithAttributeValuePolicy(AttributeValueTruncate)},
+			value: long,
+			want:  long[:maxAttributeValueBytes-len(truncatedMarker)] + truncatedMarker,
+		},
+		{
+			name:  "truncate before a multi-byte character",
+			opts:  []Option{WithAttributeValuePolicy(AttributeValueTruncate)},
+			value: multibyte,
+			want:  multibyte[:maxAttributeValueBytes-len(truncatedMarker)-1] + truncatedMarker,
+		},
+		{
+			name:  "value at the limit is kept",
+			value: long[:maxAttributeValueBytes],
+			want:  long[:maxAttributeValueBytes],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			opts := append([]Option{WithAdditionalAttributes(map[string]string{"summary": tt.value})}, tt.opts...)
+			result, err := message.DryRun(validAttributes(), opts...)
+			if tt.want == "" {
+				want := fmt.Sprintf("attribute summary is %d bytes, Pub/Sub allows at most %d", len(tt.value), maxAttributeValueBytes)
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Fatalf("DryRun error = %v, want %q", err, want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DryRun: %v", err)
+			}
+
+			got := result.Attributes["summary"]
+			if got != tt.want {
+				t.Errorf("summary = %q, want %q", got, tt.want)
+			}
+			if len(got) > maxAttributeValueBytes {
+				t.Errorf("summary is %d bytes, over the limit", len(got))
+			}
+		})
+	}
+}
+
+func TestTenantNameAttributeNeverEmpty(t *testing.T) {
+	tests := []struct {
+		name string
+		// build returns the attributes built for instructions with tenantName, by a
+		// path that may skip the earlier validation
+		build func(t *testing.T, message TinyHomeInstructions) (map[string]string, error)
+	}{
+		{
+			name: "buildAttributes",
+			build: func(t *testing.T, message TinyHomeInstructions) (map[string]string, error) {
+				return message.buildAttributes(validAttributes())
+			},
+		},
+		{
+			name: "EstimatedBytes",
+			build: func(t *testing.T, message TinyHomeInstructions) (map[string]string, error) {
+				_, err := message.EstimatedBytes(validAttributes())
+				return map[string]string{AttrTenantName: message.TenantName}, err
+			},
+		},
+		{
+			name: "publish",
+			build: func(t *testing.T, message TinyHomeInstructions) (map[string]string, error) {
+				topic := &fakeTopic{}
+				p := newTestPublisher(t, topic, nil)
+				if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+					if got := topic.published(); len(got) != 0 {
+						t.Errorf("published %d messages after an error", len(got))
+					}
+					return nil, err
+				}
+				return topic.published()[0].Attributes, nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, tenantName := range []string{"", "contract-tenant"} {
+			t.Run(fmt.Sprintf("%s %q", tt.name, tenantName), func(t *testing.T) {
+				message := validInstructions()
+				message.TenantName = tenantName
+				attributes, err := tt.build(t, message)
+				if tenantName == "" {
+					if !errors.Is(err, ErrInvalidTenantName) {
+						t.Fatalf("error = %v, want ErrInvalidTenantName", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got := attributes[AttrTenantName]; got != tenantName {
+					t.Errorf("tenantName attribute = %q, want %q", got, tenantName)
+				}
+			})
+		}
+	}
+}
+
+func TestDefaultEmptyAttributes(t *testing.T) {
+	tests := []struct {
+		name     string
+		attrs    TinyHomeMessageAttributes
+		defaults bool
+		// applyDefaults calls ApplyDefaults on the attributes before publishing
+		applyDefaults    bool
+		wantSubscription string
+	}{
+		{name: "empty booleans rejected by default", attrs: TinyHomeMessageAttributes{DeliveredFrom: "galaxy"}},
+		{name: "empty booleans default to false", attrs: TinyHomeMessageAttributes{DeliveredFrom: "galaxy"}, defaults: true, wantSubscription: "createGroups"},
+		{name: "ApplyDefaults", attrs: TinyHomeMessageAttributes{DeliveredFrom: "galaxy"}, applyDefaults: true, wantSubscription: "createGroups"},
+		{
+			name:             "set booleans are kept",
+			attrs:            TinyHomeMessageAttributes{GroupsCreated: "true", DeliveredFrom: "galaxy"},
+			defaults:         true,
+			wantSubscription: "createWorkspace",
+		},
+		{
+			name:     "invalid booleans are still rejected",
+			attrs:    TinyHomeMessageAttributes{GroupsCreated: "yes", DeliveredFrom: "galaxy"},
+			defaults: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attrs := tt.attrs
+			if tt.applyDefaults {
+				attrs.ApplyDefaults()
+			}
+			message := validInstructions()
+			result, err := message.DryRun(&attrs, WithDefaultEmptyAttributes(tt.defaults))
+			if tt.wantSubscription == "" {
+				if !errors.Is(err, ErrInvalidAttribute) {
+					t.Fatalf("DryRun error = %v, want ErrInvalidAttribute", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DryRun: %v", err)
+			}
+			if result.Subscription != tt.wantSubscription {
+				t.Errorf("Subscription = %q, want %q", result.Subscription, tt.wantSubscription)
+			}
+			if result.Attributes[AttrTenantCreated] != "false" {
+				t.Errorf("tenantCreated attribute = %q, want false", result.Attributes[AttrTenantCreated])
+			}
+
+			// Only ApplyDefaults changes the caller's attributes
+			if !tt.applyDefaults && attrs != tt.attrs {
+				t.Errorf("attributes changed to %+v", attrs)
+			}
+		})
+	}
+}
+
+func TestAttributeNormalization(t *testing.T) {
+	tests := []struct {
+		name          string
+		normalize     bool
+		deliveredFrom string
+		// want is the deliveredFrom attribute published, empty when rejected
+		want string
+		// wantMessage is part of the error when rejected
+		wantMessage string
+	}{
+		{name: "exact", deliveredFrom: "galaxy", want: "galaxy"},
+		{name: "mixed case strict", deliveredFrom: "Galaxy", wantMessage: `DeliveredFrom "Galaxy" is not one of`},
+		{name: "padded strict", deliveredFrom: " manual ", wantMessage: `DeliveredFrom " manual " is not one of`},
+		{name: "mixed case normalized", normalize: true, deliveredFrom: "Galaxy", want: "galaxy"},
+		{name: "padded normalized", normalize: true, deliveredFrom: " manual ", want: "manual"},
+		{name: "padded mixed case normalized", normalize: true, deliveredFrom: "\tMANUAL\n", want: "manual"},
+		{name: "unknown normalized", normalize: true, deliveredFrom: " Galaxie ", wantMessage: `DeliveredFrom "galaxie" is not one of`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithAttributeNormalization(tt.normalize))
+			message := validInstructions()
+			messageAttributes := validAttributes()
+			messageAttributes.DeliveredFrom = tt.deliveredFrom
+
+			_, err := p.PublishContext(context.Background(), &message, messageAttributes)
+			if messageAttributes.DeliveredFrom != tt.deliveredFrom {
+				t.Errorf("caller's DeliveredFrom changed to %q", messageAttributes.DeliveredFrom)
+			}
+			if tt.want == "" {
+				wantFieldError(t, err, AttrDeliveredFrom)
+				if !strings.Contains(err.Error(), tt.wantMessage) {
+					t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+			if got := topic.published()[0].Attributes[AttrDeliveredFrom]; got != tt.want {
+				t.Errorf("deliveredFrom attribute = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeliverySources(t *testing.T) {
+	tests := []struct {
+		name          string
+		sources       []string
+		deliveredFrom string
+		// wantMessage is part of the error when the source is rejected
+		wantMessage string
+	}{
+		{name: "default source", deliveredFrom: "galaxy"},
+		{name: "source outside the defaults", deliveredFrom: "pipeline", wantMessage: `DeliveredFrom "pipeline" is not one of: galaxy, manual`},
+		{name: "registered source", sources: []string{"galaxy", "pipeline"}, deliveredFrom: "pipeline"},
+		{name: "source outside the list", sources: []string{"galaxy", "pipeline"}, deliveredFrom: "manual", wantMessage: `DeliveredFrom "manual" is not one of: galaxy, pipeline`},
+		{name: "empty source", sources: []string{"galaxy", "pipeline"}, deliveredFrom: "", wantMessage: `DeliveredFrom "" is not one of: galaxy, pipeline`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.sources != nil {
+				opts = append(opts, WithDeliverySources(tt.sources))
+			}
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, opts...)
+			message := validInstructions()
+			messageAttributes := validAttributes()
+			messageAttributes.DeliveredFrom = tt.deliveredFrom
+
+			_, err := p.PublishContext(context.Background(), &message, messageAttributes)
+			if tt.wantMessage == "" {
+				if err != nil {
+					t.Fatalf("PublishContext: %v", err)
+				}
+				if got := topic.published()[0].Attributes[AttrDeliveredFrom]; got != tt.deliveredFrom {
+					t.Errorf("deliveredFrom attribute = %q, want %q", got, tt.deliveredFrom)
+				}
+				return
+			}
+			wantFieldError(t, err, AttrDeliveredFrom)
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+			if got := len(topic.published()); got != 0 {
+				t.Errorf("published %d messages, want none", got)
+			}
+		})
+	}
+
+	if _, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithDeliverySources([]string{})); err == nil {
+		t.Error("newPublisherWithTopic accepted an empty DeliverySources")
+	}
+}
+
+func TestCompatibilityAttribute(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		// wantSet is whether the compatibility attribute is published
+		wantSet bool
+		wantErr bool
+	}{
+		{name: "backward", mode: CompatibilityBackward, wantSet: true},
+		{name: "forward", mode: CompatibilityForward, wantSet: true},
+		{name: "full", mode: CompatibilityFull, wantSet: true},
+		{name: "unset", mode: ""},
+		{name: "unknown mode", mode: "transitive", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p, err := newPublisherWithTopic(topic, DefaultPublisherConfig(), WithCompatibility(tt.mode))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("newPublisherWithTopic accepted compatibility %q", tt.mode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newPublisherWithTopic: %v", err)
+			}
+
+			message := validInstructions()
+			if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+			got, ok := topic.published()[0].Attributes["compatibility"]
+			if ok != tt.wantSet || got != tt.mode {
+				t.Errorf("compatibility attribute = %q (set %v), want %q (set %v)", got, ok, tt.mode, tt.wantSet)
+			}
+		})
+	}
+}
+
+func TestAdditionalAttributesReserved(t *testing.T) {
+	tests := []struct {
+		name       string
+		additional map[string]string
+		// want are the reserved keys named in the error, empty when none is used
+		want string
+	}{
+		{name: "custom keys", additional: map[string]string{"team": "payments", "schema": "v2"}},
+		{name: "routing key", additional: map[string]string{AttrTenantName: "other"}, want: "tenantName"},
+		{name: "created flag", additional: map[string]string{AttrGroupsCreated: "true"}, want: "groupsCreated"},
+		{name: "key of a disabled feature", additional: map[string]string{"contentEncoding": "gzip"}, want: "contentEncoding"},
+		{name: "key set after configured attributes", additional: map[string]string{"namespace": "acme-dev"}, want: "namespace"},
+		{name: "compact marker", additional: map[string]string{"compact": "true"}, want: "compact"},
+		{name: "CloudEvents content type", additional: map[string]string{"content-type": "application/json"}, want: "content-type"},
+		{name: "label prefix", additional: map[string]string{"label.team": "payments"}, want: "label.team"},
+		{name: "every collision named", additional: map[string]string{"team": "x", "region": "x", "bodyChecksum": "x"}, want: "bodyChecksum, region"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := WithAdditionalAttributes(tt.additional)
+
+			// Rejected when the Publisher is built, before anything is published
+			topic := &fakeTopic{}
+			p, constructErr := newPublisherWithTopic(topic, DefaultPublisherConfig(), opt)
+
+			// and by the package level functions building a config per call
+			message := validInstructions()
+			result, dryRunErr := message.DryRun(validAttributes(), opt)
+
+			if tt.want == "" {
+				if constructErr != nil || dryRunErr != nil {
+					t.Fatalf("construction error = %v, DryRun error = %v, want none", constructErr, dryRunErr)
+				}
+				message := validInstructions()
+				if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+					t.Fatalf("PublishContext: %v", err)
+				}
+				for key, value := range tt.additional {
+					if got := topic.published()[0].Attributes[key]; got != value {
+						t.Errorf("attribute %s = %q, want %q", key, got, value)
+					}
+					if got := result.Attributes[key]; got != value {
+						t.Errorf("DryRun attribute %s = %q, want %q", key, got, value)
+					}
+				}
+				return
+			}
+
+			want := "reserved for the publisher: " + tt.want
+			for name, err := range map[string]error{"construction": constructErr, "DryRun": dryRunErr} {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("%s error = %v, want %q", name, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAttributesFromMap(t *testing.T) {
+	valid := func() map[string]string {
+		return map[string]string{
+			AttrGroupsCreated:    "true",
+			AttrWorkspaceCreated: "false",
+			AttrTenantCreated:    "false",
+			AttrFluxCreated:      "false",
+			AttrDeliveredFrom:    "galaxy",
+			AttrTenantName:       "contract-tenant",
+			"correlationId":      "req-42",
+			"idempotencyKey":     "key-1",
+			"publishedAt":        "2024-03-01T12:00:00Z",
+		}
+	}
+	tests := []struct {
+		name   string
+		modify func(map[string]string)
+		// wantFields are the fields of the ValidationErrors, in order, none when the
+		// attributes are accepted
+		wantFields []string
+	}{
+		{name: "valid"},
+		{name: "optional keys missing", modify: func(m map[string]string) { delete(m, "correlationId"); delete(m, "idempotencyKey") }},
+		{name: "missing flag", modify: func(m map[string]string) { delete(m, AttrTenantCreated) }, wantFields: []string{AttrTenantCreated}},
+		{
+			name: "missing several keys",
+			modify: func(m map[string]string) {
+				delete(m, AttrGroupsCreated)
+				delete(m, AttrDeliveredFrom)
+				delete(m, AttrTenantName)
+			},
+			wantFields: []string{AttrGroupsCreated, AttrDeliveredFrom, AttrTenantName},
+		},
+		{name: "non-boolean flag", modify: func(m map[string]string) { m[AttrFluxCreated] = "yes" }, wantFields: []string{AttrFluxCreated}},
+		{name: "upper case flag", modify: func(m map[string]string) { m[AttrGroupsCreated] = "TRUE" }, wantFields: []string{AttrGroupsCreated}},
+		{
+			name:       "several non-boolean flags",
+			modify:     func(m map[string]string) { m[AttrGroupsCreated] = "1"; m[AttrWorkspaceCreated] = "" },
+			wantFields: []string{AttrGroupsCreated, AttrWorkspaceCreated},
+		},
+		{name: "empty tenant name", modify: func(m map[string]string) { m[AttrTenantName] = "" }, wantFields: []string{AttrTenantName}},
+		{name: "invalid correlation ID", modify: func(m map[string]string) { m["correlationId"] = "req 42" }, wantFields: []string{"correlationId"}},
+		{
+			name: "every kind of failure",
+			modify: func(m map[string]string) {
+				m[AttrTenantCreated] = "no"
+				m[AttrDeliveredFrom] = ""
+				m["idempotencyKey"] = "key/1"
+			},
+			wantFields: []string{AttrTenantCreated, AttrDeliveredFrom, "idempotencyKey"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attributes := valid()
+			if tt.modify != nil {
+				tt.modify(attributes)
+			}
+
+			got, err := AttributesFromMap(attributes)
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("AttributesFromMap: %v", err)
+				}
+				want := &TinyHomeMessageAttributes{
+					GroupsCreated:    attributes[AttrGroupsCreated],
+					WorkspaceCreated: attributes[AttrWorkspaceCreated],
+					TenantCreated:    attributes[AttrTenantCreated],
+					FluxCreated:      attributes[AttrFluxCreated],
+					DeliveredFrom:    attributes[AttrDeliveredFrom],
+					TenantName:       attributes[AttrTenantName],
+					CorrelationID:    attributes["correlationId"],
+					IdempotencyKey:   attributes["idempotencyKey"],
+				}
+				if *got != *want {
+					t.Errorf("AttributesFromMap = %+v, want %+v", *got, *want)
+				}
+				return
+			}
+
+			if got != nil {
+				t.Errorf("AttributesFromMap = %+v with an error, want nil", *got)
+			}
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("AttributesFromMap error = %v, want ValidationErrors", err)
+			}
+			if !errors.Is(err, ErrInvalidAttribute) {
+				t.Errorf("AttributesFromMap error = %v, want it to match ErrInvalidAttribute", err)
+			}
+			var fields []string
+			for _, e := range errs {
+				fields = append(fields, e.FieldName())
+			}
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("AttributesFromMap error fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// BatchPolicy holds the limits ValidateBatchInvariants applies across a batch
+type BatchPolicy struct {
+	// Budget caps the summed NsQuota requests and, separately, the summed limits of
+	// every instruction in the batch. An empty value is uncapped.
+	Budget QuotaCaps
+}
+
+// ValidateBatchInvariants checks invariants only visible across a batch: tenant names
+// and derived project IDs must be unique and the summed quota must fit in the budget.
+// Each instruction should also be validated on its own. It returns nil or a
+// ValidationErrors holding every violation found.
+func ValidateBatchInvariants(msgs []TinyHomeInstructions, cfg BatchPolicy) error {
+	var errs ValidationErrors
+
+	tenantNames := map[string]int{}
+	projectIds := map[string]int{}
+	for i, message := range msgs {
+		if first, ok := tenantNames[message.TenantName]; ok {
+			errs = append(errs, &NamingError{
+				Field:   fmt.Sprintf("[%d].tenantName", i),
+				Message: fmt.Sprintf("tenantName %q is already used by instruction %d", message.TenantName, first),
+			})
+		} else {
+			tenantNames[message.TenantName] = i
+		}
+
+		projectId := message.ResourceNames().ProjectID
+		if first, ok := projectIds[projectId]; ok {
+			errs = append(errs, &NamingError{
+				Field:   fmt.Sprintf("[%d].projectId", i),
+				Message: fmt.Sprintf("derived projectId %q is already used by instruction %d", projectId, first),
+			})
+		} else {
+			projectIds[projectId] = i
+		}
+	}
+
+	budgetErrs, err := validateBatchBudget(msgs, cfg.Budget)
+	if err != nil {
+		return err
+	}
+	errs = append(errs, budgetErrs...)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// validateBatchBudget sums each NsQuota value across msgs and compares the totals with
+// budget. Malformed budgets are returned as a plain error, malformed or over budget
+// quotas as QuotaErrors.
+func validateBatchBudget(msgs []TinyHomeInstructions, budget QuotaCaps) ([]ValidationError, error) {
+	var errs []ValidationError
+
+	totals := map[string]*resource.Quantity{}
+	var order []quotaField
+	for i, message := range msgs {
+		for _, field := range message.quotaFields() {
+			if _, ok := totals[field.name]; !ok {
+				totals[field.name] = &resource.Quantity{}
+				order = append(order, field)
+			}
+			if field.value == "" {
+				continue
+			}
+
+			quantity, err := resource.ParseQuantity(field.value)
+			if err != nil {
+				errs = append(errs, &QuotaError{
+					Field:   fmt.Sprintf("[%d].%s", i, field.name),
+					Message: fmt.Sprintf("%s %q is not a valid quantity: %v", field.name, field.value, err),
+				})
+				continue
+			}
+			totals[field.name].Add(quantity)
+		}
+	}
+
+	for _, field := range order {
+		limit := budget.Cpu
+		if field.resource == "memory" {
+			limit = budget.Memory
+		}
+		if limit == "" {
+			continue
+		}
+
+		budgetQuantity, err := resource.ParseQuantity(limit)
+		if err != nil {
+			return nil, fmt.Errorf("%s budget %q is not a valid quantity: %v", field.resource, limit, err)
+		}
+
+		if total := totals[field.name]; total.Cmp(budgetQuantity) > 0 {
+			errs = append(errs, &QuotaError{
+				Field:   field.name,
+				Message: fmt.Sprintf("%s totals %s across the batch, exceeding the %s budget of %s", field.name, total.String(), field.resource, limit),
+			})
+		}
+	}
+	return errs, nil
+}
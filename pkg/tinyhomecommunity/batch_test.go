@@ -0,0 +1,119 @@
+package tinyhomecommunity
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// batchInstructions returns validInstructions for each tenant in tenantNames
+func batchInstructions(tenantNames ...string) []TinyHomeInstructions {
+	msgs := make([]TinyHomeInstructions, len(tenantNames))
+	for i, tenantName := range tenantNames {
+		msgs[i] = validInstructions()
+		msgs[i].TenantName = tenantName
+	}
+	return msgs
+}
+
+func TestValidateBatchInvariants(t *testing.T) {
+	tests := []struct {
+		name   string
+		msgs   []TinyHomeInstructions
+		policy BatchPolicy
+		// wantFields are the fields of the violations, in order
+		wantFields []string
+		// wantMessage is part of the error
+		wantMessage string
+	}{
+		{name: "unique tenants", msgs: batchInstructions("tenant-a", "tenant-b", "tenant-c")},
+		{name: "empty batch", msgs: nil},
+		{
+			name:        "duplicate tenant name",
+			msgs:        batchInstructions("tenant-a", "tenant-b", "tenant-a"),
+			wantFields:  []string{"[2].tenantName", "[2].projectId"},
+			wantMessage: `tenantName "tenant-a" is already used by instruction 0`,
+		},
+		{
+			name: "duplicate derived project ID",
+			msgs: func() []TinyHomeInstructions {
+				msgs := batchInstructions("acme-dev", "acme")
+				msgs[0].Environment = "test"
+				msgs[1].Environment = "dev-test"
+				return msgs
+			}(),
+			wantFields:  []string{"[1].projectId"},
+			wantMessage: `derived projectId "acme-dev-test" is already used by instruction 0`,
+		},
+		{
+			name:   "quota sums within the budget",
+			msgs:   batchInstructions("tenant-a", "tenant-b", "tenant-c"),
+			policy: BatchPolicy{Budget: QuotaCaps{Cpu: "6", Memory: "6Gi"}},
+		},
+		{
+			name:        "quota sums over the budget",
+			msgs:        batchInstructions("tenant-a", "tenant-b", "tenant-c"),
+			policy:      BatchPolicy{Budget: QuotaCaps{Cpu: "5", Memory: "2Gi"}},
+			wantFields:  []string{"nsQuota.requests.memory", "nsQuota.limits.cpu", "nsQuota.limits.memory"},
+			wantMessage: "nsQuota.limits.cpu totals 6 across the batch, exceeding the cpu budget of 5",
+		},
+		{
+			name: "malformed quota",
+			msgs: func() []TinyHomeInstructions {
+				msgs := batchInstructions("tenant-a", "tenant-b")
+				msgs[1].NsQuota.Requests.Cpu = "lots"
+				return msgs
+			}(),
+			policy:      BatchPolicy{Budget: QuotaCaps{Cpu: "10"}},
+			wantFields:  []string{"[1].nsQuota.requests.cpu"},
+			wantMessage: `"lots" is not a valid quantity`,
+		},
+		{
+			name:        "every violation reported",
+			msgs:        batchInstructions("tenant-a", "tenant-a", "tenant-a"),
+			policy:      BatchPolicy{Budget: QuotaCaps{Cpu: "5"}},
+			wantFields:  []string{"[1].tenantName", "[1].projectId", "[2].tenantName", "[2].projectId", "nsQuota.limits.cpu"},
+			wantMessage: "already used by instruction 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBatchInvariants(tt.msgs, tt.policy)
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Fatalf("ValidateBatchInvariants: %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("ValidateBatchInvariants error = %v, want ValidationErrors", err)
+			}
+			var fields []string
+			for _, e := range errs {
+				fields = append(fields, e.FieldName())
+			}
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("violations %v, want %v", fields, tt.wantFields)
+			}
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestValidateBatchInvariantsBadBudget(t *testing.T) {
+	err := ValidateBatchInvariants(batchInstructions("tenant-a"), BatchPolicy{Budget: QuotaCaps{Memory: "plenty"}})
+	var errs ValidationErrors
+	if err == nil || errors.As(err, &errs) {
+		t.Fatalf("ValidateBatchInvariants error = %v, want a plain error for the budget", err)
+	}
+	if want := fmt.Sprintf("memory budget %q is not a valid quantity", "plenty"); !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not mention %q", err, want)
+	}
+}
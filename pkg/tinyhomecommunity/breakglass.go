@@ -0,0 +1,79 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// breakglassWindowFormat describes the accepted BreakglassWindow formats for errors
+const breakglassWindowFormat = `a Go duration such as "4h" or an RFC3339 range such as "2024-06-01T00:00:00Z/2024-06-01T04:00:00Z"`
+
+// breakglassWindowEnd returns when BreakglassWindow ends for a request made at now.
+// The window is either a positive Go duration from now, such as "4h", or an RFC3339
+// range "start/end" with end after start.
+func (message TinyHomeInstructions) breakglassWindowEnd(now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(message.BreakglassWindow); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("duration must be positive")
+		}
+		return now.Add(d), nil
+	}
+
+	startText, endText, found := strings.Cut(message.BreakglassWindow, "/")
+	if !found {
+		return time.Time{}, fmt.Errorf("not a duration or a range")
+	}
+	start, err := time.Parse(time.RFC3339, startText)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("range start: %v", err)
+	}
+	end, err := time.Parse(time.RFC3339, endText)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("range end: %v", err)
+	}
+	if !end.After(start) {
+		return time.Time{}, fmt.Errorf("range must end after it starts")
+	}
+	return end, nil
+}
+
+// validateBreakglassWindow requires a BreakglassWindow in breakglassWindowFormat
+// when Breakglass is requested, and rejects one when it is not since a window
+// without break-glass is almost certainly a mistake
+func (message TinyHomeInstructions) validateBreakglassWindow(now time.Time) error {
+	if !message.Breakglass {
+		if message.BreakglassWindow != "" {
+			return &PolicyError{Field: "breakglassWindow", Rule: "breakglassWindow", Message: fmt.Sprintf("breakglassWindow %q is set but breakglass is not requested", message.BreakglassWindow)}
+		}
+		return nil
+	}
+
+	if message.BreakglassWindow == "" {
+		return &PolicyError{Field: "breakglassWindow", Rule: "breakglassWindow", Message: "breakglassWindow is required when breakglass is requested, as " + breakglassWindowFormat}
+	}
+
+	if _, err := message.breakglassWindowEnd(now); err != nil {
+		return &PolicyError{Field: "breakglassWindow", Rule: "breakglassWindow", Message: fmt.Sprintf("breakglassWindow %q is invalid: %v, expected %s", message.BreakglassWindow, err, breakglassWindowFormat)}
+	}
+	return nil
+}
+
+// validateBreakglassNotAfter rejects break-glass requested after notAfter, or whose
+// window runs past it, so break-glass can not be used through a freeze. A zero
+// notAfter disables the check.
+func (message TinyHomeInstructions) validateBreakglassNotAfter(notAfter time.Time, now time.Time) error {
+	if !message.Breakglass || notAfter.IsZero() {
+		return nil
+	}
+
+	cutoff := notAfter.UTC().Format(time.RFC3339)
+	if now.After(notAfter) {
+		return &PolicyError{Field: "breakglass", Rule: "breakglassNotAfter", Message: fmt.Sprintf("breakglass can not be requested after %s", cutoff)}
+	}
+
+	if end, err := message.breakglassWindowEnd(now); err == nil && end.After(notAfter) {
+		return &PolicyError{Field: "breakglassWindow", Rule: "breakglassNotAfter", Message: fmt.Sprintf("breakglassWindow %q ends at %s, after the break-glass cutoff %s", message.BreakglassWindow, end.UTC().Format(time.RFC3339), cutoff)}
+	}
+	return nil
+}
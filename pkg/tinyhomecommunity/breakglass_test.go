@@ -0,0 +1,159 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// breakglassInstructions returns valid instructions for environment requesting
+// break-glass with a ticket, window and approver
+func breakglassInstructions(environment string) TinyHomeInstructions {
+	message := validInstructions()
+	message.Environment = environment
+	message.Breakglass = true
+	message.BreakglassWindow = "4h"
+	message.BreakglassTicket = "INC-1234"
+	message.BreakglassApprover = "approver@example.com"
+	return message
+}
+
+// wantFieldError fails unless err is a ValidationError for field, or nil when field is
+// empty
+func wantFieldError(t *testing.T, err error, field string) {
+	t.Helper()
+	if field == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) || validationErr.FieldName() != field {
+		t.Fatalf("error = %v, want a ValidationError for %s", err, field)
+	}
+}
+
+func TestBreakglassApproval(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		approver    string
+		wantField   string
+	}{
+		{"prod with approver", "prod", "approver@example.com", ""},
+		{"prod without approver", "prod", "", "breakglassApprover"},
+		{"prod with invalid approver", "prod", "not an email", "breakglassApprover"},
+		{"prod approved by the owner", "prod", "owner@example.com", "breakglassApprover"},
+		{"dev without approver", "dev", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := breakglassInstructions(tt.environment)
+			message.BreakglassApprover = tt.approver
+			_, err := message.DryRun(validAttributes())
+			wantFieldError(t, err, tt.wantField)
+		})
+	}
+}
+
+func TestBreakglassTicket(t *testing.T) {
+	tests := []struct {
+		name       string
+		breakglass bool
+		ticket     string
+		opts       []Option
+		wantErr    bool
+	}{
+		{name: "valid ticket", breakglass: true, ticket: "INC-12345"},
+		{name: "missing ticket", breakglass: true, wantErr: true},
+		{name: "malformed ticket", breakglass: true, ticket: "INC12345", wantErr: true},
+		{name: "ticket with trailing text", breakglass: true, ticket: "INC-12345 see chat", wantErr: true},
+		{name: "custom pattern", breakglass: true, ticket: "SEC-7", opts: []Option{WithBreakglassTicketPattern(regexp.MustCompile(`^SEC-[0-9]+$`))}},
+		{name: "default ticket under a custom pattern", breakglass: true, ticket: "INC-12345", opts: []Option{WithBreakglassTicketPattern(regexp.MustCompile(`^SEC-[0-9]+$`))}, wantErr: true},
+		{name: "check disabled", breakglass: true, ticket: "anything", opts: []Option{WithBreakglassTicketPattern(nil)}},
+		{name: "no breakglass", breakglass: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, tt.opts...)
+			message := breakglassInstructions("dev")
+			if !tt.breakglass {
+				message = validInstructions()
+			}
+			message.BreakglassTicket = tt.ticket
+
+			_, err := p.PublishContext(context.Background(), &message, validAttributes())
+			if tt.wantErr {
+				wantFieldError(t, err, "breakglassTicket")
+				if got := len(topic.published()); got != 0 {
+					t.Errorf("published %d messages, want none", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+			got, ok := topic.published()[0].Attributes["breakglassTicket"]
+			if ok != (tt.ticket != "") || got != tt.ticket {
+				t.Errorf("breakglassTicket attribute = %q (set %v), want %q", got, ok, tt.ticket)
+			}
+		})
+	}
+}
+
+func TestBreakglassNotAfter(t *testing.T) {
+	cutoff := time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		notAfter   time.Time
+		now        time.Time
+		breakglass bool
+		// window is the BreakglassWindow, the 4h of breakglassInstructions when empty
+		window    string
+		wantField string
+	}{
+		{name: "window well before the cutoff", notAfter: cutoff, now: cutoff.Add(-48 * time.Hour), breakglass: true},
+		{name: "window ending at the cutoff", notAfter: cutoff, now: cutoff.Add(-4 * time.Hour), breakglass: true},
+		{name: "window ending a second past the cutoff", notAfter: cutoff, now: cutoff.Add(-4*time.Hour + time.Second), breakglass: true, wantField: "breakglassWindow"},
+		{name: "shorter window before the cutoff", notAfter: cutoff, now: cutoff.Add(-time.Hour), breakglass: true, window: "1h"},
+		{name: "requested at the cutoff", notAfter: cutoff, now: cutoff, breakglass: true, wantField: "breakglassWindow"},
+		{name: "requested after the cutoff", notAfter: cutoff, now: cutoff.Add(time.Second), breakglass: true, wantField: "breakglass"},
+		{name: "no breakglass after the cutoff", notAfter: cutoff, now: cutoff.Add(24 * time.Hour)},
+		{name: "no cutoff", now: cutoff.Add(24 * time.Hour), breakglass: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			if tt.breakglass {
+				message = breakglassInstructions("dev")
+			}
+			if tt.window != "" {
+				message.BreakglassWindow = tt.window
+			}
+			now := tt.now
+			_, err := message.DryRun(validAttributes(), WithBreakglassNotAfter(tt.notAfter), WithClock(func() time.Time { return now }))
+			wantFieldError(t, err, tt.wantField)
+			if err == nil {
+				return
+			}
+			// The error reports the cutoff
+			if want := "2024-12-20T00:00:00Z"; !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not mention the cutoff %s", err, want)
+			}
+			var policyErr *PolicyError
+			if !errors.As(err, &policyErr) || policyErr.Rule != "breakglassNotAfter" {
+				t.Errorf("error = %v, want a breakglassNotAfter PolicyError", err)
+			}
+		})
+	}
+}
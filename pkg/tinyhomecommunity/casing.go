@@ -0,0 +1,91 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CasingPolicy is the casing convention enforced on an identifier field
+type CasingPolicy string
+
+const (
+	CasingLower CasingPolicy = "lowercase"
+	CasingUpper CasingPolicy = "uppercase"
+	CasingAny   CasingPolicy = "any"
+)
+
+// identifierFields returns the identifier fields a CasingPolicy can be applied to,
+// keyed by their json name
+func (message TinyHomeInstructions) identifierFields() map[string]string {
+	return map[string]string{
+		"tenantName":       message.TenantName,
+		"environment":      message.Environment,
+		"businessUnit":     message.BusinessUnit,
+		"tenantCostCenter": message.TenantCostCenter,
+		"domain":           message.Domain,
+		"organization":     message.Organization,
+	}
+}
+
+// ValidateCasing checks each identifier field named in policies, by json name, against
+// its CasingPolicy and reports every field in violation
+func (message TinyHomeInstructions) ValidateCasing(policies map[string]CasingPolicy) error {
+	fields := message.identifierFields()
+
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fieldNames, violations []string
+	for _, name := range names {
+		value, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("casing policy set for unknown field %s", name)
+		}
+
+		switch policies[name] {
+		case CasingLower:
+			if value != strings.ToLower(value) {
+				fieldNames = append(fieldNames, name)
+				violations = append(violations, fmt.Sprintf("%s must be %s", name, CasingLower))
+			}
+		case CasingUpper:
+			if value != strings.ToUpper(value) {
+				fieldNames = append(fieldNames, name)
+				violations = append(violations, fmt.Sprintf("%s must be %s", name, CasingUpper))
+			}
+		case CasingAny:
+		default:
+			return fmt.Errorf("unknown casing policy %q for field %s", policies[name], name)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &NamingError{
+			Field:   strings.Join(fieldNames, ","),
+			Message: fmt.Sprintf("casing policy violated: %s", strings.Join(violations, "; ")),
+		}
+	}
+	return nil
+}
+
+// validateCasingPolicies rejects policies for fields ValidateCasing does not know and
+// unknown policies, so a bad config fails at construction rather than on publish
+func validateCasingPolicies(policies map[string]CasingPolicy) error {
+	fields := (TinyHomeInstructions{}).identifierFields()
+	for name, policy := range policies {
+		if _, ok := fields[name]; !ok {
+			return fmt.Errorf("casing policy set for unknown field %s", name)
+		}
+
+		switch policy {
+		case CasingLower, CasingUpper, CasingAny:
+		default:
+			return fmt.Errorf("unknown casing policy %q for field %s", policy, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package tinyhomecommunity
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateCasing(t *testing.T) {
+	message := validInstructions()
+	message.BusinessUnit = "Platform"
+	message.Organization = "ACME"
+
+	tests := []struct {
+		name       string
+		policies   map[string]CasingPolicy
+		wantFields string
+	}{
+		{"no policies", nil, ""},
+		{"lowercase satisfied", map[string]CasingPolicy{"tenantName": CasingLower}, ""},
+		{"lowercase violated", map[string]CasingPolicy{"businessUnit": CasingLower}, "businessUnit"},
+		{"uppercase satisfied", map[string]CasingPolicy{"organization": CasingUpper}, ""},
+		{"uppercase violated", map[string]CasingPolicy{"businessUnit": CasingUpper}, "businessUnit"},
+		{"any allows mixed case", map[string]CasingPolicy{"businessUnit": CasingAny}, ""},
+		{
+			name:       "every violation reported",
+			policies:   map[string]CasingPolicy{"organization": CasingLower, "businessUnit": CasingLower, "tenantName": CasingUpper},
+			wantFields: "businessUnit,organization,tenantName",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := message.ValidateCasing(tt.policies)
+			if tt.wantFields == "" {
+				if err != nil {
+					t.Fatalf("ValidateCasing: %v", err)
+				}
+				return
+			}
+
+			var namingErr *NamingError
+			if !errors.As(err, &namingErr) {
+				t.Fatalf("ValidateCasing error = %v, want a NamingError", err)
+			}
+			if namingErr.Field != tt.wantFields {
+				t.Errorf("NamingError.Field = %q, want %q", namingErr.Field, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestWithCasingPolicies(t *testing.T) {
+	tests := []struct {
+		name          string
+		policies      map[string]CasingPolicy
+		businessUnit  string
+		wantConfigErr bool
+		wantErr       bool
+	}{
+		{"compliant", map[string]CasingPolicy{"businessUnit": CasingLower}, "platform", false, false},
+		{"violating", map[string]CasingPolicy{"businessUnit": CasingLower}, "Platform", false, true},
+		{"unknown field", map[string]CasingPolicy{"tenantOwner": CasingLower}, "platform", true, false},
+		{"unknown policy", map[string]CasingPolicy{"businessUnit": "title"}, "platform", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.BusinessUnit = tt.businessUnit
+			_, err := message.DryRun(validAttributes(), WithCasingPolicies(tt.policies))
+
+			switch {
+			case tt.wantConfigErr:
+				if err == nil || errors.Is(err, ErrInvalidInstructions) {
+					t.Fatalf("DryRun error = %v, want a config error", err)
+				}
+			case tt.wantErr:
+				if !errors.Is(err, ErrInvalidInstructions) {
+					t.Fatalf("DryRun error = %v, want ErrInvalidInstructions", err)
+				}
+			case err != nil:
+				t.Fatalf("DryRun: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package tinyhomecommunity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// bodyChecksumPrefix names the algorithm at the start of the bodyChecksum attribute
+const bodyChecksumPrefix = "sha256:"
+
+// bodyChecksum returns the bodyChecksum attribute value for data
+func bodyChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return bodyChecksumPrefix + hex.EncodeToString(sum[:])
+}
+
+// addBodyChecksum sets the bodyChecksum attribute for data, it can not replace one
+// already set
+func addBodyChecksum(data []byte, attributes map[string]string) error {
+	if _, ok := attributes["bodyChecksum"]; ok {
+		return fmt.Errorf("additional attribute bodyChecksum collides with an attribute set by the publisher")
+	}
+	attributes["bodyChecksum"] = bodyChecksum(data)
+	return nil
+}
+
+// VerifyBodyChecksum checks data against the bodyChecksum attribute of a received
+// message, such as pubsub.Message.Data and Attributes. It detects corruption, not
+// tampering, since anyone can compute the checksum. Messages published without
+// BodyChecksum have no attribute and fail.
+func VerifyBodyChecksum(data []byte, attributes map[string]string) error {
+	want, ok := attributes["bodyChecksum"]
+	if !ok {
+		return fmt.Errorf("VerifyBodyChecksum: message has no bodyChecksum attribute")
+	}
+
+	if !strings.HasPrefix(want, bodyChecksumPrefix) {
+		return fmt.Errorf("VerifyBodyChecksum: bodyChecksum %q is not a %s checksum", want, strings.TrimSuffix(bodyChecksumPrefix, ":"))
+	}
+
+	if got := bodyChecksum(data); got != want {
+		return fmt.Errorf("VerifyBodyChecksum: body checksum %s does not match bodyChecksum %s", got, want)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestBodyChecksum(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		// corrupt changes the received body and attributes before they are verified
+		corrupt    func(data []byte, attributes map[string]string) []byte
+		wantVerify bool
+	}{
+		{name: "round trip", opts: []Option{WithBodyChecksum(true)}, wantVerify: true},
+		{name: "compressed body", opts: []Option{WithBodyChecksum(true), WithCompression(true)}, wantVerify: true},
+		{name: "compact body", opts: []Option{WithBodyChecksum(true), WithCompact(validInstructions())}, wantVerify: true},
+		{
+			name: "corrupted body",
+			opts: []Option{WithBodyChecksum(true)},
+			corrupt: func(data []byte, attributes map[string]string) []byte {
+				data[len(data)/2] ^= 0xff
+				return data
+			},
+		},
+		{
+			name: "other algorithm",
+			opts: []Option{WithBodyChecksum(true)},
+			corrupt: func(data []byte, attributes map[string]string) []byte {
+				attributes["bodyChecksum"] = "crc32:1234abcd"
+				return data
+			},
+		},
+		{name: "disabled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, tt.opts...)
+			message := validInstructions()
+			if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+
+			published := topic.published()[0]
+			data := append([]byte(nil), published.Data...)
+			attributes := maps.Clone(published.Attributes)
+			if tt.corrupt != nil {
+				data = tt.corrupt(data, attributes)
+			}
+			if err := VerifyBodyChecksum(data, attributes); (err == nil) != tt.wantVerify {
+				t.Errorf("VerifyBodyChecksum error = %v, want verified %v", err, tt.wantVerify)
+			}
+		})
+	}
+}
+
+func TestBodyChecksumReserved(t *testing.T) {
+	// The key is reserved whether or not BodyChecksum is enabled, so a forged
+	// checksum can never reach subscribers
+	for _, enabled := range []bool{true, false} {
+		_, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithBodyChecksum(enabled), WithAdditionalAttributes(map[string]string{"bodyChecksum": "sha256:00"}))
+		if err == nil || !strings.Contains(err.Error(), "reserved for the publisher: bodyChecksum") {
+			t.Errorf("newPublisherWithTopic with BodyChecksum %v error = %v, want bodyChecksum reserved", enabled, err)
+		}
+	}
+}
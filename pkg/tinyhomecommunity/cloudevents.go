@@ -0,0 +1,75 @@
+package tinyhomecommunity
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	// cloudEventsContentType is the content-type attribute of messages wrapped in a
+	// CloudEvents structured envelope
+	cloudEventsContentType = "application/cloudevents+json"
+
+	// cloudEventsTypePrefix starts the CloudEvents type, which ends with the
+	// subscription, e.g. com.tinyhome.createTenant
+	cloudEventsTypePrefix = "com.tinyhome."
+)
+
+// cloudEvent is a CloudEvents v1.0 JSON structured envelope
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	ID              string          `json:"id"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// wrapCloudEvent wraps data, the marshaled instructions, in a CloudEvents envelope
+// from source for subscription and sets the content-type attribute. Each envelope gets
+// a new random id.
+func wrapCloudEvent(data []byte, source, subscription string, publishedAt time.Time, attributes map[string]string) ([]byte, error) {
+	if _, ok := attributes["content-type"]; ok {
+		return nil, fmt.Errorf("additional attribute content-type collides with an attribute set by the publisher")
+	}
+
+	id, err := newCorrelationID()
+	if err != nil {
+		return nil, fmt.Errorf("cloudevents id: %v", err)
+	}
+
+	envelope, err := json.Marshal(cloudEvent{
+		SpecVersion:     "1.0",
+		Type:            cloudEventsTypePrefix + subscription,
+		Source:          source,
+		ID:              id,
+		Time:            publishedAt.Format(time.RFC3339),
+		DataContentType: "application/json",
+		Data:            data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal cloudevent: %v", err)
+	}
+	attributes["content-type"] = cloudEventsContentType
+	return envelope, nil
+}
+
+// unwrapCloudEvent returns the data of a CloudEvents envelope, or data as it is for a
+// message without the CloudEvents content-type attribute
+func unwrapCloudEvent(data []byte, attributes map[string]string) ([]byte, error) {
+	if attributes["content-type"] != cloudEventsContentType {
+		return data, nil
+	}
+
+	var envelope cloudEvent
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: cloudevent: %v", ErrDecode, err)
+	}
+
+	if envelope.SpecVersion != "1.0" {
+		return nil, fmt.Errorf("%w: unsupported cloudevents specversion %q", ErrDecode, envelope.SpecVersion)
+	}
+	return envelope.Data, nil
+}
@@ -0,0 +1,121 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDualPublish(t *testing.T) {
+	failing := func(n int, msg *pubsub.Message) publishResult {
+		return fakeResult{err: status.Error(codes.PermissionDenied, "denied")}
+	}
+
+	tests := []struct {
+		name          string
+		nativeResult  func(n int, msg *pubsub.Message) publishResult
+		ceResult      func(n int, msg *pubsub.Message) publishResult
+		wantNativeErr bool
+		wantCEErr     bool
+	}{
+		{name: "both published"},
+		{name: "native failed", nativeResult: failing, wantNativeErr: true},
+		{name: "cloudevents failed", ceResult: failing, wantCEErr: true},
+		{name: "both failed", nativeResult: failing, ceResult: failing, wantNativeErr: true, wantCEErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			native := &fakeTopic{result: tt.nativeResult}
+			ce := &fakeTopic{result: tt.ceResult}
+			p := newTestPublisher(t, native, map[string]*fakeTopic{"tinyhome-ce": ce},
+				WithCloudEvents("//publisher.test"), WithCloudEventsTopic("tinyhome-ce"))
+
+			message := validInstructions()
+			result, err := p.PublishWithResult(context.Background(), &message, validAttributes())
+
+			// Both topics get their format whatever the outcome
+			if len(native.published()) != 1 || len(ce.published()) != 1 {
+				t.Fatalf("published %d native and %d cloudevents messages, want 1 each", len(native.published()), len(ce.published()))
+			}
+			nativeMsg, ceMsg := native.published()[0], ce.published()[0]
+			if _, ok := nativeMsg.Attributes["content-type"]; ok {
+				t.Errorf("native message has a content-type attribute")
+			}
+			if _, err := DecodeInstructions(nativeMsg.Data); err != nil {
+				t.Errorf("native body does not decode: %v", err)
+			}
+			if ceMsg.Attributes["content-type"] != cloudEventsContentType {
+				t.Errorf("cloudevents content-type = %q, want %q", ceMsg.Attributes["content-type"], cloudEventsContentType)
+			}
+			var envelope cloudEvent
+			if err := json.Unmarshal(ceMsg.Data, &envelope); err != nil || envelope.Source != "//publisher.test" || envelope.Type != "com.tinyhome.createGroups" {
+				t.Errorf("cloudevents body %s is not the expected envelope: %v", ceMsg.Data, err)
+			}
+
+			if !tt.wantNativeErr && !tt.wantCEErr {
+				if err != nil {
+					t.Fatalf("PublishWithResult: %v", err)
+				}
+				if result.MessageID != "1" || result.CloudEventsMessageID != "1" {
+					t.Errorf("MessageID, CloudEventsMessageID = %q, %q, want 1, 1", result.MessageID, result.CloudEventsMessageID)
+				}
+				return
+			}
+
+			var dualErr *DualPublishError
+			if !errors.As(err, &dualErr) {
+				t.Fatalf("PublishWithResult error = %v, want a DualPublishError", err)
+			}
+			if (dualErr.Err != nil) != tt.wantNativeErr || (dualErr.CloudEventsErr != nil) != tt.wantCEErr {
+				t.Errorf("DualPublishError = %+v, want native failed %v and cloudevents failed %v", dualErr, tt.wantNativeErr, tt.wantCEErr)
+			}
+			if !tt.wantNativeErr && dualErr.MessageID != "1" {
+				t.Errorf("DualPublishError.MessageID = %q, want the native ID 1", dualErr.MessageID)
+			}
+			if !tt.wantCEErr && dualErr.CloudEventsMessageID != "1" {
+				t.Errorf("DualPublishError.CloudEventsMessageID = %q, want the cloudevents ID 1", dualErr.CloudEventsMessageID)
+			}
+			var transportErr *TransportError
+			if !errors.As(err, &transportErr) {
+				t.Errorf("PublishWithResult error %v does not wrap a TransportError", err)
+			}
+		})
+	}
+}
+
+func TestDualPublishConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"no source", []Option{WithCloudEventsTopic("tinyhome-ce")}},
+		{"same topic", []Option{WithCloudEvents("//publisher.test"), WithCloudEventsTopic(DefaultPublisherConfig().TopicID)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), tt.opts...); err == nil {
+				t.Fatal("newPublisherWithTopic returned no error")
+			}
+		})
+	}
+}
+
+func TestDualPublishUnsupported(t *testing.T) {
+	p := newTestPublisher(t, &fakeTopic{}, map[string]*fakeTopic{"tinyhome-ce": {}},
+		WithCloudEvents("//publisher.test"), WithCloudEventsTopic("tinyhome-ce"))
+
+	message := validInstructions()
+	if _, err := p.PublishAsync(context.Background(), &message, validAttributes()); !errors.Is(err, errDualPublishUnsupported) {
+		t.Errorf("PublishAsync error = %v, want errDualPublishUnsupported", err)
+	}
+	if _, err := p.PublishBatch(context.Background(), []*TinyHomeInstructions{&message}, []*TinyHomeMessageAttributes{validAttributes()}); !errors.Is(err, errDualPublishUnsupported) {
+		t.Errorf("PublishBatch error = %v, want errDualPublishUnsupported", err)
+	}
+}
@@ -0,0 +1,86 @@
+package tinyhomecommunity
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// MarshalCompact marshals the instructions leaving out every field equal to the same
+// field in defaults, recursing into nested objects such as nsQuota. Subscribers must
+// apply the same defaults before using the message, ParseCompact does so. Fields set
+// to a zero value that differs from its default, such as an empty cpu request over a
+// default of "1", are kept so the round trip is exact.
+func (message TinyHomeInstructions) MarshalCompact(defaults TinyHomeInstructions) ([]byte, error) {
+	full, err := toJSONObject(message)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := toJSONObject(defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	omitDefaults(full, base)
+	return json.Marshal(full)
+}
+
+// ParseCompact unmarshals instructions written by MarshalCompact over a copy of
+// defaults, reconstructing the full instructions
+func ParseCompact(data []byte, defaults TinyHomeInstructions) (*TinyHomeInstructions, error) {
+	// Round trip defaults through JSON so the result shares no slices with them
+	b, err := json.Marshal(defaults)
+	if err != nil {
+		return nil, fmt.Errorf("ParseCompact: %v", err)
+	}
+
+	var message TinyHomeInstructions
+	if err := json.Unmarshal(b, &message); err != nil {
+		return nil, fmt.Errorf("ParseCompact: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, fmt.Errorf("ParseCompact: %v", err)
+	}
+	return &message, nil
+}
+
+// toJSONObject returns v marshaled and unmarshaled into a generic JSON object
+func toJSONObject(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(b, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// omitDefaults deletes the keys of object whose values equal those in defaults,
+// dropping nested objects left empty
+func omitDefaults(object, defaults map[string]interface{}) {
+	for key, value := range object {
+		defaultValue, ok := defaults[key]
+		if !ok {
+			continue
+		}
+
+		nested, isObject := value.(map[string]interface{})
+		nestedDefault, defaultIsObject := defaultValue.(map[string]interface{})
+		if isObject && defaultIsObject {
+			omitDefaults(nested, nestedDefault)
+			if len(nested) == 0 {
+				delete(object, key)
+			}
+			continue
+		}
+
+		if reflect.DeepEqual(value, defaultValue) {
+			delete(object, key)
+		}
+	}
+}
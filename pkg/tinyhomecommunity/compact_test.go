@@ -0,0 +1,99 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestCompactRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(m *TinyHomeInstructions)
+		// wantKeys are the top level keys of the compact JSON
+		wantKeys []string
+	}{
+		{name: "equal to the defaults", mutate: func(m *TinyHomeInstructions) {}},
+		{name: "different tenant", mutate: func(m *TinyHomeInstructions) { m.TenantName = "other-tenant" }, wantKeys: []string{"tenantName"}},
+		{name: "one quota value", mutate: func(m *TinyHomeInstructions) { m.NsQuota.Limits.Cpu = "3" }, wantKeys: []string{"nsQuota"}},
+		{name: "value cleared from its default", mutate: func(m *TinyHomeInstructions) { m.BusinessUnit = "" }, wantKeys: []string{"businessUnit"}},
+		{
+			name: "roles and bindings",
+			mutate: func(m *TinyHomeInstructions) {
+				m.AddlGkeTenantSaRoles = append(m.AddlGkeTenantSaRoles, "roles/monitoring.metricWriter")
+			},
+			wantKeys: []string{"addlGkeTenantSaRoles"},
+		},
+		{
+			name: "labels and breakglass",
+			mutate: func(m *TinyHomeInstructions) {
+				m.Labels = map[string]string{"team": "platform"}
+				m.Breakglass = true
+			},
+			wantKeys: []string{"breakglass", "labels"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults := validInstructions()
+			message := validInstructions()
+			tt.mutate(&message)
+
+			data, err := message.MarshalCompact(defaults)
+			if err != nil {
+				t.Fatalf("MarshalCompact: %v", err)
+			}
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(data, &object); err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for key := range object {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+			if !slices.Equal(keys, tt.wantKeys) {
+				t.Errorf("compact JSON %s has keys %v, want %v", data, keys, tt.wantKeys)
+			}
+
+			parsed, err := ParseCompact(data, defaults)
+			if err != nil {
+				t.Fatalf("ParseCompact: %v", err)
+			}
+			if !reflect.DeepEqual(*parsed, message) {
+				t.Errorf("ParseCompact = %+v, want %+v", *parsed, message)
+			}
+		})
+	}
+}
+
+func TestCompactPublish(t *testing.T) {
+	defaults := validInstructions()
+	topic := &fakeTopic{}
+	p := newTestPublisher(t, topic, nil, WithCompact(defaults))
+	message := validInstructions()
+	message.TenantName = "other-tenant"
+
+	if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+		t.Fatalf("PublishContext: %v", err)
+	}
+	published := topic.published()[0]
+	if published.Attributes["compact"] != "true" {
+		t.Errorf("attributes %v, want compact true", published.Attributes)
+	}
+
+	// Subscribers applying the same defaults get the full instructions back
+	parsed, err := ParseCompact(published.Data, defaults)
+	if err != nil {
+		t.Fatalf("ParseCompact: %v", err)
+	}
+	if !reflect.DeepEqual(*parsed, message) {
+		t.Errorf("ParseCompact = %+v, want %+v", *parsed, message)
+	}
+	if full, _ := json.Marshal(message); len(published.Data) >= len(full) {
+		t.Errorf("compact body of %d bytes is no smaller than the full %d", len(published.Data), len(full))
+	}
+}
@@ -0,0 +1,70 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+const (
+	// contentEncodingGzip is the contentEncoding attribute of gzipped bodies
+	contentEncodingGzip = "gzip"
+
+	// maxDecompressedBytes is the largest body DecompressBody inflates, so a small
+	// message can not expand without bound
+	maxDecompressedBytes = 10 << 20
+)
+
+// compressBody gzips data and sets the contentEncoding attribute, unless that does not
+// make data smaller, in which case data is returned as it is. It can not replace a
+// contentEncoding attribute already set.
+func compressBody(data []byte, attributes map[string]string) ([]byte, error) {
+	if _, ok := attributes["contentEncoding"]; ok {
+		return nil, fmt.Errorf("additional attribute contentEncoding collides with an attribute set by the publisher")
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("compress: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("compress: %v", err)
+	}
+
+	if buf.Len() >= len(data) {
+		return data, nil
+	}
+	attributes["contentEncoding"] = contentEncodingGzip
+	return buf.Bytes(), nil
+}
+
+// DecompressBody returns the body of a received message, such as pubsub.Message.Data
+// and Attributes, decompressed according to its contentEncoding attribute. Bodies with
+// no contentEncoding are returned unchanged. Failures match ErrDecode.
+func DecompressBody(data []byte, attributes map[string]string) ([]byte, error) {
+	switch encoding := attributes["contentEncoding"]; encoding {
+	case "":
+		return data, nil
+	case contentEncodingGzip:
+	default:
+		return nil, fmt.Errorf("%w: unsupported contentEncoding %q", ErrDecode, encoding)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: decompress: %v", ErrDecode, err)
+	}
+	defer r.Close()
+
+	body, err := io.ReadAll(io.LimitReader(r, maxDecompressedBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: decompress: %v", ErrDecode, err)
+	}
+
+	if len(body) > maxDecompressedBytes {
+		return nil, fmt.Errorf("%w: decompressed body is over %d bytes", ErrDecode, maxDecompressedBytes)
+	}
+	return body, nil
+}
@@ -0,0 +1,1419 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/option"
+)
+
+const (
+	// DefaultProjectID is the GCP project instructions are published to when no
+	// project is configured
+	DefaultProjectID = "tdigangi-demos"
+
+	// DefaultTopicID is the Pub/Sub topic instructions are published to when no
+	// topic is configured
+	DefaultTopicID = "tiny-home-api-0.0.1"
+
+	// DefaultMaxInstructionEntries is the default limit on the total number of list
+	// and map entries in a single set of instructions
+	DefaultMaxInstructionEntries = 1000
+
+	// PubsubMaxMessageBytes is the largest message, data and attributes together,
+	// Pub/Sub accepts
+	PubsubMaxMessageBytes = 10 * 1000 * 1000
+
+	// MaxOrderedNumGoroutines is the most publish goroutines PublishSettings can ask
+	// for with MessageOrdering. Messages for one ordering key are sent one batch at a
+	// time, so more goroutines only add memory.
+	MaxOrderedNumGoroutines = 10
+
+	// DefaultMaxBatchSize is the default number of messages PublishBatch publishes
+	// at once
+	DefaultMaxBatchSize = 100
+
+	// DefaultMinTenantNameLength is the default shortest TenantName accepted
+	DefaultMinTenantNameLength = 3
+
+	// DefaultMaxTenantNameLength is the default longest TenantName accepted
+	DefaultMaxTenantNameLength = 20
+
+	// DefaultMaxBurstRatio is the default limit on how many times its request a
+	// quota limit can be
+	DefaultMaxBurstRatio = 4
+)
+
+// PublisherConfig selects where instructions are published
+type PublisherConfig struct {
+	ProjectID string
+	TopicID   string
+
+	// EnableDeliverEmail allows publishing messages routed to the deliverEmail stage,
+	// which is rejected with ErrStageNotImplemented until its subscriber exists
+	EnableDeliverEmail bool
+
+	// NormalizeAttributes trims whitespace from and lower cases DeliveredFrom before
+	// it is validated, so values like " Galaxy " are accepted. Matching is exact
+	// when it is false.
+	NormalizeAttributes bool
+
+	// MaxInstructionEntries caps the total entries across every list and map in the
+	// instructions, SA roles, IAM members and labels, plus the AdditionalAttributes,
+	// guarding public ingress against a single huge message
+	MaxInstructionEntries int
+
+	// RegionDefaults maps an Environment to the Region applied when instructions for
+	// it omit one. The applied region is validated like an explicit one, and an
+	// explicit Region always wins.
+	RegionDefaults map[string]string
+
+	// ClientOptions are passed through to pubsub.NewClient by NewPublisher and
+	// NewSubscriber, and to the schema client of ValidateAgainstSchema, e.g.
+	// option.WithEndpoint or explicit credentials with option.WithCredentialsFile,
+	// option.WithCredentials or option.WithTokenSource where Application Default
+	// Credentials are unavailable.
+	// When PUBSUB_EMULATOR_HOST is set the client connects to the emulator at that
+	// address and credentials in ClientOptions are not used, so the same options
+	// work against both.
+	ClientOptions []option.ClientOption
+
+	// Logger receives a line for each publish. Nothing is logged when it is nil.
+	Logger *slog.Logger
+
+	// PublishLog receives one PublishLogLine as JSON per publish, separate from
+	// Logger, for ingestion by log-based metric systems
+	PublishLog io.Writer
+
+	// RoleOrder sorts and dedupes, dedupes or strictly checks AddlGkeTenantSaRoles
+	// so downstream diffs are deterministic. The default, RoleOrderAsIs, leaves them.
+	RoleOrder RoleOrderPolicy
+
+	// RetryMaxAttempts is the most times a publish is attempted when it fails with a
+	// transient gRPC error such as Unavailable, 1 disables retries. Validation and
+	// permanent errors are never retried.
+	RetryMaxAttempts int
+
+	// RetryInitialBackoff is the wait before the first retry, doubled for each one
+	// after it. Retries stop early when the context is done or its deadline would
+	// pass during the wait.
+	RetryInitialBackoff time.Duration
+
+	// MessageOrdering enables ordered publishing on the topic with the tenant name
+	// as the ordering key, so the stages of one tenant are processed in sequence.
+	// The subscriptions must also have message ordering enabled.
+	MessageOrdering bool
+
+	// Compatibility is the schema compatibility mode published as the compatibility
+	// attribute, one of CompatibilityBackward, CompatibilityForward or
+	// CompatibilityFull. The attribute is omitted when it is empty.
+	Compatibility string
+
+	// MaxBurstRatio is the most times its request each NsQuota limit can be, per
+	// resource. 0 disables the check.
+	MaxBurstRatio float64
+
+	// PolicyBundle is applied to every publish after the built in validation. A
+	// bundle that does not compile fails NewPublisher.
+	PolicyBundle *PolicyBundle
+
+	// NamespaceAttribute publishes the Kubernetes namespace derived by ResourceNames
+	// as the namespace attribute, so subscribers never derive it themselves
+	NamespaceAttribute bool
+
+	// OwnerDomain is the suffix, such as "@mycorp.com", TenantOwner and
+	// TenantOwnerSecondary must end with. Any domain is allowed when it is empty.
+	OwnerDomain string
+
+	// Environments are the accepted Environment values, DefaultEnvironments unless
+	// set with WithEnvironments
+	Environments []string
+
+	// MinTenantNameLength is the shortest TenantName accepted, at least 1
+	MinTenantNameLength int
+
+	// MaxTenantNameLength is the longest TenantName accepted, at least
+	// MinTenantNameLength. Names derived from TenantName, such as the projectId, are
+	// still checked against their own limits.
+	MaxTenantNameLength int
+
+	// DecommissionedRegions maps regions being wound down to their recommended
+	// replacements. Instructions for a decommissioned Region are rejected.
+	DecommissionedRegions map[string][]string
+
+	// GenerateCorrelationID sets the correlationId attribute to a random UUID when
+	// the caller did not supply a CorrelationID
+	GenerateCorrelationID bool
+
+	// CompactDefaults, when set, publishes the instructions with MarshalCompact
+	// against these defaults and sets the compact attribute to true. Subscribers must
+	// parse such messages with ParseCompact and the same defaults.
+	CompactDefaults *TinyHomeInstructions
+
+	// Compression gzips the marshaled instructions and sets the contentEncoding
+	// attribute to gzip, when that makes the body smaller. MaxMessageBytes applies to
+	// the compressed body. Subscribers decompress with DecompressBody, which a
+	// Subscriber does itself.
+	Compression bool
+
+	// StrictDecoding makes a Subscriber reject message bodies with unknown fields, see
+	// DecodeInstructions, rather than ignore them. The flattened MarshalFlat shape is
+	// then rejected too. The LoadInstructionsFrom loaders are always strict.
+	StrictDecoding bool
+
+	// OnAbort is called by a Subscriber with each abort message, see PublishAbort,
+	// in place of the Receive handler. Call ack once, as for the handler. Aborts are
+	// nacked when it is nil.
+	OnAbort func(abort Abort, ack AckFunc)
+
+	// AsyncCallback, when set, is called with the result of every PublishAsync once the
+	// server accepts or rejects it, so callers need not keep the PendingPublish. Each
+	// call runs on its own goroutine, in no particular order, and Flush waits for them.
+	// It must be safe for concurrent use and should return quickly without blocking. A
+	// panic in it is recovered and logged.
+	AsyncCallback func(res PublishResult, err error)
+
+	// OnDelivered, when set, is called with the result of every publish the server
+	// confirms, from any publish method, on its own goroutine so it stays off the
+	// publish path. Flush waits for the calls. It must be safe for concurrent use, and
+	// a panic in it is recovered and logged.
+	OnDelivered func(res PublishResult)
+
+	// CloudEventsSource, when set, wraps the marshaled instructions as the data of a
+	// CloudEvents v1.0 JSON structured envelope with this source, a type of
+	// com.tinyhome.<subscription> and a new random id, and sets the content-type
+	// attribute to application/cloudevents+json. A Subscriber unwraps it. With
+	// CloudEventsTopicID set only the copy sent to that topic is wrapped.
+	CloudEventsSource string
+
+	// TrimWhitespace trims leading and trailing whitespace from tenantName,
+	// environment, businessUnit, tenantOwner, tenantOwnerSecondary, tenantCostCenter,
+	// domain, organization, region, priority, breakglassWindow, breakglassApprover and
+	// breakglassTicket before validation, leaving the trimmed values on the
+	// instructions. Without it a publish with such whitespace is rejected.
+	TrimWhitespace bool
+
+	// QuarantineTopicID, when set, is the topic instructions that fail validation are
+	// published to, with the failure in the validationError attribute, so bad input
+	// can be inspected and recovered later. The publish still returns the validation
+	// error. Dry runs and Preflight never quarantine.
+	QuarantineTopicID string
+
+	// AdditionalAttributes are merged into the attributes of every message, e.g. for
+	// subscription filters on team. They can not use any key the publisher sets
+	// itself, see PublisherAttributeKeys, even one whose feature is disabled.
+	AdditionalAttributes map[string]string
+
+	// ExistingTenantNames is a snapshot of tenant names already in use, new
+	// instructions can not reuse them
+	ExistingTenantNames map[string]bool
+
+	// BusinessUnitPrefixes maps a BusinessUnit to the prefix its tenant names must
+	// start with, e.g. "payments" to "payments-". Business units without an entry
+	// are not checked.
+	BusinessUnitPrefixes map[string]string
+
+	// DeliverySources are the accepted DeliveredFrom values, DefaultDeliverySources
+	// unless set with WithDeliverySources
+	DeliverySources []string
+
+	// SubscriptionRules is the decision table from the created attributes to a
+	// subscription, DefaultSubscriptionRules unless set with WithSubscriptionRules
+	SubscriptionRules []SubscriptionRule
+
+	// TenantNameAttribute selects how TinyHomeMessageAttributes.TenantName is used,
+	// see TenantNameAttributeMode. The default ignores it.
+	TenantNameAttribute TenantNameAttributeMode
+
+	// BreakglassTicketPattern is the format BreakglassTicket must match whenever
+	// Breakglass is requested, DefaultBreakglassTicketPattern unless set with
+	// WithBreakglassTicketPattern. nil disables the check.
+	BreakglassTicketPattern *regexp.Regexp
+
+	// MaxBatchSize is the most messages PublishBatch publishes at once, larger
+	// batches are split into chunks of this size
+	MaxBatchSize int
+
+	// MaxMessageBytes is the largest message, data and attributes together, that is
+	// sent. It defaults to PubsubMaxMessageBytes and can be lowered as a soft limit
+	// to catch runaway payloads early.
+	MaxMessageBytes int
+
+	// PublishSettings tune batching and flow control on the topic. Fields left zero
+	// keep pubsub.DefaultPublishSettings: a batch is sent after 10ms, 100 messages or
+	// 1MB, with 25 goroutines per CPU and at most 1000 outstanding messages. When it
+	// is nil the library defaults are used unchanged. With MessageOrdering,
+	// NumGoroutines can be at most MaxOrderedNumGoroutines.
+	PublishSettings *pubsub.PublishSettings
+
+	// TracerProvider creates the span around each publish, nested under the span in
+	// the caller's context. When it is nil the global OpenTelemetry provider is used,
+	// which is a no-op until one is registered.
+	TracerProvider trace.TracerProvider
+
+	// BodyChecksum adds a bodyChecksum attribute holding the SHA-256 of the message
+	// body, which subscribers check with VerifyBodyChecksum
+	BodyChecksum bool
+
+	// Metrics is told the subscription, outcome and duration of every publish,
+	// including ones rejected by validation, and of the messages in flight when it is
+	// also InFlightMetrics, like PrometheusMetrics. Nothing is recorded when it is nil.
+	Metrics Metrics
+
+	// BreakglassNotAfter is the freeze date after which break-glass can not be
+	// requested, and no break-glass window may run past it. The zero time disables
+	// the check.
+	BreakglassNotAfter time.Time
+
+	// Clock returns the current time for time based rules and the publishedAt
+	// attribute. time.Now is used when it is nil.
+	Clock func() time.Time
+
+	// NormalizeTenantName lowercases TenantName, and the TenantName attribute, before
+	// validation instead of rejecting upper case letters. Only the tenantName
+	// attribute and the result carry the normalized name, which resources are named
+	// after. The body, its instructionHash and the caller's instructions keep the name
+	// as supplied. With OrganizationSlug it also slugifies Organization.
+	NormalizeTenantName bool
+
+	// TopicVersion is the schema version of the topic being published to, checked
+	// against EnvironmentTopicVersions
+	TopicVersion string
+
+	// EnvironmentTopicVersions maps an Environment to the topic versions it accepts
+	// during a phased schema rollout. Environments without an entry accept any
+	// version. TopicVersion is required when it is set.
+	EnvironmentTopicVersions map[string][]string
+
+	// DeliveryPolicy, when set, is published on every message as the subscriber
+	// retry and dead-letter policy
+	DeliveryPolicy *DeliveryPolicy
+
+	// IAMMemberDomains are the domains AddlGroupIamBindings members must belong to,
+	// such as example.com. Empty allows any domain.
+	IAMMemberDomains []string
+
+	// EnvironmentDefaults maps an Environment to the values PublishWithDefaults fills
+	// into instructions that leave them unset. TenantName and Breakglass are never
+	// taken from the defaults.
+	EnvironmentDefaults map[string]TinyHomeInstructions
+
+	// OrganizationPattern is the format Organization must match,
+	// DefaultOrganizationPattern unless set with WithOrganizationPattern. nil only
+	// requires Organization to be set.
+	OrganizationPattern *regexp.Regexp
+
+	// RequireOwnerAcceptance rejects instructions unless OwnerAccepted is true
+	RequireOwnerAcceptance bool
+
+	// TopicByEnvironment maps an Environment to the topic its instructions are
+	// published to. Environments without an entry use TopicID, which can be left
+	// empty when every environment is mapped.
+	TopicByEnvironment map[string]string
+
+	// CostCenterPattern is the format TenantCostCenter must match,
+	// DefaultCostCenterPattern unless set with WithCostCenterPattern. nil only
+	// requires TenantCostCenter to be set.
+	CostCenterPattern *regexp.Regexp
+
+	// BusinessUnits are the accepted BusinessUnit values. Empty only requires
+	// BusinessUnit to be set.
+	BusinessUnits []string
+
+	// PublishTimeout bounds each publish, from validation to the server's result,
+	// by deriving a context with this timeout from the caller's. A shorter deadline
+	// on the caller's context still wins. On expiry the error wraps
+	// context.DeadlineExceeded like any other deadline. 0 disables it.
+	PublishTimeout time.Duration
+
+	// MaxConcurrentPublishes is the most publishes of this Publisher in flight against
+	// a single topic at once, so many goroutines sharing it can not exhaust the gRPC
+	// stream. 0 allows DefaultMaxConcurrentPublishes. Every Publisher has its own
+	// limit, so one Publisher's load does not throttle another's.
+	MaxConcurrentPublishes int
+
+	// RetryQueue receives the messages whose publish still fails after retries, so a
+	// reprocessor can try them again later. nil drops them.
+	RetryQueue RetryQueue
+
+	// AttributeValuePolicy decides how attribute values over the 1024 byte Pub/Sub
+	// limit are handled, AttributeValueReject unless set
+	AttributeValuePolicy AttributeValuePolicy
+
+	// NameGenerator produces the TenantName of instructions that leave it blank. When
+	// it is nil blank names are left to validation, which rejects them.
+	NameGenerator NameGenerator
+
+	// Regions are the GCP regions Region is validated against, DefaultRegions when it
+	// is empty
+	Regions []string
+
+	// CasingPolicies maps identifier fields, by json name such as businessUnit, to the
+	// CasingPolicy they must follow, see ValidateCasing. Fields without an entry are
+	// not checked.
+	CasingPolicies map[string]CasingPolicy
+
+	// RequireDNS1123TenantName additionally checks TenantName with
+	// ValidateDNS1123Label, for tenants whose name is used directly as a resource name
+	RequireDNS1123TenantName bool
+
+	// IAMMemberConsistency rejects AddlGroupIamBindings that bind a principal to more
+	// than one role or bind a tenant owner, see ValidateIAMMemberConsistency
+	IAMMemberConsistency bool
+
+	// MaxIAMMembersPerRole is the most principals each AddlGroupIamBindings role can
+	// be bound to, see ValidateIAMMemberLimit. 0 disables the check.
+	MaxIAMMembersPerRole int
+
+	// QuotaCaps maps an Environment to the largest NsQuota values its instructions can
+	// request, see ValidateQuotaCaps. Environments without an entry are unrestricted.
+	QuotaCaps map[string]QuotaCaps
+
+	// CostCenterBudgets maps a TenantCostCenter to the largest NsQuota requests its
+	// tenants can make, see ValidateCostCenterBudget. Cost centers without an entry are
+	// unrestricted.
+	CostCenterBudgets map[string]QuotaCaps
+
+	// OrganizationSlug requires Organization to be a URL safe slug, see
+	// ValidateOrganizationSlug, in place of matching OrganizationPattern. With
+	// NormalizeTenantName the Organization is slugified first, see SlugifyOrganization.
+	OrganizationSlug bool
+
+	// MarkRepublished sets the republished attribute to true on messages sent with
+	// Republish, and the replayed attribute on those sent by Replay, so subscribers can
+	// tell recovered messages apart. It is set in DefaultPublisherConfig.
+	MarkRepublished bool
+
+	// DefaultTenantCostCenter is the TenantCostCenter applied to instructions that
+	// leave it blank, before they are validated, for teams sharing one cost center.
+	// Explicit cost centers always win. Empty applies no default.
+	DefaultTenantCostCenter string
+
+	// MinStage is the earliest stage this Publisher may publish to, so a specialized
+	// publisher, such as one that only handles tenant creation and beyond, rejects
+	// messages routed to an earlier stage. Empty allows every stage.
+	MinStage Stage
+
+	// DeprecatedStages are stages being retired. Messages routed to one are still
+	// published, with a warning logged and reported in PublishResult.Warnings, so
+	// callers can be migrated off them.
+	DeprecatedStages []Stage
+
+	// StrictDeprecatedStages rejects messages routed to a DeprecatedStages stage
+	// instead of publishing them with a warning
+	StrictDeprecatedStages bool
+
+	// CloudEventsTopicID, when set, dual-publishes each message during a migration to
+	// CloudEvents: the native format to its usual topic and a CloudEvents envelope from
+	// CloudEventsSource, which is then required and no longer wraps the native copy, to
+	// this topic. PublishResult.CloudEventsMessageID is the ID on this topic. Only the
+	// publishes that wait for a single result dual-publish, PublishAsync and
+	// PublishBatch return an error instead.
+	CloudEventsTopicID string
+
+	// AllowedOrganizations maps an Environment to the Organizations its instructions
+	// can use, see ValidateOrganizationForEnvironment. Environments without an entry,
+	// or with an empty list, are unrestricted.
+	AllowedOrganizations map[string][]string
+
+	// FlattenQuota publishes the instructions with MarshalFlat, NsQuota as the top
+	// level cpuRequest, memoryRequest, cpuLimit and memoryLimit fields, for subscribers
+	// that expect that shape. ParseInstructions reads either shape. It can not be
+	// combined with CompactDefaults.
+	FlattenQuota bool
+
+	// PublisherVersion, when set, overrides the package PublisherVersion in the
+	// publisherVersion attribute of this Publisher's messages
+	PublisherVersion string
+
+	// OrderingKeyCollisions decides what PublishBatch does when MessageOrdering is
+	// enabled and messages of one batch share an ordering key, ignoring it by default.
+	// See OrderingKeyCollisionPolicy.
+	OrderingKeyCollisions OrderingKeyCollisionPolicy
+
+	// BreakglassForbiddenEnvironments are the Environments, such as a locked
+	// compliance environment, where break-glass requests are rejected outright, see
+	// ValidateBreakglassPolicy
+	BreakglassForbiddenEnvironments []string
+
+	// DefaultEmptyAttributes treats GroupsCreated, WorkspaceCreated, TenantCreated and
+	// FluxCreated left as an empty string as "false" before the attributes are
+	// validated, see ApplyDefaults, so attributes with only DeliveredFrom set route to
+	// createGroups. The caller's attributes are not changed. Empty values are rejected
+	// when it is false.
+	DefaultEmptyAttributes bool
+
+	// BusinessUnitCostCenters maps a BusinessUnit to the TenantCostCenters it may
+	// charge, see ValidateCostCenter. An empty mapping disables the check.
+	BusinessUnitCostCenters map[string][]string
+
+	// AllowedOwners are the TenantOwners permitted to own tenants, exact email
+	// addresses or domains written with a leading "@", see ValidateOwnerAllowed. An
+	// empty list disables the check.
+	AllowedOwners []string
+
+	// RequireProjectIDTenantName additionally checks the project ID derived from
+	// TenantName and Environment with ValidateAsProjectID
+	RequireProjectIDTenantName bool
+
+	// MessageKeyField, when set, names the instructions field, by json name such as
+	// businessUnit, whose value is published in the messageKey attribute. Transports
+	// that partition, such as a Kafka bridge, can key on it independently of the
+	// MessageOrdering ordering key. Instructions leaving the field empty are rejected.
+	// It can be any field a CasingPolicy applies to.
+	MessageKeyField string
+
+	// DedupTTL, when set, skips publishing a message identical to one this Publisher
+	// published within the TTL, by the cfg clock, and returns the earlier
+	// PublishResult flagged Deduplicated. Messages are identical when they share an
+	// idempotencyKey, by default the InstructionHash, stage and topic. Only the
+	// publishes that wait for a single result are deduplicated, PublishAsync and
+	// PublishBatch always publish.
+	DedupTTL time.Duration
+
+	// DedupCacheSize is the most publishes remembered for DedupTTL,
+	// DefaultDedupCacheSize when 0. The oldest is forgotten first when it is full.
+	DedupCacheSize int
+
+	// WebhookURL, when set, is POSTed a JSON summary of each published message, its
+	// ID, subscription, tenant, attributes and warnings, once the server confirms it.
+	// A failed webhook is logged and the publish still succeeds, unless StrictWebhook
+	// is set. Only the publishes that wait for a single result notify it,
+	// PublishAsync and PublishBatch do not.
+	WebhookURL string
+
+	// WebhookClient sends the WebhookURL requests, http.DefaultClient when nil
+	WebhookClient *http.Client
+
+	// WebhookIncludeBody adds the published message data to the webhook payload
+	WebhookIncludeBody bool
+
+	// WebhookTimeout bounds each WebhookURL request, DefaultWebhookTimeout when 0. The
+	// caller's context still applies.
+	WebhookTimeout time.Duration
+
+	// StrictWebhook fails a publish whose WebhookURL request fails with a
+	// WebhookError holding the ID of the message, which was still published
+	StrictWebhook bool
+
+	// costCenterPatternErr is the error compiling the WithCostCenterPattern pattern,
+	// reported by validate
+	costCenterPatternErr error
+}
+
+// DefaultBreakglassTicketPattern matches incident tickets such as INC-12345
+var DefaultBreakglassTicketPattern = regexp.MustCompile(`^INC-[0-9]+$`)
+
+// DefaultDeliverySources are the upstream systems DeliveredFrom accepts by default
+var DefaultDeliverySources = []string{"galaxy", "manual"}
+
+// Schema compatibility modes for PublisherConfig.Compatibility, telling subscribers
+// how to treat fields they do not know
+const (
+	CompatibilityBackward = "backward"
+	CompatibilityForward  = "forward"
+	CompatibilityFull     = "full"
+)
+
+var compatibilityVals = []string{CompatibilityBackward, CompatibilityForward, CompatibilityFull}
+
+// Option changes a PublisherConfig
+type Option func(*PublisherConfig)
+
+// WithProject publishes to the GCP project id
+func WithProject(id string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.ProjectID = id
+	}
+}
+
+// WithTopic publishes to the Pub/Sub topic id
+func WithTopic(id string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.TopicID = id
+	}
+}
+
+// WithDeliverEmail allows or rejects publishing to the deliverEmail stage
+func WithDeliverEmail(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.EnableDeliverEmail = enabled
+	}
+}
+
+// WithAttributeNormalization enables or disables NormalizeAttributes
+func WithAttributeNormalization(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.NormalizeAttributes = enabled
+	}
+}
+
+// WithMaxInstructionEntries sets MaxInstructionEntries
+func WithMaxInstructionEntries(max int) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.MaxInstructionEntries = max
+	}
+}
+
+// WithRegionDefaults sets RegionDefaults
+func WithRegionDefaults(defaults map[string]string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.RegionDefaults = defaults
+	}
+}
+
+// WithClientOptions appends opts to ClientOptions
+func WithClientOptions(opts ...option.ClientOption) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.ClientOptions = append(cfg.ClientOptions, opts...)
+	}
+}
+
+// WithLogger sets Logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.Logger = logger
+	}
+}
+
+// WithPublishLog sets PublishLog
+func WithPublishLog(w io.Writer) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.PublishLog = w
+	}
+}
+
+// WithRoleOrder sets RoleOrder
+func WithRoleOrder(policy RoleOrderPolicy) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.RoleOrder = policy
+	}
+}
+
+// WithDedupeRoles sets RoleOrder to RoleOrderDedupe when enabled, so duplicate
+// AddlGkeTenantSaRoles are removed in order instead of rejected, and back to
+// RoleOrderAsIs when disabled
+func WithDedupeRoles(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		if enabled {
+			cfg.RoleOrder = RoleOrderDedupe
+		} else if cfg.RoleOrder == RoleOrderDedupe {
+			cfg.RoleOrder = RoleOrderAsIs
+		}
+	}
+}
+
+// WithRetry retries transient publish failures up to maxAttempts attempts in total,
+// waiting initialBackoff before the first retry and doubling it each time
+func WithRetry(maxAttempts int, initialBackoff time.Duration) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.RetryMaxAttempts = maxAttempts
+		cfg.RetryInitialBackoff = initialBackoff
+	}
+}
+
+// WithMessageOrdering enables or disables MessageOrdering
+func WithMessageOrdering(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.MessageOrdering = enabled
+	}
+}
+
+// WithCompatibility sets Compatibility
+func WithCompatibility(mode string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.Compatibility = mode
+	}
+}
+
+// WithMaxBurstRatio sets MaxBurstRatio
+func WithMaxBurstRatio(ratio float64) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.MaxBurstRatio = ratio
+	}
+}
+
+// WithPolicyBundle sets PolicyBundle
+func WithPolicyBundle(bundle *PolicyBundle) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.PolicyBundle = bundle
+	}
+}
+
+// WithNamespaceAttribute enables or disables NamespaceAttribute
+func WithNamespaceAttribute(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.NamespaceAttribute = enabled
+	}
+}
+
+// WithOwnerDomain sets OwnerDomain
+func WithOwnerDomain(domain string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.OwnerDomain = domain
+	}
+}
+
+// WithEnvironments sets Environments
+func WithEnvironments(environments []string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.Environments = environments
+	}
+}
+
+// WithMinTenantNameLength sets MinTenantNameLength
+func WithMinTenantNameLength(length int) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.MinTenantNameLength = length
+	}
+}
+
+// WithMaxTenantNameLength sets MaxTenantNameLength
+func WithMaxTenantNameLength(length int) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.MaxTenantNameLength = length
+	}
+}
+
+// WithDecommissionedRegions sets DecommissionedRegions
+func WithDecommissionedRegions(regions map[string][]string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.DecommissionedRegions = regions
+	}
+}
+
+// WithCorrelationIDGeneration enables or disables GenerateCorrelationID
+func WithCorrelationIDGeneration(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.GenerateCorrelationID = enabled
+	}
+}
+
+// WithCompact publishes compacted instructions against defaults, see CompactDefaults
+func WithCompact(defaults TinyHomeInstructions) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.CompactDefaults = &defaults
+	}
+}
+
+// WithAdditionalAttributes sets AdditionalAttributes
+func WithAdditionalAttributes(attributes map[string]string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.AdditionalAttributes = attributes
+	}
+}
+
+// WithExistingTenantNames sets ExistingTenantNames from a list of names
+func WithExistingTenantNames(names []string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.ExistingTenantNames = make(map[string]bool, len(names))
+		for _, name := range names {
+			cfg.ExistingTenantNames[name] = true
+		}
+	}
+}
+
+// WithBusinessUnitPrefixes sets BusinessUnitPrefixes
+func WithBusinessUnitPrefixes(prefixes map[string]string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.BusinessUnitPrefixes = prefixes
+	}
+}
+
+// WithDeliverySources sets DeliverySources, e.g. to register a new upstream system
+func WithDeliverySources(sources []string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.DeliverySources = sources
+	}
+}
+
+// WithSubscriptionRules sets SubscriptionRules, e.g. to route a new pipeline stage
+func WithSubscriptionRules(rules []SubscriptionRule) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.SubscriptionRules = rules
+	}
+}
+
+// WithTenantNameAttribute sets TenantNameAttribute
+func WithTenantNameAttribute(mode TenantNameAttributeMode) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.TenantNameAttribute = mode
+	}
+}
+
+// WithBreakglassTicketPattern sets BreakglassTicketPattern
+func WithBreakglassTicketPattern(pattern *regexp.Regexp) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.BreakglassTicketPattern = pattern
+	}
+}
+
+// WithMaxBatchSize sets MaxBatchSize
+func WithMaxBatchSize(size int) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.MaxBatchSize = size
+	}
+}
+
+// WithPublishSettings sets PublishSettings
+func WithPublishSettings(settings pubsub.PublishSettings) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.PublishSettings = &settings
+	}
+}
+
+// WithTracerProvider sets TracerProvider
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.TracerProvider = tp
+	}
+}
+
+// WithBodyChecksum enables or disables BodyChecksum
+func WithBodyChecksum(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.BodyChecksum = enabled
+	}
+}
+
+// WithMetrics sets Metrics
+func WithMetrics(metrics Metrics) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.Metrics = metrics
+	}
+}
+
+// WithBreakglassNotAfter sets BreakglassNotAfter
+func WithBreakglassNotAfter(notAfter time.Time) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.BreakglassNotAfter = notAfter
+	}
+}
+
+// WithClock sets Clock, e.g. to pin the time in tests
+func WithClock(clock func() time.Time) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.Clock = clock
+	}
+}
+
+// now returns the time from Clock, or time.Now when it is not set
+func (cfg PublisherConfig) now() time.Time {
+	if cfg.Clock == nil {
+		return time.Now()
+	}
+	return cfg.Clock()
+}
+
+// WithTenantNameNormalization enables or disables NormalizeTenantName
+func WithTenantNameNormalization(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.NormalizeTenantName = enabled
+	}
+}
+
+// WithTopicVersion sets TopicVersion
+func WithTopicVersion(version string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.TopicVersion = version
+	}
+}
+
+// WithEnvironmentTopicVersions sets EnvironmentTopicVersions
+func WithEnvironmentTopicVersions(versions map[string][]string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.EnvironmentTopicVersions = versions
+	}
+}
+
+// WithDeliveryPolicy sets DeliveryPolicy
+func WithDeliveryPolicy(policy DeliveryPolicy) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.DeliveryPolicy = &policy
+	}
+}
+
+// WithIAMMemberDomains sets IAMMemberDomains
+func WithIAMMemberDomains(domains []string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.IAMMemberDomains = domains
+	}
+}
+
+// WithEnvironmentDefaults sets EnvironmentDefaults
+func WithEnvironmentDefaults(defaults map[string]TinyHomeInstructions) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.EnvironmentDefaults = defaults
+	}
+}
+
+// WithOrganizationPattern sets OrganizationPattern
+func WithOrganizationPattern(pattern *regexp.Regexp) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.OrganizationPattern = pattern
+	}
+}
+
+// WithRequireOwnerAcceptance enables or disables RequireOwnerAcceptance
+func WithRequireOwnerAcceptance(required bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.RequireOwnerAcceptance = required
+	}
+}
+
+// WithTopicByEnvironment sets TopicByEnvironment
+func WithTopicByEnvironment(topics map[string]string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.TopicByEnvironment = topics
+	}
+}
+
+// WithCostCenterPattern sets CostCenterPattern from a regular expression, an invalid
+// expression fails the config
+func WithCostCenterPattern(pattern string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.CostCenterPattern, cfg.costCenterPatternErr = regexp.Compile(pattern)
+	}
+}
+
+// WithBusinessUnits sets BusinessUnits
+func WithBusinessUnits(units []string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.BusinessUnits = units
+	}
+}
+
+// WithTimeout sets PublishTimeout
+func WithTimeout(d time.Duration) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.PublishTimeout = d
+	}
+}
+
+// WithCompression sets Compression
+func WithCompression(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.Compression = enabled
+	}
+}
+
+// WithStrictDecoding sets StrictDecoding
+func WithStrictDecoding(strict bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.StrictDecoding = strict
+	}
+}
+
+// WithOnAbort sets OnAbort
+func WithOnAbort(handler func(abort Abort, ack AckFunc)) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.OnAbort = handler
+	}
+}
+
+// WithAsyncCallback sets AsyncCallback
+func WithAsyncCallback(callback func(res PublishResult, err error)) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.AsyncCallback = callback
+	}
+}
+
+// WithOnDelivered sets OnDelivered
+func WithOnDelivered(callback func(res PublishResult)) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.OnDelivered = callback
+	}
+}
+
+// WithCloudEvents sets CloudEventsSource
+func WithCloudEvents(source string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.CloudEventsSource = source
+	}
+}
+
+// WithTrimWhitespace sets TrimWhitespace
+func WithTrimWhitespace(trim bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.TrimWhitespace = trim
+	}
+}
+
+// WithQuarantineTopic sets QuarantineTopicID
+func WithQuarantineTopic(id string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.QuarantineTopicID = id
+	}
+}
+
+// WithMaxConcurrentPublishes sets MaxConcurrentPublishes
+func WithMaxConcurrentPublishes(max int) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.MaxConcurrentPublishes = max
+	}
+}
+
+// WithRetryQueue sets RetryQueue
+func WithRetryQueue(q RetryQueue) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.RetryQueue = q
+	}
+}
+
+// WithAttributeValuePolicy sets AttributeValuePolicy
+func WithAttributeValuePolicy(policy AttributeValuePolicy) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.AttributeValuePolicy = policy
+	}
+}
+
+// WithNameGenerator sets NameGenerator
+func WithNameGenerator(g NameGenerator) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.NameGenerator = g
+	}
+}
+
+// WithRegions sets Regions, e.g. to restrict tenants to a subset or to add a region
+// newer than DefaultRegions
+func WithRegions(regions []string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.Regions = regions
+	}
+}
+
+// WithCasingPolicies sets CasingPolicies
+func WithCasingPolicies(policies map[string]CasingPolicy) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.CasingPolicies = policies
+	}
+}
+
+// WithDNS1123TenantName enables or disables RequireDNS1123TenantName
+func WithDNS1123TenantName(required bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.RequireDNS1123TenantName = required
+	}
+}
+
+// WithIAMMemberConsistency enables or disables IAMMemberConsistency
+func WithIAMMemberConsistency(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.IAMMemberConsistency = enabled
+	}
+}
+
+// WithMaxIAMMembersPerRole sets MaxIAMMembersPerRole
+func WithMaxIAMMembersPerRole(max int) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.MaxIAMMembersPerRole = max
+	}
+}
+
+// WithQuotaCaps sets QuotaCaps
+func WithQuotaCaps(caps map[string]QuotaCaps) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.QuotaCaps = caps
+	}
+}
+
+// WithCostCenterBudgets sets CostCenterBudgets
+func WithCostCenterBudgets(budgets map[string]QuotaCaps) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.CostCenterBudgets = budgets
+	}
+}
+
+// WithOrganizationSlug enables or disables OrganizationSlug
+func WithOrganizationSlug(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.OrganizationSlug = enabled
+	}
+}
+
+// WithMarkRepublished enables or disables MarkRepublished
+func WithMarkRepublished(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.MarkRepublished = enabled
+	}
+}
+
+// WithDefaultTenantCostCenter sets DefaultTenantCostCenter
+func WithDefaultTenantCostCenter(costCenter string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.DefaultTenantCostCenter = costCenter
+	}
+}
+
+// WithMinStage sets MinStage
+func WithMinStage(stage Stage) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.MinStage = stage
+	}
+}
+
+// WithDeprecatedStages sets DeprecatedStages
+func WithDeprecatedStages(stages []Stage) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.DeprecatedStages = stages
+	}
+}
+
+// WithStrictDeprecatedStages enables or disables StrictDeprecatedStages
+func WithStrictDeprecatedStages(strict bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.StrictDeprecatedStages = strict
+	}
+}
+
+// WithCloudEventsTopic sets CloudEventsTopicID
+func WithCloudEventsTopic(id string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.CloudEventsTopicID = id
+	}
+}
+
+// WithAllowedOrganizations sets AllowedOrganizations
+func WithAllowedOrganizations(allowedOrgs map[string][]string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.AllowedOrganizations = allowedOrgs
+	}
+}
+
+// WithFlattenQuota enables or disables FlattenQuota
+func WithFlattenQuota(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.FlattenQuota = enabled
+	}
+}
+
+// WithPublisherVersion sets PublisherVersion
+func WithPublisherVersion(version string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.PublisherVersion = version
+	}
+}
+
+// WithOrderingKeyCollisions sets OrderingKeyCollisions
+func WithOrderingKeyCollisions(policy OrderingKeyCollisionPolicy) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.OrderingKeyCollisions = policy
+	}
+}
+
+// WithBreakglassForbiddenEnvironments sets BreakglassForbiddenEnvironments
+func WithBreakglassForbiddenEnvironments(environments []string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.BreakglassForbiddenEnvironments = environments
+	}
+}
+
+// WithDefaultEmptyAttributes enables or disables DefaultEmptyAttributes
+func WithDefaultEmptyAttributes(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.DefaultEmptyAttributes = enabled
+	}
+}
+
+// WithBusinessUnitCostCenters sets BusinessUnitCostCenters
+func WithBusinessUnitCostCenters(costCenters map[string][]string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.BusinessUnitCostCenters = costCenters
+	}
+}
+
+// WithAllowedOwners sets AllowedOwners
+func WithAllowedOwners(owners []string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.AllowedOwners = owners
+	}
+}
+
+// WithProjectIDTenantName enables or disables RequireProjectIDTenantName
+func WithProjectIDTenantName(required bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.RequireProjectIDTenantName = required
+	}
+}
+
+// WithMessageKeyField sets MessageKeyField
+func WithMessageKeyField(field string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.MessageKeyField = field
+	}
+}
+
+// WithDedupTTL sets DedupTTL
+func WithDedupTTL(ttl time.Duration) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.DedupTTL = ttl
+	}
+}
+
+// WithDedupCacheSize sets DedupCacheSize
+func WithDedupCacheSize(size int) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.DedupCacheSize = size
+	}
+}
+
+// WithWebhook sets WebhookURL
+func WithWebhook(url string) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.WebhookURL = url
+	}
+}
+
+// WithWebhookClient sets WebhookClient
+func WithWebhookClient(client *http.Client) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.WebhookClient = client
+	}
+}
+
+// WithWebhookIncludeBody enables or disables WebhookIncludeBody
+func WithWebhookIncludeBody(enabled bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.WebhookIncludeBody = enabled
+	}
+}
+
+// WithWebhookTimeout sets WebhookTimeout
+func WithWebhookTimeout(d time.Duration) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.WebhookTimeout = d
+	}
+}
+
+// WithStrictWebhook enables or disables StrictWebhook
+func WithStrictWebhook(strict bool) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.StrictWebhook = strict
+	}
+}
+
+// WithMaxMessageBytes sets MaxMessageBytes
+func WithMaxMessageBytes(max int) Option {
+	return func(cfg *PublisherConfig) {
+		cfg.MaxMessageBytes = max
+	}
+}
+
+// DefaultPublisherConfig returns the project and topic used when nothing else is set
+func DefaultPublisherConfig() PublisherConfig {
+	return PublisherConfig{
+		ProjectID:               DefaultProjectID,
+		TopicID:                 DefaultTopicID,
+		MaxInstructionEntries:   DefaultMaxInstructionEntries,
+		RetryMaxAttempts:        1,
+		MaxBurstRatio:           DefaultMaxBurstRatio,
+		Environments:            DefaultEnvironments,
+		MinTenantNameLength:     DefaultMinTenantNameLength,
+		MaxTenantNameLength:     DefaultMaxTenantNameLength,
+		DeliverySources:         DefaultDeliverySources,
+		SubscriptionRules:       DefaultSubscriptionRules,
+		BreakglassTicketPattern: DefaultBreakglassTicketPattern,
+		MaxBatchSize:            DefaultMaxBatchSize,
+		MaxMessageBytes:         PubsubMaxMessageBytes,
+		OrganizationPattern:     DefaultOrganizationPattern,
+		CostCenterPattern:       DefaultCostCenterPattern,
+		MarkRepublished:         true,
+	}
+}
+
+// newPublisherConfig applies opts over DefaultPublisherConfig and validates the result
+func newPublisherConfig(opts ...Option) (PublisherConfig, error) {
+	cfg := DefaultPublisherConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return PublisherConfig{}, err
+	}
+	return cfg, nil
+}
+
+// validate checks the config before any Pub/Sub client is created from it
+func (cfg PublisherConfig) validate() error {
+	if cfg.ProjectID == "" {
+		return fmt.Errorf("publisher config: project id can not be empty")
+	}
+
+	for environment, id := range cfg.TopicByEnvironment {
+		if id == "" {
+			return fmt.Errorf("publisher config: topic id for environment %q can not be empty", environment)
+		}
+	}
+
+	if cfg.TopicID == "" && len(cfg.TopicByEnvironment) == 0 {
+		return fmt.Errorf("publisher config: topic id can not be empty")
+	}
+
+	if cfg.costCenterPatternErr != nil {
+		return fmt.Errorf("publisher config: cost center pattern: %v", cfg.costCenterPatternErr)
+	}
+
+	if cfg.PublishTimeout < 0 {
+		return fmt.Errorf("publisher config: publish timeout can not be negative, got %v", cfg.PublishTimeout)
+	}
+
+	if err := validateCasingPolicies(cfg.CasingPolicies); err != nil {
+		return fmt.Errorf("publisher config: %v", err)
+	}
+
+	if cfg.MaxIAMMembersPerRole < 0 {
+		return fmt.Errorf("publisher config: max IAM members per role can not be negative, got %d", cfg.MaxIAMMembersPerRole)
+	}
+
+	if err := validateQuotaCapQuantities("quota cap", cfg.QuotaCaps); err != nil {
+		return fmt.Errorf("publisher config: %v", err)
+	}
+
+	if err := validateQuotaCapQuantities("budget", cfg.CostCenterBudgets); err != nil {
+		return fmt.Errorf("publisher config: %v", err)
+	}
+
+	if cfg.DefaultTenantCostCenter != "" && cfg.CostCenterPattern != nil && !cfg.CostCenterPattern.MatchString(cfg.DefaultTenantCostCenter) {
+		return fmt.Errorf("publisher config: DefaultTenantCostCenter %q does not match CostCenterPattern %s", cfg.DefaultTenantCostCenter, cfg.CostCenterPattern)
+	}
+
+	if cfg.MinStage != "" && !contains(subscriptionOrder, string(cfg.MinStage)) {
+		return fmt.Errorf("publisher config: MinStage %q is not one of: %s", cfg.MinStage, subscriptionOrder)
+	}
+
+	for _, stage := range cfg.DeprecatedStages {
+		if !contains(subscriptionOrder, string(stage)) {
+			return fmt.Errorf("publisher config: deprecated stage %q is not one of: %s", stage, subscriptionOrder)
+		}
+	}
+
+	if cfg.CloudEventsTopicID != "" {
+		if cfg.CloudEventsSource == "" {
+			return fmt.Errorf("publisher config: CloudEventsTopicID requires a CloudEventsSource")
+		}
+		if cfg.CloudEventsTopicID == cfg.TopicID {
+			return fmt.Errorf("publisher config: CloudEventsTopicID must differ from TopicID")
+		}
+	}
+
+	if cfg.FlattenQuota && cfg.CompactDefaults != nil {
+		return fmt.Errorf("publisher config: FlattenQuota can not be combined with CompactDefaults")
+	}
+
+	if cfg.MaxConcurrentPublishes < 0 {
+		return fmt.Errorf("publisher config: max concurrent publishes can not be negative, got %d", cfg.MaxConcurrentPublishes)
+	}
+
+	if cfg.QuarantineTopicID != "" && cfg.QuarantineTopicID == cfg.TopicID {
+		return fmt.Errorf("publisher config: quarantine topic can not be the topic %s", cfg.TopicID)
+	}
+
+	if cfg.CloudEventsSource != "" {
+		if _, err := url.Parse(cfg.CloudEventsSource); err != nil {
+			return fmt.Errorf("publisher config: cloudevents source %q is not a URI reference: %v", cfg.CloudEventsSource, err)
+		}
+	}
+
+	if cfg.MaxInstructionEntries < 1 {
+		return fmt.Errorf("publisher config: max instruction entries must be at least 1, got %d", cfg.MaxInstructionEntries)
+	}
+
+	if cfg.MaxMessageBytes < 1 || cfg.MaxMessageBytes > PubsubMaxMessageBytes {
+		return fmt.Errorf("publisher config: max message bytes must be between 1 and %d, got %d", PubsubMaxMessageBytes, cfg.MaxMessageBytes)
+	}
+
+	if cfg.MaxBatchSize < 1 {
+		return fmt.Errorf("publisher config: max batch size must be at least 1, got %d", cfg.MaxBatchSize)
+	}
+
+	if err := cfg.validatePublishSettings(); err != nil {
+		return err
+	}
+
+	if len(cfg.EnvironmentTopicVersions) > 0 && cfg.TopicVersion == "" {
+		return fmt.Errorf("publisher config: topic version is required with environment topic versions")
+	}
+
+	if cfg.DeliveryPolicy != nil {
+		if err := cfg.DeliveryPolicy.validate(); err != nil {
+			return fmt.Errorf("publisher config: delivery policy: %v", err)
+		}
+	}
+
+	if cfg.RetryMaxAttempts < 1 {
+		return fmt.Errorf("publisher config: retry max attempts must be at least 1, got %d", cfg.RetryMaxAttempts)
+	}
+
+	if cfg.Compatibility != "" && !contains(compatibilityVals, cfg.Compatibility) {
+		return fmt.Errorf("publisher config: compatibility %q is not one of: %s", cfg.Compatibility, compatibilityVals)
+	}
+
+	if cfg.MaxBurstRatio < 0 {
+		return fmt.Errorf("publisher config: max burst ratio can not be negative, got %g", cfg.MaxBurstRatio)
+	}
+
+	if cfg.MaxTenantNameLength < 1 {
+		return fmt.Errorf("publisher config: max tenant name length must be positive, got %d", cfg.MaxTenantNameLength)
+	}
+
+	if cfg.MinTenantNameLength < 1 || cfg.MinTenantNameLength > cfg.MaxTenantNameLength {
+		return fmt.Errorf("publisher config: min tenant name length must be between 1 and %d, got %d", cfg.MaxTenantNameLength, cfg.MinTenantNameLength)
+	}
+
+	if len(cfg.DeliverySources) == 0 {
+		return fmt.Errorf("publisher config: at least one delivery source must be allowed")
+	}
+
+	if err := validateSubscriptionRules(cfg.SubscriptionRules); err != nil {
+		return fmt.Errorf("publisher config: %v", err)
+	}
+
+	if len(cfg.Environments) == 0 {
+		return fmt.Errorf("publisher config: at least one environment must be allowed")
+	}
+
+	if err := validateAdditionalAttributes(cfg.AdditionalAttributes); err != nil {
+		return fmt.Errorf("publisher config: %v", err)
+	}
+
+	if cfg.PolicyBundle != nil {
+		if err := cfg.PolicyBundle.compile(); err != nil {
+			return fmt.Errorf("publisher config: %v", err)
+		}
+	}
+
+	if cfg.RetryInitialBackoff < 0 {
+		return fmt.Errorf("publisher config: retry initial backoff can not be negative, got %s", cfg.RetryInitialBackoff)
+	}
+
+	if cfg.MessageKeyField != "" && !contains(messageKeyFields(), cfg.MessageKeyField) {
+		return fmt.Errorf("publisher config: message key field %q is not one of: %s", cfg.MessageKeyField, messageKeyFields())
+	}
+
+	if cfg.DedupTTL < 0 {
+		return fmt.Errorf("publisher config: dedup TTL can not be negative, got %v", cfg.DedupTTL)
+	}
+
+	if cfg.DedupCacheSize < 0 {
+		return fmt.Errorf("publisher config: dedup cache size can not be negative, got %d", cfg.DedupCacheSize)
+	}
+
+	if cfg.WebhookURL != "" {
+		if err := validateWebhookURL(cfg.WebhookURL); err != nil {
+			return fmt.Errorf("publisher config: %v", err)
+		}
+	}
+
+	if cfg.WebhookTimeout < 0 {
+		return fmt.Errorf("publisher config: webhook timeout can not be negative, got %v", cfg.WebhookTimeout)
+	}
+	return nil
+}
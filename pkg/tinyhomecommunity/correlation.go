@@ -0,0 +1,48 @@
+package tinyhomecommunity
+
+import (
+	"crypto/rand"
+	"fmt"
+	"unicode"
+)
+
+// maxCorrelationIDLength is the longest CorrelationID accepted
+const maxCorrelationIDLength = 128
+
+// validateIdempotencyKey checks a caller supplied idempotency key follows the same
+// rules as a correlation ID
+func validateIdempotencyKey(key string) error {
+	return validateIdentifierAttribute("idempotencyKey", key)
+}
+
+// validateCorrelationID checks an upstream supplied correlation ID is short enough
+// for an attribute and only uses characters safe in logs and filter expressions
+func validateCorrelationID(correlationId string) error {
+	return validateIdentifierAttribute("correlationId", correlationId)
+}
+
+// validateIdentifierAttribute checks an identifier attribute is at most
+// maxCorrelationIDLength ASCII letters, digits, '-', '_', '.' and ':'
+func validateIdentifierAttribute(field, value string) error {
+	if len(value) > maxCorrelationIDLength {
+		return &RoutingError{Field: field, Message: fmt.Sprintf("%s greater than %d characters", field, maxCorrelationIDLength)}
+	}
+
+	for _, r := range value {
+		if r > unicode.MaxASCII || (!unicode.IsLetter(r) && !unicode.IsDigit(r) && !contains([]string{"-", "_", ".", ":"}, string(r))) {
+			return &RoutingError{Field: field, Message: fmt.Sprintf("%s contains unsupported character %q, only letters, digits, '-', '_', '.' and ':' are supported", field, r)}
+		}
+	}
+	return nil
+}
+
+// newCorrelationID returns a random version 4 UUID
+func newCorrelationID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generate correlationId: %v", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,74 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCorrelationID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	tests := []struct {
+		name          string
+		correlationID string
+		generate      bool
+		// want is the correlationId attribute, checked against uuid when generated
+		want      string
+		wantField string
+	}{
+		{name: "supplied", correlationID: "order-42:run.7_b", want: "order-42:run.7_b"},
+		{name: "supplied with generation", correlationID: "upstream-1", generate: true, want: "upstream-1"},
+		{name: "longest", correlationID: strings.Repeat("a", 128), want: strings.Repeat("a", 128)},
+		{name: "not supplied"},
+		{name: "generated", generate: true},
+		{name: "too long", correlationID: strings.Repeat("a", 129), wantField: "correlationId"},
+		{name: "unsupported character", correlationID: "order 42", wantField: "correlationId"},
+		{name: "non ASCII", correlationID: "ordér-42", wantField: "correlationId"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithCorrelationIDGeneration(tt.generate))
+			message := validInstructions()
+			messageAttributes := validAttributes()
+			messageAttributes.CorrelationID = tt.correlationID
+
+			result, err := p.PublishWithResult(context.Background(), &message, messageAttributes)
+			if tt.wantField != "" {
+				wantFieldError(t, err, tt.wantField)
+				if got := len(topic.published()); got != 0 {
+					t.Errorf("published %d messages, want none", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+
+			attribute, ok := topic.published()[0].Attributes["correlationId"]
+			switch {
+			case tt.generate && tt.correlationID == "":
+				if !uuid.MatchString(attribute) {
+					t.Errorf("generated correlationId attribute %q is not a UUID", attribute)
+				}
+			case tt.want == "":
+				if ok {
+					t.Errorf("correlationId attribute %q published, want none", attribute)
+				}
+			case attribute != tt.want:
+				t.Errorf("correlationId attribute = %q, want %q", attribute, tt.want)
+			}
+
+			// The result carries both the correlation ID and the server message ID
+			if result.CorrelationID != attribute {
+				t.Errorf("result CorrelationID = %q, want the attribute %q", result.CorrelationID, attribute)
+			}
+			if result.MessageID != "1" {
+				t.Errorf("result MessageID = %q, want 1", result.MessageID)
+			}
+		})
+	}
+}
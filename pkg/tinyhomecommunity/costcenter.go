@@ -0,0 +1,22 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultCostCenterPattern is the default TenantCostCenter format, a 4 to 6 digit code
+var DefaultCostCenterPattern = regexp.MustCompile(`^[0-9]{4,6}$`)
+
+// validateCostCenter requires TenantCostCenter, since cost allocation is mandatory,
+// and checks it matches pattern. A nil pattern only requires it be set.
+func (message TinyHomeInstructions) validateCostCenter(pattern *regexp.Regexp) error {
+	if message.TenantCostCenter == "" {
+		return &NamingError{Field: "tenantCostCenter", Message: "tenantCostCenter can not be empty"}
+	}
+
+	if pattern != nil && !pattern.MatchString(message.TenantCostCenter) {
+		return &NamingError{Field: "tenantCostCenter", Message: fmt.Sprintf("tenantCostCenter %q does not match the expected format %s", message.TenantCostCenter, pattern)}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package tinyhomecommunity
+
+import "testing"
+
+func TestDefaultTenantCostCenter(t *testing.T) {
+	tests := []struct {
+		name       string
+		costCenter string
+		def        string
+		want       string
+		wantField  string
+	}{
+		{"default applied", "", "5678", "5678", ""},
+		{"explicit value wins", "1234", "5678", "1234", ""},
+		{"no default", "", "", "", "tenantCostCenter"},
+		{"invalid explicit value still rejected", "12", "5678", "12", "tenantCostCenter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.TenantCostCenter = tt.costCenter
+			_, err := message.DryRun(validAttributes(), WithDefaultTenantCostCenter(tt.def))
+			wantFieldError(t, err, tt.wantField)
+			if message.TenantCostCenter != tt.want {
+				t.Errorf("TenantCostCenter = %q, want %q", message.TenantCostCenter, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultTenantCostCenterValidated(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{"matches the pattern", []Option{WithDefaultTenantCostCenter("5678")}, false},
+		{"does not match the pattern", []Option{WithDefaultTenantCostCenter("cc-1")}, true},
+		{"matches a custom pattern", []Option{WithCostCenterPattern(`^cc-[0-9]+$`), WithDefaultTenantCostCenter("cc-1")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPublisherWithTopic error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvColumns maps each supported CSV header to the instructions field it sets.
+// addlGkeTenantSaRoles holds the roles separated by ";".
+var csvColumns = map[string]func(message *TinyHomeInstructions, value string){
+	"tenantName":           func(m *TinyHomeInstructions, v string) { m.TenantName = v },
+	"environment":          func(m *TinyHomeInstructions, v string) { m.Environment = v },
+	"businessUnit":         func(m *TinyHomeInstructions, v string) { m.BusinessUnit = v },
+	"tenantOwner":          func(m *TinyHomeInstructions, v string) { m.TenantOwner = v },
+	"tenantOwnerSecondary": func(m *TinyHomeInstructions, v string) { m.TenantOwnerSecondary = v },
+	"tenantCostCenter":     func(m *TinyHomeInstructions, v string) { m.TenantCostCenter = v },
+	"domain":               func(m *TinyHomeInstructions, v string) { m.Domain = v },
+	"organization":         func(m *TinyHomeInstructions, v string) { m.Organization = v },
+	"region":               func(m *TinyHomeInstructions, v string) { m.Region = v },
+	"priority":             func(m *TinyHomeInstructions, v string) { m.Priority = v },
+	"cpuRequest":           func(m *TinyHomeInstructions, v string) { m.NsQuota.Requests.Cpu = v },
+	"memoryRequest":        func(m *TinyHomeInstructions, v string) { m.NsQuota.Requests.Memory = v },
+	"cpuLimit":             func(m *TinyHomeInstructions, v string) { m.NsQuota.Limits.Cpu = v },
+	"memoryLimit":          func(m *TinyHomeInstructions, v string) { m.NsQuota.Limits.Memory = v },
+	"addlGkeTenantSaRoles": func(m *TinyHomeInstructions, v string) {
+		if v != "" {
+			m.AddlGkeTenantSaRoles = strings.Split(v, ";")
+		}
+	},
+}
+
+// PublishCSV publishes one set of instructions per row of a CSV with p, every row
+// with attrs. The first row is a header naming a column per field, using the json
+// names of the instruction fields plus cpuRequest, memoryRequest, cpuLimit and
+// memoryLimit for NsQuota, and addlGkeTenantSaRoles with roles separated by ";".
+// Columns may be in any order and omitted.
+//
+// Rows that are malformed, invalid or fail to publish do not stop the others. The
+// results hold one PublishResult per data row, zero for failed rows, and the error
+// is a BatchError keyed by data row index whose messages name the CSV row number.
+func PublishCSV(ctx context.Context, p *Publisher, r io.Reader, attrs *TinyHomeMessageAttributes) ([]PublishResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("PublishCSV: header: %v", err)
+	}
+	for _, column := range header {
+		if _, ok := csvColumns[column]; !ok {
+			return nil, fmt.Errorf("PublishCSV: header: unknown column %q", column)
+		}
+	}
+
+	var results []PublishResult
+	failed := map[int]error{}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		index := len(results)
+		results = append(results, PublishResult{})
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			failed[index] = fmt.Errorf("row %d: %v", row, err)
+			continue
+		} else if err != nil {
+			return results, fmt.Errorf("PublishCSV: row %d: %v", row, err)
+		}
+
+		if len(record) != len(header) {
+			failed[index] = fmt.Errorf("row %d: has %d columns, the header has %d", row, len(record), len(header))
+			continue
+		}
+
+		var message TinyHomeInstructions
+		for i, column := range header {
+			csvColumns[column](&message, record[i])
+		}
+
+		result, err := p.PublishWithResult(ctx, &message, attrs)
+		if err != nil {
+			failed[index] = fmt.Errorf("row %d: %w", row, err)
+			continue
+		}
+		results[index] = *result
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("PublishCSV: %w", &BatchError{Failures: failed})
+	}
+	return results, nil
+}
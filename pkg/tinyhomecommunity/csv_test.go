@@ -0,0 +1,138 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+)
+
+const csvHeader = "tenantName,environment,businessUnit,tenantOwner,tenantCostCenter,organization,cpuRequest,memoryRequest,cpuLimit,memoryLimit,addlGkeTenantSaRoles\n"
+
+// csvRow returns a valid CSV row for tenant with roles
+func csvRow(tenant, roles string) string {
+	return tenant + ",dev,platform,owner@example.com,1234,123456789012,1,1Gi,2,2Gi," + roles + "\n"
+}
+
+func TestPublishCSV(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	tests := []struct {
+		name   string
+		input  string
+		result func(n int, msg *pubsub.Message) publishResult
+		// wantTenants are the tenants published, in order
+		wantTenants []string
+		// wantFailed maps each failed data row index to part of its error
+		wantFailed map[int]string
+	}{
+		{
+			name:        "valid rows",
+			input:       csvHeader + csvRow("tenant-a", "") + csvRow("tenant-b", "roles/viewer;roles/logging.logWriter"),
+			wantTenants: []string{"tenant-a", "tenant-b"},
+		},
+		{
+			name:        "columns in another order",
+			input:       "tenantOwner, tenantName,environment,businessUnit,tenantCostCenter,organization\nowner@example.com, tenant-a,dev,platform,1234,123456789012\n",
+			wantTenants: []string{"tenant-a"},
+		},
+		{
+			name: "invalid rows do not stop the others",
+			input: csvHeader +
+				csvRow("tenant-a", "") +
+				csvRow("Not Valid", "") +
+				"tenant-c,dev\n" +
+				`tenant-d,dev,platform,owner"@example.com,1234,123456789012,1,1Gi,2,2Gi,` + "\n" +
+				csvRow("tenant-e", "storage.admin") +
+				csvRow("tenant-f", ""),
+			wantTenants: []string{"tenant-a", "tenant-f"},
+			wantFailed: map[int]string{
+				1: "row 3: ",
+				2: "row 4: has 2 columns, the header has 11",
+				3: "row 5: parse error",
+				4: "row 6: ",
+			},
+		},
+		{
+			name:  "publish failures are reported per row",
+			input: csvHeader + csvRow("tenant-a", "") + csvRow("tenant-b", ""),
+			result: func(n int, msg *pubsub.Message) publishResult {
+				if n == 2 {
+					return fakeResult{err: errPubSub}
+				}
+				return fakeResult{id: "1"}
+			},
+			wantTenants: []string{"tenant-a", "tenant-b"},
+			wantFailed:  map[int]string{1: "row 3: "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{result: tt.result}
+			p := newTestPublisher(t, topic, nil)
+			results, err := PublishCSV(context.Background(), p, strings.NewReader(tt.input), validAttributes())
+
+			var published []string
+			for _, msg := range topic.published() {
+				published = append(published, msg.Attributes[AttrTenantName])
+			}
+			if !slices.Equal(published, tt.wantTenants) {
+				t.Errorf("published %v, want %v", published, tt.wantTenants)
+			}
+
+			failures := map[int]error{}
+			if err != nil {
+				var batchErr *BatchError
+				if !errors.As(err, &batchErr) {
+					t.Fatalf("PublishCSV error = %v, want a BatchError", err)
+				}
+				failures = batchErr.Failures
+			}
+			if len(failures) != len(tt.wantFailed) {
+				t.Errorf("failures %v, want rows %v", failures, tt.wantFailed)
+			}
+			for i, want := range tt.wantFailed {
+				if got := failures[i]; got == nil || !strings.HasPrefix(got.Error(), want) {
+					t.Errorf("row index %d error = %v, want one starting %q", i, got, want)
+				}
+			}
+
+			// One result per data row, zero for the failed ones
+			wantRows := strings.Count(tt.input, "\n") - 1
+			if len(results) != wantRows {
+				t.Fatalf("%d results, want %d", len(results), wantRows)
+			}
+			for i, result := range results {
+				if _, failed := tt.wantFailed[i]; failed != (result.MessageID == "") {
+					t.Errorf("result %d = %+v, want failed %v", i, result, failed)
+				}
+			}
+		})
+	}
+}
+
+func TestPublishCSVHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "empty", input: ""},
+		{name: "unknown column", input: "tenantName,owner\ntenant-a,owner@example.com\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil)
+			if _, err := PublishCSV(context.Background(), p, strings.NewReader(tt.input), validAttributes()); err == nil || !strings.Contains(err.Error(), "header") {
+				t.Fatalf("PublishCSV error = %v, want a header error", err)
+			}
+			if got := len(topic.published()); got != 0 {
+				t.Errorf("published %d messages, want none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package tinyhomecommunity
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// maxNDJSONLineBytes is the longest line ValidateNDJSON reads
+const maxNDJSONLineBytes = 1 << 20
+
+// ValidateRawInstructions checks a raw JSON payload is a single TinyHomeInstructions
+// object with no unknown fields that passes validation, e.g. at API ingress before
+// acting on it. Decode failures match ErrDecode and validation failures are returned
+// as ValidationError values.
+func ValidateRawInstructions(data []byte) error {
+	if _, err := validateRaw(data); err != nil {
+		return fmt.Errorf("ValidateRawInstructions: %w", err)
+	}
+	return nil
+}
+
+// DecodeInstructions decodes a single JSON instructions object without validating it,
+// rejecting unknown fields such as a mistyped "tenantowner" so required values are not
+// silently left empty. The error names the unexpected field and matches ErrDecode.
+func DecodeInstructions(data []byte) (*TinyHomeInstructions, error) {
+	message, err := decodeStrict(data)
+	if err != nil {
+		return nil, fmt.Errorf("DecodeInstructions: %w", err)
+	}
+	return message, nil
+}
+
+// decodeStrict decodes a single instructions object with no unknown fields
+func decodeStrict(data []byte) (*TinyHomeInstructions, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var message TinyHomeInstructions
+	if err := decoder.Decode(&message); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	if decoder.More() {
+		return nil, fmt.Errorf("%w: unexpected data after instructions object", ErrDecode)
+	}
+
+	// encoding/json matches field names case insensitively, so tenantowner would
+	// still fill TenantOwner
+	if err := checkFieldNames(data, reflect.TypeOf(message)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+	return &message, nil
+}
+
+// checkFieldNames checks every key of the JSON object in data, and of the objects
+// nested in it, is exactly the json name of a field of the struct type t
+func checkFieldNames(data []byte, t reflect.Type) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		// Not an object, e.g. null, which decoding already accepted
+		return nil
+	}
+
+	known := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		known[name] = field.Type
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fieldType, ok := known[key]
+		if !ok {
+			return fmt.Errorf("json: unknown field %q", key)
+		}
+
+		if fieldType.Kind() == reflect.Struct {
+			if err := checkFieldNames(fields[key], fieldType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateRaw strictly decodes a single instructions object and validates it
+func validateRaw(data []byte) (*TinyHomeInstructions, error) {
+	message, err := decodeStrict(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := message.validateInstructions(); err != nil {
+		return message, err
+	}
+	return message, nil
+}
+
+// ValidationResult is the outcome of validating one line of an NDJSON file
+type ValidationResult struct {
+	// Line is the 1 based line number
+	Line int
+	// Instructions are the decoded instructions, nil when the line did not decode
+	Instructions *TinyHomeInstructions
+	// Err is the decode or validation failure, nil when the line is valid
+	Err error
+}
+
+// ValidateNDJSON reads newline delimited JSON instructions from r one line at a time,
+// validating each like ValidateRawInstructions, so large files are never held in
+// memory. A ValidationResult is sent for every non blank line and the channel is
+// closed at the end of r. A read failure is reported on the line it happened. A
+// consumer that stops reading early must cancel ctx, which stops the reading and
+// closes the channel.
+func ValidateNDJSON(ctx context.Context, r io.Reader) (<-chan ValidationResult, error) {
+	if r == nil {
+		return nil, fmt.Errorf("ValidateNDJSON: reader can not be nil")
+	}
+
+	results := make(chan ValidationResult)
+	send := func(result ValidationResult) bool {
+		select {
+		case results <- result:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(results)
+
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineBytes)
+
+		line := 0
+		for scanner.Scan() {
+			line++
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+
+			message, err := validateRaw(data)
+			if err != nil {
+				err = fmt.Errorf("line %d: %w", line, err)
+			}
+			if !send(ValidationResult{Line: line, Instructions: message, Err: err}) {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			send(ValidationResult{Line: line + 1, Err: fmt.Errorf("line %d: read: %v", line+1, err)})
+		}
+	}()
+	return results, nil
+}
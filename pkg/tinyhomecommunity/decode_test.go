@@ -0,0 +1,211 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validJSON returns validInstructions as JSON, after mutate changes the decoded object
+func validJSON(t *testing.T, mutate func(fields map[string]interface{})) []byte {
+	t.Helper()
+	b, err := json.Marshal(validInstructions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mutate == nil {
+		return b
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	mutate(fields)
+	if b, err = json.Marshal(fields); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestValidateRawInstructions(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		// wantDecode is whether the error matches ErrDecode
+		wantDecode bool
+		// wantField is the field of the ValidationError returned
+		wantField string
+		// wantMessage is part of the error message
+		wantMessage string
+	}{
+		{name: "valid", data: validJSON(t, nil)},
+		{name: "malformed", data: []byte(`{"tenantName": "broken"`), wantDecode: true, wantMessage: "unexpected EOF"},
+		{name: "not an object", data: []byte(`["contract-tenant"]`), wantDecode: true},
+		{name: "empty", data: []byte(``), wantDecode: true},
+		{
+			name:        "unknown field",
+			data:        validJSON(t, func(fields map[string]interface{}) { fields["tenantowner"] = "owner@example.com" }),
+			wantDecode:  true,
+			wantMessage: `unknown field "tenantowner"`,
+		},
+		{
+			name:        "wrong type",
+			data:        validJSON(t, func(fields map[string]interface{}) { fields["breakglass"] = "yes" }),
+			wantDecode:  true,
+			wantMessage: "breakglass",
+		},
+		{
+			name:        "trailing data",
+			data:        append(validJSON(t, nil), []byte(`{}`)...),
+			wantDecode:  true,
+			wantMessage: "unexpected data after instructions object",
+		},
+		{
+			name:      "constraint violation",
+			data:      validJSON(t, func(fields map[string]interface{}) { fields["tenantName"] = "Not Valid" }),
+			wantField: "tenantName",
+		},
+		{
+			name:      "missing required field",
+			data:      validJSON(t, func(fields map[string]interface{}) { delete(fields, "tenantOwner") }),
+			wantField: "tenantOwner",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRawInstructions(tt.data)
+			if tt.wantDecode {
+				if !errors.Is(err, ErrDecode) {
+					t.Fatalf("ValidateRawInstructions error = %v, want ErrDecode", err)
+				}
+				var validationErr ValidationError
+				if errors.As(err, &validationErr) {
+					t.Errorf("decode failure %v is a ValidationError", err)
+				}
+			} else {
+				wantFieldError(t, err, tt.wantField)
+			}
+			if tt.wantMessage != "" && !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestValidateNDJSON(t *testing.T) {
+	valid := string(validJSON(t, nil))
+	invalidTenant := string(validJSON(t, func(fields map[string]interface{}) { fields["tenantName"] = "Not Valid" }))
+
+	type want struct {
+		line int
+		// decode is whether the error matches ErrDecode
+		decode bool
+		// field is the field of the ValidationError, empty for a valid line
+		field string
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  []want
+	}{
+		{name: "empty"},
+		{name: "valid lines", input: valid + "\n" + valid + "\n", want: []want{{line: 1}, {line: 2}}},
+		{name: "no trailing newline", input: valid, want: []want{{line: 1}}},
+		{
+			name:  "mixed lines",
+			input: valid + "\n\n" + `{"tenantName": ` + "\n" + invalidTenant + "\n  \n" + valid + "\n",
+			want:  []want{{line: 1}, {line: 3, decode: true}, {line: 4, field: "tenantName"}, {line: 6}},
+		},
+		{
+			name:  "read failure",
+			input: valid + "\n" + `{"tenantName": "` + strings.Repeat("a", maxNDJSONLineBytes) + `"}` + "\n",
+			want:  []want{{line: 1}, {line: 2, decode: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := ValidateNDJSON(context.Background(), strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ValidateNDJSON: %v", err)
+			}
+
+			var got []ValidationResult
+			for result := range results {
+				got = append(got, result)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("%d results, want %d", len(got), len(tt.want))
+			}
+			for i, w := range tt.want {
+				result := got[i]
+				if result.Line != w.line {
+					t.Errorf("result %d on line %d, want line %d", i, result.Line, w.line)
+				}
+				if w.decode || w.field != "" {
+					// Errors carry the line for context
+					if result.Err == nil || !strings.HasPrefix(result.Err.Error(), fmt.Sprintf("line %d: ", w.line)) {
+						t.Errorf("line %d error = %v, want one naming the line", w.line, result.Err)
+					}
+				}
+				if w.decode {
+					if err := result.Err; err == nil || (!errors.Is(err, ErrDecode) && !strings.Contains(err.Error(), "read:")) {
+						t.Errorf("line %d error = %v, want a decode or read failure", w.line, err)
+					}
+					continue
+				}
+				wantFieldError(t, result.Err, w.field)
+				if result.Instructions == nil || result.Instructions.TenantName == "" {
+					t.Errorf("line %d has no decoded instructions", w.line)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateNDJSONCancel(t *testing.T) {
+	var input bytes.Buffer
+	for i := 0; i < 100; i++ {
+		input.Write(validJSON(t, nil))
+		input.WriteByte('\n')
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, err := ValidateNDJSON(ctx, &input)
+	if err != nil {
+		t.Fatalf("ValidateNDJSON: %v", err)
+	}
+	<-results
+
+	// The reader stops once the consumer cancels, rather than blocking on results
+	cancel()
+	timeout := time.After(time.Second)
+	received := 0
+	for {
+		select {
+		case _, ok := <-results:
+			if !ok {
+				if received > 1 {
+					t.Errorf("received %d results after cancel", received)
+				}
+				return
+			}
+			received++
+		case <-timeout:
+			t.Fatal("results not closed after cancel")
+		}
+	}
+}
+
+func TestValidateNDJSONNilReader(t *testing.T) {
+	if _, err := ValidateNDJSON(context.Background(), nil); err == nil {
+		t.Error("ValidateNDJSON accepted a nil reader")
+	}
+}
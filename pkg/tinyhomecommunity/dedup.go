@@ -0,0 +1,97 @@
+package tinyhomecommunity
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultDedupCacheSize is the most recent publishes remembered for DedupTTL when
+// DedupCacheSize is not set
+const DefaultDedupCacheSize = 1024
+
+// dedupCache remembers the results of recent publishes by dedupKey, so an identical
+// publish within the TTL returns the earlier result instead of publishing again
+type dedupCache struct {
+	mu      sync.Mutex
+	entries map[string]dedupEntry
+}
+
+// dedupEntry is a remembered result and when it was published
+type dedupEntry struct {
+	result PublishResult
+	at     time.Time
+}
+
+// dedupKey identifies identical publishes: the same idempotencyKey, by default the
+// InstructionHash, routed to the same stage on the same topic. The stage is part of
+// the key so instructions moving on to their next stage are not mistaken for a repeat.
+func (prepared *preparedMessage) dedupKey() string {
+	return prepared.topicID + "\x00" + prepared.subscription + "\x00" + prepared.attributes["idempotencyKey"]
+}
+
+// recentPublish returns the result published for prepared within DedupTTL, flagged
+// Deduplicated, when DedupTTL is set
+func (p *Publisher) recentPublish(prepared *preparedMessage) (*PublishResult, bool) {
+	if p.cfg.DedupTTL == 0 {
+		return nil, false
+	}
+
+	c := &p.dedup
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[prepared.dedupKey()]
+	if !ok || p.cfg.now().Sub(entry.at) >= p.cfg.DedupTTL {
+		return nil, false
+	}
+	result := entry.result.clone()
+	result.Deduplicated = true
+	return &result, true
+}
+
+// rememberPublish records result as published for prepared now, when DedupTTL is set.
+// A full cache drops its expired entries, and then its oldest, to make room.
+func (p *Publisher) rememberPublish(prepared *preparedMessage, result *PublishResult) {
+	if p.cfg.DedupTTL == 0 {
+		return
+	}
+
+	size := p.cfg.DedupCacheSize
+	if size == 0 {
+		size = DefaultDedupCacheSize
+	}
+	now := p.cfg.now()
+
+	c := &p.dedup
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]dedupEntry{}
+	}
+
+	key := prepared.dedupKey()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= size {
+		oldestKey, oldest := "", now
+		for k, entry := range c.entries {
+			if now.Sub(entry.at) >= p.cfg.DedupTTL {
+				delete(c.entries, k)
+				continue
+			}
+			if !entry.at.After(oldest) {
+				oldestKey, oldest = k, entry.at
+			}
+		}
+		if len(c.entries) >= size {
+			delete(c.entries, oldestKey)
+		}
+	}
+	c.entries[key] = dedupEntry{result: result.clone(), at: now}
+}
+
+// clone returns a copy of res that shares no maps or slices with it
+func (res PublishResult) clone() PublishResult {
+	res.Attributes = maps.Clone(res.Attributes)
+	res.Warnings = slices.Clone(res.Warnings)
+	return res
+}
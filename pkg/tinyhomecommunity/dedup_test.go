@@ -0,0 +1,219 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// testClock is a clock tests move forward by hand
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestDedup(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		// elapsed is how long after the first publish the second is made
+		elapsed time.Duration
+		// first and second change the two publishes, which are otherwise identical
+		first            func(m *TinyHomeInstructions, attrs *TinyHomeMessageAttributes)
+		second           func(m *TinyHomeInstructions, attrs *TinyHomeMessageAttributes)
+		wantDeduplicated bool
+	}{
+		{
+			name:             "identical within the TTL",
+			opts:             []Option{WithDedupTTL(time.Minute)},
+			elapsed:          59 * time.Second,
+			wantDeduplicated: true,
+		},
+		{
+			name:    "identical once the TTL expired",
+			opts:    []Option{WithDedupTTL(time.Minute)},
+			elapsed: time.Minute,
+		},
+		{
+			name: "identical with no TTL",
+		},
+		{
+			name:    "different instructions",
+			opts:    []Option{WithDedupTTL(time.Minute)},
+			elapsed: time.Second,
+			second:  func(m *TinyHomeInstructions, attrs *TinyHomeMessageAttributes) { m.BusinessUnit = "finance" },
+		},
+		{
+			name:    "same instructions at the next stage",
+			opts:    []Option{WithDedupTTL(time.Minute)},
+			elapsed: time.Second,
+			second: func(m *TinyHomeInstructions, attrs *TinyHomeMessageAttributes) {
+				attrs.GroupsCreated = "true"
+			},
+		},
+		{
+			name:    "different idempotency key",
+			opts:    []Option{WithDedupTTL(time.Minute)},
+			elapsed: time.Second,
+			second: func(m *TinyHomeInstructions, attrs *TinyHomeMessageAttributes) {
+				attrs.IdempotencyKey = "retry-2"
+			},
+		},
+		{
+			name:    "same idempotency key for different instructions",
+			opts:    []Option{WithDedupTTL(time.Minute)},
+			elapsed: time.Second,
+			first: func(m *TinyHomeInstructions, attrs *TinyHomeMessageAttributes) {
+				attrs.IdempotencyKey = "key-1"
+			},
+			second: func(m *TinyHomeInstructions, attrs *TinyHomeMessageAttributes) {
+				m.BusinessUnit = "finance"
+				attrs.IdempotencyKey = "key-1"
+			},
+			wantDeduplicated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &testClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, append([]Option{WithClock(clock.Now)}, tt.opts...)...)
+
+			message, attrs := validInstructions(), validAttributes()
+			if tt.first != nil {
+				tt.first(&message, attrs)
+			}
+			first, err := p.PublishWithResult(context.Background(), &message, attrs)
+			if err != nil {
+				t.Fatalf("first PublishWithResult: %v", err)
+			}
+			if first.Deduplicated {
+				t.Error("first PublishWithResult Deduplicated = true, want false")
+			}
+
+			clock.advance(tt.elapsed)
+			message, attrs = validInstructions(), validAttributes()
+			if tt.second != nil {
+				tt.second(&message, attrs)
+			}
+			second, err := p.PublishWithResult(context.Background(), &message, attrs)
+			if err != nil {
+				t.Fatalf("second PublishWithResult: %v", err)
+			}
+
+			if second.Deduplicated != tt.wantDeduplicated {
+				t.Errorf("second PublishWithResult Deduplicated = %t, want %t", second.Deduplicated, tt.wantDeduplicated)
+			}
+			wantPublished := 2
+			if tt.wantDeduplicated {
+				wantPublished = 1
+				if second.MessageID != first.MessageID || !second.PublishedAt.Equal(first.PublishedAt) {
+					t.Errorf("deduplicated result MessageID %s published at %v, want the first result's %s at %v", second.MessageID, second.PublishedAt, first.MessageID, first.PublishedAt)
+				}
+			}
+			if got := len(topic.published()); got != wantPublished {
+				t.Errorf("published %d messages, want %d", got, wantPublished)
+			}
+		})
+	}
+}
+
+func TestDedupFailedPublishNotRemembered(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+		if n == 1 {
+			return fakeResult{err: errPubSub}
+		}
+		return fakeResult{id: "2"}
+	}}
+	p := newTestPublisher(t, topic, nil, WithDedupTTL(time.Minute))
+
+	message := validInstructions()
+	if _, err := p.PublishWithResult(context.Background(), &message, validAttributes()); !errors.Is(err, errPubSub) {
+		t.Fatalf("first PublishWithResult error = %v, want %v", err, errPubSub)
+	}
+	message = validInstructions()
+	result, err := p.PublishWithResult(context.Background(), &message, validAttributes())
+	if err != nil {
+		t.Fatalf("second PublishWithResult: %v", err)
+	}
+	if result.Deduplicated || result.MessageID != "2" {
+		t.Errorf("second PublishWithResult = %s, Deduplicated %t, want a new publish as 2", result.MessageID, result.Deduplicated)
+	}
+}
+
+func TestDedupCacheSize(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	topic := &fakeTopic{}
+	p := newTestPublisher(t, topic, nil, WithClock(clock.Now), WithDedupTTL(time.Hour), WithDedupCacheSize(2))
+
+	publish := func(unit string) *PublishResult {
+		t.Helper()
+		message := validInstructions()
+		message.BusinessUnit = unit
+		result, err := p.PublishWithResult(context.Background(), &message, validAttributes())
+		if err != nil {
+			t.Fatalf("PublishWithResult for %s: %v", unit, err)
+		}
+		clock.advance(time.Second)
+		return result
+	}
+
+	// A third message forgets the oldest of the two remembered
+	for _, unit := range []string{"platform", "finance", "sales"} {
+		publish(unit)
+	}
+	for _, tc := range []struct {
+		unit             string
+		wantDeduplicated bool
+	}{
+		{"sales", true},
+		{"finance", true},
+		{"platform", false},
+	} {
+		if got := publish(tc.unit).Deduplicated; got != tc.wantDeduplicated {
+			t.Errorf("publish of %s Deduplicated = %t, want %t", tc.unit, got, tc.wantDeduplicated)
+		}
+	}
+	if got := len(topic.published()); got != 4 {
+		t.Errorf("published %d messages, want 4", got)
+	}
+}
+
+func TestDedupConfig(t *testing.T) {
+	tests := []struct {
+		opts    []Option
+		wantErr bool
+	}{
+		{opts: []Option{WithDedupTTL(time.Minute), WithDedupCacheSize(10)}},
+		{opts: []Option{WithDedupTTL(-time.Minute)}, wantErr: true},
+		{opts: []Option{WithDedupTTL(time.Minute), WithDedupCacheSize(-1)}, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			_, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newPublisherWithTopic error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
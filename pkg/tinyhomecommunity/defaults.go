@@ -0,0 +1,23 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+)
+
+// PublishWithDefaults fills the zero-valued fields of message with the
+// EnvironmentDefaults of its Environment, such as quota, region and roles, then
+// validates and publishes it like PublishWithResult. Explicit fields always win, see
+// ApplyTemplate. The resolved instructions are returned even when the publish fails,
+// so callers can see what was sent or rejected.
+func (p *Publisher) PublishWithDefaults(ctx context.Context, message TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes) (TinyHomeInstructions, *PublishResult, error) {
+	if defaults, ok := p.cfg.EnvironmentDefaults[message.Environment]; ok {
+		message = message.ApplyTemplate(defaults)
+	}
+
+	result, err := p.publish(ctx, &message, messageAttributes)
+	if err != nil {
+		return message, nil, fmt.Errorf("PublishWithDefaults: %w", err)
+	}
+	return message, result, nil
+}
@@ -0,0 +1,128 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// minimalInstructions returns instructions for environment with only the fields
+// specific to the tenant set
+func minimalInstructions(environment string) TinyHomeInstructions {
+	var message TinyHomeInstructions
+	message.TenantName = "contract-tenant"
+	message.Environment = environment
+	message.BusinessUnit = "platform"
+	message.TenantOwner = "owner@example.com"
+	message.TenantCostCenter = "1234"
+	message.Organization = "123456789012"
+	return message
+}
+
+func TestPublishWithDefaults(t *testing.T) {
+	var dev, prod TinyHomeInstructions
+	dev.Region = "us-central1"
+	dev.NsQuota.Requests.Cpu, dev.NsQuota.Requests.Memory = "500m", "512Mi"
+	dev.NsQuota.Limits.Cpu, dev.NsQuota.Limits.Memory = "1", "1Gi"
+	dev.AddlGkeTenantSaRoles = []string{"roles/logging.logWriter"}
+	prod.Region = "us-east1"
+	prod.NsQuota.Requests.Cpu, prod.NsQuota.Requests.Memory = "2", "4Gi"
+	prod.NsQuota.Limits.Cpu, prod.NsQuota.Limits.Memory = "4", "8Gi"
+	prod.AddlGkeTenantSaRoles = []string{"roles/logging.logWriter", "roles/monitoring.metricWriter"}
+	defaults := map[string]TinyHomeInstructions{"dev": dev, "prod": prod}
+
+	tests := []struct {
+		name    string
+		message TinyHomeInstructions
+		// want returns the resolved instructions expected from the message
+		want    func(m TinyHomeInstructions) TinyHomeInstructions
+		wantErr bool
+	}{
+		{
+			name:    "dev defaults",
+			message: minimalInstructions("dev"),
+			want:    func(m TinyHomeInstructions) TinyHomeInstructions { return m.ApplyTemplate(dev) },
+		},
+		{
+			name:    "prod defaults",
+			message: minimalInstructions("prod"),
+			want:    func(m TinyHomeInstructions) TinyHomeInstructions { return m.ApplyTemplate(prod) },
+		},
+		{
+			name: "explicit fields win",
+			message: func() TinyHomeInstructions {
+				m := minimalInstructions("prod")
+				m.Region = "europe-west1"
+				m.NsQuota.Limits.Cpu = "8"
+				m.AddlGkeTenantSaRoles = []string{"roles/storage.objectViewer"}
+				return m
+			}(),
+			want: func(m TinyHomeInstructions) TinyHomeInstructions {
+				m.NsQuota.Requests = prod.NsQuota.Requests
+				m.NsQuota.Limits.Memory = prod.NsQuota.Limits.Memory
+				return m
+			},
+		},
+		{
+			name:    "environment without defaults",
+			message: minimalInstructions("test"),
+			want:    func(m TinyHomeInstructions) TinyHomeInstructions { return m },
+		},
+		{
+			name: "defaults still validated",
+			message: func() TinyHomeInstructions {
+				m := minimalInstructions("dev")
+				m.NsQuota.Limits.Cpu = "100m"
+				return m
+			}(),
+			want: func(m TinyHomeInstructions) TinyHomeInstructions {
+				cpu := m.NsQuota.Limits.Cpu
+				m = m.ApplyTemplate(dev)
+				m.NsQuota.Limits.Cpu = cpu
+				return m
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithEnvironmentDefaults(defaults))
+			want := tt.want(tt.message)
+
+			resolved, result, err := p.PublishWithDefaults(context.Background(), tt.message, validAttributes())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("PublishWithDefaults accepted invalid resolved instructions")
+				}
+				if got := len(topic.published()); got != 0 {
+					t.Errorf("published %d messages, want none", got)
+				}
+			} else if err != nil {
+				t.Fatalf("PublishWithDefaults: %v", err)
+			} else if result.TenantName != want.TenantName {
+				t.Errorf("result TenantName = %q, want %q", result.TenantName, want.TenantName)
+			}
+
+			// The resolved instructions are returned either way
+			if resolved.Region != want.Region || resolved.NsQuota != want.NsQuota || !slices.Equal(resolved.AddlGkeTenantSaRoles, want.AddlGkeTenantSaRoles) {
+				t.Errorf("resolved %+v, want %+v", resolved, want)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// and are what was published
+			var body TinyHomeInstructions
+			if err := json.Unmarshal(topic.published()[0].Data, &body); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(body, resolved) {
+				t.Errorf("published %+v, want the resolved %+v", body, resolved)
+			}
+		})
+	}
+}
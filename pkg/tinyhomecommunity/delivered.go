@@ -0,0 +1,27 @@
+package tinyhomecommunity
+
+import "context"
+
+// delivered hands result to OnDelivered, when set, on its own goroutine so a slow
+// callback does not hold up the publish. Flush waits for it.
+func (p *Publisher) delivered(ctx context.Context, result *PublishResult) {
+	if p.cfg.OnDelivered == nil {
+		return
+	}
+
+	res := *result
+	p.settling.Add(1)
+	go func() {
+		defer p.settling.Done()
+		defer p.recoverCallback(ctx, "OnDelivered")
+		p.cfg.OnDelivered(res)
+	}()
+}
+
+// recoverCallback logs a panic in the named callback rather than let it crash the
+// process, it must be deferred directly
+func (p *Publisher) recoverCallback(ctx context.Context, name string) {
+	if r := recover(); r != nil {
+		p.logger().ErrorContext(ctx, "publish callback panicked", "callback", name, "panic", r)
+	}
+}
@@ -0,0 +1,175 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestOnDelivered(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	tests := []struct {
+		name string
+		// publish publishes validInstructions for tenant with p, returning the
+		// message ID the caller sees
+		publish func(p *Publisher, tenant string) (string, error)
+		result  func(n int, msg *pubsub.Message) publishResult
+	}{
+		{
+			name: "PublishContext",
+			publish: func(p *Publisher, tenant string) (string, error) {
+				message := validInstructions()
+				message.TenantName = tenant
+				return p.PublishContext(context.Background(), &message, validAttributes())
+			},
+		},
+		{
+			name: "PublishAsync",
+			publish: func(p *Publisher, tenant string) (string, error) {
+				message := validInstructions()
+				message.TenantName = tenant
+				pending, err := p.PublishAsync(context.Background(), &message, validAttributes())
+				if err != nil {
+					return "", err
+				}
+				res, err := pending.Get(context.Background())
+				if err != nil {
+					return "", err
+				}
+				return res.MessageID, nil
+			},
+		},
+		{
+			name: "PublishBatch",
+			publish: func(p *Publisher, tenant string) (string, error) {
+				message := validInstructions()
+				message.TenantName = tenant
+				results, err := p.PublishBatch(context.Background(), []*TinyHomeInstructions{&message}, []*TinyHomeMessageAttributes{validAttributes()})
+				if err != nil {
+					return "", err
+				}
+				return results[0].MessageID, nil
+			},
+		},
+		{
+			name: "publish error",
+			publish: func(p *Publisher, tenant string) (string, error) {
+				message := validInstructions()
+				message.TenantName = tenant
+				return p.PublishContext(context.Background(), &message, validAttributes())
+			},
+			result: func(n int, msg *pubsub.Message) publishResult { return fakeResult{err: errPubSub} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu        sync.Mutex
+				delivered []PublishResult
+			)
+			p := newTestPublisher(t, &fakeTopic{result: tt.result}, nil, WithOnDelivered(func(res PublishResult) {
+				mu.Lock()
+				defer mu.Unlock()
+				delivered = append(delivered, res)
+			}))
+
+			id, err := tt.publish(p, "delivered-tenant")
+			if flushErr := p.Flush(context.Background()); flushErr != nil {
+				t.Fatalf("Flush: %v", flushErr)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.result != nil {
+				if err == nil {
+					t.Fatal("publish succeeded, want an error")
+				}
+				if len(delivered) != 0 {
+					t.Errorf("OnDelivered called with %+v for a failed publish", delivered)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+
+			if len(delivered) != 1 {
+				t.Fatalf("OnDelivered called %d times, want 1", len(delivered))
+			}
+			res := delivered[0]
+			if res.MessageID != id || res.TenantName != "delivered-tenant" || res.Subscription != "createGroups" {
+				t.Errorf("OnDelivered result MessageID, TenantName, Subscription = %q, %q, %q, want %q, delivered-tenant, createGroups", res.MessageID, res.TenantName, res.Subscription, id)
+			}
+		})
+	}
+}
+
+func TestOnDeliveredOffPublishPath(t *testing.T) {
+	release := make(chan struct{})
+	called := make(chan PublishResult, 1)
+	p := newTestPublisher(t, &fakeTopic{}, nil, WithOnDelivered(func(res PublishResult) {
+		called <- res
+		<-release
+	}))
+
+	// The publish returns while the callback is still blocked
+	message := validInstructions()
+	returned := make(chan error, 1)
+	go func() {
+		_, err := p.PublishContext(context.Background(), &message, validAttributes())
+		returned <- err
+	}()
+	select {
+	case err := <-returned:
+		if err != nil {
+			t.Fatalf("PublishContext: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("PublishContext blocked on OnDelivered")
+	}
+	<-called
+
+	// Flush waits for the callback to return
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Flush with the callback blocked = %v, want context.DeadlineExceeded", err)
+	}
+	close(release)
+	if err := p.Flush(context.Background()); err != nil {
+		t.Errorf("Flush: %v", err)
+	}
+}
+
+func TestOnDeliveredPanicRecovered(t *testing.T) {
+	var logs bytes.Buffer
+	p := newTestPublisher(t, &fakeTopic{}, nil,
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithOnDelivered(func(PublishResult) { panic("callback failed") }),
+	)
+
+	for i := 0; i < 2; i++ {
+		message := validInstructions()
+		if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+			t.Fatalf("PublishContext %d: %v", i, err)
+		}
+	}
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if got := strings.Count(logs.String(), "publish callback panicked"); got != 2 {
+		t.Errorf("logged %d panics, want 2:\n%s", got, logs.String())
+	}
+	if !strings.Contains(logs.String(), "callback=OnDelivered") || !strings.Contains(logs.String(), `panic="callback failed"`) {
+		t.Errorf("panic log does not name the callback and panic:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,51 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Pub/Sub limits on a subscription's dead-letter max delivery attempts
+const (
+	minDeliveryAttempts = 5
+	maxDeliveryAttempts = 100
+)
+
+// topicNamePattern is a full Pub/Sub topic name, projects/{project}/topics/{topic}
+var topicNamePattern = regexp.MustCompile(`^projects/[a-z][-a-z0-9]{4,28}[a-z0-9]/topics/[A-Za-z][-A-Za-z0-9_.~+%]{2,254}$`)
+
+// DeliveryPolicy describes the retry and dead-letter behavior subscribers should use
+// for a message. It is published as the maxDeliveryAttempts and deadLetterTopic
+// attributes so subscriptions, or tooling that reconciles them, can be configured
+// from the message.
+type DeliveryPolicy struct {
+	// MaxDeliveryAttempts is how often delivery is attempted before the message is
+	// dead-lettered, between 5 and 100 as Pub/Sub allows
+	MaxDeliveryAttempts int
+	// DeadLetterTopic is the full name of the dead-letter topic,
+	// projects/{project}/topics/{topic}
+	DeadLetterTopic string
+}
+
+// validate checks the policy is one a Pub/Sub subscription accepts
+func (d DeliveryPolicy) validate() error {
+	if d.MaxDeliveryAttempts < minDeliveryAttempts || d.MaxDeliveryAttempts > maxDeliveryAttempts {
+		return fmt.Errorf("max delivery attempts must be between %d and %d, got %d", minDeliveryAttempts, maxDeliveryAttempts, d.MaxDeliveryAttempts)
+	}
+
+	topic := d.DeadLetterTopic[strings.LastIndex(d.DeadLetterTopic, "/")+1:]
+	if !topicNamePattern.MatchString(d.DeadLetterTopic) || strings.HasPrefix(topic, "goog") {
+		return fmt.Errorf("dead-letter topic %q is not a topic name of the form projects/{project}/topics/{topic}", d.DeadLetterTopic)
+	}
+	return nil
+}
+
+// attributes returns the policy as message attributes
+func (d DeliveryPolicy) attributes() map[string]string {
+	return map[string]string{
+		"maxDeliveryAttempts": strconv.Itoa(d.MaxDeliveryAttempts),
+		"deadLetterTopic":     d.DeadLetterTopic,
+	}
+}
@@ -0,0 +1,70 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDeliveryPolicy(t *testing.T) {
+	const deadLetterTopic = "projects/tenant-project/topics/tiny-home-dead-letter"
+
+	tests := []struct {
+		name    string
+		policy  *DeliveryPolicy
+		wantErr bool
+	}{
+		{name: "published", policy: &DeliveryPolicy{MaxDeliveryAttempts: 10, DeadLetterTopic: deadLetterTopic}},
+		{name: "fewest attempts", policy: &DeliveryPolicy{MaxDeliveryAttempts: 5, DeadLetterTopic: deadLetterTopic}},
+		{name: "most attempts", policy: &DeliveryPolicy{MaxDeliveryAttempts: 100, DeadLetterTopic: deadLetterTopic}},
+		{name: "no policy"},
+		{name: "too few attempts", policy: &DeliveryPolicy{MaxDeliveryAttempts: 4, DeadLetterTopic: deadLetterTopic}, wantErr: true},
+		{name: "no attempts", policy: &DeliveryPolicy{DeadLetterTopic: deadLetterTopic}, wantErr: true},
+		{name: "too many attempts", policy: &DeliveryPolicy{MaxDeliveryAttempts: 101, DeadLetterTopic: deadLetterTopic}, wantErr: true},
+		{name: "bare topic ID", policy: &DeliveryPolicy{MaxDeliveryAttempts: 10, DeadLetterTopic: "tiny-home-dead-letter"}, wantErr: true},
+		{name: "invalid project", policy: &DeliveryPolicy{MaxDeliveryAttempts: 10, DeadLetterTopic: "projects/Tenant/topics/dead-letter"}, wantErr: true},
+		{name: "reserved topic", policy: &DeliveryPolicy{MaxDeliveryAttempts: 10, DeadLetterTopic: "projects/tenant-project/topics/goog-dead-letter"}, wantErr: true},
+		{name: "no topic", policy: &DeliveryPolicy{MaxDeliveryAttempts: 10}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.policy != nil {
+				opts = append(opts, WithDeliveryPolicy(*tt.policy))
+			}
+			topic := &fakeTopic{}
+			p, err := newPublisherWithTopic(topic, DefaultPublisherConfig(), opts...)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "publisher config") {
+					t.Fatalf("newPublisherWithTopic error = %v, want a config error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newPublisherWithTopic: %v", err)
+			}
+
+			message := validInstructions()
+			if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+			attributes := topic.published()[0].Attributes
+			if tt.policy == nil {
+				for _, key := range []string{"maxDeliveryAttempts", "deadLetterTopic"} {
+					if v, ok := attributes[key]; ok {
+						t.Errorf("%s attribute = %q without a policy", key, v)
+					}
+				}
+				return
+			}
+			if got, want := attributes["maxDeliveryAttempts"], fmt.Sprint(tt.policy.MaxDeliveryAttempts); got != want {
+				t.Errorf("maxDeliveryAttempts attribute = %q, want %q", got, want)
+			}
+			if got := attributes["deadLetterTopic"]; got != tt.policy.DeadLetterTopic {
+				t.Errorf("deadLetterTopic attribute = %q, want %q", got, tt.policy.DeadLetterTopic)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// ValidateDNS1123Label checks TenantName is a strict DNS-1123 label: at most 63
+// characters of lower case letters, digits and hyphens that starts and ends with a
+// letter or digit. This is stricter than validateInstructions about the first and last
+// characters and is meant for tenants whose name is used directly as a resource name.
+func (message TinyHomeInstructions) ValidateDNS1123Label() error {
+	if err := validateDNS1123Label(message.TenantName); err != nil {
+		return &NamingError{Field: "tenantName", Message: fmt.Sprintf("tenantName %v", err)}
+	}
+	return nil
+}
+
+// validateDNS1123TenantName applies ValidateDNS1123Label when required is set
+func (message TinyHomeInstructions) validateDNS1123TenantName(required bool) error {
+	if !required {
+		return nil
+	}
+	return message.ValidateDNS1123Label()
+}
+
+// validateDNS1123Label returns an error naming the specific DNS-1123 rule value breaks
+func validateDNS1123Label(value string) error {
+	if len(value) == 0 {
+		return fmt.Errorf("must not be empty")
+	}
+
+	if len(value) > 63 {
+		return fmt.Errorf("greater than 63 characters")
+	}
+
+	for _, r := range value {
+		if r > unicode.MaxASCII || (!unicode.IsLower(r) && !unicode.IsDigit(r) && r != '-') {
+			return fmt.Errorf("may only contain lower case alphanumeric characters or '-', found %q", r)
+		}
+	}
+
+	if value[0] == '-' {
+		return fmt.Errorf("must start with an alphanumeric character")
+	}
+
+	if value[len(value)-1] == '-' {
+		return fmt.Errorf("must end with an alphanumeric character")
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package tinyhomecommunity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateDNS1123Label(t *testing.T) {
+	tests := []struct {
+		name       string
+		tenantName string
+		wantErr    string
+	}{
+		{"lower case letters", "acme", ""},
+		{"letters digits and hyphens", "acme-42-dev", ""},
+		{"single character", "a", ""},
+		{"leading digit", "42acme", ""},
+		{"63 characters", strings.Repeat("a", 63), ""},
+		{"empty", "", "must not be empty"},
+		{"64 characters", strings.Repeat("a", 64), "greater than 63 characters"},
+		{"upper case", "Acme", "found 'A'"},
+		{"underscore", "acme_dev", "found '_'"},
+		{"dot", "acme.dev", "found '.'"},
+		{"non ascii", "acmé", "found 'é'"},
+		{"leading hyphen", "-acme", "must start with an alphanumeric character"},
+		{"trailing hyphen", "acme-", "must end with an alphanumeric character"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := TinyHomeInstructions{TenantName: tt.tenantName}.ValidateDNS1123Label()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateDNS1123Label: %v", err)
+				}
+				return
+			}
+
+			var namingErr *NamingError
+			if !errors.As(err, &namingErr) || namingErr.Field != "tenantName" {
+				t.Fatalf("ValidateDNS1123Label error = %v, want a NamingError for tenantName", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateDNS1123Label error = %q, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithDNS1123TenantName(t *testing.T) {
+	// A name the length limit allows but a DNS-1123 label does not
+	long := strings.Repeat("a", 64)
+
+	tests := []struct {
+		name    string
+		enabled bool
+		wantErr bool
+	}{
+		{"disabled", false, false},
+		{"enabled", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.TenantName = long
+			_, err := message.DryRun(validAttributes(), WithMaxTenantNameLength(70), WithDNS1123TenantName(tt.enabled))
+			if gotErr := errors.Is(err, ErrInvalidInstructions); gotErr != tt.wantErr {
+				t.Fatalf("DryRun error = %v, want invalid %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// DryRun runs the same validation as PublishTinyHomeInstructions with the same opts
+// and returns the routing the message would get, without creating a Pub/Sub client or
+// publishing anything. The returned PublishResult has no MessageID. Like a publish it
+// fills in a generated TenantName and default Region on the instructions.
+func (message *TinyHomeInstructions) DryRun(messageAttributes *TinyHomeMessageAttributes, opts ...Option) (*PublishResult, error) {
+	result, _, err := message.DryRunData(messageAttributes, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DryRunData is DryRun also returning the message body exactly as a publish with the
+// same opts would send it, compacted, wrapped in a CloudEvents envelope or compressed
+// as configured
+func (message *TinyHomeInstructions) DryRunData(messageAttributes *TinyHomeMessageAttributes, opts ...Option) (*PublishResult, []byte, error) {
+	cfg, err := newPublisherConfig(opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("DryRun: %v", err)
+	}
+
+	p := &Publisher{cfg: cfg}
+	prepared, err := p.prepare(context.Background(), message, messageAttributes, &PublishLogLine{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("DryRun: %w", err)
+	}
+
+	return prepared.result(""), prepared.data, nil
+}
+
+// Preflight runs the same validation as PublishTinyHomeInstructions with the same opts
+// and returns the delivery text, such as "message will be delivered to subscription:
+// createTenant", without creating a Pub/Sub client or publishing anything. Unlike
+// DryRun it validates a copy, leaving msg and attrs untouched.
+func Preflight(msg *TinyHomeInstructions, attrs *TinyHomeMessageAttributes, opts ...Option) (string, error) {
+	cfg, err := newPublisherConfig(opts...)
+	if err != nil {
+		return "", fmt.Errorf("Preflight: %v", err)
+	}
+
+	// Generated names and defaults land on a copy
+	message, err := msg.clone()
+	if err != nil {
+		return "", fmt.Errorf("Preflight: %v", err)
+	}
+
+	p := &Publisher{cfg: cfg}
+	prepared, err := p.prepare(context.Background(), message, attrs, &PublishLogLine{})
+	if err != nil {
+		return "", fmt.Errorf("Preflight: %w", err)
+	}
+	return prepared.attrMessage, nil
+}
+
+// clone returns a deep copy of the instructions, made by a round trip through JSON
+func (message *TinyHomeInstructions) clone() (*TinyHomeInstructions, error) {
+	b, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	var copied TinyHomeInstructions
+	if err := json.Unmarshal(b, &copied); err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
@@ -0,0 +1,108 @@
+package tinyhomecommunity
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		modify     func(*TinyHomeInstructions)
+		attributes *TinyHomeMessageAttributes
+		want       string
+		// wantField is the field of the ValidationError found, empty when the
+		// instructions pass
+		wantField string
+	}{
+		{
+			name: "delivery text",
+			want: "message will be delivered to subscription: createGroups",
+		},
+		{
+			name:       "delivery text for a later stage",
+			attributes: &TinyHomeMessageAttributes{GroupsCreated: "true", WorkspaceCreated: "true", TenantCreated: "false", FluxCreated: "false", DeliveredFrom: "manual"},
+			want:       "message will be delivered to subscription: createTenant",
+		},
+		{
+			name:   "generated name left off the instructions",
+			opts:   []Option{WithNameGenerator(NameGeneratorFunc(func(TinyHomeInstructions) (string, error) { return "generated-tenant", nil }))},
+			modify: func(m *TinyHomeInstructions) { m.TenantName = "" },
+			want:   "message will be delivered to subscription: createGroups",
+		},
+		{
+			name:   "defaults left off the instructions",
+			opts:   []Option{WithRegionDefaults(map[string]string{"dev": "us-east1"}), WithDefaultTenantCostCenter("1234")},
+			modify: func(m *TinyHomeInstructions) { m.Region = ""; m.TenantCostCenter = "" },
+			want:   "message will be delivered to subscription: createGroups",
+		},
+		{
+			name:   "trimming and normalizing left off the instructions",
+			opts:   []Option{WithTrimWhitespace(true), WithTenantNameNormalization(true)},
+			modify: func(m *TinyHomeInstructions) { m.TenantName = " Contract-Tenant\n" },
+			want:   "message will be delivered to subscription: createGroups",
+		},
+		{
+			name:      "invalid tenant name found",
+			modify:    func(m *TinyHomeInstructions) { m.TenantName = "Not Valid" },
+			wantField: "tenantName",
+		},
+		{
+			name:       "invalid attributes found",
+			attributes: &TinyHomeMessageAttributes{GroupsCreated: "false", WorkspaceCreated: "true", TenantCreated: "false", FluxCreated: "false", DeliveredFrom: "galaxy"},
+			wantField:  AttrWorkspaceCreated,
+		},
+		{
+			name:      "missing owner found",
+			modify:    func(m *TinyHomeInstructions) { m.TenantOwner = "" },
+			wantField: "tenantOwner",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			if tt.modify != nil {
+				tt.modify(&message)
+			}
+			attributes := validAttributes()
+			if tt.attributes != nil {
+				attributes = tt.attributes
+			}
+			wantMessage, wantAttributes := message, *attributes
+			wantMessage.AddlGkeTenantSaRoles = append([]string(nil), message.AddlGkeTenantSaRoles...)
+
+			got, err := Preflight(&message, attributes, tt.opts...)
+			wantFieldError(t, err, tt.wantField)
+			if tt.wantField == "" && got != tt.want {
+				t.Errorf("Preflight = %q, want %q", got, tt.want)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "Preflight: ") {
+				t.Errorf("Preflight error = %v, want it prefixed with Preflight", err)
+			}
+
+			// Whatever Preflight finds, the caller's values are left as they were
+			if !reflect.DeepEqual(message, wantMessage) {
+				t.Errorf("Preflight changed the instructions to %+v, want %+v", message, wantMessage)
+			}
+			if *attributes != wantAttributes {
+				t.Errorf("Preflight changed the attributes to %+v, want %+v", *attributes, wantAttributes)
+			}
+		})
+	}
+}
+
+func TestPreflightInvalidConfig(t *testing.T) {
+	message := validInstructions()
+	_, err := Preflight(&message, validAttributes(), WithMaxConcurrentPublishes(-1))
+	if err == nil {
+		t.Fatal("Preflight with an invalid config returned no error")
+	}
+	var validationErr ValidationError
+	if errors.As(err, &validationErr) {
+		t.Errorf("Preflight config error = %v, want it apart from the instructions' ValidationErrors", err)
+	}
+}
@@ -0,0 +1,25 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+)
+
+// entryCount returns the total number of entries across every list and map in the
+// instructions
+func (message TinyHomeInstructions) entryCount() int {
+	count := len(message.AddlGkeTenantSaRoles) + len(message.Labels)
+	for _, members := range message.iamBindings() {
+		count += len(members)
+	}
+	return count
+}
+
+// validateEntryCount rejects instructions with more than max list and map entries in
+// total, counting the extraAttributes merged into every message, so one message can
+// not exhaust subscriber resources
+func (message TinyHomeInstructions) validateEntryCount(max int, extraAttributes map[string]string) error {
+	if count := message.entryCount() + len(extraAttributes); count > max {
+		return &QuotaError{Field: "instructions", Message: fmt.Sprintf("instructions have %d list and map entries in total, at most %d are allowed", count, max)}
+	}
+	return nil
+}
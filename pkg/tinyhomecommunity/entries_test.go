@@ -0,0 +1,79 @@
+package tinyhomecommunity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMaxInstructionEntries(t *testing.T) {
+	// labels returns n distinct labels
+	labels := func(n int) map[string]string {
+		l := make(map[string]string, n)
+		for i := 0; i < n; i++ {
+			l[fmt.Sprintf("label-%d", i)] = "value"
+		}
+		return l
+	}
+
+	tests := []struct {
+		name            string
+		mutate          func(m *TinyHomeInstructions)
+		extraAttributes map[string]string
+		// wantTotal is the total reported, zero when within the limit of 6
+		wantTotal int
+	}{
+		{name: "roles and members within the limit", mutate: func(*TinyHomeInstructions) {}},
+		{
+			name:            "at the limit",
+			mutate:          func(m *TinyHomeInstructions) { m.Labels = labels(2) },
+			extraAttributes: map[string]string{"team": "platform", "source": "portal"},
+		},
+		{
+			name:      "labels past the limit",
+			mutate:    func(m *TinyHomeInstructions) { m.Labels = labels(5) },
+			wantTotal: 7,
+		},
+		{
+			name: "roles and members past the limit",
+			mutate: func(m *TinyHomeInstructions) {
+				m.AddlGkeTenantSaRoles = []string{"roles/logging.logWriter", "roles/monitoring.metricWriter", "roles/cloudtrace.agent"}
+				m.AddlGroupIamBindings = map[string][]string{
+					"roles/viewer": {"group:viewers@example.com", "group:auditors@example.com"},
+					"roles/editor": {"group:editors@example.com", "user:dev@example.com"},
+				}
+			},
+			wantTotal: 7,
+		},
+		{
+			name:            "extra attributes past the limit",
+			mutate:          func(m *TinyHomeInstructions) { m.Labels = labels(2) },
+			extraAttributes: additionalAttributes(3),
+			wantTotal:       7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			tt.mutate(&message)
+			_, err := message.DryRun(validAttributes(), WithMaxInstructionEntries(6), WithAdditionalAttributes(tt.extraAttributes))
+			if tt.wantTotal == 0 {
+				if err != nil {
+					t.Fatalf("DryRun: %v", err)
+				}
+				return
+			}
+
+			var quotaErr *QuotaError
+			if !errors.As(err, &quotaErr) || quotaErr.Field != "instructions" {
+				t.Fatalf("DryRun error = %v, want a QuotaError for instructions", err)
+			}
+			// The error reports the total and the limit
+			if want := fmt.Sprintf("instructions have %d list and map entries in total, at most 6 are allowed", tt.wantTotal); !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not mention %q", err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+)
+
+// DefaultEnvironments are the Environment values accepted unless WithEnvironments
+// configures others
+var DefaultEnvironments = []string{"dev", "test", "stage", "prod"}
+
+// validateEnvironment checks Environment is exactly one of allowed. Matching is case
+// sensitive and surrounding whitespace is rejected rather than trimmed.
+func (message TinyHomeInstructions) validateEnvironment(allowed []string) error {
+	if !contains(allowed, message.Environment) {
+		return &NamingError{Field: "environment", Message: fmt.Sprintf("environment %q is not one of: %s", message.Environment, allowed)}
+	}
+	return nil
+}
+
+// validateTopicVersion checks topicVersion is one of the versions allowed lists for
+// Environment, so a schema rolled out to some environments is not published to the
+// others. Environments allowed has no entry for are not checked.
+func (message TinyHomeInstructions) validateTopicVersion(topicVersion string, allowed map[string][]string) error {
+	versions, ok := allowed[message.Environment]
+	if !ok || contains(versions, topicVersion) {
+		return nil
+	}
+	return &PolicyError{Field: "environment", Rule: "environmentTopicVersions", Message: fmt.Sprintf("environment %q does not allow topic version %q, allowed versions are: %s", message.Environment, topicVersion, versions)}
+}
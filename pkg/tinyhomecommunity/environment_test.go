@@ -0,0 +1,58 @@
+package tinyhomecommunity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentTopicVersions(t *testing.T) {
+	// 0.0.2 is rolled out to dev and test only
+	versions := map[string][]string{
+		"dev":  {"0.0.1", "0.0.2"},
+		"test": {"0.0.1", "0.0.2"},
+		"prod": {"0.0.1"},
+	}
+
+	tests := []struct {
+		name         string
+		environment  string
+		topicVersion string
+		wantErr      bool
+	}{
+		{name: "new version in dev", environment: "dev", topicVersion: "0.0.2"},
+		{name: "old version in dev", environment: "dev", topicVersion: "0.0.1"},
+		{name: "old version in prod", environment: "prod", topicVersion: "0.0.1"},
+		{name: "new version in prod", environment: "prod", topicVersion: "0.0.2", wantErr: true},
+		{name: "unknown version in test", environment: "test", topicVersion: "0.1.0", wantErr: true},
+		{name: "environment without an entry", environment: "stage", topicVersion: "0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.Environment = tt.environment
+			_, err := message.DryRun(validAttributes(), WithTopicVersion(tt.topicVersion), WithEnvironmentTopicVersions(versions))
+			if !tt.wantErr {
+				wantFieldError(t, err, "")
+				return
+			}
+			wantFieldError(t, err, "environment")
+			// The error names the environment, topic version and allowed set
+			for _, want := range []string{`environment "` + tt.environment + `"`, `topic version "` + tt.topicVersion + `"`, strings.Join(versions[tt.environment], " ")} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %s", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEnvironmentTopicVersionsValidated(t *testing.T) {
+	versions := map[string][]string{"prod": {"0.0.1"}}
+	if _, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithEnvironmentTopicVersions(versions)); err == nil {
+		t.Error("newPublisherWithTopic accepted EnvironmentTopicVersions without a TopicVersion")
+	}
+	if _, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithEnvironmentTopicVersions(versions), WithTopicVersion("0.0.1")); err != nil {
+		t.Errorf("newPublisherWithTopic: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package tinyhomecommunity
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sentinel errors the validation errors match with errors.Is. Every ValidationError
+// from instructions matches ErrInvalidInstructions and tenant name failures also match
+// ErrInvalidTenantName. Attribute and routing failures match ErrInvalidAttribute.
+var (
+	ErrInvalidInstructions = errors.New("invalid instructions")
+	ErrInvalidTenantName   = errors.New("invalid tenant name")
+	ErrInvalidAttribute    = errors.New("invalid message attribute")
+)
+
+// ErrStageNotImplemented is returned when attributes route to a pipeline stage that
+// has no subscriber yet and publishing to it has not been enabled
+var ErrStageNotImplemented = errors.New("stage not implemented")
+
+// ErrDecode is matched by failures to decode instructions from JSON or YAML, as
+// opposed to decoded instructions that fail validation
+var ErrDecode = errors.New("decode")
+
+// ErrMessageTooLarge is returned when a message, counting its attributes, is over
+// MaxMessageBytes. It is checked before anything is sent to Pub/Sub.
+var ErrMessageTooLarge = errors.New("message too large")
+
+// ErrTopicNotFound and ErrPermissionDenied are matched by HealthCheck failures for a
+// topic that does not exist and for credentials that can not publish to it
+var (
+	ErrTopicNotFound    = errors.New("topic not found")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// ErrOrderingNotSupported is matched by publish failures caused by publishing with
+// MessageOrdering to a topic that does not have message ordering enabled. The
+// TransportError still unwraps to the Pub/Sub error too.
+var ErrOrderingNotSupported = errors.New("message ordering not supported")
+
+// ValidationError is implemented by every error returned when instructions or
+// attributes fail validation, so callers can tell bad input apart from publish
+// failures and handle each category with errors.As
+type ValidationError interface {
+	error
+	// FieldName is the json name of the field or attribute that failed validation
+	FieldName() string
+}
+
+// NamingError reports a tenant or resource name that breaks the naming rules
+type NamingError struct {
+	Field   string
+	Message string
+}
+
+func (e *NamingError) Error() string     { return e.Message }
+func (e *NamingError) FieldName() string { return e.Field }
+func (e *NamingError) Is(target error) bool {
+	return target == ErrInvalidInstructions || (target == ErrInvalidTenantName && e.Field == "tenantName")
+}
+
+// QuotaError reports a namespace quota that is malformed or outside policy
+type QuotaError struct {
+	Field   string
+	Message string
+}
+
+func (e *QuotaError) Error() string        { return e.Message }
+func (e *QuotaError) FieldName() string    { return e.Field }
+func (e *QuotaError) Is(target error) bool { return target == ErrInvalidInstructions }
+
+// IAMError reports an IAM role or binding that is malformed or outside policy
+type IAMError struct {
+	Field   string
+	Message string
+}
+
+func (e *IAMError) Error() string        { return e.Message }
+func (e *IAMError) FieldName() string    { return e.Field }
+func (e *IAMError) Is(target error) bool { return target == ErrInvalidInstructions }
+
+// RoutingError reports message attributes that do not route to a known subscription
+type RoutingError struct {
+	Field   string
+	Message string
+}
+
+func (e *RoutingError) Error() string        { return e.Message }
+func (e *RoutingError) FieldName() string    { return e.Field }
+func (e *RoutingError) Is(target error) bool { return target == ErrInvalidAttribute }
+
+// OwnerError reports an owner or approver identity that is missing or invalid
+type OwnerError struct {
+	Field   string
+	Message string
+}
+
+func (e *OwnerError) Error() string        { return e.Message }
+func (e *OwnerError) FieldName() string    { return e.Field }
+func (e *OwnerError) Is(target error) bool { return target == ErrInvalidInstructions }
+
+// TransportError reports a failure talking to Pub/Sub rather than a problem with the
+// message itself. Unwrap returns the underlying Pub/Sub or gRPC error.
+type TransportError struct {
+	Op  string
+	Err error
+}
+
+func (e *TransportError) Error() string { return e.Op + ": " + e.Err.Error() }
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// ValidationErrors is every validation failure found by ValidateAll, in the order the
+// checks ran. errors.Is and errors.As see each failure through Unwrap.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.FieldName() + ": " + err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// BatchError reports the messages of a batch that failed, keyed by their index in
+// the batch
+type BatchError struct {
+	Failures map[int]error
+}
+
+func (e *BatchError) Error() string {
+	indices := make([]int, 0, len(e.Failures))
+	for i := range e.Failures {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+
+	messages := make([]string, len(indices))
+	for n, i := range indices {
+		messages[n] = fmt.Sprintf("message %d: %v", i, e.Failures[i])
+	}
+	return fmt.Sprintf("%d messages failed: %s", len(indices), strings.Join(messages, "; "))
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, err := range e.Failures {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// DirError reports the files of a PublishDir run that failed, keyed by their path
+type DirError struct {
+	Failures map[string]error
+}
+
+func (e *DirError) Error() string {
+	paths := make([]string, 0, len(e.Failures))
+	for path := range e.Failures {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	messages := make([]string, len(paths))
+	for n, path := range paths {
+		messages[n] = fmt.Sprintf("%s: %v", path, e.Failures[path])
+	}
+	return fmt.Sprintf("%d files failed: %s", len(paths), strings.Join(messages, "; "))
+}
+
+func (e *DirError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, err := range e.Failures {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// errDualPublishUnsupported is returned by the publishes that do not dual-publish
+// when CloudEventsTopicID is set, rather than silently skip the CloudEvents copy
+var errDualPublishUnsupported = errors.New("dual publishing with CloudEventsTopicID is only supported by publishes that wait for a single result")
+
+// DualPublishError reports a dual-published message that failed on its topic, its
+// CloudEventsTopicID copy or both. The ID of a copy that was published is kept, so
+// callers can tell a partial failure apart and reconcile it.
+type DualPublishError struct {
+	MessageID            string
+	Err                  error
+	CloudEventsMessageID string
+	CloudEventsErr       error
+}
+
+func (e *DualPublishError) Error() string {
+	switch {
+	case e.Err != nil && e.CloudEventsErr != nil:
+		return fmt.Sprintf("dual publish failed on both topics: %v; cloudevents: %v", e.Err, e.CloudEventsErr)
+	case e.Err != nil:
+		return fmt.Sprintf("dual publish failed on the native topic, cloudevents copy published as %s: %v", e.CloudEventsMessageID, e.Err)
+	}
+	return fmt.Sprintf("dual publish failed on the cloudevents topic, native message published as %s: %v", e.MessageID, e.CloudEventsErr)
+}
+
+func (e *DualPublishError) Unwrap() []error {
+	var errs []error
+	for _, err := range []error{e.Err, e.CloudEventsErr} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// PolicyError reports instructions rejected by a policy rule, such as one of the
+// PolicyBundle or the break-glass cutoff
+type PolicyError struct {
+	Field   string
+	Rule    string
+	Message string
+}
+
+func (e *PolicyError) Error() string        { return e.Message }
+func (e *PolicyError) FieldName() string    { return e.Field }
+func (e *PolicyError) Is(target error) bool { return target == ErrInvalidInstructions }
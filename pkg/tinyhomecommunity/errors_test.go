@@ -0,0 +1,82 @@
+package tinyhomecommunity
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidationErrorCategories(t *testing.T) {
+	tests := []struct {
+		name      string
+		mutate    func(m *TinyHomeInstructions, a *TinyHomeMessageAttributes)
+		target    interface{}
+		sentinel  error
+		wantField string
+	}{
+		{
+			name:      "naming",
+			mutate:    func(m *TinyHomeInstructions, a *TinyHomeMessageAttributes) { m.TenantName = "-acme" },
+			target:    new(*NamingError),
+			sentinel:  ErrInvalidTenantName,
+			wantField: "tenantName",
+		},
+		{
+			name:      "quota",
+			mutate:    func(m *TinyHomeInstructions, a *TinyHomeMessageAttributes) { m.NsQuota.Requests.Cpu = "lots" },
+			target:    new(*QuotaError),
+			sentinel:  ErrInvalidInstructions,
+			wantField: "nsQuota.requests.cpu",
+		},
+		{
+			name: "iam",
+			mutate: func(m *TinyHomeInstructions, a *TinyHomeMessageAttributes) {
+				m.AddlGkeTenantSaRoles = []string{"logging.logWriter"}
+			},
+			target:    new(*IAMError),
+			sentinel:  ErrInvalidInstructions,
+			wantField: "addlGkeTenantSaRoles[0]",
+		},
+		{
+			name:      "routing",
+			mutate:    func(m *TinyHomeInstructions, a *TinyHomeMessageAttributes) { a.GroupsCreated = "maybe" },
+			target:    new(*RoutingError),
+			sentinel:  ErrInvalidAttribute,
+			wantField: AttrGroupsCreated,
+		},
+		{
+			name:      "owner",
+			mutate:    func(m *TinyHomeInstructions, a *TinyHomeMessageAttributes) { m.TenantOwner = "not an email" },
+			target:    new(*OwnerError),
+			sentinel:  ErrInvalidInstructions,
+			wantField: "tenantOwner",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, messageAttributes := validInstructions(), validAttributes()
+			tt.mutate(&message, messageAttributes)
+			_, err := message.DryRun(messageAttributes)
+
+			if !errors.As(err, tt.target) {
+				t.Fatalf("DryRun error = %v (%T), want %T", err, errors.Unwrap(err), tt.target)
+			}
+			if !errors.Is(err, tt.sentinel) {
+				t.Errorf("DryRun error %v does not match %v", err, tt.sentinel)
+			}
+
+			var validationErr ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("DryRun error %v is not a ValidationError", err)
+			}
+			if validationErr.FieldName() != tt.wantField {
+				t.Errorf("FieldName() = %q, want %q", validationErr.FieldName(), tt.wantField)
+			}
+
+			var transportErr *TransportError
+			if errors.As(err, &transportErr) {
+				t.Errorf("validation error %v is also a TransportError", err)
+			}
+		})
+	}
+}
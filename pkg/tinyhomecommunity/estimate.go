@@ -0,0 +1,53 @@
+package tinyhomecommunity
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// minBilledMessageBytes is the smallest size Pub/Sub bills a published message at
+const minBilledMessageBytes = 1000
+
+// EstimatedBytes estimates the billed size of publishing the instructions with attrs:
+// the marshaled body plus every attribute key and value, rounded up to the Pub/Sub
+// minimum. Multiply by the Pub/Sub throughput price to estimate cost.
+func (message TinyHomeInstructions) EstimatedBytes(attrs *TinyHomeMessageAttributes) (int, error) {
+	byteMessage, err := json.Marshal(&message)
+	if err != nil {
+		return 0, fmt.Errorf("EstimatedBytes: marshal: %v", err)
+	}
+
+	attributes, err := message.buildAttributes(attrs)
+	if err != nil {
+		return 0, fmt.Errorf("EstimatedBytes: %w", err)
+	}
+
+	size := messageBytes(byteMessage, attributes)
+	if size < minBilledMessageBytes {
+		return minBilledMessageBytes, nil
+	}
+	return size, nil
+}
+
+// messageBytes is the size Pub/Sub counts for a message, its data plus every
+// attribute key and value
+func messageBytes(data []byte, attributes map[string]string) int {
+	size := len(data)
+	for key, value := range attributes {
+		size += len(key) + len(value)
+	}
+	return size
+}
+
+// EstimatedBatchBytes sums EstimatedBytes over messages published with the same attrs
+func EstimatedBatchBytes(messages []TinyHomeInstructions, attrs *TinyHomeMessageAttributes) (int, error) {
+	total := 0
+	for i, message := range messages {
+		size, err := message.EstimatedBytes(attrs)
+		if err != nil {
+			return 0, fmt.Errorf("message %d: %w", i, err)
+		}
+		total += size
+	}
+	return total, nil
+}
@@ -0,0 +1,177 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// largeInstructions returns validInstructions bound to enough members that the
+// message is well over the minimum billed size
+func largeInstructions() TinyHomeInstructions {
+	message := validInstructions()
+	members := make([]string, 100)
+	for i := range members {
+		members[i] = fmt.Sprintf("user:member-%03d@example.com", i)
+	}
+	message.AddlGroupIamBindings = map[string][]string{"roles/viewer": members}
+	return message
+}
+
+func TestEstimatedBytes(t *testing.T) {
+	large := largeInstructions()
+	largeBody, err := json.Marshal(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	largeAttributes, err := large.buildAttributes(validAttributes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	largeSize := len(largeBody)
+	for key, value := range largeAttributes {
+		largeSize += len(key) + len(value)
+	}
+
+	tests := []struct {
+		name    string
+		message TinyHomeInstructions
+		want    int
+	}{
+		{name: "small message billed at the minimum", message: validInstructions(), want: minBilledMessageBytes},
+		// Attribute keys and values count as well as the body
+		{name: "large message billed at its size", message: large, want: largeSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.message.EstimatedBytes(validAttributes())
+			if err != nil {
+				t.Fatalf("EstimatedBytes: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("EstimatedBytes = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// 100 quoted members of 27 bytes each, with their commas
+	if len(largeBody) < 3000 {
+		t.Errorf("large message body is %d bytes, want at least 3000", len(largeBody))
+	}
+}
+
+func TestEstimatedBatchBytes(t *testing.T) {
+	large, err := largeInstructions().EstimatedBytes(validAttributes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := validInstructions()
+	invalid.TenantName = ""
+
+	tests := []struct {
+		name     string
+		messages []TinyHomeInstructions
+		want     int
+		wantErr  string
+	}{
+		{name: "empty batch", want: 0},
+		{name: "small messages", messages: []TinyHomeInstructions{validInstructions(), validInstructions()}, want: 2 * minBilledMessageBytes},
+		{name: "mixed sizes", messages: []TinyHomeInstructions{validInstructions(), largeInstructions()}, want: minBilledMessageBytes + large},
+		{name: "invalid message", messages: []TinyHomeInstructions{validInstructions(), invalid}, wantErr: "message 1:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EstimatedBatchBytes(tt.messages, validAttributes())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("EstimatedBatchBytes error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EstimatedBatchBytes: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("EstimatedBatchBytes = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxMessageBytes(t *testing.T) {
+	clock := WithClock(func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) })
+
+	// The size Pub/Sub counts for the message, body and attributes together
+	sized := &fakeTopic{}
+	message := largeInstructions()
+	if _, err := newTestPublisher(t, sized, nil, clock).PublishContext(context.Background(), &message, validAttributes()); err != nil {
+		t.Fatalf("PublishContext: %v", err)
+	}
+	size := messageBytes(sized.published()[0].Data, sized.published()[0].Attributes)
+
+	tests := []struct {
+		name    string
+		max     int
+		wantErr bool
+	}{
+		{name: "under the limit", max: size + 1},
+		{name: "at the limit", max: size},
+		{name: "just over the limit", max: size - 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, clock, WithMaxMessageBytes(tt.max))
+			message := largeInstructions()
+			_, err := p.PublishContext(context.Background(), &message, validAttributes())
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("PublishContext: %v", err)
+				}
+				if got := len(topic.published()); got != 1 {
+					t.Errorf("published %d messages, want 1", got)
+				}
+				return
+			}
+			if !errors.Is(err, ErrMessageTooLarge) {
+				t.Fatalf("PublishContext error = %v, want ErrMessageTooLarge", err)
+			}
+			if want := fmt.Sprintf("message is %d bytes including attributes, at most %d are allowed", size, tt.max); !strings.Contains(err.Error(), want) {
+				t.Errorf("PublishContext error = %v, want it to contain %q", err, want)
+			}
+			// The limit is checked before anything is sent
+			if got := len(topic.published()); got != 0 {
+				t.Errorf("published %d messages, want none", got)
+			}
+		})
+	}
+}
+
+func TestMaxMessageBytesValidated(t *testing.T) {
+	tests := []struct {
+		max     int
+		wantErr bool
+	}{
+		{max: 1},
+		{max: PubsubMaxMessageBytes},
+		{max: 0, wantErr: true},
+		{max: PubsubMaxMessageBytes + 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.max), func(t *testing.T) {
+			_, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithMaxMessageBytes(tt.max))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPublisherWithTopic error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package tinyhomecommunity
+
+// RoutingFingerprint returns the stage and delivery source the attributes route by,
+// such as "createTenant/galaxy", for use as a bounded cardinality metric label.
+// Attributes that fail validation return "invalid".
+func (a TinyHomeMessageAttributes) RoutingFingerprint() string {
+	stage, err := a.Subscription()
+	if err != nil {
+		return "invalid"
+	}
+	return stage + "/" + a.DeliveredFrom
+}
@@ -0,0 +1,47 @@
+package tinyhomecommunity
+
+import "testing"
+
+func TestRoutingFingerprint(t *testing.T) {
+	tests := []struct {
+		name   string
+		stage  string
+		source string
+		// mutate changes the attributes after they are built for stage and source
+		mutate func(a *TinyHomeMessageAttributes)
+		want   string
+	}{
+		{name: "createGroups from galaxy", stage: "createGroups", source: "galaxy", want: "createGroups/galaxy"},
+		{name: "createGroups from manual", stage: "createGroups", source: "manual", want: "createGroups/manual"},
+		{name: "createWorkspace from galaxy", stage: "createWorkspace", source: "galaxy", want: "createWorkspace/galaxy"},
+		{name: "createWorkspace from manual", stage: "createWorkspace", source: "manual", want: "createWorkspace/manual"},
+		{name: "createTenant from galaxy", stage: "createTenant", source: "galaxy", want: "createTenant/galaxy"},
+		{name: "createTenant from manual", stage: "createTenant", source: "manual", want: "createTenant/manual"},
+		{name: "createFlux from galaxy", stage: "createFlux", source: "galaxy", want: "createFlux/galaxy"},
+		{name: "createFlux from manual", stage: "createFlux", source: "manual", want: "createFlux/manual"},
+		{name: "deliverEmail from galaxy", stage: "deliverEmail", source: "galaxy", want: "deliverEmail/galaxy"},
+		{name: "deliverEmail from manual", stage: "deliverEmail", source: "manual", want: "deliverEmail/manual"},
+		{name: "unknown source", stage: "createTenant", source: "terraform", want: "invalid"},
+		{name: "missing source", stage: "createTenant", source: "", want: "invalid"},
+		{
+			name:   "unroutable flags",
+			stage:  "createGroups",
+			source: "galaxy",
+			mutate: func(a *TinyHomeMessageAttributes) { a.FluxCreated = "true" },
+			want:   "invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attrs := attributesFor(t, tt.stage)
+			attrs.DeliveredFrom = tt.source
+			if tt.mutate != nil {
+				tt.mutate(attrs)
+			}
+			if got := attrs.RoutingFingerprint(); got != tt.want {
+				t.Errorf("RoutingFingerprint = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ContractFixture is the JSON layout of an exported fixture, the message body as
+// published and its Pub/Sub attributes
+type ContractFixture struct {
+	Data       json.RawMessage   `json:"data"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// contractClock pins publishedAt so the fixtures only change with the format
+func contractClock() time.Time {
+	return time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// contractSample is the instructions every fixture publishes
+func contractSample() TinyHomeInstructions {
+	var sample TinyHomeInstructions
+	sample.TenantName = "contract-tenant"
+	sample.Environment = "dev"
+	sample.BusinessUnit = "platform"
+	sample.TenantOwner = "owner@example.com"
+	sample.TenantOwnerSecondary = "backup@example.com"
+	sample.TenantCostCenter = "1234"
+	sample.Domain = "example.com"
+	sample.Organization = "123456789012"
+	sample.Region = "us-central1"
+	sample.Priority = "normal"
+	sample.AddlGkeTenantSaRoles = []string{"roles/logging.logWriter"}
+	sample.AddlGroupIamBindings = map[string][]string{"roles/viewer": {"group:tenant@example.com"}}
+	sample.NsQuota.Requests.Cpu = "1"
+	sample.NsQuota.Requests.Memory = "1Gi"
+	sample.NsQuota.Limits.Cpu = "2"
+	sample.NsQuota.Limits.Memory = "2Gi"
+	return sample
+}
+
+// ExportContractFixtures returns a representative published message for each stage,
+// as a ContractFixture in JSON, for consumer-driven contract tests. The fixtures are
+// built by running sample instructions through the same validation and serialization
+// as a publish with the default config and a fixed clock, so they change only when
+// the published format does.
+func ExportContractFixtures() (map[Stage][]byte, error) {
+	cfg, err := newPublisherConfig(WithDeliverEmail(true), WithClock(contractClock))
+	if err != nil {
+		return nil, fmt.Errorf("ExportContractFixtures: %v", err)
+	}
+	p := &Publisher{cfg: cfg}
+
+	fixtures := make(map[Stage][]byte, len(DefaultSubscriptionRules))
+	for _, rule := range DefaultSubscriptionRules {
+		message := contractSample()
+		messageAttributes := &TinyHomeMessageAttributes{
+			GroupsCreated:    boolAttribute(rule.GroupsCreated),
+			WorkspaceCreated: boolAttribute(rule.WorkspaceCreated),
+			TenantCreated:    boolAttribute(rule.TenantCreated),
+			FluxCreated:      boolAttribute(rule.FluxCreated),
+			DeliveredFrom:    "galaxy",
+		}
+
+		prepared, err := p.prepare(context.Background(), &message, messageAttributes, &PublishLogLine{})
+		if err != nil {
+			return nil, fmt.Errorf("ExportContractFixtures: %s: %w", rule.Subscription, err)
+		}
+
+		fixture, err := json.Marshal(ContractFixture{Data: prepared.data, Attributes: prepared.attributes})
+		if err != nil {
+			return nil, fmt.Errorf("ExportContractFixtures: %s: %v", rule.Subscription, err)
+		}
+		fixtures[Stage(rule.Subscription)] = fixture
+	}
+	return fixtures, nil
+}
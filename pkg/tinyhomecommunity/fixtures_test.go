@@ -0,0 +1,73 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestExportContractFixtures(t *testing.T) {
+	fixtures, err := ExportContractFixtures()
+	if err != nil {
+		t.Fatalf("ExportContractFixtures: %v", err)
+	}
+	again, err := ExportContractFixtures()
+	if err != nil {
+		t.Fatalf("ExportContractFixtures: %v", err)
+	}
+
+	stages := []Stage{StageCreateGroups, StageCreateWorkspace, StageCreateTenant, StageCreateFlux, StageDeliverEmail}
+	if len(fixtures) != len(stages) {
+		t.Errorf("%d fixtures, want one for each of %v", len(fixtures), stages)
+	}
+
+	for _, stage := range stages {
+		t.Run(string(stage), func(t *testing.T) {
+			data, ok := fixtures[stage]
+			if !ok {
+				t.Fatalf("no fixture for %s", stage)
+			}
+			if !bytes.Equal(data, again[stage]) {
+				t.Errorf("fixture changed between exports:\n%s\n%s", data, again[stage])
+			}
+
+			var fixture ContractFixture
+			if err := json.Unmarshal(data, &fixture); err != nil {
+				t.Fatalf("fixture is not a ContractFixture: %v", err)
+			}
+			if err := ValidateRawInstructions(fixture.Data); err != nil {
+				t.Errorf("fixture body does not validate: %v", err)
+			}
+
+			// The attributes route back to the fixture's stage
+			subscription, err := attributesFromMessage(fixture.Attributes).subscriptionWith(DefaultPublisherConfig())
+			if err != nil || subscription != string(stage) {
+				t.Errorf("fixture attributes route to %q, %v, want %s", subscription, err, stage)
+			}
+			if got, want := fixture.Attributes[AttrTenantName], contractSample().TenantName; got != want {
+				t.Errorf("tenantName attribute = %q, want %q", got, want)
+			}
+			if got, want := fixture.Attributes["publishedAt"], contractClock().Format(time.RFC3339); got != want {
+				t.Errorf("publishedAt attribute = %q, want the fixed %s", got, want)
+			}
+
+			// A real publish of the sample with the same config sends the same message
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithDeliverEmail(true), WithClock(contractClock))
+			message := contractSample()
+			if _, err := p.PublishContext(context.Background(), &message, attributesFor(t, string(stage))); err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+			published := topic.published()[0]
+			if !bytes.Equal(published.Data, fixture.Data) {
+				t.Errorf("published body %s, fixture %s", published.Data, fixture.Data)
+			}
+			if !maps.Equal(published.Attributes, fixture.Attributes) {
+				t.Errorf("published attributes %v, fixture %v", published.Attributes, fixture.Attributes)
+			}
+		})
+	}
+}
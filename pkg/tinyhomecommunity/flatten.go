@@ -0,0 +1,74 @@
+package tinyhomecommunity
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// flatQuota is the flattened shape of NsQuota some subscribers expect as top level
+// fields in place of the nested nsQuota object
+type flatQuota struct {
+	CpuRequest    string `json:"cpuRequest,omitempty"`
+	MemoryRequest string `json:"memoryRequest,omitempty"`
+	CpuLimit      string `json:"cpuLimit,omitempty"`
+	MemoryLimit   string `json:"memoryLimit,omitempty"`
+}
+
+// MarshalFlat marshals the instructions with NsQuota flattened into the top level
+// cpuRequest, memoryRequest, cpuLimit and memoryLimit fields. The nested nsQuota
+// object used by json.Marshal remains the default wire format, WithFlattenQuota
+// publishes this shape instead.
+func (message TinyHomeInstructions) MarshalFlat() ([]byte, error) {
+	nested, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(nested, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "nsQuota")
+
+	quota, err := json.Marshal(flatQuota{
+		CpuRequest:    message.NsQuota.Requests.Cpu,
+		MemoryRequest: message.NsQuota.Requests.Memory,
+		CpuLimit:      message.NsQuota.Limits.Cpu,
+		MemoryLimit:   message.NsQuota.Limits.Memory,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(quota, &fields); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(fields)
+}
+
+// ParseInstructions unmarshals instructions in either the nested nsQuota shape or the
+// flattened shape written by MarshalFlat. Nested values win when both are present.
+func ParseInstructions(data []byte) (*TinyHomeInstructions, error) {
+	var message TinyHomeInstructions
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, fmt.Errorf("ParseInstructions: %v", err)
+	}
+
+	var quota flatQuota
+	if err := json.Unmarshal(data, &quota); err != nil {
+		return nil, fmt.Errorf("ParseInstructions: %v", err)
+	}
+
+	fillEmpty(&message.NsQuota.Requests.Cpu, quota.CpuRequest)
+	fillEmpty(&message.NsQuota.Requests.Memory, quota.MemoryRequest)
+	fillEmpty(&message.NsQuota.Limits.Cpu, quota.CpuLimit)
+	fillEmpty(&message.NsQuota.Limits.Memory, quota.MemoryLimit)
+	return &message, nil
+}
+
+// fillEmpty sets *field to value when the field is empty
+func fillEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
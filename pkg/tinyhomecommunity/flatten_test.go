@@ -0,0 +1,117 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFlattenQuotaRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		// wantFlat is whether the published body has the flattened quota fields in
+		// place of nsQuota
+		wantFlat bool
+	}{
+		{name: "nested by default"},
+		{name: "flattened", opts: []Option{WithFlattenQuota(true)}, wantFlat: true},
+		{name: "flattened and compressed", opts: []Option{WithFlattenQuota(true), WithCompression(true)}, wantFlat: true},
+		{name: "flattened cloudevents", opts: []Option{WithFlattenQuota(true), WithCloudEvents("//publisher.test")}, wantFlat: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, tt.opts...)
+			message := validInstructions()
+			if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+			msg := topic.published()[0]
+
+			body, err := DecompressBody(msg.Data, msg.Attributes)
+			if err != nil {
+				t.Fatalf("DecompressBody: %v", err)
+			}
+			if body, err = unwrapCloudEvent(body, msg.Attributes); err != nil {
+				t.Fatalf("unwrapCloudEvent: %v", err)
+			}
+
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(body, &fields); err != nil {
+				t.Fatalf("unmarshal body %s: %v", body, err)
+			}
+			_, nested := fields["nsQuota"]
+			_, flat := fields["cpuRequest"]
+			if nested == tt.wantFlat || flat != tt.wantFlat {
+				t.Errorf("body %s has nsQuota %v and cpuRequest %v, want flattened %v", body, nested, flat, tt.wantFlat)
+			}
+
+			// ParseInstructions and a Subscriber read either shape back to the
+			// instructions published
+			parsed, err := ParseInstructions(body)
+			if err != nil {
+				t.Fatalf("ParseInstructions: %v", err)
+			}
+			if !reflect.DeepEqual(*parsed, message) {
+				t.Errorf("ParseInstructions = %+v, want %+v", *parsed, message)
+			}
+
+			s := &Subscriber{cfg: DefaultPublisherConfig()}
+			decoded, err := s.decode(msg)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(*decoded, message) {
+				t.Errorf("decode = %+v, want %+v", *decoded, message)
+			}
+		})
+	}
+}
+
+func TestParseInstructionsQuotaShapes(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		// want are the cpu and memory requests, then the cpu and memory limits
+		want [4]string
+	}{
+		{
+			name: "nested",
+			body: `{"nsQuota":{"requests":{"cpu":"1","memory":"1Gi"},"limits":{"cpu":"2","memory":"2Gi"}}}`,
+			want: [4]string{"1", "1Gi", "2", "2Gi"},
+		},
+		{
+			name: "flat",
+			body: `{"cpuRequest":"1","memoryRequest":"1Gi","cpuLimit":"2","memoryLimit":"2Gi"}`,
+			want: [4]string{"1", "1Gi", "2", "2Gi"},
+		},
+		{
+			name: "nested wins over flat",
+			body: `{"nsQuota":{"requests":{"cpu":"1"}},"cpuRequest":"4","memoryRequest":"4Gi"}`,
+			want: [4]string{"1", "4Gi", "", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseInstructions([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("ParseInstructions: %v", err)
+			}
+			quota := parsed.NsQuota
+			if got := [4]string{quota.Requests.Cpu, quota.Requests.Memory, quota.Limits.Cpu, quota.Limits.Memory}; got != tt.want {
+				t.Errorf("NsQuota = %+v, want %v", quota, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenQuotaWithCompact(t *testing.T) {
+	_, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithFlattenQuota(true), WithCompact(validInstructions()))
+	if err == nil {
+		t.Fatal("newPublisherWithTopic accepted FlattenQuota with CompactDefaults")
+	}
+}
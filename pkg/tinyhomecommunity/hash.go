@@ -0,0 +1,21 @@
+package tinyhomecommunity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// InstructionHash returns a stable SHA-256 hash of the marshaled instructions.
+// encoding/json writes struct fields in declaration order, so identical instructions
+// always hash the same and subscribers can use the instructionHash attribute to spot
+// a redelivered duplicate. It is also the default idempotencyKey attribute.
+func (message TinyHomeInstructions) InstructionHash() (string, error) {
+	byteMessage, err := json.Marshal(&message)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(byteMessage)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,95 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"testing"
+)
+
+// hashBase returns validInstructions with labels and two roles, the instructions
+// TestInstructionHash changes
+func hashBase() TinyHomeInstructions {
+	message := validInstructions()
+	message.AddlGkeTenantSaRoles = []string{"roles/logging.logWriter", "roles/monitoring.metricWriter"}
+	message.Labels = map[string]string{"team": "platform", "tier": "gold"}
+	return message
+}
+
+func TestInstructionHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		mutate   func(m *TinyHomeInstructions)
+		wantSame bool
+	}{
+		{name: "identical", mutate: func(m *TinyHomeInstructions) {}, wantSame: true},
+		{
+			name: "maps built in another order",
+			mutate: func(m *TinyHomeInstructions) {
+				m.AddlGroupIamBindings = map[string][]string{}
+				m.AddlGroupIamBindings["roles/viewer"] = []string{"group:tenant@example.com"}
+				m.Labels = map[string]string{"tier": "gold", "team": "platform"}
+			},
+			wantSame: true,
+		},
+		{name: "different tenant", mutate: func(m *TinyHomeInstructions) { m.TenantName = "other-tenant" }},
+		{name: "different quota", mutate: func(m *TinyHomeInstructions) { m.NsQuota.Limits.Cpu = "3" }},
+		{name: "different label", mutate: func(m *TinyHomeInstructions) { m.Labels = map[string]string{"team": "platform", "tier": "silver"} }},
+		{
+			name: "reordered roles",
+			mutate: func(m *TinyHomeInstructions) {
+				m.AddlGkeTenantSaRoles = []string{"roles/monitoring.metricWriter", "roles/logging.logWriter"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, other := hashBase(), hashBase()
+			tt.mutate(&other)
+
+			baseHash, err := base.InstructionHash()
+			if err != nil {
+				t.Fatalf("InstructionHash: %v", err)
+			}
+			otherHash, err := other.InstructionHash()
+			if err != nil {
+				t.Fatalf("InstructionHash: %v", err)
+			}
+			if len(baseHash) != 64 {
+				t.Errorf("hash %q is not a hex SHA-256", baseHash)
+			}
+			if (baseHash == otherHash) != tt.wantSame {
+				t.Errorf("hashes %s and %s, want the same %v", baseHash, otherHash, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestInstructionHashAttribute(t *testing.T) {
+	topic := &fakeTopic{}
+	p := newTestPublisher(t, topic, nil)
+
+	// A redelivery of the same instructions carries the same hash, other instructions
+	// a different one
+	for _, tenant := range []string{"contract-tenant", "contract-tenant", "other-tenant"} {
+		message := validInstructions()
+		message.TenantName = tenant
+		if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+			t.Fatalf("PublishContext: %v", err)
+		}
+	}
+
+	published := topic.published()
+	want, err := validInstructions().InstructionHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := published[0].Attributes["instructionHash"]; got != want {
+		t.Errorf("instructionHash attribute = %q, want InstructionHash %q", got, want)
+	}
+	if published[1].Attributes["instructionHash"] != want {
+		t.Errorf("identical instructions have instructionHash %q, want %q", published[1].Attributes["instructionHash"], want)
+	}
+	if published[2].Attributes["instructionHash"] == want {
+		t.Error("different instructions have the same instructionHash")
+	}
+}
@@ -0,0 +1,91 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// publishPermission is the IAM permission needed to publish to a topic
+const publishPermission = "pubsub.topics.publish"
+
+// HealthCheck verifies that every topic the Publisher publishes to exists and that
+// its credentials can publish to it, e.g. for a readiness probe, without publishing
+// anything. A missing topic matches ErrTopicNotFound and missing permission matches
+// ErrPermissionDenied. Any other failure to reach Pub/Sub is a TransportError. The
+// permission check is skipped where it is not implemented, such as on the emulator.
+func (p *Publisher) HealthCheck(ctx context.Context) error {
+	if p.client == nil {
+		return fmt.Errorf("HealthCheck: publisher has no Pub/Sub client")
+	}
+
+	for _, id := range p.topicIDs() {
+		if err := p.checkTopic(ctx, id); err != nil {
+			return fmt.Errorf("HealthCheck: topic %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// checkTopic checks topic id exists and can be published to
+func (p *Publisher) checkTopic(ctx context.Context, id string) error {
+	topic := p.client.Topic(id)
+	exists, err := topic.Exists(ctx)
+	if err != nil {
+		return healthError("topic.Exists", err)
+	}
+	if !exists {
+		return ErrTopicNotFound
+	}
+
+	granted, err := topic.IAM().TestPermissions(ctx, []string{publishPermission})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	if err != nil {
+		return healthError("topic.IAM.TestPermissions", err)
+	}
+	if !contains(granted, publishPermission) {
+		return fmt.Errorf("%w: credentials lack %s", ErrPermissionDenied, publishPermission)
+	}
+	return nil
+}
+
+// healthError classifies a failed call to op by its gRPC code
+func healthError(op string, err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return fmt.Errorf("%w: %v", ErrTopicNotFound, err)
+	case codes.PermissionDenied:
+		return fmt.Errorf("%w: %v", ErrPermissionDenied, err)
+	}
+	return &TransportError{Op: op, Err: err}
+}
+
+// topicIDs returns the ids of TopicID, if set, every TopicByEnvironment topic and
+// QuarantineTopicID and CloudEventsTopicID, if set, sorted and without duplicates
+func (p *Publisher) topicIDs() []string {
+	seen := map[string]bool{}
+	if p.cfg.TopicID != "" {
+		seen[p.cfg.TopicID] = true
+	}
+	for _, id := range p.cfg.TopicByEnvironment {
+		seen[id] = true
+	}
+	if p.cfg.QuarantineTopicID != "" {
+		seen[p.cfg.QuarantineTopicID] = true
+	}
+	if p.cfg.CloudEventsTopicID != "" {
+		seen[p.cfg.CloudEventsTopicID] = true
+	}
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
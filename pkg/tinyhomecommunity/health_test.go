@@ -0,0 +1,103 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHealthCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		// topics are the topics created on the emulator
+		topics  []string
+		opts    []Option
+		wantErr error
+		// wantTopic is the topic named in the error
+		wantTopic string
+	}{
+		{
+			name:   "healthy topic",
+			topics: []string{DefaultTopicID},
+		},
+		{
+			name:   "healthy environment and quarantine topics",
+			topics: []string{DefaultTopicID, "prod-topic", "quarantine"},
+			opts:   []Option{WithTopicByEnvironment(map[string]string{"prod": "prod-topic"}), WithQuarantineTopic("quarantine")},
+		},
+		{
+			name:      "missing topic",
+			wantErr:   ErrTopicNotFound,
+			wantTopic: DefaultTopicID,
+		},
+		{
+			name:      "missing environment topic",
+			topics:    []string{DefaultTopicID},
+			opts:      []Option{WithTopicByEnvironment(map[string]string{"prod": "prod-topic"})},
+			wantErr:   ErrTopicNotFound,
+			wantTopic: "prod-topic",
+		},
+		{
+			name:      "missing quarantine topic",
+			topics:    []string{DefaultTopicID},
+			opts:      []Option{WithQuarantineTopic("quarantine")},
+			wantErr:   ErrTopicNotFound,
+			wantTopic: "quarantine",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, connect := newEmulator(t, tt.topics...)
+			p, err := NewPublisher(context.Background(), DefaultPublisherConfig(), append([]Option{connect}, tt.opts...)...)
+			if err != nil {
+				t.Fatalf("NewPublisher: %v", err)
+			}
+			defer p.Close()
+
+			err = p.HealthCheck(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("HealthCheck error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && !strings.Contains(err.Error(), "topic "+tt.wantTopic+":") {
+				t.Errorf("HealthCheck error = %v, want it to name topic %s", err, tt.wantTopic)
+			}
+		})
+	}
+}
+
+func TestHealthCheckNoClient(t *testing.T) {
+	p := newTestPublisher(t, &fakeTopic{}, nil)
+	if err := p.HealthCheck(context.Background()); err == nil {
+		t.Fatal("HealthCheck with no Pub/Sub client returned no error")
+	}
+}
+
+func TestHealthError(t *testing.T) {
+	tests := []struct {
+		code          codes.Code
+		want          error
+		wantTransport bool
+	}{
+		{code: codes.NotFound, want: ErrTopicNotFound},
+		{code: codes.PermissionDenied, want: ErrPermissionDenied},
+		{code: codes.Unavailable, wantTransport: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			err := healthError("topic.Exists", status.Error(tt.code, "failed"))
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("healthError = %v, want %v", err, tt.want)
+			}
+			var transportErr *TransportError
+			if got := errors.As(err, &transportErr); got != tt.wantTransport {
+				t.Errorf("healthError = %v, TransportError %t, want %t", err, got, tt.wantTransport)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MessageTypeHeartbeat is the messageType attribute set on heartbeat messages.
+// Subscribers should ack and ignore any message carrying it.
+const MessageTypeHeartbeat = "heartbeat"
+
+// heartbeat is the minimal body of a heartbeat message
+type heartbeat struct {
+	MessageType string `json:"messageType"`
+	SentAt      string `json:"sentAt"`
+}
+
+// PublishHeartbeat publishes a minimal heartbeat message to prove the publish path
+// works end to end without onboarding a real tenant. opts select the topic as for
+// PublishTinyHomeInstructions.
+func PublishHeartbeat(ctx context.Context, opts ...Option) (string, error) {
+	p, err := NewPublisher(ctx, DefaultPublisherConfig(), opts...)
+	if err != nil {
+		return "", fmt.Errorf("PublishHeartbeat: %w", err)
+	}
+	defer p.Close()
+
+	return p.PublishHeartbeat(ctx)
+}
+
+// PublishHeartbeat publishes a minimal heartbeat message to prove the publish path
+// works end to end without onboarding a real tenant. It goes to the topic instructions
+// with no environment are published to, use PublishHeartbeatFor when only
+// TopicByEnvironment is set.
+func (p *Publisher) PublishHeartbeat(ctx context.Context) (string, error) {
+	return p.PublishHeartbeatFor(ctx, "")
+}
+
+// PublishHeartbeatFor publishes a heartbeat message to the topic instructions for
+// environment are published to, its TopicByEnvironment topic or else TopicID
+func (p *Publisher) PublishHeartbeatFor(ctx context.Context, environment string) (string, error) {
+	topicID, err := p.cfg.topicFor(environment)
+	if err != nil {
+		return "", fmt.Errorf("PublishHeartbeat: %w", err)
+	}
+
+	byteMessage, err := json.Marshal(heartbeat{
+		MessageType: MessageTypeHeartbeat,
+		SentAt:      p.cfg.now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return "", fmt.Errorf("PublishHeartbeat: %v", err)
+	}
+
+	id, err := p.publishTo(ctx, topicID, byteMessage, map[string]string{
+		"messageType": MessageTypeHeartbeat,
+	})
+	if err != nil {
+		return "", fmt.Errorf("PublishHeartbeat: %w", err)
+	}
+
+	p.logger().InfoContext(ctx, "published heartbeat", "messageId", id, "topic", topicID)
+	return id, nil
+}
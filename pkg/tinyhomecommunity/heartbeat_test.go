@@ -0,0 +1,153 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestPublishHeartbeat(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	tests := []struct {
+		name    string
+		result  func(n int, msg *pubsub.Message) publishResult
+		wantErr error
+	}{
+		{name: "published"},
+		{
+			name:    "publish error",
+			result:  func(n int, msg *pubsub.Message) publishResult { return fakeResult{err: errPubSub} },
+			wantErr: errPubSub,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{result: tt.result}
+			p := newTestPublisher(t, topic, nil)
+
+			before := time.Now().UTC().Truncate(time.Second)
+			id, err := p.PublishHeartbeat(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PublishHeartbeat error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && id != "1" {
+				t.Errorf("PublishHeartbeat id = %q, want 1", id)
+			}
+
+			published := topic.published()
+			if len(published) != 1 {
+				t.Fatalf("published %d messages, want 1", len(published))
+			}
+			msg := published[0]
+			if len(msg.Attributes) != 1 || msg.Attributes["messageType"] != MessageTypeHeartbeat {
+				t.Errorf("attributes = %v, want only messageType: %s", msg.Attributes, MessageTypeHeartbeat)
+			}
+
+			// The body is just the message type and when it was sent
+			var body map[string]string
+			if err := json.Unmarshal(msg.Data, &body); err != nil {
+				t.Fatalf("unmarshal body %s: %v", msg.Data, err)
+			}
+			if len(body) != 2 || body["messageType"] != MessageTypeHeartbeat {
+				t.Errorf("body = %s, want only messageType and sentAt", msg.Data)
+			}
+			sentAt, err := time.Parse(time.RFC3339, body["sentAt"])
+			if err != nil {
+				t.Fatalf("sentAt %q: %v", body["sentAt"], err)
+			}
+			if sentAt.Before(before) || sentAt.After(time.Now().UTC()) {
+				t.Errorf("sentAt = %v, want the time of the publish", sentAt)
+			}
+		})
+	}
+}
+
+func TestPublishHeartbeatClock(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("EST", -5*60*60))
+	topic := &fakeTopic{}
+	p := newTestPublisher(t, topic, nil, WithClock(func() time.Time { return now }))
+
+	if _, err := p.PublishHeartbeat(context.Background()); err != nil {
+		t.Fatalf("PublishHeartbeat: %v", err)
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal(topic.published()[0].Data, &body); err != nil {
+		t.Fatalf("unmarshal body: %v", err)
+	}
+	if want := "2024-03-01T17:30:00Z"; body["sentAt"] != want {
+		t.Errorf("sentAt = %q, want %q", body["sentAt"], want)
+	}
+}
+
+func TestPublishHeartbeatFor(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		environment string
+		wantTopic   string
+		wantErr     bool
+	}{
+		{
+			name:      "default topic",
+			wantTopic: DefaultTopicID,
+		},
+		{
+			name:        "environment topic",
+			opts:        []Option{WithTopicByEnvironment(map[string]string{"dev": "dev-topic"})},
+			environment: "dev",
+			wantTopic:   "dev-topic",
+		},
+		{
+			name:        "environment topic only",
+			opts:        []Option{WithTopic(""), WithTopicByEnvironment(map[string]string{"dev": "dev-topic"})},
+			environment: "dev",
+			wantTopic:   "dev-topic",
+		},
+		{
+			name:        "environment without a topic falls back to the default topic",
+			opts:        []Option{WithTopicByEnvironment(map[string]string{"dev": "dev-topic"})},
+			environment: "prod",
+			wantTopic:   DefaultTopicID,
+		},
+		{
+			name:    "no environment and only environment topics",
+			opts:    []Option{WithTopic(""), WithTopicByEnvironment(map[string]string{"dev": "dev-topic"})},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topics := map[string]*fakeTopic{DefaultTopicID: {}, "dev-topic": {}}
+			p := newTestPublisher(t, topics[DefaultTopicID], map[string]*fakeTopic{"dev-topic": topics["dev-topic"]}, tt.opts...)
+
+			_, err := p.PublishHeartbeatFor(context.Background(), tt.environment)
+			if tt.wantErr {
+				var routingErr *RoutingError
+				if !errors.As(err, &routingErr) {
+					t.Fatalf("PublishHeartbeatFor error = %v, want a RoutingError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishHeartbeatFor: %v", err)
+			}
+
+			for id, topic := range topics {
+				want := 0
+				if id == tt.wantTopic {
+					want = 1
+				}
+				if got := len(topic.published()); got != want {
+					t.Errorf("%d messages published to %s, want %d", got, id, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// fakeTopic is an in-memory topicPublisher recording every message published to it.
+// Messages get the IDs "1", "2" and so on in publish order.
+type fakeTopic struct {
+	mu       sync.Mutex
+	messages []*pubsub.Message
+	// result, when set, returns the result of the n-th publish, from 1, in place of
+	// a successful one
+	result  func(n int, msg *pubsub.Message) publishResult
+	resumed []string
+	flushes int
+	stopped bool
+}
+
+func (t *fakeTopic) Publish(ctx context.Context, msg *pubsub.Message) publishResult {
+	t.mu.Lock()
+	t.messages = append(t.messages, msg)
+	n := len(t.messages)
+	result := t.result
+	t.mu.Unlock()
+
+	if result != nil {
+		return result(n, msg)
+	}
+	return fakeResult{id: strconv.Itoa(n)}
+}
+
+func (t *fakeTopic) ResumePublish(orderingKey string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resumed = append(t.resumed, orderingKey)
+}
+
+func (t *fakeTopic) Flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.flushes++
+}
+
+func (t *fakeTopic) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+}
+
+// published returns the messages published so far
+func (t *fakeTopic) published() []*pubsub.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*pubsub.Message(nil), t.messages...)
+}
+
+// fakeResult is a publishResult returning id or err, once ready is closed when set
+type fakeResult struct {
+	id    string
+	err   error
+	ready <-chan struct{}
+}
+
+func (r fakeResult) Get(ctx context.Context) (string, error) {
+	if r.ready != nil {
+		select {
+		case <-r.ready:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	if r.err != nil {
+		return "", r.err
+	}
+	return r.id, nil
+}
+
+// newTestPublisher returns a Publisher over the default config and opts that
+// publishes to topic, and to the other fake topics keyed by their id
+func newTestPublisher(t *testing.T, topic *fakeTopic, others map[string]*fakeTopic, opts ...Option) *Publisher {
+	t.Helper()
+	p, err := newPublisherWithTopic(topic, DefaultPublisherConfig(), opts...)
+	if err != nil {
+		t.Fatalf("newPublisherWithTopic: %v", err)
+	}
+	for id, other := range others {
+		if p.topics == nil {
+			p.topics = map[string]topicPublisher{}
+		}
+		p.topics[id] = other
+	}
+	return p
+}
+
+// validInstructions returns instructions that pass the default validation
+func validInstructions() TinyHomeInstructions {
+	return contractSample()
+}
+
+// validAttributes returns attributes routing to createGroups
+func validAttributes() *TinyHomeMessageAttributes {
+	return &TinyHomeMessageAttributes{
+		GroupsCreated:    "false",
+		WorkspaceCreated: "false",
+		TenantCreated:    "false",
+		FluxCreated:      "false",
+		DeliveredFrom:    "galaxy",
+	}
+}
+
+// attributesFor returns attributes routing to subscription
+func attributesFor(t *testing.T, subscription string) *TinyHomeMessageAttributes {
+	t.Helper()
+	for _, rule := range DefaultSubscriptionRules {
+		if rule.Subscription == subscription {
+			return &TinyHomeMessageAttributes{
+				GroupsCreated:    boolAttribute(rule.GroupsCreated),
+				WorkspaceCreated: boolAttribute(rule.WorkspaceCreated),
+				TenantCreated:    boolAttribute(rule.TenantCreated),
+				FluxCreated:      boolAttribute(rule.FluxCreated),
+				DeliveredFrom:    "galaxy",
+			}
+		}
+	}
+	t.Fatalf("no subscription rule for %s", subscription)
+	return nil
+}
+
+// eventually fails the test unless cond becomes true within a second
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// emulatorOptions are the client options connecting to an emulator at addr
+func emulatorOptions(addr string) []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+}
+
+// newEmulator starts an in-memory Pub/Sub server with topicIDs created in
+// DefaultProjectID. It returns a client of the server, for creating subscriptions
+// and reading what was published, and the option connecting a Publisher to it.
+func newEmulator(t *testing.T, topicIDs ...string) (*pubsub.Client, Option) {
+	t.Helper()
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+
+	clientOpts := emulatorOptions(srv.Addr)
+	client, err := pubsub.NewClient(context.Background(), DefaultProjectID, clientOpts...)
+	if err != nil {
+		t.Fatalf("pubsub.NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	for _, id := range topicIDs {
+		if _, err := client.CreateTopic(context.Background(), id); err != nil {
+			t.Fatalf("CreateTopic %s: %v", id, err)
+		}
+	}
+	return client, WithClientOptions(clientOpts...)
+}
@@ -0,0 +1,179 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// iamBindings returns AddlGroupIamBindings as role to members
+func (message TinyHomeInstructions) iamBindings() map[string][]string {
+	return message.AddlGroupIamBindings
+}
+
+// iamMemberTypes are the principal types AddlGroupIamBindings members can have
+var iamMemberTypes = []string{"user", "group", "serviceAccount", "domain"}
+
+// validateIamBindings checks every AddlGroupIamBindings key is a predefined or custom
+// IAM role name bound to principals of the form type:identity. A role may have no
+// members, as legacy payloads often send an empty list.
+func (message TinyHomeInstructions) validateIamBindings() error {
+	bindings := message.iamBindings()
+
+	roles := make([]string, 0, len(bindings))
+	for role := range bindings {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		field := fmt.Sprintf("addlGroupIamBindings[%q]", role)
+		if !predefinedRolePattern.MatchString(role) && !customRolePattern.MatchString(role) {
+			return &IAMError{Field: field, Message: fmt.Sprintf("addlGroupIamBindings role %q is not an IAM role, expected roles/..., organizations/.../roles/... or projects/.../roles/...", role)}
+		}
+
+		for _, member := range bindings[role] {
+			memberType, identity, found := strings.Cut(member, ":")
+			if !found || identity == "" || !contains(iamMemberTypes, memberType) {
+				return &IAMError{Field: field, Message: fmt.Sprintf("addlGroupIamBindings role %q member %q is not a principal, expected one of %s followed by :identity", role, member, iamMemberTypes)}
+			}
+		}
+	}
+	return nil
+}
+
+// memberIdentity strips the principal type prefix, e.g. "user:", from an IAM member
+func memberIdentity(member string) string {
+	if i := strings.Index(member, ":"); i >= 0 {
+		return member[i+1:]
+	}
+	return member
+}
+
+// ValidateIAMMemberConsistency flags principals bound to more than one role in
+// AddlGroupIamBindings, and tenant owners that also appear in a binding, since both
+// are usually accidental over-granting
+func (message TinyHomeInstructions) ValidateIAMMemberConsistency() error {
+	bindings := message.iamBindings()
+
+	roles := make([]string, 0, len(bindings))
+	for role := range bindings {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	memberRoles := map[string][]string{}
+	var members []string
+	for _, role := range roles {
+		for _, member := range bindings[role] {
+			identity := memberIdentity(member)
+			if _, ok := memberRoles[identity]; !ok {
+				members = append(members, identity)
+			}
+			if !contains(memberRoles[identity], role) {
+				memberRoles[identity] = append(memberRoles[identity], role)
+			}
+		}
+	}
+
+	for _, member := range members {
+		if len(memberRoles[member]) > 1 {
+			return &IAMError{
+				Field:   "addlGroupIamBindings",
+				Message: fmt.Sprintf("member %s is bound to multiple roles: %s", member, memberRoles[member]),
+			}
+		}
+	}
+
+	for _, owner := range []string{message.TenantOwner, message.TenantOwnerSecondary} {
+		if owner == "" {
+			continue
+		}
+		if boundRoles, ok := memberRoles[owner]; ok {
+			return &IAMError{
+				Field:   "addlGroupIamBindings",
+				Message: fmt.Sprintf("tenant owner %s is also bound to roles: %s", owner, boundRoles),
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateIAMMemberConsistency applies ValidateIAMMemberConsistency when enabled
+func (message TinyHomeInstructions) validateIAMMemberConsistency(enabled bool) error {
+	if !enabled {
+		return nil
+	}
+	return message.ValidateIAMMemberConsistency()
+}
+
+// ValidateIAMMemberLimit rejects any role in AddlGroupIamBindings bound to more than
+// maxMembers principals, keeping tenant policies manageable
+func (message TinyHomeInstructions) ValidateIAMMemberLimit(maxMembers int) error {
+	bindings := message.iamBindings()
+
+	roles := make([]string, 0, len(bindings))
+	for role := range bindings {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		if count := len(bindings[role]); count > maxMembers {
+			return &IAMError{
+				Field:   "addlGroupIamBindings",
+				Message: fmt.Sprintf("role %s has %d members, at most %d are allowed per role", role, count, maxMembers),
+			}
+		}
+	}
+	return nil
+}
+
+// validateIAMMemberLimit applies ValidateIAMMemberLimit, a maxMembers of 0 disables it
+func (message TinyHomeInstructions) validateIAMMemberLimit(maxMembers int) error {
+	if maxMembers == 0 {
+		return nil
+	}
+	return message.ValidateIAMMemberLimit(maxMembers)
+}
+
+// memberDomain returns the domain of an IAM member, the part after @ for users,
+// groups and service accounts or the identity itself for domain members
+func memberDomain(member string) string {
+	identity := memberIdentity(member)
+	if strings.HasPrefix(member, "domain:") {
+		return strings.ToLower(identity)
+	}
+	return strings.ToLower(identity[strings.LastIndex(identity, "@")+1:])
+}
+
+// validateIamMemberDomains rejects AddlGroupIamBindings members outside the allowed
+// domains, so access is never granted to external principals. Domains may be given
+// with or without a leading @. An empty allowed disables the check.
+func (message TinyHomeInstructions) validateIamMemberDomains(allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	domains := make([]string, len(allowed))
+	for i, domain := range allowed {
+		domains[i] = strings.ToLower(strings.TrimPrefix(domain, "@"))
+	}
+
+	bindings := message.iamBindings()
+	roles := make([]string, 0, len(bindings))
+	for role := range bindings {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		for _, member := range bindings[role] {
+			if !contains(domains, memberDomain(member)) {
+				return &IAMError{Field: fmt.Sprintf("addlGroupIamBindings[%q]", role), Message: fmt.Sprintf("addlGroupIamBindings role %q member %q is outside the allowed domains: %s", role, member, allowed)}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package tinyhomecommunity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIAMMemberConsistency(t *testing.T) {
+	tests := []struct {
+		name     string
+		bindings map[string][]string
+		enabled  bool
+		// wantMessage are the parts of the error message, none when valid
+		wantMessage []string
+	}{
+		{
+			name:     "distinct members",
+			bindings: map[string][]string{"roles/viewer": {"group:viewers@example.com"}, "roles/editor": {"group:editors@example.com"}},
+			enabled:  true,
+		},
+		{
+			name:        "member in two roles",
+			bindings:    map[string][]string{"roles/viewer": {"group:tenant@example.com"}, "roles/editor": {"group:tenant@example.com"}},
+			enabled:     true,
+			wantMessage: []string{"tenant@example.com", "roles/editor", "roles/viewer"},
+		},
+		{
+			name:        "member in two roles with different types",
+			bindings:    map[string][]string{"roles/viewer": {"user:dev@example.com"}, "roles/editor": {"serviceAccount:dev@example.com"}},
+			enabled:     true,
+			wantMessage: []string{"dev@example.com", "roles/editor", "roles/viewer"},
+		},
+		{
+			name:     "member listed twice in one role",
+			bindings: map[string][]string{"roles/viewer": {"group:tenant@example.com", "group:tenant@example.com"}},
+			enabled:  true,
+		},
+		{
+			name:        "tenant owner bound",
+			bindings:    map[string][]string{"roles/editor": {"user:owner@example.com"}},
+			enabled:     true,
+			wantMessage: []string{"tenant owner owner@example.com", "roles/editor"},
+		},
+		{
+			name:        "secondary owner bound",
+			bindings:    map[string][]string{"roles/viewer": {"user:backup@example.com"}},
+			enabled:     true,
+			wantMessage: []string{"tenant owner backup@example.com", "roles/viewer"},
+		},
+		{
+			name:     "disabled",
+			bindings: map[string][]string{"roles/viewer": {"group:tenant@example.com"}, "roles/editor": {"group:tenant@example.com"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.AddlGroupIamBindings = tt.bindings
+			_, err := message.DryRun(validAttributes(), WithIAMMemberConsistency(tt.enabled))
+
+			var wantField string
+			if len(tt.wantMessage) > 0 {
+				wantField = "addlGroupIamBindings"
+			}
+			wantFieldError(t, err, wantField)
+			for _, want := range tt.wantMessage {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIAMMemberLimit(t *testing.T) {
+	// members returns n distinct group members
+	members := func(n int) []string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = fmt.Sprintf("group:team-%d@example.com", i)
+		}
+		return list
+	}
+
+	tests := []struct {
+		name     string
+		bindings map[string][]string
+		max      int
+		// wantMessage is the error message, empty when valid
+		wantMessage string
+	}{
+		{name: "under the limit", bindings: map[string][]string{"roles/viewer": members(2)}, max: 3},
+		{name: "at the limit", bindings: map[string][]string{"roles/viewer": members(3)}, max: 3},
+		{
+			name:        "over the limit",
+			bindings:    map[string][]string{"roles/viewer": members(4)},
+			max:         3,
+			wantMessage: "role roles/viewer has 4 members, at most 3 are allowed per role",
+		},
+		{
+			name:        "one role of several over the limit",
+			bindings:    map[string][]string{"roles/editor": members(1), "roles/viewer": members(5)},
+			max:         3,
+			wantMessage: "role roles/viewer has 5 members",
+		},
+		{name: "disabled", bindings: map[string][]string{"roles/viewer": members(50)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.AddlGroupIamBindings = tt.bindings
+			_, err := message.DryRun(validAttributes(), WithMaxIAMMembersPerRole(tt.max))
+
+			if tt.wantMessage == "" {
+				wantFieldError(t, err, "")
+				return
+			}
+			wantFieldError(t, err, "addlGroupIamBindings")
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestIamBindings(t *testing.T) {
+	tests := []struct {
+		name string
+		// bindings is the addlGroupIamBindings JSON
+		bindings string
+		want     map[string][]string
+		// wantField is the field of the ValidationError, empty when valid
+		wantField string
+	}{
+		{
+			name:     "any predefined roles",
+			bindings: `{"roles/viewer": ["group:tenant@example.com"], "roles/editor": ["user:dev@example.com", "serviceAccount:ci@tenant-project.iam.gserviceaccount.com"]}`,
+			want: map[string][]string{
+				"roles/viewer": {"group:tenant@example.com"},
+				"roles/editor": {"user:dev@example.com", "serviceAccount:ci@tenant-project.iam.gserviceaccount.com"},
+			},
+		},
+		{
+			name:     "custom roles",
+			bindings: `{"organizations/123456789012/roles/tenantAdmin": ["domain:example.com"], "projects/tenant-project/roles/custom.viewer": ["group:tenant@example.com"]}`,
+			want: map[string][]string{
+				"organizations/123456789012/roles/tenantAdmin": {"domain:example.com"},
+				"projects/tenant-project/roles/custom.viewer":  {"group:tenant@example.com"},
+			},
+		},
+		{name: "legacy role with no members", bindings: `{"roles/roles.test": []}`, want: map[string][]string{"roles/roles.test": {}}},
+		{name: "empty", bindings: `{}`, want: map[string][]string{}},
+		{
+			name:      "not a role",
+			bindings:  `{"viewer": ["group:tenant@example.com"]}`,
+			want:      map[string][]string{"viewer": {"group:tenant@example.com"}},
+			wantField: `addlGroupIamBindings["viewer"]`,
+		},
+		{
+			name:      "member without a type",
+			bindings:  `{"roles/viewer": ["tenant@example.com"]}`,
+			want:      map[string][]string{"roles/viewer": {"tenant@example.com"}},
+			wantField: `addlGroupIamBindings["roles/viewer"]`,
+		},
+		{
+			name:      "unknown member type",
+			bindings:  `{"roles/viewer": ["robot:tenant@example.com"]}`,
+			want:      map[string][]string{"roles/viewer": {"robot:tenant@example.com"}},
+			wantField: `addlGroupIamBindings["roles/viewer"]`,
+		},
+		{
+			name:      "member without an identity",
+			bindings:  `{"roles/viewer": ["group:"]}`,
+			want:      map[string][]string{"roles/viewer": {"group:"}},
+			wantField: `addlGroupIamBindings["roles/viewer"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := validJSON(t, func(fields map[string]interface{}) {
+				fields["addlGroupIamBindings"] = json.RawMessage(tt.bindings)
+			})
+			var message TinyHomeInstructions
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(message.AddlGroupIamBindings, tt.want) {
+				t.Errorf("AddlGroupIamBindings = %v, want %v", message.AddlGroupIamBindings, tt.want)
+			}
+
+			_, err := message.DryRun(validAttributes())
+			wantFieldError(t, err, tt.wantField)
+		})
+	}
+}
+
+func TestIamMemberDomains(t *testing.T) {
+	domains := []string{"example.com", "@Corp.Example.org"}
+
+	tests := []struct {
+		name     string
+		domains  []string
+		bindings map[string][]string
+		// wantMember is the member reported, empty when the bindings are allowed
+		wantMember string
+	}{
+		{
+			name:     "internal members",
+			domains:  domains,
+			bindings: map[string][]string{"roles/viewer": {"group:tenant@example.com", "user:Dev@CORP.example.org"}, "roles/editor": {"domain:example.com"}},
+		},
+		{
+			name:       "external user",
+			domains:    domains,
+			bindings:   map[string][]string{"roles/viewer": {"group:tenant@example.com", "user:someone@gmail.com"}},
+			wantMember: "user:someone@gmail.com",
+		},
+		{
+			name:       "subdomain of an allowed domain",
+			domains:    domains,
+			bindings:   map[string][]string{"roles/viewer": {"user:dev@eng.example.com"}},
+			wantMember: "user:dev@eng.example.com",
+		},
+		{
+			name:       "lookalike domain",
+			domains:    domains,
+			bindings:   map[string][]string{"roles/viewer": {"group:tenant@notexample.com"}},
+			wantMember: "group:tenant@notexample.com",
+		},
+		{
+			name:       "external domain principal",
+			domains:    domains,
+			bindings:   map[string][]string{"roles/editor": {"domain:partner.com"}},
+			wantMember: "domain:partner.com",
+		},
+		{
+			name:     "empty allowlist",
+			bindings: map[string][]string{"roles/viewer": {"user:someone@gmail.com"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.AddlGroupIamBindings = tt.bindings
+			_, err := message.DryRun(validAttributes(), WithIAMMemberDomains(tt.domains))
+			if tt.wantMember == "" {
+				wantFieldError(t, err, "")
+				return
+			}
+
+			var validationErr ValidationError
+			if !errors.As(err, &validationErr) || !strings.HasPrefix(validationErr.FieldName(), "addlGroupIamBindings[") {
+				t.Fatalf("error = %v, want a ValidationError for an addlGroupIamBindings role", err)
+			}
+			if want := fmt.Sprintf("member %q is outside the allowed domains", tt.wantMember); !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not mention %s", err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// labelAttributePrefix starts the attribute key of each of Labels
+	labelAttributePrefix = "label."
+
+	// maxLabels is the most labels GCP allows on a resource
+	maxLabels = 64
+
+	// maxLabelLength is the longest GCP label key or value
+	maxLabelLength = 63
+)
+
+// validateLabels checks Labels follow the GCP label rules: at most 64 labels, keys of
+// 1 to 63 characters starting with a lower case letter and values of at most 63
+// characters, both using only lower case letters, digits, '_' and '-'
+func (message TinyHomeInstructions) validateLabels() error {
+	if len(message.Labels) > maxLabels {
+		return &NamingError{Field: "labels", Message: fmt.Sprintf("labels has %d entries, at most %d are allowed", len(message.Labels), maxLabels)}
+	}
+
+	for _, key := range sortedKeys(message.Labels) {
+		if key == "" || key[0] < 'a' || key[0] > 'z' {
+			return &NamingError{Field: "labels", Message: fmt.Sprintf("label key %q must start with a lower case letter", key)}
+		}
+
+		if err := validateLabelPart(key); err != nil {
+			return &NamingError{Field: "labels", Message: fmt.Sprintf("label key %q %v", key, err)}
+		}
+
+		if err := validateLabelPart(message.Labels[key]); err != nil {
+			return &NamingError{Field: "labels", Message: fmt.Sprintf("label %s value %v", key, err)}
+		}
+	}
+	return nil
+}
+
+// validateLabelPart checks a label key or value is at most maxLabelLength lower case
+// letters, digits, '_' and '-'
+func validateLabelPart(s string) error {
+	if len(s) > maxLabelLength {
+		return fmt.Errorf("greater than %d characters", maxLabelLength)
+	}
+
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' && r != '-' {
+			return fmt.Errorf("contains unsupported character %q, only lower case letters, digits, '_' and '-' are supported", r)
+		}
+	}
+	return nil
+}
+
+// addLabelAttributes sets a label.<key> attribute for each of labels. The prefix keeps
+// them apart from the routing attributes, any other attribute already set is a
+// collision.
+func addLabelAttributes(labels map[string]string, attributes map[string]string) error {
+	for _, key := range sortedKeys(labels) {
+		name := labelAttributePrefix + key
+		if _, ok := attributes[name]; ok {
+			return fmt.Errorf("label attribute %s collides with an attribute set by the publisher", name)
+		}
+		attributes[name] = labels[key]
+	}
+	return nil
+}
+
+// mergeLabels returns labels with the template's labels added for keys it does not
+// set, as a new map so neither input is aliased
+func mergeLabels(labels, tmpl map[string]string) map[string]string {
+	if len(labels) == 0 && len(tmpl) == 0 {
+		return labels
+	}
+
+	merged := make(map[string]string, len(labels)+len(tmpl))
+	for key, value := range tmpl {
+		merged[key] = value
+	}
+	for key, value := range labels {
+		merged[key] = value
+	}
+	return merged
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
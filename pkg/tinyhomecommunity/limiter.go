@@ -0,0 +1,88 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultMaxConcurrentPublishes is the number of publish operations allowed in flight
+// against a single topic by a Publisher without MaxConcurrentPublishes
+const DefaultMaxConcurrentPublishes = 64
+
+// PublishWaitStats reports how long publish operations have waited for a free slot
+// on their topic before being sent
+type PublishWaitStats struct {
+	Acquisitions int64         `json:"acquisitions"`
+	TotalWait    time.Duration `json:"totalWait"`
+	MaxWait      time.Duration `json:"maxWait"`
+}
+
+// topicLimiter caps the number of concurrent publish operations per topic so many
+// goroutines sharing a topic can not exhaust the underlying gRPC stream
+type topicLimiter struct {
+	mu    sync.Mutex
+	max   int
+	slots map[string]chan struct{}
+	stats PublishWaitStats
+}
+
+// PublishWaitStats returns a snapshot of the time publishes of p have spent waiting on
+// its concurrency limit
+func (p *Publisher) PublishWaitStats() PublishWaitStats {
+	return p.concurrencyLimiter().snapshot()
+}
+
+// concurrencyLimiter returns the limiter publishes of p take a slot from, allowing
+// MaxConcurrentPublishes, or DefaultMaxConcurrentPublishes when it is 0, per topic
+func (p *Publisher) concurrencyLimiter() *topicLimiter {
+	p.limiterOnce.Do(func() {
+		max := p.cfg.MaxConcurrentPublishes
+		if max == 0 {
+			max = DefaultMaxConcurrentPublishes
+		}
+		p.limiter = &topicLimiter{max: max}
+	})
+	return p.limiter
+}
+
+func (l *topicLimiter) snapshot() PublishWaitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// acquire blocks until a publish slot for topicId is free or ctx is done. The returned
+// func must be called to release the slot.
+func (l *topicLimiter) acquire(ctx context.Context, topicId string) (func(), error) {
+	l.mu.Lock()
+	if l.slots == nil {
+		l.slots = map[string]chan struct{}{}
+	}
+	slot, ok := l.slots[topicId]
+	if !ok {
+		slot = make(chan struct{}, l.max)
+		l.slots[topicId] = slot
+	}
+	l.mu.Unlock()
+
+	start := time.Now()
+	select {
+	case slot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	l.record(time.Since(start))
+
+	return func() { <-slot }, nil
+}
+
+func (l *topicLimiter) record(wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.Acquisitions++
+	l.stats.TotalWait += wait
+	if wait > l.stats.MaxWait {
+		l.stats.MaxWait = wait
+	}
+}
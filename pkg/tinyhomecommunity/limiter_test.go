@@ -0,0 +1,122 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestMaxConcurrentPublishes(t *testing.T) {
+	tests := []struct {
+		name      string
+		max       int
+		publishes int
+	}{
+		{"one at a time", 1, 4},
+		{"several at a time", 3, 8},
+		{"limit above publishes", 10, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+				return fakeResult{id: "id", ready: release}
+			}}
+			p := newTestPublisher(t, topic, nil, WithMaxConcurrentPublishes(tt.max))
+
+			var wg sync.WaitGroup
+			errs := make(chan error, tt.publishes)
+			for i := 0; i < tt.publishes; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					message := validInstructions()
+					_, err := p.PublishContext(context.Background(), &message, validAttributes())
+					errs <- err
+				}()
+			}
+
+			// Publishes past the limit wait for a slot rather than reach the topic
+			inFlight := tt.max
+			if tt.publishes < inFlight {
+				inFlight = tt.publishes
+			}
+			eventually(t, func() bool { return len(topic.published()) == inFlight })
+			time.Sleep(10 * time.Millisecond)
+			if got := len(topic.published()); got != inFlight {
+				t.Fatalf("%d messages in flight, want at most %d", got, inFlight)
+			}
+
+			close(release)
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Errorf("PublishContext: %v", err)
+				}
+			}
+			if got := len(topic.published()); got != tt.publishes {
+				t.Errorf("%d messages published, want %d", got, tt.publishes)
+			}
+			if stats := p.PublishWaitStats(); stats.Acquisitions != int64(tt.publishes) {
+				t.Errorf("PublishWaitStats().Acquisitions = %d, want %d", stats.Acquisitions, tt.publishes)
+			}
+		})
+	}
+}
+
+func TestWithMaxConcurrentPublishesInvalid(t *testing.T) {
+	if _, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithMaxConcurrentPublishes(-1)); err == nil {
+		t.Fatal("newPublisherWithTopic with a negative limit returned no error")
+	}
+}
+
+func TestConcurrencyLimitPerPublisher(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		// busy is how many publishes hold the slots of the first Publisher
+		busy int
+	}{
+		{name: "default limit", busy: DefaultMaxConcurrentPublishes},
+		{name: "configured limit", opts: []Option{WithMaxConcurrentPublishes(2)}, busy: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			defer close(release)
+			busyTopic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+				return fakeResult{id: "id", ready: release}
+			}}
+			busy := newTestPublisher(t, busyTopic, nil, tt.opts...)
+			for i := 0; i < tt.busy; i++ {
+				go func() {
+					message := validInstructions()
+					busy.PublishContext(context.Background(), &message, validAttributes())
+				}()
+			}
+			eventually(t, func() bool { return len(busyTopic.published()) == tt.busy })
+
+			// The first Publisher is at its limit on the same topic ID, which does not
+			// hold up a second one
+			other := newTestPublisher(t, &fakeTopic{}, nil, tt.opts...)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			message := validInstructions()
+			if _, err := other.PublishContext(ctx, &message, validAttributes()); err != nil {
+				t.Fatalf("PublishContext on a second Publisher: %v", err)
+			}
+			if got := other.PublishWaitStats().Acquisitions; got != 1 {
+				t.Errorf("second Publisher PublishWaitStats().Acquisitions = %d, want 1", got)
+			}
+			if got := busy.PublishWaitStats().Acquisitions; got != int64(tt.busy) {
+				t.Errorf("first Publisher PublishWaitStats().Acquisitions = %d, want %d", got, tt.busy)
+			}
+		})
+	}
+}
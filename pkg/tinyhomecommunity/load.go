@@ -0,0 +1,86 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// LoadInstructionsFromJSON decodes a single JSON instructions object from r, with no
+// unknown fields, and validates it, so instructions it returns are valid. Decode
+// failures match ErrDecode and validation failures are ValidationError values.
+func LoadInstructionsFromJSON(r io.Reader) (*TinyHomeInstructions, error) {
+	message, err := loadJSON(r)
+	if err != nil {
+		return nil, fmt.Errorf("LoadInstructionsFromJSON: %w", err)
+	}
+	return message, nil
+}
+
+// LoadInstructionsFromYAML is LoadInstructionsFromJSON for YAML, using the json field
+// names as keys
+func LoadInstructionsFromYAML(r io.Reader) (*TinyHomeInstructions, error) {
+	message, err := loadYAML(r)
+	if err != nil {
+		return nil, fmt.Errorf("LoadInstructionsFromYAML: %w", err)
+	}
+	return message, nil
+}
+
+// LoadInstructionsFromFile loads instructions from the file at path, as YAML when it
+// ends in .yaml or .yml and as JSON otherwise. See LoadInstructionsFromJSON.
+func LoadInstructionsFromFile(path string) (*TinyHomeInstructions, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadInstructionsFromFile: %v", err)
+	}
+	defer f.Close()
+
+	load := loadJSON
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		load = loadYAML
+	}
+
+	message, err := load(f)
+	if err != nil {
+		return nil, fmt.Errorf("LoadInstructionsFromFile: %s: %w", path, err)
+	}
+	return message, nil
+}
+
+// loadJSON reads r and strictly decodes and validates it
+func loadJSON(r io.Reader) (*TinyHomeInstructions, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read: %v", err)
+	}
+	return validRaw(data)
+}
+
+// loadYAML reads r, converts it to JSON and strictly decodes and validates it
+func loadYAML(r io.Reader) (*TinyHomeInstructions, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read: %v", err)
+	}
+
+	data, err = yaml.YAMLToJSONStrict(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+	return validRaw(data)
+}
+
+// validRaw is validateRaw returning no instructions when they are invalid
+func validRaw(data []byte) (*TinyHomeInstructions, error) {
+	message, err := validateRaw(data)
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
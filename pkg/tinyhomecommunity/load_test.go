@@ -0,0 +1,134 @@
+package tinyhomecommunity
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+// instructionsYAML returns message as YAML, with extra keys added to it
+func instructionsYAML(t *testing.T, message TinyHomeInstructions, extra map[string]any) []byte {
+	t.Helper()
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range extra {
+		fields[key] = value
+	}
+	if data, err = yaml.Marshal(fields); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestLoadInstructionsFromYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		message TinyHomeInstructions
+		extra   map[string]any
+		// raw replaces the YAML of message when set
+		raw string
+		// wantDecode expects an ErrDecode failure mentioning wantMessage
+		wantDecode  bool
+		wantMessage string
+		// wantField is the field of the ValidationError, empty when the file loads
+		wantField string
+	}{
+		{name: "valid file", message: validInstructions()},
+		{name: "valid break-glass duration", message: breakglassInstructions("dev")},
+		{
+			name:        "unknown key",
+			message:     validInstructions(),
+			extra:       map[string]any{"tenantowner": "owner@example.com"},
+			wantDecode:  true,
+			wantMessage: "tenantowner",
+		},
+		{
+			name:        "duplicate key",
+			raw:         "tenantName: contract-tenant\ntenantName: other-tenant\n",
+			wantDecode:  true,
+			wantMessage: "tenantName",
+		},
+		{name: "malformed YAML", raw: "tenantName: [contract-tenant\n", wantDecode: true},
+		{
+			name: "bad duration",
+			message: func() TinyHomeInstructions {
+				m := breakglassInstructions("dev")
+				m.BreakglassWindow = "forever"
+				return m
+			}(),
+			wantField:   "breakglassWindow",
+			wantMessage: `breakglassWindow "forever" is invalid`,
+		},
+		{
+			name: "negative duration",
+			message: func() TinyHomeInstructions {
+				m := breakglassInstructions("dev")
+				m.BreakglassWindow = "-4h"
+				return m
+			}(),
+			wantField:   "breakglassWindow",
+			wantMessage: "duration must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		data := []byte(tt.raw)
+		if tt.raw == "" {
+			data = instructionsYAML(t, tt.message, tt.extra)
+		}
+		check := func(t *testing.T, got *TinyHomeInstructions, err error) {
+			t.Helper()
+			if tt.wantMessage != "" && (err == nil || !strings.Contains(err.Error(), tt.wantMessage)) {
+				t.Errorf("error = %v, want it to mention %q", err, tt.wantMessage)
+			}
+			if tt.wantDecode {
+				var validationErr ValidationError
+				if !errors.Is(err, ErrDecode) || errors.As(err, &validationErr) {
+					t.Fatalf("error = %v, want only ErrDecode", err)
+				}
+				return
+			}
+			wantFieldError(t, err, tt.wantField)
+			if tt.wantField != "" {
+				if errors.Is(err, ErrDecode) {
+					t.Errorf("error = %v, want a validation failure apart from ErrDecode", err)
+				}
+				if got != nil {
+					t.Errorf("returned %+v with the validation failure, want nil", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(*got, tt.message) {
+				t.Errorf("loaded %+v, want %+v", *got, tt.message)
+			}
+		}
+
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadInstructionsFromYAML(strings.NewReader(string(data)))
+			check(t, got, err)
+		})
+		t.Run(tt.name+" from file", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tenant.yaml")
+			if err := os.WriteFile(path, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadInstructionsFromFile(path)
+			check(t, got, err)
+			if err != nil && !strings.Contains(err.Error(), path) {
+				t.Errorf("error = %v, want it to name %s", err, path)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler is a slog.Handler that drops every record
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// nopLogger is used when no Logger is configured, so the package never writes to the
+// application's default logger
+var nopLogger = slog.New(discardHandler{})
+
+// logger returns the configured Logger or a no-op logger
+func (p *Publisher) logger() *slog.Logger {
+	return p.cfg.logger()
+}
+
+func (cfg PublisherConfig) logger() *slog.Logger {
+	if cfg.Logger == nil {
+		return nopLogger
+	}
+	return cfg.Logger
+}
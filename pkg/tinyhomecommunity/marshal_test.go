@@ -0,0 +1,59 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMarshalFailure(t *testing.T) {
+	errMarshal := errors.New("forced marshal failure")
+	marshal := marshalInstructions
+	t.Cleanup(func() { marshalInstructions = marshal })
+	marshalInstructions = func(*TinyHomeInstructions) ([]byte, error) { return nil, errMarshal }
+
+	tests := []struct {
+		name    string
+		publish func(p *Publisher, message *TinyHomeInstructions) error
+	}{
+		{
+			name: "PublishContext",
+			publish: func(p *Publisher, message *TinyHomeInstructions) error {
+				_, err := p.PublishContext(context.Background(), message, validAttributes())
+				return err
+			},
+		},
+		{
+			name: "PublishAsync",
+			publish: func(p *Publisher, message *TinyHomeInstructions) error {
+				_, err := p.PublishAsync(context.Background(), message, validAttributes())
+				return err
+			},
+		},
+		{
+			name: "PublishBatch",
+			publish: func(p *Publisher, message *TinyHomeInstructions) error {
+				_, err := p.PublishBatch(context.Background(), []*TinyHomeInstructions{message}, []*TinyHomeMessageAttributes{validAttributes()})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil)
+			message := validInstructions()
+
+			err := tt.publish(p, &message)
+			if err == nil || !strings.Contains(err.Error(), "marshal: forced marshal failure") {
+				t.Fatalf("%s error = %v, want the marshal failure", tt.name, err)
+			}
+			// Nothing, in particular no empty payload, is published
+			if got := len(topic.published()); got != 0 {
+				t.Errorf("published %d messages, want none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"sort"
+)
+
+// messageKeyFields returns the json names of the instructions fields MessageKeyField
+// can name
+func messageKeyFields() []string {
+	fields := (TinyHomeInstructions{}).identifierFields()
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyMessageKey sets the messageKey attribute to the value of the MessageKeyField
+// field, when one is configured, rejecting instructions that leave it empty
+func (message TinyHomeInstructions) applyMessageKey(field string, attributes map[string]string) error {
+	if field == "" {
+		return nil
+	}
+
+	key := message.identifierFields()[field]
+	if key == "" {
+		return &NamingError{Field: field, Message: fmt.Sprintf("%s can not be empty, it is the MessageKeyField the messageKey attribute is taken from", field)}
+	}
+	attributes["messageKey"] = key
+	return nil
+}
@@ -0,0 +1,99 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMessageKey(t *testing.T) {
+	tests := []struct {
+		name            string
+		opts            []Option
+		modify          func(*TinyHomeInstructions)
+		wantMessageKey  string
+		wantOrderingKey string
+		wantErrField    string
+	}{
+		{name: "not configured"},
+		{
+			name:           "from businessUnit",
+			opts:           []Option{WithMessageKeyField("businessUnit")},
+			wantMessageKey: "platform",
+		},
+		{
+			name:            "independent of the ordering key",
+			opts:            []Option{WithMessageKeyField("businessUnit"), WithMessageOrdering(true)},
+			wantMessageKey:  "platform",
+			wantOrderingKey: "contract-tenant",
+		},
+		{
+			name:            "same field as the ordering key",
+			opts:            []Option{WithMessageKeyField("tenantName"), WithMessageOrdering(true)},
+			wantMessageKey:  "contract-tenant",
+			wantOrderingKey: "contract-tenant",
+		},
+		{
+			name:            "ordering key only",
+			opts:            []Option{WithMessageOrdering(true)},
+			wantOrderingKey: "contract-tenant",
+		},
+		{
+			name:         "empty source field",
+			opts:         []Option{WithMessageKeyField("domain")},
+			modify:       func(m *TinyHomeInstructions) { m.Domain = "" },
+			wantErrField: "domain",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, tt.opts...)
+
+			message := validInstructions()
+			if tt.modify != nil {
+				tt.modify(&message)
+			}
+			_, err := p.PublishContext(context.Background(), &message, validAttributes())
+			wantFieldError(t, err, tt.wantErrField)
+			if tt.wantErrField != "" {
+				if !strings.Contains(err.Error(), "MessageKeyField") {
+					t.Errorf("PublishContext error = %v, want it to name the MessageKeyField", err)
+				}
+				return
+			}
+
+			msg := topic.published()[0]
+			if got, ok := msg.Attributes["messageKey"]; got != tt.wantMessageKey || ok != (tt.wantMessageKey != "") {
+				t.Errorf("messageKey attribute = %q, present %t, want %q", got, ok, tt.wantMessageKey)
+			}
+			if msg.OrderingKey != tt.wantOrderingKey {
+				t.Errorf("OrderingKey = %q, want %q", msg.OrderingKey, tt.wantOrderingKey)
+			}
+		})
+	}
+}
+
+func TestMessageKeyFieldValidated(t *testing.T) {
+	tests := []struct {
+		field   string
+		wantErr bool
+	}{
+		{field: ""},
+		{field: "tenantName"},
+		{field: "environment"},
+		{field: "tenantCostCenter"},
+		{field: "tenantOwner", wantErr: true},
+		{field: "BusinessUnit", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			_, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithMessageKeyField(tt.field))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newPublisherWithTopic error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package tinyhomecommunity
+
+import "time"
+
+// Metrics receives one call per publish for SLO monitoring. subscription is the stage
+// the attributes route to, empty when routing failed, and outcome is one of
+// OutcomePublished, OutcomeInvalid or OutcomeFailed. Implementations must be safe for
+// concurrent use.
+type Metrics interface {
+	RecordPublish(subscription string, outcome string, dur time.Duration)
+}
+
+// InFlightMetrics is implemented by Metrics that also track the messages in flight.
+// AddInFlight is called with 1 when a message is handed to its topic and with -1 once
+// its result is in.
+type InFlightMetrics interface {
+	AddInFlight(delta int)
+}
+
+// MetricsFunc adapts a function to Metrics
+type MetricsFunc func(subscription string, outcome string, dur time.Duration)
+
+func (f MetricsFunc) RecordPublish(subscription string, outcome string, dur time.Duration) {
+	f(subscription, outcome, dur)
+}
+
+// recordPublish reports a finished publish, including one that failed validation,
+// to the PublishLog, Metrics and Stats of p
+func (p *Publisher) recordPublish(line PublishLogLine, start time.Time, err error) {
+	writePublishLog(p.cfg.PublishLog, line, start, err)
+	outcome := publishOutcome(err)
+	p.stats.record(line.Stage, outcome)
+	if p.cfg.Metrics != nil {
+		p.cfg.Metrics.RecordPublish(line.Stage, outcome, time.Since(start))
+	}
+}
+
+// addInFlight reports delta messages in flight to Metrics, when it is InFlightMetrics
+func (p *Publisher) addInFlight(delta int) {
+	if metrics, ok := p.cfg.Metrics.(InFlightMetrics); ok {
+		metrics.AddInFlight(delta)
+	}
+}
@@ -0,0 +1,57 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NameGenerator produces a tenant name for instructions that leave TenantName blank.
+// The generated name is validated like any other.
+type NameGenerator interface {
+	GenerateName(message TinyHomeInstructions) (string, error)
+}
+
+// NameGeneratorFunc adapts a function to a NameGenerator
+type NameGeneratorFunc func(message TinyHomeInstructions) (string, error)
+
+func (f NameGeneratorFunc) GenerateName(message TinyHomeInstructions) (string, error) {
+	return f(message)
+}
+
+// generateTenantName fills a blank TenantName from g, if set. Explicit names are
+// never replaced.
+func (message *TinyHomeInstructions) generateTenantName(g NameGenerator) error {
+	if message.TenantName != "" {
+		return nil
+	}
+
+	if g == nil {
+		return nil
+	}
+
+	name, err := g.GenerateName(*message)
+	if err != nil {
+		return fmt.Errorf("generate tenantName: %v", err)
+	}
+	message.TenantName = name
+	return nil
+}
+
+// validateTenantNameUnused rejects a TenantName already in existing, a snapshot of
+// current tenant names
+func (message TinyHomeInstructions) validateTenantNameUnused(existing map[string]bool) error {
+	if existing[message.TenantName] {
+		return &NamingError{Field: "tenantName", Message: fmt.Sprintf("tenantName %q is already used by an existing tenant", message.TenantName)}
+	}
+	return nil
+}
+
+// validateBusinessUnitPrefix requires TenantName to start with the prefix prefixes
+// maps the BusinessUnit to, if any
+func (message TinyHomeInstructions) validateBusinessUnitPrefix(prefixes map[string]string) error {
+	prefix, ok := prefixes[message.BusinessUnit]
+	if !ok || strings.HasPrefix(message.TenantName, prefix) {
+		return nil
+	}
+	return &NamingError{Field: "tenantName", Message: fmt.Sprintf("tenantName %q for businessUnit %q must start with %q", message.TenantName, message.BusinessUnit, prefix)}
+}
@@ -0,0 +1,147 @@
+package tinyhomecommunity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// orgSuffixGenerator is a deterministic NameGenerator naming tenants after the last
+// digits of their Organization and a fixed suffix, counting its calls
+type orgSuffixGenerator struct {
+	suffix string
+	calls  int
+}
+
+func (g *orgSuffixGenerator) GenerateName(message TinyHomeInstructions) (string, error) {
+	g.calls++
+	return "org-" + message.Organization[len(message.Organization)-4:] + "-" + g.suffix, nil
+}
+
+func TestNameGenerator(t *testing.T) {
+	errGenerate := errors.New("generator unavailable")
+	tests := []struct {
+		name       string
+		tenantName string
+		generator  NameGenerator
+		want       string
+		wantField  string
+		wantErr    error
+	}{
+		{name: "blank name generated", generator: &orgSuffixGenerator{suffix: "a1"}, want: "org-9012-a1"},
+		{name: "explicit name wins", tenantName: "explicit-tenant", generator: &orgSuffixGenerator{suffix: "a1"}, want: "explicit-tenant"},
+		{name: "generated name is validated", generator: &orgSuffixGenerator{suffix: "A1"}, wantField: "tenantName"},
+		{
+			name:      "generator error",
+			generator: NameGeneratorFunc(func(TinyHomeInstructions) (string, error) { return "", errGenerate }),
+			wantErr:   errGenerate,
+		},
+		{name: "no generator", wantField: "tenantName"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.TenantName = tt.tenantName
+			var opts []Option
+			if tt.generator != nil {
+				opts = append(opts, WithNameGenerator(tt.generator))
+			}
+			result, err := message.DryRun(validAttributes(), opts...)
+
+			switch {
+			case tt.wantErr != nil:
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr.Error()) {
+					t.Fatalf("DryRun error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantField != "":
+				wantFieldError(t, err, tt.wantField)
+				return
+			case err != nil:
+				t.Fatalf("DryRun: %v", err)
+			}
+
+			if result.TenantName != tt.want || message.TenantName != tt.want {
+				t.Errorf("result TenantName = %q, instructions %q, want %q", result.TenantName, message.TenantName, tt.want)
+			}
+			if got := result.Attributes[AttrTenantName]; got != tt.want {
+				t.Errorf("tenantName attribute = %q, want %q", got, tt.want)
+			}
+			if g, ok := tt.generator.(*orgSuffixGenerator); ok && tt.tenantName != "" && g.calls != 0 {
+				t.Errorf("generator called %d times for an explicit name", g.calls)
+			}
+		})
+	}
+}
+
+func TestExistingTenantNames(t *testing.T) {
+	existing := []string{"acme", "contract-tenant", "team-blue"}
+
+	tests := []struct {
+		name       string
+		existing   []string
+		tenantName string
+		wantErr    bool
+	}{
+		{name: "new name", existing: existing, tenantName: "team-green"},
+		{name: "existing name", existing: existing, tenantName: "contract-tenant", wantErr: true},
+		{name: "prefix of an existing name", existing: existing, tenantName: "team"},
+		{name: "no snapshot", tenantName: "contract-tenant"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.TenantName = tt.tenantName
+			_, err := message.DryRun(validAttributes(), WithExistingTenantNames(tt.existing))
+			if !tt.wantErr {
+				wantFieldError(t, err, "")
+				return
+			}
+			wantFieldError(t, err, "tenantName")
+			if want := `tenantName "` + tt.tenantName + `" is already used`; !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not mention %q", err, want)
+			}
+		})
+	}
+}
+
+func TestBusinessUnitPrefixes(t *testing.T) {
+	prefixes := map[string]string{"payments": "payments-", "platform": "plat-"}
+
+	tests := []struct {
+		name         string
+		prefixes     map[string]string
+		businessUnit string
+		tenantName   string
+		wantErr      bool
+	}{
+		{name: "compliant name", prefixes: prefixes, businessUnit: "payments", tenantName: "payments-ledger"},
+		{name: "other compliant name", prefixes: prefixes, businessUnit: "platform", tenantName: "plat-tools"},
+		{name: "missing prefix", prefixes: prefixes, businessUnit: "payments", tenantName: "ledger", wantErr: true},
+		{name: "prefix of another unit", prefixes: prefixes, businessUnit: "platform", tenantName: "payments-ledger", wantErr: true},
+		{name: "prefix without the separator", prefixes: prefixes, businessUnit: "payments", tenantName: "paymentsledger", wantErr: true},
+		{name: "unit without a prefix", prefixes: prefixes, businessUnit: "research", tenantName: "ledger"},
+		{name: "no prefixes", businessUnit: "payments", tenantName: "ledger"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.BusinessUnit, message.TenantName = tt.businessUnit, tt.tenantName
+			_, err := message.DryRun(validAttributes(), WithBusinessUnitPrefixes(tt.prefixes))
+			if !tt.wantErr {
+				wantFieldError(t, err, "")
+				return
+			}
+			wantFieldError(t, err, "tenantName")
+			// The error names the name, business unit and required prefix
+			for _, want := range []string{`"` + tt.tenantName + `"`, `businessUnit "` + tt.businessUnit + `"`, `must start with "` + tt.prefixes[tt.businessUnit] + `"`} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %s", err, want)
+				}
+			}
+		})
+	}
+}
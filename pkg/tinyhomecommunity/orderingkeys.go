@@ -0,0 +1,72 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// OrderingKeyCollisionPolicy decides what PublishBatch does when MessageOrdering is
+// enabled and several messages of a batch share an ordering key, so Pub/Sub
+// publishes them one after another
+type OrderingKeyCollisionPolicy int
+
+const (
+	// OrderingKeyCollisionIgnore publishes the batch as is
+	OrderingKeyCollisionIgnore OrderingKeyCollisionPolicy = iota
+	// OrderingKeyCollisionWarn logs the colliding keys and publishes the batch
+	OrderingKeyCollisionWarn
+	// OrderingKeyCollisionReject fails the batch before anything is published
+	OrderingKeyCollisionReject
+)
+
+// orderingKeyCollisions returns the indices of prepared messages sharing each ordering
+// key, for keys used more than once. It is empty without MessageOrdering.
+func (p *Publisher) orderingKeyCollisions(prepared []*preparedMessage) map[string][]int {
+	indices := map[string][]int{}
+	for i, message := range prepared {
+		if key := p.orderingKey(message.attributes); key != "" {
+			indices[key] = append(indices[key], i)
+		}
+	}
+
+	for key, shared := range indices {
+		if len(shared) < 2 {
+			delete(indices, key)
+		}
+	}
+	return indices
+}
+
+// checkOrderingKeyCollisions applies the OrderingKeyCollisions policy to a batch of
+// prepared messages. With OrderingKeyCollisionReject the error is a BatchError naming
+// every message with a colliding key.
+func (p *Publisher) checkOrderingKeyCollisions(ctx context.Context, prepared []*preparedMessage) error {
+	if p.cfg.OrderingKeyCollisions == OrderingKeyCollisionIgnore {
+		return nil
+	}
+
+	collisions := p.orderingKeyCollisions(prepared)
+	if len(collisions) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(collisions))
+	for key := range collisions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if p.cfg.OrderingKeyCollisions == OrderingKeyCollisionWarn {
+		p.logger().WarnContext(ctx, "batch messages share ordering keys and will be published one after another", "orderingKeys", keys)
+		return nil
+	}
+
+	failures := map[int]error{}
+	for _, key := range keys {
+		for _, i := range collisions[key] {
+			failures[i] = fmt.Errorf("ordering key %q is shared by messages %v", key, collisions[key])
+		}
+	}
+	return &BatchError{Failures: failures}
+}
@@ -0,0 +1,142 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestOrderingKeyCollisions(t *testing.T) {
+	tests := []struct {
+		name     string
+		ordering bool
+		policy   OrderingKeyCollisionPolicy
+		// tenants are the tenant names of the batch, and so its ordering keys
+		tenants []string
+		// wantFailed are the indices rejected for a colliding key, none when the
+		// batch is published
+		wantFailed []int
+		wantWarn   bool
+	}{
+		{name: "ignored by default", ordering: true, tenants: []string{"tenant-a", "tenant-a"}},
+		{name: "warn", ordering: true, policy: OrderingKeyCollisionWarn, tenants: []string{"tenant-a", "tenant-b", "tenant-a"}, wantWarn: true},
+		{name: "warn without collisions", ordering: true, policy: OrderingKeyCollisionWarn, tenants: []string{"tenant-a", "tenant-b"}},
+		{
+			name:       "reject",
+			ordering:   true,
+			policy:     OrderingKeyCollisionReject,
+			tenants:    []string{"tenant-a", "tenant-b", "tenant-a", "tenant-c", "tenant-b"},
+			wantFailed: []int{0, 1, 2, 4},
+		},
+		{name: "reject without collisions", ordering: true, policy: OrderingKeyCollisionReject, tenants: []string{"tenant-a", "tenant-b"}},
+		{name: "no ordering keys without ordering", policy: OrderingKeyCollisionReject, tenants: []string{"tenant-a", "tenant-a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil,
+				WithMessageOrdering(tt.ordering),
+				WithOrderingKeyCollisions(tt.policy),
+				WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+			)
+
+			msgs := make([]*TinyHomeInstructions, len(tt.tenants))
+			attrs := make([]*TinyHomeMessageAttributes, len(tt.tenants))
+			for i, tenant := range tt.tenants {
+				message := validInstructions()
+				message.TenantName = tenant
+				msgs[i], attrs[i] = &message, validAttributes()
+			}
+
+			_, err := p.PublishBatch(context.Background(), msgs, attrs)
+			if len(tt.wantFailed) > 0 {
+				var batchErr *BatchError
+				if !errors.As(err, &batchErr) {
+					t.Fatalf("PublishBatch error = %v, want a BatchError", err)
+				}
+				if len(batchErr.Failures) != len(tt.wantFailed) {
+					t.Errorf("failed messages %v, want %v", batchErr.Failures, tt.wantFailed)
+				}
+				for _, i := range tt.wantFailed {
+					if failure := batchErr.Failures[i]; failure == nil || !strings.Contains(failure.Error(), tt.tenants[i]) {
+						t.Errorf("message %d error = %v, want it to name ordering key %s", i, failure, tt.tenants[i])
+					}
+				}
+				if got := topic.published(); len(got) != 0 {
+					t.Errorf("published %d messages, want none", len(got))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("PublishBatch: %v", err)
+			}
+			if got := topic.published(); len(got) != len(tt.tenants) {
+				t.Errorf("published %d messages, want %d", len(got), len(tt.tenants))
+			}
+
+			warned := strings.Contains(logs.String(), "share ordering keys")
+			if warned != tt.wantWarn {
+				t.Errorf("warned %v, want %v, logs:\n%s", warned, tt.wantWarn, logs.String())
+			}
+			if tt.wantWarn && !strings.Contains(logs.String(), "orderingKeys=[tenant-a]") {
+				t.Errorf("warning does not name the colliding key tenant-a:\n%s", logs.String())
+			}
+		})
+	}
+}
+
+func TestErrOrderingNotSupported(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "server rejects the ordering key", err: status.Error(codes.InvalidArgument, "Message ordering is not enabled for this topic"), want: true},
+		{name: "failed precondition on ordering", err: status.Error(codes.FailedPrecondition, "topic does not support ordering"), want: true},
+		{name: "handle without ordering", err: errors.New("pubsub: Topic.EnableMessageOrdering=false, but an OrderingKey was set in Message"), want: true},
+		{name: "other invalid argument", err: status.Error(codes.InvalidArgument, "attribute value too long")},
+		{name: "other failure", err: errors.New("pubsub unavailable")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+				return fakeResult{err: tt.err}
+			}}
+			p := newTestPublisher(t, topic, nil, WithMessageOrdering(true))
+
+			message := validInstructions()
+			_, err := p.PublishContext(context.Background(), &message, validAttributes())
+			if got := errors.Is(err, ErrOrderingNotSupported); got != tt.want {
+				t.Fatalf("PublishContext error = %v, ErrOrderingNotSupported %t, want %t", err, got, tt.want)
+			}
+
+			// The Pub/Sub error is still there to inspect, in a TransportError
+			var transportErr *TransportError
+			if !errors.As(err, &transportErr) || !errors.Is(err, tt.err) {
+				t.Errorf("PublishContext error = %v, want a TransportError wrapping %v", err, tt.err)
+			}
+			if tt.want && !strings.Contains(err.Error(), "must have message ordering enabled") {
+				t.Errorf("PublishContext error = %v, want it to tell the operator to enable ordering", err)
+			}
+
+			// The ordering key is resumed so later messages for the tenant are not blocked
+			topic.mu.Lock()
+			resumed := topic.resumed
+			topic.mu.Unlock()
+			if len(resumed) != 1 || resumed[0] != message.TenantName {
+				t.Errorf("resumed ordering keys %v, want [%s]", resumed, message.TenantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultOrganizationPattern accepts a numeric GCP organization ID or the
+// organization's domain, such as 123456789012 or example.com
+var DefaultOrganizationPattern = regexp.MustCompile(`^([0-9]+|([a-z0-9]([-a-z0-9]*[a-z0-9])?\.)+[a-z]{2,})$`)
+
+// validateDomainFormat checks Domain, when set, is a DNS name with a top level
+// domain. validateForStage requires it from createTenant on.
+func (message TinyHomeInstructions) validateDomainFormat() error {
+	if message.Domain == "" {
+		return nil
+	}
+
+	if err := validateDomain(message.Domain); err != nil {
+		return &NamingError{Field: "domain", Message: fmt.Sprintf("domain %q %v", message.Domain, err)}
+	}
+	return nil
+}
+
+// validateOrganization requires Organization, which downstream GCP org and folder
+// lookups use, and checks it matches pattern. A nil pattern only requires it be set.
+func (message TinyHomeInstructions) validateOrganization(pattern *regexp.Regexp) error {
+	if message.Organization == "" {
+		return &NamingError{Field: "organization", Message: "organization can not be empty"}
+	}
+
+	if pattern != nil && !pattern.MatchString(message.Organization) {
+		return &NamingError{Field: "organization", Message: fmt.Sprintf("organization %q does not match %s", message.Organization, pattern)}
+	}
+	return nil
+}
+
+// validateBusinessUnit requires BusinessUnit, which chargeback and labeling depend on,
+// and when allowed is set requires it to be exactly one of allowed
+func (message TinyHomeInstructions) validateBusinessUnit(allowed []string) error {
+	if message.BusinessUnit == "" {
+		return &NamingError{Field: "businessUnit", Message: "businessUnit can not be empty"}
+	}
+
+	if len(allowed) > 0 && !contains(allowed, message.BusinessUnit) {
+		return &NamingError{Field: "businessUnit", Message: fmt.Sprintf("businessUnit %q is not one of: %s", message.BusinessUnit, allowed)}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package tinyhomecommunity
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDomainFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		domain string
+		// wantMessage is the rule reported, empty when the domain is valid
+		wantMessage string
+	}{
+		{name: "valid domain", domain: "example.com"},
+		{name: "valid subdomain", domain: "tenants.example.co.uk"},
+		{name: "upper case", domain: "Example.COM"},
+		{name: "unset before createTenant", domain: ""},
+		{name: "bare hostname", domain: "example", wantMessage: "must include a top level domain"},
+		{name: "underscore in a label", domain: "bad_label.example.com", wantMessage: `label "bad_label"`},
+		{name: "space in a label", domain: "exa mple.com", wantMessage: `label "exa mple"`},
+		{name: "empty label", domain: "example..com", wantMessage: `label ""`},
+		{name: "over 253 characters", domain: strings.Repeat("a.", 127) + "com", wantMessage: "greater than 253 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.Domain = tt.domain
+			_, err := message.DryRun(validAttributes())
+			if tt.wantMessage == "" {
+				wantFieldError(t, err, "")
+				return
+			}
+			wantFieldError(t, err, "domain")
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestOrganization(t *testing.T) {
+	tests := []struct {
+		name         string
+		organization string
+		opts         []Option
+		// wantMessage is the rule reported, empty when the organization is valid
+		wantMessage string
+	}{
+		{name: "numeric ID", organization: "123456789012"},
+		{name: "domain", organization: "example.com"},
+		{name: "empty", organization: "", wantMessage: "organization can not be empty"},
+		{name: "whitespace", organization: "   ", wantMessage: "has leading or trailing whitespace"},
+		{name: "padded ID", organization: " 123456789012 ", wantMessage: "has leading or trailing whitespace"},
+		{name: "bare name", organization: "acme", wantMessage: "does not match"},
+		{
+			name:         "custom pattern",
+			organization: "acme",
+			opts:         []Option{WithOrganizationPattern(regexp.MustCompile(`^[a-z]+$`))},
+		},
+		{
+			name:         "custom pattern rejects IDs",
+			organization: "123456789012",
+			opts:         []Option{WithOrganizationPattern(regexp.MustCompile(`^[a-z]+$`))},
+			wantMessage:  "does not match",
+		},
+		{
+			name:         "slug",
+			organization: "acme-corp",
+			opts:         []Option{WithOrganizationSlug(true)},
+		},
+		{
+			name:         "empty slug",
+			organization: "",
+			opts:         []Option{WithOrganizationSlug(true)},
+			wantMessage:  "organization slug can not be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.Organization = tt.organization
+			_, err := message.DryRun(validAttributes(), tt.opts...)
+			if tt.wantMessage == "" {
+				wantFieldError(t, err, "")
+				return
+			}
+			wantFieldError(t, err, "organization")
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestBusinessUnit(t *testing.T) {
+	allowed := []string{"retail", "platform", "finance"}
+	tests := []struct {
+		name         string
+		allowed      []string
+		businessUnit string
+		// wantMessage is the rule reported, empty when the business unit is valid
+		wantMessage string
+	}{
+		{name: "listed", allowed: allowed, businessUnit: "platform"},
+		{name: "any value without a list", businessUnit: "research"},
+		{name: "empty", allowed: allowed, businessUnit: "", wantMessage: "businessUnit can not be empty"},
+		{name: "empty without a list", businessUnit: "", wantMessage: "businessUnit can not be empty"},
+		{name: "unlisted", allowed: allowed, businessUnit: "research", wantMessage: `businessUnit "research" is not one of: [retail platform finance]`},
+		{name: "case differs", allowed: allowed, businessUnit: "Platform", wantMessage: `businessUnit "Platform" is not one of`},
+		{name: "prefix of a listed unit", allowed: allowed, businessUnit: "plat", wantMessage: `businessUnit "plat" is not one of`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.BusinessUnit = tt.businessUnit
+			_, err := message.DryRun(validAttributes(), WithBusinessUnits(tt.allowed))
+			if tt.wantMessage == "" {
+				wantFieldError(t, err, "")
+				return
+			}
+			wantFieldError(t, err, "businessUnit")
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// validateOwners requires TenantOwner and allows an empty TenantOwnerSecondary, and
+// checks each owner that is set is a bare email address
+func (message TinyHomeInstructions) validateOwners() error {
+	if message.TenantOwner == "" {
+		return &OwnerError{Field: "tenantOwner", Message: "tenantOwner is required"}
+	}
+
+	if err := validateOwnerEmail("tenantOwner", message.TenantOwner); err != nil {
+		return err
+	}
+
+	if message.TenantOwnerSecondary != "" {
+		return validateOwnerEmail("tenantOwnerSecondary", message.TenantOwnerSecondary)
+	}
+	return nil
+}
+
+// validateOwnerEmail checks value parses as an email address with no display name
+func validateOwnerEmail(field, value string) error {
+	address, err := mail.ParseAddress(value)
+	if err != nil {
+		return &OwnerError{Field: field, Message: fmt.Sprintf("%s %q is not a valid email address: %v", field, value, err)}
+	}
+
+	if address.Address != value {
+		return &OwnerError{Field: field, Message: fmt.Sprintf("%s %q must be a bare email address such as %s", field, value, address.Address)}
+	}
+	return nil
+}
+
+// validateOwnerDomain checks every owner that is set ends with domain, such as
+// "@mycorp.com". An empty domain disables the check.
+func (message TinyHomeInstructions) validateOwnerDomain(domain string) error {
+	if domain == "" {
+		return nil
+	}
+
+	owners := []struct{ field, value string }{
+		{"tenantOwner", message.TenantOwner},
+		{"tenantOwnerSecondary", message.TenantOwnerSecondary},
+	}
+	for _, owner := range owners {
+		if owner.value != "" && !strings.HasSuffix(strings.ToLower(owner.value), strings.ToLower(domain)) {
+			return &OwnerError{Field: owner.field, Message: fmt.Sprintf("%s %q is not in the allowed domain %s", owner.field, owner.value, domain)}
+		}
+	}
+	return nil
+}
+
+// validateOwnerAccepted requires OwnerAccepted when required is set, gating
+// onboarding on the owner's consent to the terms
+func (message TinyHomeInstructions) validateOwnerAccepted(required bool) error {
+	if required && !message.OwnerAccepted {
+		return &OwnerError{Field: "ownerAccepted", Message: "ownerAccepted must be true, the tenant owner has to accept the terms before onboarding"}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestOwners(t *testing.T) {
+	tests := []struct {
+		name      string
+		owner     string
+		secondary string
+		wantField string
+		// wantMessage is part of the error message
+		wantMessage string
+	}{
+		{name: "both owners", owner: "owner@example.com", secondary: "backup@example.com"},
+		{name: "no secondary owner", owner: "owner@example.com"},
+		{name: "subaddress", owner: "owner+tenants@example.com"},
+		{name: "missing owner", secondary: "backup@example.com", wantField: "tenantOwner", wantMessage: "tenantOwner is required"},
+		{name: "invalid owner", owner: "owner.example.com", wantField: "tenantOwner", wantMessage: `"owner.example.com" is not a valid email address`},
+		{name: "invalid secondary owner", owner: "owner@example.com", secondary: "backup@", wantField: "tenantOwnerSecondary", wantMessage: `"backup@"`},
+		{
+			name:        "display name",
+			owner:       "Tenant Owner <owner@example.com>",
+			wantField:   "tenantOwner",
+			wantMessage: "must be a bare email address such as owner@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.TenantOwner, message.TenantOwnerSecondary = tt.owner, tt.secondary
+			_, err := message.DryRun(validAttributes())
+			wantFieldError(t, err, tt.wantField)
+			if tt.wantMessage != "" && !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestOwnerDomain(t *testing.T) {
+	tests := []struct {
+		name      string
+		domain    string
+		owner     string
+		secondary string
+		wantField string
+	}{
+		{name: "both in the domain", domain: "@mycorp.com", owner: "owner@mycorp.com", secondary: "backup@mycorp.com"},
+		{name: "domain matched without case", domain: "@MyCorp.com", owner: "Owner@MYCORP.COM"},
+		{name: "empty secondary owner", domain: "@mycorp.com", owner: "owner@mycorp.com"},
+		{name: "owner in another domain", domain: "@mycorp.com", owner: "owner@example.com", wantField: "tenantOwner"},
+		{name: "lookalike domain", domain: "@mycorp.com", owner: "owner@notmycorp.com", wantField: "tenantOwner"},
+		{name: "secondary owner in another domain", domain: "@mycorp.com", owner: "owner@mycorp.com", secondary: "backup@example.com", wantField: "tenantOwnerSecondary"},
+		{name: "no domain", owner: "owner@example.com", secondary: "backup@example.net"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.TenantOwner, message.TenantOwnerSecondary = tt.owner, tt.secondary
+			_, err := message.DryRun(validAttributes(), WithOwnerDomain(tt.domain))
+			wantFieldError(t, err, tt.wantField)
+			if tt.wantField != "" && !strings.Contains(err.Error(), "is not in the allowed domain "+tt.domain) {
+				t.Errorf("error %q does not name the allowed domain %s", err, tt.domain)
+			}
+		})
+	}
+}
+
+func TestOwnerAccepted(t *testing.T) {
+	tests := []struct {
+		name     string
+		required bool
+		accepted bool
+		wantErr  bool
+	}{
+		{name: "accepted when required", required: true, accepted: true},
+		{name: "not accepted when required", required: true, wantErr: true},
+		{name: "accepted when not required", accepted: true},
+		{name: "not accepted when not required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithRequireOwnerAcceptance(tt.required))
+			message := validInstructions()
+			message.OwnerAccepted = tt.accepted
+
+			_, err := p.PublishContext(context.Background(), &message, validAttributes())
+			if tt.wantErr {
+				wantFieldError(t, err, "ownerAccepted")
+				if got := len(topic.published()); got != 0 {
+					t.Errorf("published %d messages, want none", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+			if got, want := topic.published()[0].Attributes["ownerAccepted"], boolAttribute(tt.accepted); got != want {
+				t.Errorf("ownerAccepted attribute = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateOrganizationForEnvironment checks Organization is in the allowed list for
+// the instruction's Environment. An environment with no entry, or an empty list, is
+// unrestricted.
+func (message TinyHomeInstructions) ValidateOrganizationForEnvironment(allowedOrgs map[string][]string) error {
+	allowed := allowedOrgs[message.Environment]
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	if !contains(allowed, message.Organization) {
+		return &NamingError{
+			Field:   "organization",
+			Message: fmt.Sprintf("organization %q is not allowed in environment %q, allowed organizations are: %s", message.Organization, message.Environment, allowed),
+		}
+	}
+	return nil
+}
+
+// ValidateBreakglassPolicy rejects break-glass requests in any of the
+// forbiddenEnvironments, such as a locked compliance environment
+func (message TinyHomeInstructions) ValidateBreakglassPolicy(forbiddenEnvironments []string) error {
+	if message.Breakglass && contains(forbiddenEnvironments, message.Environment) {
+		return &IAMError{
+			Field:   "breakglass",
+			Message: fmt.Sprintf("breakglass is forbidden by policy in environment %q, forbidden environments are: %s", message.Environment, forbiddenEnvironments),
+		}
+	}
+	return nil
+}
+
+// ValidateCostCenter checks TenantCostCenter is one of the cost centers mapped to the
+// instruction's BusinessUnit. An empty mapping disables the check, and business units
+// without an entry may not be used while it is enabled.
+func (message TinyHomeInstructions) ValidateCostCenter(costCenters map[string][]string) error {
+	if len(costCenters) == 0 {
+		return nil
+	}
+
+	allowed := costCenters[message.BusinessUnit]
+	if !contains(allowed, message.TenantCostCenter) {
+		return &NamingError{
+			Field:   "tenantCostCenter",
+			Message: fmt.Sprintf("tenantCostCenter %q is not allowed for businessUnit %q, allowed cost centers are: %s", message.TenantCostCenter, message.BusinessUnit, allowed),
+		}
+	}
+	return nil
+}
+
+// ValidateOwnerAllowed checks TenantOwner against allowedOwners, where an entry is
+// either an exact email address or a domain written with a leading "@", such as
+// "@example.com". An empty list disables the check.
+func (message TinyHomeInstructions) ValidateOwnerAllowed(allowedOwners []string) error {
+	if len(allowedOwners) == 0 {
+		return nil
+	}
+
+	owner := strings.ToLower(message.TenantOwner)
+	for _, allowed := range allowedOwners {
+		allowed = strings.ToLower(allowed)
+		if owner == allowed || (strings.HasPrefix(allowed, "@") && strings.HasSuffix(owner, allowed)) {
+			return nil
+		}
+	}
+
+	return &OwnerError{
+		Field:   "tenantOwner",
+		Message: fmt.Sprintf("tenantOwner %q is not permitted to own tenants, allowed owners are: %s", message.TenantOwner, allowedOwners),
+	}
+}
@@ -0,0 +1,177 @@
+package tinyhomecommunity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAllowedOrganizations(t *testing.T) {
+	allowedOrgs := map[string][]string{
+		"prod":  {"111111111111", "222222222222"},
+		"stage": {"333333333333"},
+		"test":  {},
+	}
+
+	tests := []struct {
+		name         string
+		environment  string
+		organization string
+		wantField    string
+	}{
+		{"prod allowed organization", "prod", "222222222222", ""},
+		{"prod other organization", "prod", "123456789012", "organization"},
+		{"prod organization allowed in stage", "prod", "333333333333", "organization"},
+		{"stage allowed organization", "stage", "333333333333", ""},
+		{"stage organization allowed in prod", "stage", "111111111111", "organization"},
+		{"empty list is unrestricted", "test", "123456789012", ""},
+		{"no entry is unrestricted", "dev", "123456789012", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.Environment = tt.environment
+			message.Organization = tt.organization
+			_, err := message.DryRun(validAttributes(), WithAllowedOrganizations(allowedOrgs))
+			wantFieldError(t, err, tt.wantField)
+			if err == nil {
+				return
+			}
+
+			// The error names the environment, the organization and the allowed set
+			for _, want := range append([]string{tt.environment, tt.organization}, allowedOrgs[tt.environment]...) {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakglassForbiddenEnvironments(t *testing.T) {
+	forbidden := []string{"compliance", "prod"}
+
+	tests := []struct {
+		name        string
+		environment string
+		breakglass  bool
+		wantField   string
+	}{
+		{"breakglass in a forbidden environment", "compliance", true, "breakglass"},
+		{"breakglass in another forbidden environment", "prod", true, "breakglass"},
+		{"breakglass in an allowed environment", "dev", true, ""},
+		{"no breakglass in a forbidden environment", "compliance", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			if tt.breakglass {
+				message = breakglassInstructions(tt.environment)
+			}
+			message.Environment = tt.environment
+			_, err := message.DryRun(validAttributes(),
+				WithEnvironments(append([]string{"compliance"}, DefaultEnvironments...)),
+				WithBreakglassForbiddenEnvironments(forbidden),
+			)
+			wantFieldError(t, err, tt.wantField)
+			if err == nil {
+				return
+			}
+
+			var iamErr *IAMError
+			if !errors.As(err, &iamErr) {
+				t.Errorf("error %v is not an IAMError", err)
+			}
+			// The error names the environment and the policy
+			for _, want := range []string{`environment "` + tt.environment + `"`, "forbidden by policy", "[compliance prod]"} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBusinessUnitCostCenters(t *testing.T) {
+	costCenters := map[string][]string{
+		"retail":   {"1234", "5678"},
+		"platform": {"4321"},
+	}
+
+	tests := []struct {
+		name         string
+		costCenters  map[string][]string
+		businessUnit string
+		costCenter   string
+		wantField    string
+	}{
+		{"matching pair", costCenters, "retail", "5678", ""},
+		{"other matching pair", costCenters, "platform", "4321", ""},
+		{"cost center of another business unit", costCenters, "retail", "4321", "tenantCostCenter"},
+		{"unmapped cost center", costCenters, "platform", "9999", "tenantCostCenter"},
+		{"unmapped business unit", costCenters, "finance", "1234", "tenantCostCenter"},
+		{"empty mapping disables the check", nil, "finance", "9999", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.BusinessUnit = tt.businessUnit
+			message.TenantCostCenter = tt.costCenter
+			_, err := message.DryRun(validAttributes(),
+				WithBusinessUnits([]string{"retail", "platform", "finance"}),
+				WithBusinessUnitCostCenters(tt.costCenters),
+			)
+			wantFieldError(t, err, tt.wantField)
+			if err == nil {
+				return
+			}
+
+			// The error names the business unit, the cost center and the allowed set
+			for _, want := range append([]string{tt.businessUnit, tt.costCenter}, tt.costCenters[tt.businessUnit]...) {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowedOwners(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowedOwners []string
+		owner         string
+		wantField     string
+	}{
+		{"listed email", []string{"lead@example.com", "owner@corp.example.com"}, "owner@corp.example.com", ""},
+		{"listed email in another case", []string{"Owner@Corp.example.com"}, "owner@corp.EXAMPLE.com", ""},
+		{"allowed domain", []string{"@corp.example.com"}, "anyone@corp.example.com", ""},
+		{"email and domain", []string{"lead@example.com", "@corp.example.com"}, "lead@example.com", ""},
+		{"unlisted email", []string{"lead@example.com"}, "owner@example.com", "tenantOwner"},
+		{"other domain", []string{"@corp.example.com"}, "owner@example.com", "tenantOwner"},
+		{"domain suffix is not a subdomain", []string{"@example.com"}, "owner@badexample.com", "tenantOwner"},
+		{"empty list disables the check", nil, "owner@example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.TenantOwner = tt.owner
+			_, err := message.DryRun(validAttributes(), WithAllowedOwners(tt.allowedOwners))
+			wantFieldError(t, err, tt.wantField)
+			if err == nil {
+				return
+			}
+
+			// The error names the owner and the policy
+			for _, want := range append([]string{tt.owner}, tt.allowedOwners...) {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
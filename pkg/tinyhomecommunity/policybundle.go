@@ -0,0 +1,197 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// PolicyBundle is a versioned set of org-wide rules applied to every publish, kept as
+// a deploy-time artifact rather than in code. Load one with ParsePolicyBundle or
+// LoadPolicyBundle and set it with WithPolicyBundle.
+//
+//	{
+//	  "version": "2024-06-01",
+//	  "rules": [
+//	    {"name": "prod-needs-bu", "field": "businessUnit", "required": true, "when": {"environment": "prod"}},
+//	    {"name": "known-envs", "field": "environment", "allowed": ["dev", "prod"]},
+//	    {"name": "no-owner-roles", "field": "addlGkeTenantSaRoles", "denied": ["roles/owner"]}
+//	  ]
+//	}
+type PolicyBundle struct {
+	Version string       `json:"version"`
+	Rules   []PolicyRule `json:"rules"`
+
+	compileOnce sync.Once
+	compileErr  error
+}
+
+// PolicyRule checks one field, named by its json path such as nsQuota.limits.cpu.
+// For list fields every entry is checked. A rule with When only applies to
+// instructions whose fields equal every value in it.
+type PolicyRule struct {
+	Name     string            `json:"name"`
+	Field    string            `json:"field"`
+	When     map[string]string `json:"when,omitempty"`
+	Required bool              `json:"required,omitempty"`
+	Allowed  []string          `json:"allowed,omitempty"`
+	Denied   []string          `json:"denied,omitempty"`
+	Pattern  string            `json:"pattern,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// ParsePolicyBundle parses and checks a JSON policy bundle, failing on unknown
+// fields, rules naming fields TinyHomeInstructions does not have and bad patterns
+func ParsePolicyBundle(data []byte) (*PolicyBundle, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var bundle PolicyBundle
+	if err := decoder.Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("ParsePolicyBundle: %v", err)
+	}
+
+	if err := bundle.compile(); err != nil {
+		return nil, fmt.Errorf("ParsePolicyBundle: %v", err)
+	}
+	return &bundle, nil
+}
+
+// LoadPolicyBundle reads and parses the JSON policy bundle at path
+func LoadPolicyBundle(path string) (*PolicyBundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("LoadPolicyBundle: %v", err)
+	}
+	return ParsePolicyBundle(data)
+}
+
+// compile checks every rule and compiles its pattern, once per bundle
+func (bundle *PolicyBundle) compile() error {
+	bundle.compileOnce.Do(func() {
+		for i := range bundle.Rules {
+			if err := bundle.Rules[i].compile(); err != nil {
+				bundle.compileErr = fmt.Errorf("policy bundle %s: rule %d %q: %v", bundle.Version, i, bundle.Rules[i].Name, err)
+				return
+			}
+		}
+	})
+	return bundle.compileErr
+}
+
+func (rule *PolicyRule) compile() error {
+	if rule.Name == "" {
+		return fmt.Errorf("name can not be empty")
+	}
+
+	if _, ok := instructionField(reflect.ValueOf(TinyHomeInstructions{}), rule.Field); !ok {
+		return fmt.Errorf("field %q is not an instructions field", rule.Field)
+	}
+
+	for field := range rule.When {
+		if _, ok := instructionField(reflect.ValueOf(TinyHomeInstructions{}), field); !ok {
+			return fmt.Errorf("when field %q is not an instructions field", field)
+		}
+	}
+
+	if rule.Pattern != "" {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern: %v", err)
+		}
+		rule.pattern = pattern
+	}
+	return nil
+}
+
+// check applies every rule to message, failing on the first violation
+func (bundle *PolicyBundle) check(message TinyHomeInstructions) error {
+	if err := bundle.compile(); err != nil {
+		return err
+	}
+
+	for _, rule := range bundle.Rules {
+		if err := rule.check(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rule PolicyRule) check(message TinyHomeInstructions) error {
+	v := reflect.ValueOf(message)
+	for field, want := range rule.When {
+		values, _ := instructionField(v, field)
+		if len(values) != 1 || values[0] != want {
+			return nil
+		}
+	}
+
+	values, _ := instructionField(v, rule.Field)
+	violation := func(format string, args ...interface{}) error {
+		return &PolicyError{Field: rule.Field, Rule: rule.Name, Message: fmt.Sprintf("%s violates policy %s: ", rule.Field, rule.Name) + fmt.Sprintf(format, args...)}
+	}
+
+	if rule.Required && (len(values) == 0 || (len(values) == 1 && values[0] == "")) {
+		return violation("a value is required")
+	}
+
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		if len(rule.Allowed) > 0 && !contains(rule.Allowed, value) {
+			return violation("%q is not one of: %s", value, rule.Allowed)
+		}
+		if contains(rule.Denied, value) {
+			return violation("%q is denied", value)
+		}
+		if rule.pattern != nil && !rule.pattern.MatchString(value) {
+			return violation("%q does not match %s", value, rule.Pattern)
+		}
+	}
+	return nil
+}
+
+// instructionField returns the values at a json path of v, a TinyHomeInstructions,
+// as strings. Lists return one value per entry. ok is false when the path does not
+// name a string, bool or string list field.
+func instructionField(v reflect.Value, path string) (values []string, ok bool) {
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return nil, false
+		}
+
+		found := false
+		for i := 0; i < v.NumField(); i++ {
+			if strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0] == name {
+				v = v.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+
+	switch {
+	case v.Kind() == reflect.String:
+		return []string{v.String()}, true
+	case v.Kind() == reflect.Bool:
+		return []string{fmt.Sprint(v.Bool())}, true
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		values = make([]string, v.Len())
+		for i := range values {
+			values[i] = v.Index(i).String()
+		}
+		return values, true
+	}
+	return nil, false
+}
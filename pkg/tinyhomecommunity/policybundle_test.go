@@ -0,0 +1,125 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testPolicyBundle = `{
+  "version": "2024-06-01",
+  "rules": [
+    {"name": "prod-needs-domain", "field": "domain", "required": true, "when": {"environment": "prod"}},
+    {"name": "known-envs", "field": "environment", "allowed": ["dev", "prod"]},
+    {"name": "no-owner-roles", "field": "addlGkeTenantSaRoles", "denied": ["roles/owner"]},
+    {"name": "team-prefix", "field": "tenantName", "pattern": "^(contract|team)-"}
+  ]
+}`
+
+func TestPolicyBundle(t *testing.T) {
+	bundle, err := ParsePolicyBundle([]byte(testPolicyBundle))
+	if err != nil {
+		t.Fatalf("ParsePolicyBundle: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(m *TinyHomeInstructions)
+		// wantRule is the rule rejecting the instructions, empty when they pass
+		wantRule string
+	}{
+		{name: "passes every rule", mutate: func(m *TinyHomeInstructions) {}},
+		{name: "required when prod", mutate: func(m *TinyHomeInstructions) { m.Environment, m.Domain = "prod", "" }, wantRule: "prod-needs-domain"},
+		{name: "not required outside prod", mutate: func(m *TinyHomeInstructions) { m.Domain = "" }},
+		{name: "environment not allowed", mutate: func(m *TinyHomeInstructions) { m.Environment = "test" }, wantRule: "known-envs"},
+		{
+			name: "denied list entry",
+			mutate: func(m *TinyHomeInstructions) {
+				m.AddlGkeTenantSaRoles = []string{"roles/logging.logWriter", "roles/owner"}
+			},
+			wantRule: "no-owner-roles",
+		},
+		{name: "pattern mismatch", mutate: func(m *TinyHomeInstructions) { m.TenantName = "other-tenant" }, wantRule: "team-prefix"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithPolicyBundle(bundle))
+			message := validInstructions()
+			tt.mutate(&message)
+
+			_, err := p.PublishContext(context.Background(), &message, validAttributes())
+			if tt.wantRule == "" {
+				if err != nil {
+					t.Fatalf("PublishContext: %v", err)
+				}
+				return
+			}
+
+			var policyErr *PolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("PublishContext error = %v, want a PolicyError", err)
+			}
+			if policyErr.Rule != tt.wantRule {
+				t.Errorf("rejected by rule %q, want %q", policyErr.Rule, tt.wantRule)
+			}
+			if !errors.Is(err, ErrInvalidInstructions) {
+				t.Errorf("error %v does not match ErrInvalidInstructions", err)
+			}
+			if got := len(topic.published()); got != 0 {
+				t.Errorf("published %d messages, want none", got)
+			}
+		})
+	}
+}
+
+func TestParsePolicyBundle(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "valid", data: testPolicyBundle},
+		{name: "nested field", data: `{"version": "1", "rules": [{"name": "cpu", "field": "nsQuota.limits.cpu", "pattern": "^[0-9]+$"}]}`},
+		{name: "malformed", data: `{"version": "1", "rules": [`, wantErr: true},
+		{name: "unknown bundle field", data: `{"version": "1", "rule": []}`, wantErr: true},
+		{name: "unknown rule field", data: `{"version": "1", "rules": [{"name": "r", "field": "tenantName", "deny": ["x"]}]}`, wantErr: true},
+		{name: "unnamed rule", data: `{"version": "1", "rules": [{"field": "tenantName", "required": true}]}`, wantErr: true},
+		{name: "unknown instructions field", data: `{"version": "1", "rules": [{"name": "r", "field": "tenantname", "required": true}]}`, wantErr: true},
+		{name: "unknown when field", data: `{"version": "1", "rules": [{"name": "r", "field": "tenantName", "required": true, "when": {"env": "prod"}}]}`, wantErr: true},
+		{name: "bad pattern", data: `{"version": "1", "rules": [{"name": "r", "field": "tenantName", "pattern": "("}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePolicyBundle([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePolicyBundle error = %v, want error %v", err, tt.wantErr)
+			}
+
+			// A file holding the bundle loads the same
+			path := filepath.Join(t.TempDir(), "policy.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadPolicyBundle(path); (err != nil) != tt.wantErr {
+				t.Fatalf("LoadPolicyBundle error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicyBundleValidated(t *testing.T) {
+	// A bundle built in code is checked when the publisher is constructed
+	bad := &PolicyBundle{Version: "1", Rules: []PolicyRule{{Name: "r", Field: "tenantname", Required: true}}}
+	if _, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithPolicyBundle(bad)); err == nil {
+		t.Error("newPublisherWithTopic accepted a bundle with an unknown field")
+	}
+
+	if _, err := LoadPolicyBundle(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadPolicyBundle of a missing file succeeded")
+	}
+}
@@ -0,0 +1,18 @@
+package tinyhomecommunity
+
+// Priority values subscribers can use to order onboarding work
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+var priorityVals = []string{PriorityLow, PriorityNormal, PriorityHigh}
+
+// priority returns Priority, defaulting to PriorityNormal when unset
+func (message TinyHomeInstructions) priority() string {
+	if message.Priority == "" {
+		return PriorityNormal
+	}
+	return message.Priority
+}
@@ -0,0 +1,46 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority string
+		// wantAttribute is the priority attribute published, empty when rejected
+		wantAttribute string
+	}{
+		{name: "low", priority: PriorityLow, wantAttribute: "low"},
+		{name: "normal", priority: PriorityNormal, wantAttribute: "normal"},
+		{name: "high", priority: PriorityHigh, wantAttribute: "high"},
+		{name: "unset defaults to normal", priority: "", wantAttribute: "normal"},
+		{name: "invalid", priority: "urgent"},
+		{name: "wrong case", priority: "High"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil)
+			message := validInstructions()
+			message.Priority = tt.priority
+
+			_, err := p.PublishContext(context.Background(), &message, validAttributes())
+			if tt.wantAttribute == "" {
+				wantFieldError(t, err, "priority")
+				if got := len(topic.published()); got != 0 {
+					t.Errorf("published %d messages, want none", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+			if got := topic.published()[0].Attributes["priority"]; got != tt.wantAttribute {
+				t.Errorf("priority attribute = %q, want %q", got, tt.wantAttribute)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package tinyhomecommunity
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusMetrics is Metrics exported in the Prometheus format: a counter of
+// publishes by subscription and outcome, a histogram of publish latency by
+// subscription and a gauge of messages in flight. Set it with WithMetrics and
+// register its Collector with the app's registry.
+type PrometheusMetrics struct {
+	publishes *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+	inFlight  prometheus.Gauge
+}
+
+// NewPrometheusMetrics returns PrometheusMetrics with metric names under namespace,
+// such as tinyhome_publishes_total for a namespace of tinyhome
+func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
+	return &PrometheusMetrics{
+		publishes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "publishes_total",
+			Help:      "Publishes by subscription and outcome.",
+		}, []string{"subscription", "outcome"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "publish_duration_seconds",
+			Help:      "Publish latency, from the publish call to the server's result.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"subscription"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "publishes_in_flight",
+			Help:      "Messages handed to a topic whose result is still to come.",
+		}),
+	}
+}
+
+func (m *PrometheusMetrics) RecordPublish(subscription string, outcome string, dur time.Duration) {
+	m.publishes.WithLabelValues(subscription, outcome).Inc()
+	m.latency.WithLabelValues(subscription).Observe(dur.Seconds())
+}
+
+func (m *PrometheusMetrics) AddInFlight(delta int) {
+	m.inFlight.Add(float64(delta))
+}
+
+// Collector returns the prometheus.Collector of the metrics, to register with a
+// prometheus.Registerer
+func (m *PrometheusMetrics) Collector() prometheus.Collector {
+	return prometheusCollector{m}
+}
+
+// prometheusCollector collects every metric of a PrometheusMetrics
+type prometheusCollector struct {
+	m *PrometheusMetrics
+}
+
+func (c prometheusCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.m.publishes.Describe(ch)
+	c.m.latency.Describe(ch)
+	c.m.inFlight.Describe(ch)
+}
+
+func (c prometheusCollector) Collect(ch chan<- prometheus.Metric) {
+	c.m.publishes.Collect(ch)
+	c.m.latency.Collect(ch)
+	c.m.inFlight.Collect(ch)
+}
@@ -0,0 +1,80 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics("tinyhome")
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(metrics.Collector()); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	server := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	scrape := func() string {
+		t.Helper()
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("scrape: %v", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("scrape: %v", err)
+		}
+		return string(body)
+	}
+
+	release := make(chan struct{})
+	topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+		return fakeResult{id: "id", ready: release}
+	}}
+	p := newTestPublisher(t, topic, nil, WithMetrics(metrics))
+
+	// A publish waiting on the server is in flight
+	message := validInstructions()
+	pending, err := p.PublishAsync(context.Background(), &message, validAttributes())
+	if err != nil {
+		t.Fatalf("PublishAsync: %v", err)
+	}
+	if body := scrape(); !strings.Contains(body, "tinyhome_publishes_in_flight 1\n") {
+		t.Errorf("scrape during the publish has no in-flight publish:\n%s", body)
+	}
+	close(release)
+	if _, err := pending.Get(context.Background()); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	invalid := validInstructions()
+	invalid.TenantOwner = ""
+	if _, err := p.PublishContext(context.Background(), &invalid, validAttributes()); err == nil {
+		t.Fatal("PublishContext of invalid instructions returned no error")
+	}
+
+	body := scrape()
+	for _, want := range []string{
+		`tinyhome_publishes_total{outcome="published",subscription="createGroups"} 1`,
+		// Validation failed before the message was routed
+		`tinyhome_publishes_total{outcome="invalid",subscription=""} 1`,
+		`tinyhome_publish_duration_seconds_count{subscription="createGroups"} 1`,
+		"tinyhome_publishes_in_flight 0\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("scrape has no %s:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PublishBatch validates every message, then hands them all to the topic in order
+// before waiting on any result, so their publish results pipeline instead of each
+// blocking in turn, up to the MaxConcurrentPublishes limit. Batches larger
+// than MaxBatchSize are published in chunks of that size, one chunk after another.
+// attrs[i] are the attributes of msgs[i].
+//
+// When any message fails validation nothing is published and the error is a
+// BatchError naming every invalid index, as it is for messages sharing an ordering
+// key under OrderingKeyCollisionReject. When some publishes fail the results of
+// the others are still returned, failed indices hold a zero PublishResult and the
+// error is a BatchError naming them.
+func (p *Publisher) PublishBatch(ctx context.Context, msgs []*TinyHomeInstructions, attrs []*TinyHomeMessageAttributes) ([]PublishResult, error) {
+	return p.publishBatch(ctx, msgs, attrs, nil)
+}
+
+// publishBatch is PublishBatch, calling completed, when not nil, with the tenant of
+// each message as its publish completes, successfully or not. Calls to completed
+// may come from several goroutines at once.
+func (p *Publisher) publishBatch(ctx context.Context, msgs []*TinyHomeInstructions, attrs []*TinyHomeMessageAttributes, completed func(tenant string)) ([]PublishResult, error) {
+	if len(msgs) != len(attrs) {
+		return nil, fmt.Errorf("PublishBatch: %d messages but %d attribute sets", len(msgs), len(attrs))
+	}
+	if p.cfg.CloudEventsTopicID != "" {
+		return nil, fmt.Errorf("PublishBatch: %w", errDualPublishUnsupported)
+	}
+
+	start := time.Now()
+	prepared := make([]*preparedMessage, len(msgs))
+	logLines := make([]PublishLogLine, len(msgs))
+	invalid := map[int]error{}
+	for i, message := range msgs {
+		logLines[i] = PublishLogLine{Topic: p.cfg.TopicID}
+		var err error
+		prepared[i], err = p.prepare(ctx, message, attrs[i], &logLines[i])
+		if err != nil {
+			invalid[i] = err
+		}
+	}
+
+	if len(invalid) > 0 {
+		for i, err := range invalid {
+			p.quarantine(ctx, msgs[i], attrs[i], err)
+			logLines[i].Tenant = msgs[i].TenantName
+			p.recordPublish(logLines[i], start, err)
+		}
+		return nil, fmt.Errorf("PublishBatch: %w", &BatchError{Failures: invalid})
+	}
+
+	if err := p.checkOrderingKeyCollisions(ctx, prepared); err != nil {
+		return nil, fmt.Errorf("PublishBatch: %w", err)
+	}
+
+	var (
+		mu     sync.Mutex
+		failed = map[int]error{}
+	)
+	results := make([]PublishResult, len(msgs))
+	fail := func(i int, err error) {
+		p.recordPublish(logLines[i], start, err)
+		mu.Lock()
+		failed[i] = err
+		mu.Unlock()
+		if completed != nil {
+			completed(logLines[i].Tenant)
+		}
+	}
+
+	// Chunks are published one after another. The messages of a chunk are handed to
+	// the topic in order, so ordering keys keep their order, and their results are
+	// then handled in that same order while the later ones are still in flight.
+	for chunkStart := 0; chunkStart < len(prepared); chunkStart += p.cfg.MaxBatchSize {
+		chunkEnd := chunkStart + p.cfg.MaxBatchSize
+		if chunkEnd > len(prepared) {
+			chunkEnd = len(prepared)
+		}
+
+		var wg sync.WaitGroup
+		prev := make(chan struct{})
+		close(prev)
+		for i := chunkStart; i < chunkEnd; i++ {
+			logLines[i].Tenant = prepared[i].tenantName
+			if err := ctx.Err(); err != nil {
+				fail(i, err)
+				continue
+			}
+
+			// A slot frees up as the results before it are handled, so waiting on
+			// one here can not block those
+			ctx, cancel := p.withTimeout(ctx)
+			f, err := p.issue(ctx, prepared[i].topicID, prepared[i].topic, prepared[i].data, prepared[i].attributes)
+			if err != nil {
+				cancel()
+				fail(i, err)
+				continue
+			}
+
+			done := make(chan struct{})
+			wg.Add(1)
+			go func(i int, prev <-chan struct{}) {
+				defer wg.Done()
+				defer close(done)
+				defer cancel()
+				<-prev
+
+				id, err := p.await(ctx, f)
+				if err != nil {
+					fail(i, err)
+					return
+				}
+				logLines[i].MessageID = id
+				p.recordPublish(logLines[i], start, nil)
+				results[i] = *prepared[i].result(id)
+				p.delivered(ctx, &results[i])
+				if completed != nil {
+					completed(logLines[i].Tenant)
+				}
+			}(i, prev)
+			prev = done
+		}
+		wg.Wait()
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("PublishBatch: %w", &BatchError{Failures: failed})
+	}
+	return results, nil
+}
+
+// Progress reports how far a PublishBatchProgress run has got
+type Progress struct {
+	// Done is the number of messages whose publish has completed, successfully or not
+	Done int
+	// Total is the number of messages in the batch
+	Total int
+	// LastTenant is the tenant of the message that completed last
+	LastTenant string
+}
+
+// PublishBatchProgress is PublishBatch for long runs such as bulk onboarding behind a
+// progress bar. It publishes in the background and sends a Progress on the first
+// channel as each message completes, then closes it. The second channel then
+// receives the error PublishBatch would have returned, if any, and is closed. When a
+// message fails validation nothing is published and no progress is sent.
+//
+// The caller must keep receiving progress until the channel is closed or cancel ctx.
+// Once ctx is done no more progress is sent and the messages not yet handed to the
+// topic fail with the ctx error.
+func (p *Publisher) PublishBatchProgress(ctx context.Context, msgs []*TinyHomeInstructions, attrs []*TinyHomeMessageAttributes) (<-chan Progress, <-chan error) {
+	progress := make(chan Progress)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+
+		var (
+			mu   sync.Mutex
+			done int
+		)
+		_, err := p.publishBatch(ctx, msgs, attrs, func(tenant string) {
+			// Sending under the lock keeps Done increasing on the channel
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case progress <- Progress{Done: done, Total: len(msgs), LastTenant: tenant}:
+			case <-ctx.Done():
+			}
+		})
+		close(progress)
+		if err != nil {
+			errc <- err
+		}
+	}()
+	return progress, errc
+}
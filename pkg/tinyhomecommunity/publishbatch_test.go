@@ -0,0 +1,362 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// batchOf returns n validInstructions for distinct tenants, with their attributes
+func batchOf(n int) ([]*TinyHomeInstructions, []*TinyHomeMessageAttributes) {
+	msgs := make([]*TinyHomeInstructions, n)
+	attrs := make([]*TinyHomeMessageAttributes, n)
+	for i := range msgs {
+		message := validInstructions()
+		message.TenantName = fmt.Sprintf("tenant-%d", i)
+		msgs[i] = &message
+		attrs[i] = validAttributes()
+	}
+	return msgs, attrs
+}
+
+func TestPublishBatch(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	tests := []struct {
+		name   string
+		size   int
+		opts   []Option
+		mutate func(msgs []*TinyHomeInstructions)
+		result func(n int, msg *pubsub.Message) publishResult
+		// wantPublished is the number of messages handed to the topic
+		wantPublished int
+		// wantFailed are the indices in the BatchError, nil when the batch succeeds
+		wantFailed []int
+	}{
+		{name: "every message", size: 5, wantPublished: 5},
+		{name: "chunks", size: 5, opts: []Option{WithMaxBatchSize(2)}, wantPublished: 5},
+		{
+			name: "partial failure",
+			size: 4,
+			result: func(n int, msg *pubsub.Message) publishResult {
+				if n == 2 || n == 4 {
+					return fakeResult{err: errPubSub}
+				}
+				return fakeResult{id: fmt.Sprint(n)}
+			},
+			wantPublished: 4,
+			wantFailed:    []int{1, 3},
+		},
+		{
+			name: "validation failures publish nothing",
+			size: 4,
+			mutate: func(msgs []*TinyHomeInstructions) {
+				msgs[1].TenantName = "Not Valid"
+				msgs[3].TenantOwner = ""
+			},
+			wantFailed: []int{1, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{result: tt.result}
+			p := newTestPublisher(t, topic, nil, tt.opts...)
+			msgs, attrs := batchOf(tt.size)
+			if tt.mutate != nil {
+				tt.mutate(msgs)
+			}
+
+			results, err := p.PublishBatch(context.Background(), msgs, attrs)
+
+			// Messages are handed to the topic in batch order
+			published := topic.published()
+			if len(published) != tt.wantPublished {
+				t.Fatalf("published %d messages, want %d", len(published), tt.wantPublished)
+			}
+			for i, msg := range published {
+				if got, want := msg.Attributes[AttrTenantName], msgs[i].TenantName; got != want {
+					t.Errorf("message %d published for %s, want %s", i, got, want)
+				}
+			}
+
+			var failed []int
+			if err != nil {
+				var batchErr *BatchError
+				if !errors.As(err, &batchErr) {
+					t.Fatalf("PublishBatch error = %v, want a BatchError", err)
+				}
+				for i := range batchErr.Failures {
+					failed = append(failed, i)
+				}
+				slices.Sort(failed)
+			}
+			if !slices.Equal(failed, tt.wantFailed) {
+				t.Errorf("failed indices %v, want %v", failed, tt.wantFailed)
+			}
+			if tt.wantPublished == 0 {
+				if results != nil {
+					t.Errorf("results %+v after a validation failure, want none", results)
+				}
+				return
+			}
+
+			// Successful results line up with their messages, failed ones are zero
+			if len(results) != tt.size {
+				t.Fatalf("%d results, want %d", len(results), tt.size)
+			}
+			for i, result := range results {
+				if slices.Contains(tt.wantFailed, i) {
+					if result.MessageID != "" {
+						t.Errorf("failed result %d has MessageID %q", i, result.MessageID)
+					}
+					continue
+				}
+				if result.MessageID != fmt.Sprint(i+1) || result.TenantName != msgs[i].TenantName {
+					t.Errorf("result %d = %+v, want message %d for %s", i, result, i+1, msgs[i].TenantName)
+				}
+			}
+		})
+	}
+}
+
+func TestPublishBatchChunks(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		// maxBatchSize is the chunk size, DefaultMaxBatchSize when zero
+		maxBatchSize int
+		// wantChunks is the number of chunks the batch is split into
+		wantChunks int
+	}{
+		{name: "smaller than a chunk", size: 2, maxBatchSize: 10, wantChunks: 1},
+		{name: "exact chunks", size: 6, maxBatchSize: 3, wantChunks: 2},
+		{name: "partial last chunk", size: 7, maxBatchSize: 3, wantChunks: 3},
+		{name: "chunks of one", size: 3, maxBatchSize: 1, wantChunks: 3},
+		{name: "larger than the default", size: DefaultMaxBatchSize*2 + 1, wantChunks: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk := tt.maxBatchSize
+			if chunk == 0 {
+				chunk = DefaultMaxBatchSize
+			}
+			// Each chunk's results are held until the test releases them
+			release := make([]chan struct{}, tt.wantChunks)
+			for i := range release {
+				release[i] = make(chan struct{})
+			}
+			topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+				return fakeResult{id: fmt.Sprint(n), ready: release[(n-1)/chunk]}
+			}}
+			// Lift the concurrency limit so a whole chunk can be in flight
+			opts := []Option{WithMaxConcurrentPublishes(chunk)}
+			if tt.maxBatchSize != 0 {
+				opts = append(opts, WithMaxBatchSize(tt.maxBatchSize))
+			}
+			p := newTestPublisher(t, topic, nil, opts...)
+			msgs, attrs := batchOf(tt.size)
+
+			type batchResult struct {
+				results []PublishResult
+				err     error
+			}
+			done := make(chan batchResult, 1)
+			go func() {
+				results, err := p.PublishBatch(context.Background(), msgs, attrs)
+				done <- batchResult{results, err}
+			}()
+
+			// The next chunk is only handed to the topic once the previous one completes
+			for i := range release {
+				want := (i + 1) * chunk
+				if want > tt.size {
+					want = tt.size
+				}
+				eventually(t, func() bool { return len(topic.published()) == want })
+				time.Sleep(10 * time.Millisecond)
+				if got := len(topic.published()); got != want {
+					t.Fatalf("published %d messages before chunk %d completed, want %d", got, i, want)
+				}
+				close(release[i])
+			}
+
+			got := <-done
+			if got.err != nil {
+				t.Fatalf("PublishBatch: %v", got.err)
+			}
+			if len(got.results) != tt.size {
+				t.Fatalf("%d results, want %d", len(got.results), tt.size)
+			}
+			for i, result := range got.results {
+				if result.MessageID != fmt.Sprint(i+1) || result.TenantName != msgs[i].TenantName {
+					t.Errorf("result %d = %+v, want message %d for %s", i, result, i+1, msgs[i].TenantName)
+				}
+			}
+		})
+	}
+}
+
+func TestPublishBatchPipelines(t *testing.T) {
+	// No result is ready until every message has been handed to the topic
+	ready := make(chan struct{})
+	topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+		return fakeResult{id: fmt.Sprint(n), ready: ready}
+	}}
+	p := newTestPublisher(t, topic, nil)
+	msgs, attrs := batchOf(5)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.PublishBatch(context.Background(), msgs, attrs)
+		done <- err
+	}()
+
+	eventually(t, func() bool { return len(topic.published()) == len(msgs) })
+	close(ready)
+	if err := <-done; err != nil {
+		t.Fatalf("PublishBatch: %v", err)
+	}
+}
+
+func TestPublishBatchMismatchedAttributes(t *testing.T) {
+	topic := &fakeTopic{}
+	p := newTestPublisher(t, topic, nil)
+	msgs, attrs := batchOf(3)
+
+	if _, err := p.PublishBatch(context.Background(), msgs, attrs[:2]); err == nil {
+		t.Fatal("PublishBatch accepted 3 messages with 2 attribute sets")
+	}
+	if got := len(topic.published()); got != 0 {
+		t.Errorf("published %d messages, want none", got)
+	}
+}
+
+func TestPublishBatchProgress(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	tests := []struct {
+		name   string
+		size   int
+		opts   []Option
+		mutate func(msgs []*TinyHomeInstructions)
+		result func(n int, msg *pubsub.Message) publishResult
+		// wantProgress is the number of progress events
+		wantProgress int
+		// wantFailed are the indices in the BatchError, nil when the batch succeeds
+		wantFailed []int
+	}{
+		{name: "one message", size: 1, wantProgress: 1},
+		{name: "several messages", size: 4, wantProgress: 4},
+		{name: "several chunks", size: 5, opts: []Option{WithMaxBatchSize(2)}, wantProgress: 5},
+		{name: "empty batch", size: 0},
+		{
+			name: "publish failure",
+			size: 3,
+			result: func(n int, msg *pubsub.Message) publishResult {
+				if n == 2 {
+					return fakeResult{err: errPubSub}
+				}
+				return fakeResult{id: fmt.Sprint(n)}
+			},
+			wantProgress: 3,
+			wantFailed:   []int{1},
+		},
+		{
+			name:       "validation failure",
+			size:       3,
+			mutate:     func(msgs []*TinyHomeInstructions) { msgs[2].TenantName = "Not Valid" },
+			wantFailed: []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{result: tt.result}
+			p := newTestPublisher(t, topic, nil, tt.opts...)
+			msgs, attrs := batchOf(tt.size)
+			if tt.mutate != nil {
+				tt.mutate(msgs)
+			}
+
+			progress, errc := p.PublishBatchProgress(context.Background(), msgs, attrs)
+			var events []Progress
+			for event := range progress {
+				events = append(events, event)
+			}
+			err := <-errc
+
+			if len(events) != tt.wantProgress {
+				t.Fatalf("%d progress events, want %d", len(events), tt.wantProgress)
+			}
+			tenants := map[string]bool{}
+			for i, event := range events {
+				if event.Done != i+1 || event.Total != tt.size {
+					t.Errorf("progress event %d = %+v, want Done %d of %d", i, event, i+1, tt.size)
+				}
+				tenants[event.LastTenant] = true
+			}
+			if len(tenants) != len(events) {
+				t.Errorf("progress events %+v do not name each tenant once", events)
+			}
+
+			if tt.wantFailed == nil {
+				if err != nil {
+					t.Fatalf("PublishBatchProgress error = %v", err)
+				}
+				return
+			}
+			var batchErr *BatchError
+			if !errors.As(err, &batchErr) {
+				t.Fatalf("PublishBatchProgress error = %v, want a BatchError", err)
+			}
+			if len(batchErr.Failures) != len(tt.wantFailed) {
+				t.Errorf("failures %v, want indices %v", batchErr.Failures, tt.wantFailed)
+			}
+			for _, i := range tt.wantFailed {
+				if _, ok := batchErr.Failures[i]; !ok {
+					t.Errorf("failures %v do not include message %d", batchErr.Failures, i)
+				}
+			}
+		})
+	}
+}
+
+func TestPublishBatchProgressCancel(t *testing.T) {
+	// Only the first publish completes, the others wait until ctx is canceled
+	never := make(chan struct{})
+	topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+		if n == 1 {
+			return fakeResult{id: "1"}
+		}
+		return fakeResult{id: fmt.Sprint(n), ready: never}
+	}}
+	p := newTestPublisher(t, topic, nil)
+	msgs, attrs := batchOf(3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	progress, errc := p.PublishBatchProgress(ctx, msgs, attrs)
+
+	first := <-progress
+	if first.Done != 1 || first.LastTenant != "tenant-0" {
+		t.Errorf("first progress event = %+v, want Done 1 for tenant-0", first)
+	}
+	cancel()
+	for event := range progress {
+		t.Errorf("progress event %+v after cancel", event)
+	}
+
+	err := <-errc
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("PublishBatchProgress error = %v, want context.Canceled", err)
+	}
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failures) != 2 {
+		t.Errorf("PublishBatchProgress error = %v, want the 2 unfinished messages to fail", err)
+	}
+}
@@ -0,0 +1,114 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// PublishDir publishes the instructions in every .json, .yaml and .yml file directly
+// in dir, such as a GitOps repository of tenants, each with a copy of attrs. Each file
+// is decoded strictly, see LoadInstructionsFromFile, and validated against the config
+// of p, then the valid ones are published together with PublishBatch.
+//
+// Files are taken in name order and results[i] is the PublishResult of the i-th
+// file, a zero PublishResult when it failed. A file that does not parse, is invalid
+// or fails to publish does not stop the others, the error is then a DirError naming
+// every failed file.
+func PublishDir(ctx context.Context, p *Publisher, dir string, attrs *TinyHomeMessageAttributes) ([]PublishResult, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("PublishDir: %v", err)
+	}
+
+	// ReadDir returns the entries sorted by file name
+	var paths []string
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".json", ".yaml", ".yml":
+			if !entry.IsDir() {
+				paths = append(paths, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+
+	var (
+		msgs     []*TinyHomeInstructions
+		msgAttrs []*TinyHomeMessageAttributes
+		// indices maps the position of each message in the batch to its file
+		indices []int
+		failed  = map[string]error{}
+	)
+	for i, path := range paths {
+		message, err := decodeInstructionsFile(path)
+		if err == nil {
+			err = p.checkCopy(ctx, message, attrs)
+		}
+		if err != nil {
+			failed[path] = err
+			continue
+		}
+
+		messageAttributes := *attrs
+		msgs = append(msgs, message)
+		msgAttrs = append(msgAttrs, &messageAttributes)
+		indices = append(indices, i)
+	}
+
+	results := make([]PublishResult, len(paths))
+	if len(msgs) > 0 {
+		batchResults, err := p.PublishBatch(ctx, msgs, msgAttrs)
+		var batchErr *BatchError
+		if err != nil && !errors.As(err, &batchErr) {
+			return nil, fmt.Errorf("PublishDir: %w", err)
+		}
+		if batchErr != nil {
+			for n, err := range batchErr.Failures {
+				failed[paths[indices[n]]] = err
+			}
+		}
+		for n, result := range batchResults {
+			results[indices[n]] = result
+		}
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("PublishDir: %w", &DirError{Failures: failed})
+	}
+	return results, nil
+}
+
+// decodeInstructionsFile strictly decodes the file at path, as YAML when it ends in
+// .yaml or .yml and as JSON otherwise, without validating it
+func decodeInstructionsFile(path string) (*TinyHomeInstructions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if data, err = yaml.YAMLToJSONStrict(data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+		}
+	}
+	return decodeStrict(data)
+}
+
+// checkCopy runs the publish validation of p on a copy of message and
+// messageAttributes, leaving both untouched
+func (p *Publisher) checkCopy(ctx context.Context, message *TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes) error {
+	copied, err := message.clone()
+	if err != nil {
+		return err
+	}
+
+	copiedAttributes := *messageAttributes
+	_, err = p.prepare(ctx, copied, &copiedAttributes, &PublishLogLine{})
+	return err
+}
@@ -0,0 +1,168 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"sigs.k8s.io/yaml"
+)
+
+// writeFixture writes validInstructions for tenant to dir/name, as YAML when the name
+// ends in .yaml
+func writeFixture(t *testing.T, dir, name, tenant string) {
+	t.Helper()
+	message := validInstructions()
+	message.TenantName = tenant
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Ext(name) == ".yaml" {
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPublishDir(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	tests := []struct {
+		name string
+		// files maps each fixture file name to the tenant of its instructions
+		files map[string]string
+		// raw maps other file names to their contents
+		raw map[string]string
+		// result fails publishes of the fake topic when set
+		result func(n int, msg *pubsub.Message) publishResult
+		// wantTenants are the tenants published, in order
+		wantTenants []string
+		// wantFailed are the files that failed
+		wantFailed []string
+	}{
+		{
+			name:        "json and yaml",
+			files:       map[string]string{"a.json": "tenant-a", "b.yaml": "tenant-b", "c.json": "tenant-c"},
+			wantTenants: []string{"tenant-a", "tenant-b", "tenant-c"},
+		},
+		{
+			name:        "other files ignored",
+			files:       map[string]string{"a.json": "tenant-a"},
+			raw:         map[string]string{"README.md": "# tenants", "notes.txt": "not instructions"},
+			wantTenants: []string{"tenant-a"},
+		},
+		{
+			name:        "parse errors do not stop the run",
+			files:       map[string]string{"a.json": "tenant-a", "d.json": "tenant-d"},
+			raw:         map[string]string{"b.json": `{"tenantName": `, "c.yml": "tenantName: [unclosed", "e.yaml": "tenantowner: typo@example.com"},
+			wantTenants: []string{"tenant-a", "tenant-d"},
+			wantFailed:  []string{"b.json", "c.yml", "e.yaml"},
+		},
+		{
+			name:        "invalid instructions do not stop the run",
+			files:       map[string]string{"a.json": "tenant-a", "b.json": "Invalid Tenant", "c.yaml": "tenant-c"},
+			wantTenants: []string{"tenant-a", "tenant-c"},
+			wantFailed:  []string{"b.json"},
+		},
+		{
+			name:  "publish failures are reported per file",
+			files: map[string]string{"a.json": "tenant-a", "b.json": "tenant-b"},
+			result: func(n int, msg *pubsub.Message) publishResult {
+				if msg.Attributes[AttrTenantName] == "tenant-b" {
+					return fakeResult{err: errPubSub}
+				}
+				return fakeResult{id: "1"}
+			},
+			wantTenants: []string{"tenant-a", "tenant-b"},
+			wantFailed:  []string{"b.json"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, tenant := range tt.files {
+				writeFixture(t, dir, name, tenant)
+			}
+			for name, contents := range tt.raw {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Directories are not read, whatever their name
+			if err := os.Mkdir(filepath.Join(dir, "nested.json"), 0o700); err != nil {
+				t.Fatal(err)
+			}
+
+			topic := &fakeTopic{result: tt.result}
+			p := newTestPublisher(t, topic, nil)
+			results, err := PublishDir(context.Background(), p, dir, validAttributes())
+
+			var published []string
+			for _, msg := range topic.published() {
+				published = append(published, msg.Attributes[AttrTenantName])
+			}
+			if !slices.Equal(published, tt.wantTenants) {
+				t.Errorf("published %v, want %v", published, tt.wantTenants)
+			}
+
+			// One result for each instructions file, in name order
+			var names []string
+			for name := range tt.files {
+				names = append(names, name)
+			}
+			for name := range tt.raw {
+				if ext := filepath.Ext(name); ext == ".json" || ext == ".yaml" || ext == ".yml" {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			if len(results) != len(names) {
+				t.Fatalf("%d results, want %d", len(results), len(names))
+			}
+
+			var failed []string
+			if err != nil {
+				var dirErr *DirError
+				if !errors.As(err, &dirErr) {
+					t.Fatalf("PublishDir error = %v, want a DirError", err)
+				}
+				for path := range dirErr.Failures {
+					failed = append(failed, filepath.Base(path))
+				}
+				sort.Strings(failed)
+			}
+			if !slices.Equal(failed, tt.wantFailed) {
+				t.Errorf("failed files %v, want %v", failed, tt.wantFailed)
+			}
+
+			for i, name := range names {
+				wantFailed := false
+				for _, f := range tt.wantFailed {
+					wantFailed = wantFailed || f == name
+				}
+				if got := results[i]; wantFailed != (got.MessageID == "") {
+					t.Errorf("result %d for %s = %+v, want failed %v", i, name, got, wantFailed)
+				} else if !wantFailed && got.TenantName != tt.files[name] {
+					t.Errorf("result %d for %s has TenantName %q, want %q", i, name, got.TenantName, tt.files[name])
+				}
+			}
+		})
+	}
+}
+
+func TestPublishDirMissing(t *testing.T) {
+	p := newTestPublisher(t, &fakeTopic{}, nil)
+	if _, err := PublishDir(context.Background(), p, filepath.Join(t.TempDir(), "missing"), validAttributes()); err == nil {
+		t.Fatalf("PublishDir of a missing directory error = %v, want an error", err)
+	}
+}
@@ -4,10 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"maps"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 	"unicode"
 
 	"cloud.google.com/go/pubsub"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // TinyHomeMessageAttributes sets are attributes set on the given message published
@@ -19,23 +27,41 @@ type TinyHomeMessageAttributes struct {
 	FluxCreated      string `json:"fluxCreated"`
 	DeliveredFrom    string `json:"deliveredFrom"`
 	TenantName       string `json:"tenantName"`
+	// CorrelationID is an optional caller supplied ID, e.g. from an upstream system,
+	// published as the correlationId attribute alongside the server message ID
+	CorrelationID string `json:"correlationId,omitempty"`
+	// IdempotencyKey is an optional caller supplied key, published as the
+	// idempotencyKey attribute so subscribers can dedupe retried publishes. The
+	// InstructionHash of the instructions is used when it is empty.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
 }
 
 type TinyHomeInstructions struct {
-	TenantName           string   `json:"tenantName"`
-	Environment          string   `json:"environment"`
-	BusinessUnit         string   `json:"businessUnit"`
-	TenantOwner          string   `json:"tenantOwner"`
-	TenantOwnerSecondary string   `json:"tenantOwnerSecondary"`
-	TenantCostCenter     string   `json:"tenantCostCenter"`
-	Domain               string   `json:"domain"`
-	Organization         string   `json:"organization"`
-	Breakglass           bool     `json:"breakglass"`
-	BreakglassWindow     string   `json:"breakglassWindow"`
+	TenantName           string `json:"tenantName"`
+	Environment          string `json:"environment"`
+	BusinessUnit         string `json:"businessUnit"`
+	TenantOwner          string `json:"tenantOwner"`
+	TenantOwnerSecondary string `json:"tenantOwnerSecondary"`
+	TenantCostCenter     string `json:"tenantCostCenter"`
+	Domain               string `json:"domain"`
+	Organization         string `json:"organization"`
+	Region               string `json:"region,omitempty"`
+	Priority             string `json:"priority,omitempty"`
+	Breakglass           bool   `json:"breakglass"`
+	BreakglassWindow     string `json:"breakglassWindow"`
+	BreakglassApprover   string `json:"breakglassApprover,omitempty"`
+	BreakglassTicket     string `json:"breakglassTicket,omitempty"`
+	// OwnerAccepted records that the tenant owner accepted the terms of use, required
+	// when the Publisher is configured with RequireOwnerAcceptance
+	OwnerAccepted        bool     `json:"ownerAccepted,omitempty"`
 	AddlGkeTenantSaRoles []string `json:"addlGkeTenantSaRoles"`
-	AddlGroupIamBindings struct {
-		RolesRolesTest []string `json:"roles/roles.test"`
-	} `json:"addlGroupIamBindings"`
+	// AddlGroupIamBindings maps an IAM role, such as roles/viewer, to the principals
+	// bound to it, such as group:team@example.com
+	AddlGroupIamBindings map[string][]string `json:"addlGroupIamBindings"`
+	// Labels are arbitrary key/value labels, such as team or project code, following
+	// the GCP label rules. Each is also published as a label.<key> attribute so
+	// subscriptions can filter on it.
+	Labels  map[string]string `json:"labels,omitempty"`
 	NsQuota struct {
 		Requests struct {
 			Cpu    string `json:"cpu"`
@@ -48,120 +74,993 @@ type TinyHomeInstructions struct {
 	} `json:"nsQuota"`
 }
 
-func (message *TinyHomeInstructions) PublishTinyHomeInstructions(messageAttributes *TinyHomeMessageAttributes) (string, error) {
+// PublishResult describes a published message
+type PublishResult struct {
+	// MessageID is the server generated ID of the message
+	MessageID string
+	// Subscription is the subscription the attributes route the message to
+	Subscription string
+	// TenantName is the tenant the message is for, including a generated name
+	TenantName string
+	// CorrelationID is the correlationId attribute, supplied or generated
+	CorrelationID string
+	// IdempotencyKey is the idempotencyKey attribute, supplied or the InstructionHash
+	IdempotencyKey string
+	// Attributes are the Pub/Sub attributes set on the message
+	Attributes map[string]string
+	// PublishedAt is the producer's clock at publish, also sent as the publishedAt
+	// attribute
+	PublishedAt time.Time
+	// Warnings are problems that did not stop the publish, such as routing to one of
+	// the DeprecatedStages
+	Warnings []string
+	// Deduplicated is set when an identical message was published within DedupTTL, so
+	// this is that publish's result and nothing was published again
+	Deduplicated bool
+	// CloudEventsMessageID is the ID of the CloudEvents copy on CloudEventsTopicID when
+	// dual-publishing
+	CloudEventsMessageID string
+	// Err is set on results delivered over a channel or to AsyncCallback when the
+	// publish failed
+	Err error
+}
+
+// Publisher publishes instructions over a single long-lived Pub/Sub client and its
+// topic handles. Create one with NewPublisher, reuse it for every publish and Close it
+// when done.
+//
+// A Publisher is safe for concurrent use by multiple goroutines. Its config and topic
+// handles are fixed at construction, and the shared state it writes to, the publish
+// log, the limiter and the Stats counters, is guarded by locks or atomics. The
+// instructions passed to a publish are updated in place, with generated names and
+// defaults, so each goroutine must publish its own instructions value. The Logger,
+// Metrics and RetryQueue supplied must also be safe for concurrent use.
+type Publisher struct {
+	cfg    PublisherConfig
+	client *pubsub.Client
+	// topic is the handle for TopicID, nil when only TopicByEnvironment is set
+	topic topicPublisher
+	// topics are the handles for the TopicByEnvironment topics, keyed by topic id
+	topics map[string]topicPublisher
+
+	// ownsClient is set when NewPublisher created client, so Close releases it
+	ownsClient bool
+
+	// settling counts the PublishAsync results still being awaited and the
+	// AsyncCallback and OnDelivered calls still to run, so Flush waits for them
+	settling sync.WaitGroup
+	// stats are the counters reported by Stats
+	stats publisherStats
+	// schemas are the topic schemas ValidateAgainstSchema has looked up
+	schemas schemaCache
+	// dedup remembers recent publishes for DedupTTL
+	dedup dedupCache
+
+	// limiter holds the per topic publish slots, created on first use
+	limiterOnce sync.Once
+	limiter     *topicLimiter
+}
+
+// InstructionPublisher is the publishing surface of a Publisher, so code that
+// publishes instructions can be tested with publishertest.Publisher instead
+type InstructionPublisher interface {
+	Publish(message *TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes) (string, error)
+	PublishContext(ctx context.Context, message *TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes) (string, error)
+	PublishWithResult(ctx context.Context, message *TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes) (*PublishResult, error)
+}
+
+var _ InstructionPublisher = (*Publisher)(nil)
+
+// NewPublisher creates the Pub/Sub client and topic handle described by cfg, after
+// applying opts. Start from DefaultPublisherConfig to keep the default project and
+// topic. The client honors PUBSUB_EMULATOR_HOST, so setting it points the Publisher
+// at the emulator without credentials.
+func NewPublisher(ctx context.Context, cfg PublisherConfig, opts ...Option) (*Publisher, error) {
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("NewPublisher: %v", err)
+	}
+
+	client, err := pubsub.NewClient(ctx, cfg.ProjectID, cfg.ClientOptions...)
+	if err != nil {
+		return nil, &TransportError{Op: "pubsub.NewClient", Err: err}
+	}
+
+	p := newPublisherWithClient(client, cfg)
+	p.ownsClient = true
+	return p, nil
+}
+
+// Flush blocks until every message handed to the topics, including PublishAsync
+// messages not yet waited on, has been sent and every AsyncCallback and OnDelivered
+// call has returned, or ctx is done. On shutdown call Flush with a deadline and then
+// Close, so buffered messages are not lost and shutdown can not hang. Messages still
+// unsent when ctx is done stay buffered and Close sends them.
+func (p *Publisher) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if p.topic != nil {
+			p.topic.Flush()
+		}
+		for _, topic := range p.topics {
+			topic.Flush()
+		}
+		p.settling.Wait()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("Flush: %w", ctx.Err())
+	}
+}
+
+// Close flushes any messages still buffered on the topic and releases the client
+// when it was created by NewPublisher
+func (p *Publisher) Close() error {
+	if p.topic != nil {
+		p.topic.Stop()
+	}
+	for _, topic := range p.topics {
+		topic.Stop()
+	}
+	p.schemas.mu.Lock()
+	if p.schemas.service != nil {
+		p.schemas.service.close()
+	}
+	p.schemas.mu.Unlock()
+	if !p.ownsClient {
+		return nil
+	}
+	return p.client.Close()
+}
+
+// Publish validates and publishes the instructions, blocking until the server returns
+// the message ID. It is PublishContext with context.Background.
+func (p *Publisher) Publish(message *TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes) (string, error) {
+	return p.PublishContext(context.Background(), message, messageAttributes)
+}
+
+// PublishContext validates and publishes the instructions, blocking until the server
+// returns the message ID or ctx is done. When ctx is cancelled or its deadline passes
+// the returned error wraps ctx.Err(), so callers can tell timeouts apart from publish
+// failures with errors.Is. It returns as soon as ctx is done, and a message already
+// handed to the topic may still be delivered by the server after that.
+func (p *Publisher) PublishContext(ctx context.Context, message *TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes) (string, error) {
+	result, err := p.PublishWithResult(ctx, message, messageAttributes)
+	if err != nil {
+		return "", err
+	}
+	return result.MessageID, nil
+}
+
+// PublishWithResult is PublishContext returning the PublishResult rather than only
+// the message ID
+func (p *Publisher) PublishWithResult(ctx context.Context, message *TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes) (*PublishResult, error) {
+	result, err := p.publish(ctx, message, messageAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("Publish: %w", err)
+	}
+	return result, nil
+}
+
+// PublishTinyHomeInstructions validates and publishes the instructions. The message
+// goes to DefaultProjectID and DefaultTopicID unless opts, such as WithProject and
+// WithTopic, say otherwise. A new client is created for every call, services that
+// publish often should create a Publisher once and reuse it instead.
+func (message *TinyHomeInstructions) PublishTinyHomeInstructions(messageAttributes *TinyHomeMessageAttributes, opts ...Option) (string, error) {
+	return message.PublishTinyHomeInstructionsContext(context.Background(), messageAttributes, opts...)
+}
+
+// PublishTinyHomeInstructionsContext is PublishTinyHomeInstructions with a caller
+// supplied context, used to create the client and for the publish itself. See
+// Publisher.PublishContext for how cancellation is reported.
+func (message *TinyHomeInstructions) PublishTinyHomeInstructionsContext(ctx context.Context, messageAttributes *TinyHomeMessageAttributes, opts ...Option) (string, error) {
+	result, err := message.PublishTinyHomeInstructionsWithResult(ctx, messageAttributes, opts...)
+	if err != nil {
+		return "", err
+	}
+	return result.MessageID, nil
+}
+
+// PublishTinyHomeInstructionsWithResult is PublishTinyHomeInstructionsContext
+// returning the PublishResult rather than only the message ID
+func (message *TinyHomeInstructions) PublishTinyHomeInstructionsWithResult(ctx context.Context, messageAttributes *TinyHomeMessageAttributes, opts ...Option) (*PublishResult, error) {
+	p, err := NewPublisher(ctx, DefaultPublisherConfig(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("PublishTinyHomeInstructions: %w", err)
+	}
+	defer p.Close()
+
+	result, err := p.publish(ctx, message, messageAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("PublishTinyHomeInstructions: %w", err)
+	}
+	return result, nil
+}
+
+// publish runs the validation and publish path shared by every entry point
+func (p *Publisher) publish(ctx context.Context, message *TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes) (result *PublishResult, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	ctx, span := p.tracer().Start(ctx, publishSpanName, trace.WithAttributes(
+		attribute.String("tinyhome.topic", p.cfg.TopicID),
+	))
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	logLine := PublishLogLine{Topic: p.cfg.TopicID}
+	defer func() {
+		logLine.Tenant = message.TenantName
+		if result != nil {
+			logLine.MessageID = result.MessageID
+		}
+		p.recordPublish(logLine, start, err)
+	}()
+
+	prepared, err := p.prepare(ctx, message, messageAttributes, &logLine)
+	if err != nil {
+		p.quarantine(ctx, message, messageAttributes, err)
+		return nil, err
+	}
+
+	if result, ok := p.recentPublish(prepared); ok {
+		p.logger().InfoContext(ctx, "skipped publishing a duplicate of a recent message", "tenantName", prepared.tenantName, "messageId", result.MessageID, "idempotencyKey", result.IdempotencyKey)
+		return result, nil
+	}
+	result, err = p.sendPrepared(ctx, prepared)
+	if err != nil {
+		return nil, err
+	}
+	p.rememberPublish(prepared, result)
+	return result, nil
+}
+
+// preparedMessage is a validated message ready to be sent to the topic
+type preparedMessage struct {
+	data              []byte
+	attributes        map[string]string
+	subscription      string
+	tenantName        string
+	messageAttributes *TinyHomeMessageAttributes
+	attrMessage       string
+	publishedAt       time.Time
+	warnings          []string
+	topicID           string
+	topic             topicPublisher
+	// dual is the CloudEvents copy for CloudEventsTopicID, nil unless dual-publishing
+	dual *preparedCopy
+}
+
+// preparedCopy is the body and attributes of the CloudEvents copy of a dual-published
+// message
+type preparedCopy struct {
+	data       []byte
+	attributes map[string]string
+}
+
+// result returns the PublishResult of the prepared message published with id
+func (prepared *preparedMessage) result(id string) *PublishResult {
+	return &PublishResult{
+		MessageID:      id,
+		Subscription:   prepared.subscription,
+		TenantName:     prepared.tenantName,
+		CorrelationID:  prepared.attributes["correlationId"],
+		IdempotencyKey: prepared.attributes["idempotencyKey"],
+		Attributes:     prepared.attributes,
+		PublishedAt:    prepared.publishedAt,
+		Warnings:       prepared.warnings,
+	}
+}
+
+// prepare validates the instructions and attributes and builds the message body and
+// Pub/Sub attributes, recording what it learns on the span in ctx and on logLine
+func (p *Publisher) prepare(ctx context.Context, message *TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes, logLine *PublishLogLine) (*preparedMessage, error) {
+	span := trace.SpanFromContext(ctx)
+
+	if p.cfg.NormalizeAttributes {
+		messageAttributes = messageAttributes.normalized()
+	}
+	if p.cfg.DefaultEmptyAttributes {
+		defaulted := *messageAttributes
+		defaulted.ApplyDefaults()
+		messageAttributes = &defaulted
+	}
+
 	// Validate the TinyHomeMessageAttributes
-	attrMessage, err := messageAttributes.validateAttributes()
+	attrMessage, err := messageAttributes.validateAttributes(p.cfg)
 	if err != nil {
-		return "", fmt.Errorf("PublishTinyHomeInstructions: %v", err)
+		return nil, err
+	}
+
+	// Trimmed values are left on the instructions so they are what is published
+	if p.cfg.TrimWhitespace {
+		message.trimWhitespace()
+	} else if err := message.validateWhitespace(); err != nil {
+		return nil, err
+	}
+
+	// Lowercase the tenant name rather than reject upper case, and slugify the
+	// organization when it must be a slug. Instructions are validated with the
+	// normalized name, the slugified organization is left on them.
+	suppliedTenantName := message.TenantName
+	if p.cfg.NormalizeTenantName {
+		messageAttributes = message.normalizeTenantName(messageAttributes)
+		if p.cfg.OrganizationSlug {
+			message.SlugifyOrganization()
+		}
+	}
+
+	if err := message.applyTenantNameAttributeMode(messageAttributes, p.cfg); err != nil {
+		return nil, err
+	}
+
+	// Generate a TenantName when one was not supplied, the generated name is left on
+	// the instructions so the caller can read it back
+	if err := message.generateTenantName(p.cfg.NameGenerator); err != nil {
+		return nil, err
+	}
+	// The body keeps the tenant name as supplied, or as generated, and so do the
+	// caller's instructions once prepared
+	bodyTenantName := message.TenantName
+	if p.cfg.NormalizeTenantName {
+		if suppliedTenantName != "" {
+			bodyTenantName = suppliedTenantName
+		}
+		message.TenantName = strings.ToLower(message.TenantName)
+		defer func() { message.TenantName = bodyTenantName }()
+	}
+
+	span.SetAttributes(attribute.String("tinyhome.tenant_name", message.TenantName))
+
+	// Apply the environment's default Region, it is left on the instructions and
+	// published in the region attribute so the caller can see what was applied
+	if region := message.applyRegionDefault(p.cfg.RegionDefaults); region != "" {
+		p.logger().DebugContext(ctx, "applied default region", "region", region, "environment", message.Environment)
+	}
+
+	// The default cost center is left on the instructions like the default Region
+	if message.TenantCostCenter == "" {
+		message.TenantCostCenter = p.cfg.DefaultTenantCostCenter
+	}
+
+	// Sort and dedupe roles before they are validated, so RoleOrderFix repairs
+	// duplicates rather than having them rejected
+	if err := message.applyRoleOrderPolicy(p.cfg.RoleOrder); err != nil {
+		return nil, err
 	}
 
 	// Validate all TinyHomeInstructions
-	err = message.validateInstructions()
+	err = message.validateInstructionsWith(p.cfg)
 	if err != nil {
-		return "", fmt.Errorf("PublishTinyHomeInstructions: %v", err)
+		return nil, err
 	}
 
-	byteMessage, _ := json.Marshal(&message)
-	projectId := "tdigangi-demos"
-	topicId := "tiny-home-api-0.0.1"
-	ctx := context.Background()
-	client, err := pubsub.NewClient(ctx, projectId)
+	topicID, err := p.cfg.topicFor(message.Environment)
 	if err != nil {
-		return "", fmt.Errorf("pubsub.NewClient: %v", err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("tinyhome.topic", topicID))
+	logLine.Topic = topicID
+
+	if err := message.validateEntryCount(p.cfg.MaxInstructionEntries, p.cfg.AdditionalAttributes); err != nil {
+		return nil, err
+	}
+
+	if err := message.validateOwnerDomain(p.cfg.OwnerDomain); err != nil {
+		return nil, err
 	}
-	defer client.Close()
 
-	t := client.Topic(topicId)
-	result := t.Publish(ctx, &pubsub.Message{
-		Data: byteMessage,
-		Attributes: map[string]string{
-			"groupsCreated":    messageAttributes.GroupsCreated,    // true or false
-			"workspaceCreated": messageAttributes.WorkspaceCreated, // true or false
-			"tenantCreated":    messageAttributes.TenantCreated,    // true or false
-			"fluxCreated":      messageAttributes.FluxCreated,      // true or false
-			"deliveredFrom":    messageAttributes.DeliveredFrom,    // manual or galaxy
-			"tenantName":       message.TenantName,
-		},
-	})
+	if p.cfg.PolicyBundle != nil {
+		if err := p.cfg.PolicyBundle.check(*message); err != nil {
+			return nil, err
+		}
+	}
 
-	// Block until the result is returned and a server-generated
-	// ID is returned for the published message.
-	id, err := result.Get(ctx)
+	if err := message.validateBurstRatio(p.cfg.MaxBurstRatio); err != nil {
+		return nil, err
+	}
+
+	// Validate fields only required once the pipeline reaches a given stage
+	subscription, _ := messageAttributes.subscriptionWith(p.cfg)
+	span.SetAttributes(attribute.String("tinyhome.stage", subscription))
+	logLine.Stage = subscription
+	if err := message.validateForStage(subscription); err != nil {
+		return nil, err
+	}
+	if err := validateMinStage(subscription, p.cfg.MinStage); err != nil {
+		return nil, err
+	}
+	var warnings []string
+	warning, err := deprecatedStageWarning(subscription, p.cfg.DeprecatedStages, p.cfg.StrictDeprecatedStages)
 	if err != nil {
-		return "", fmt.Errorf("PublishTinyHomeInstructions: %v", err)
+		return nil, err
+	}
+	if warning != "" {
+		p.logger().WarnContext(ctx, warning, "tenantName", message.TenantName, "subscription", subscription)
+		warnings = append(warnings, warning)
+	}
+
+	if subscription == "deliverEmail" && !p.cfg.EnableDeliverEmail {
+		return nil, fmt.Errorf("%w: %s, enable it with WithDeliverEmail", ErrStageNotImplemented, subscription)
 	}
 
-	log.Printf("tenant name: %v, published message id: %v with attributes: %v \n\n", message.TenantName, id, messageAttributes)
-	log.Printf(attrMessage)
-	return id, nil
+	// Only the tenantName attribute carries the normalized name, which resources are
+	// named after. The instructionHash is taken from the body as published.
+	body := *message
+	body.TenantName = bodyTenantName
+	attributes, err := body.buildAttributes(messageAttributes)
+	if err != nil {
+		return nil, err
+	}
+	attributes[AttrTenantName] = message.TenantName
+	if p.cfg.TenantNameAttribute == TenantNameAttributeSource && messageAttributes.TenantName != "" {
+		attributes[AttrTenantName] = messageAttributes.TenantName
+	}
+	// The producer's wall clock time, so subscribers can detect late arrivals
+	publishedAt := p.cfg.now().UTC()
+	attributes["publishedAt"] = publishedAt.Format(time.RFC3339)
+
+	if err := p.addConfigAttributes(attributes); err != nil {
+		return nil, err
+	}
+
+	if p.cfg.GenerateCorrelationID && attributes["correlationId"] == "" {
+		attributes["correlationId"], err = newCorrelationID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := message.applyMessageKey(p.cfg.MessageKeyField, attributes); err != nil {
+		return nil, err
+	}
+
+	if p.cfg.NamespaceAttribute {
+		namespace := message.ResourceNames().Namespace
+		if err := validateDNS1123Label(namespace); err != nil {
+			return nil, &NamingError{Field: "namespace", Message: fmt.Sprintf("namespace %q: %v", namespace, err)}
+		}
+		attributes["namespace"] = namespace
+	}
+
+	var byteMessage []byte
+	if p.cfg.CompactDefaults != nil {
+		attributes["compact"] = "true"
+		byteMessage, err = body.MarshalCompact(*p.cfg.CompactDefaults)
+	} else if p.cfg.FlattenQuota {
+		byteMessage, err = body.MarshalFlat()
+	} else {
+		byteMessage, err = marshalInstructions(&body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %v", err)
+	}
+
+	// When dual-publishing the CloudEvents copy gets its own attributes, as wrapping
+	// sets content-type and checksums differ
+	var dual *preparedCopy
+	source := p.cfg.CloudEventsSource
+	if p.cfg.CloudEventsTopicID != "" {
+		dual = &preparedCopy{attributes: maps.Clone(attributes)}
+		if dual.data, err = p.encodeBody(byteMessage, source, subscription, publishedAt, dual.attributes); err != nil {
+			return nil, err
+		}
+		source = ""
+	}
+
+	if byteMessage, err = p.encodeBody(byteMessage, source, subscription, publishedAt, attributes); err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("tinyhome.message_size", len(byteMessage)))
+	logLine.SizeBytes = len(byteMessage)
+
+	return &preparedMessage{
+		data:              byteMessage,
+		attributes:        attributes,
+		subscription:      subscription,
+		tenantName:        message.TenantName,
+		messageAttributes: messageAttributes,
+		attrMessage:       attrMessage,
+		publishedAt:       publishedAt,
+		warnings:          warnings,
+		topicID:           topicID,
+		topic:             p.topicHandle(topicID),
+		dual:              dual,
+	}, nil
+}
+
+// marshalInstructions encodes the instructions published in the native format, a
+// variable so tests can force a marshal failure
+var marshalInstructions = func(message *TinyHomeInstructions) ([]byte, error) {
+	return json.Marshal(message)
 }
 
+// encodeBody turns the marshaled instructions into the message body sent, wrapped in
+// a CloudEvents envelope from source when it is set, then compressed and checksummed
+// as configured, and applies the message size limits
+func (p *Publisher) encodeBody(data []byte, source, subscription string, publishedAt time.Time, attributes map[string]string) ([]byte, error) {
+	var err error
+	if source != "" {
+		if data, err = wrapCloudEvent(data, source, subscription, publishedAt, attributes); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.cfg.Compression {
+		if data, err = compressBody(data, attributes); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.cfg.BodyChecksum {
+		if err := addBodyChecksum(data, attributes); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.checkMessage(data, attributes); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// sendPrepared sends a prepared message and logs the published message ID
+func (p *Publisher) sendPrepared(ctx context.Context, prepared *preparedMessage) (*PublishResult, error) {
+	if prepared.dual != nil {
+		return p.sendDual(ctx, prepared)
+	}
+
+	id, err := p.sendTo(ctx, prepared.topicID, prepared.topic, prepared.data, prepared.attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("tinyhome.message_id", id))
+	p.logger().InfoContext(ctx, "published message", "tenantName", prepared.tenantName, "messageId", id, "correlationId", prepared.attributes["correlationId"], "attributes", prepared.messageAttributes)
+	p.logger().DebugContext(ctx, prepared.attrMessage)
+	result := prepared.result(id)
+	p.delivered(ctx, result)
+	if err := p.notifyWebhook(ctx, result, prepared.data); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// sendDual sends a prepared message to its topic and its CloudEvents copy to
+// CloudEventsTopicID at the same time. When either fails the error is a
+// DualPublishError holding the ID of the one that succeeded, if any.
+func (p *Publisher) sendDual(ctx context.Context, prepared *preparedMessage) (*PublishResult, error) {
+	var (
+		ceID  string
+		ceErr error
+		done  = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		ceID, ceErr = p.sendTo(ctx, p.cfg.CloudEventsTopicID, p.topicHandle(p.cfg.CloudEventsTopicID), prepared.dual.data, prepared.dual.attributes)
+	}()
+	id, err := p.sendTo(ctx, prepared.topicID, prepared.topic, prepared.data, prepared.attributes)
+	<-done
+
+	if err != nil || ceErr != nil {
+		return nil, &DualPublishError{MessageID: id, Err: err, CloudEventsMessageID: ceID, CloudEventsErr: ceErr}
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("tinyhome.message_id", id))
+	p.logger().InfoContext(ctx, "published message", "tenantName", prepared.tenantName, "messageId", id, "cloudEventsMessageId", ceID, "correlationId", prepared.attributes["correlationId"], "attributes", prepared.messageAttributes)
+	p.logger().DebugContext(ctx, prepared.attrMessage)
+	result := prepared.result(id)
+	result.CloudEventsMessageID = ceID
+	p.delivered(ctx, result)
+	if err := p.notifyWebhook(ctx, result, prepared.data); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// publishMessage sends data with attributes to the topic and blocks until the server
+// returns the message ID. Messages that fail are handed to the RetryQueue, if one is set.
+func (p *Publisher) publishMessage(ctx context.Context, data []byte, attributes map[string]string) (string, error) {
+	if err := p.checkMessage(data, attributes); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	return p.send(ctx, data, attributes)
+}
+
+// publishTo is publishMessage for the topic with id topicID rather than TopicID
+func (p *Publisher) publishTo(ctx context.Context, topicID string, data []byte, attributes map[string]string) (string, error) {
+	if err := p.checkMessage(data, attributes); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	return p.sendTo(ctx, topicID, p.topicHandle(topicID), data, attributes)
+}
+
+// withTimeout bounds ctx by PublishTimeout, when set. A shorter deadline already on
+// ctx still wins.
+func (p *Publisher) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.cfg.PublishTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.cfg.PublishTimeout)
+}
+
+// checkMessage applies the Pub/Sub attribute count and value limits, then the
+// MaxMessageBytes limit to the data and attributes together
+func (p *Publisher) checkMessage(data []byte, attributes map[string]string) error {
+	if err := validateAttributeCount(attributes); err != nil {
+		return err
+	}
+
+	if err := applyAttributeValuePolicy(attributes, p.cfg.AttributeValuePolicy); err != nil {
+		return err
+	}
+
+	if size := messageBytes(data, attributes); size > p.cfg.MaxMessageBytes {
+		return fmt.Errorf("%w: message is %d bytes including attributes, at most %d are allowed", ErrMessageTooLarge, size, p.cfg.MaxMessageBytes)
+	}
+	return nil
+}
+
+// send publishes a message that already passed checkMessage to the TopicID topic
+func (p *Publisher) send(ctx context.Context, data []byte, attributes map[string]string) (string, error) {
+	if p.topic == nil {
+		return "", fmt.Errorf("no default topic is configured, set one with WithTopic")
+	}
+	return p.sendTo(ctx, p.cfg.TopicID, p.topic, data, attributes)
+}
+
+// sendTo publishes a message that already passed checkMessage to topic, retrying
+// transient failures and handing messages that still fail to the RetryQueue
+func (p *Publisher) sendTo(ctx context.Context, topicID string, topic topicPublisher, data []byte, attributes map[string]string) (string, error) {
+	f, err := p.issue(ctx, topicID, topic, data, attributes)
+	if err != nil {
+		return "", err
+	}
+	return p.await(ctx, f)
+}
+
+// inFlight is a message handed to its topic whose result is still to be awaited
+type inFlight struct {
+	topicID string
+	topic   topicPublisher
+	msg     *pubsub.Message
+	result  publishResult
+	// release frees the publish slot the message holds
+	release func()
+}
+
+// issue waits for a publish slot on topic and hands it the message without waiting
+// for the result. await must be called on the returned inFlight to free the slot.
+func (p *Publisher) issue(ctx context.Context, topicID string, topic topicPublisher, data []byte, attributes map[string]string) (*inFlight, error) {
+	if topic == nil {
+		return nil, fmt.Errorf("no handle for topic %s", topicID)
+	}
+
+	release, err := p.concurrencyLimiter().acquire(ctx, topicID)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for a publish slot: %w", err)
+	}
+
+	msg := &pubsub.Message{
+		Data:        data,
+		Attributes:  attributes,
+		OrderingKey: p.orderingKey(attributes),
+	}
+	p.addInFlight(1)
+	return &inFlight{
+		topicID: topicID,
+		topic:   topic,
+		msg:     msg,
+		result:  topic.Publish(ctx, msg),
+		release: release,
+	}, nil
+}
+
+// await blocks until the server returns the message ID of f or ctx is done, retrying
+// transient failures, handing messages that still fail to the RetryQueue and then
+// freeing the publish slot
+func (p *Publisher) await(ctx context.Context, f *inFlight) (string, error) {
+	defer f.release()
+	defer p.addInFlight(-1)
+
+	// Transient failures are retried with exponential backoff, up to
+	// RetryMaxAttempts attempts in total
+	var err error
+	backoff := p.cfg.RetryInitialBackoff
+	for attempt := 1; ; attempt++ {
+		// Block until the result is returned and a server-generated
+		// ID is returned for the published message, or ctx is done.
+		var id string
+		id, err = waitResult(ctx, f.result)
+		if err == nil {
+			return id, nil
+		}
+
+		// A failed publish pauses its ordering key, later publishes for the tenant
+		// fail until it is resumed
+		if f.msg.OrderingKey != "" {
+			f.topic.ResumePublish(f.msg.OrderingKey)
+		}
+
+		if attempt >= p.cfg.RetryMaxAttempts || ctx.Err() != nil || !isRetryable(err) || !waitBackoff(ctx, backoff) {
+			break
+		}
+		p.logger().DebugContext(ctx, "retrying publish", "attempt", attempt, "error", err)
+		backoff *= 2
+		f.msg = retryMessage(f.msg, attempt)
+		f.result = f.topic.Publish(ctx, f.msg)
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return "", fmt.Errorf("waiting for publish result: %w", ctxErr)
+	}
+	if qErr := p.enqueueFailed(f.msg.Data, f.msg.Attributes, err); qErr != nil {
+		p.logger().WarnContext(ctx, "failed message not queued for retry", "error", qErr)
+	}
+	return "", publishError(f.topicID, err)
+}
+
+// retryMessage returns a copy of msg for retry number attempt, from 1, with the
+// retryAttempt attribute set to attempt so subscribers and monitoring see how many
+// attempts a message took. The first attempt has no retryAttempt, read it as 0. A
+// message already at the Pub/Sub attribute limit is retried without it.
+func retryMessage(msg *pubsub.Message, attempt int) *pubsub.Message {
+	attributes := maps.Clone(msg.Attributes)
+	if _, ok := attributes["retryAttempt"]; ok || len(attributes) < maxMessageAttributes {
+		attributes["retryAttempt"] = strconv.Itoa(attempt)
+	}
+	return &pubsub.Message{
+		Data:        msg.Data,
+		Attributes:  attributes,
+		OrderingKey: msg.OrderingKey,
+	}
+}
+
+// orderingKey returns the ordering key for a message with attributes. With ordering
+// enabled messages for the same tenant are delivered in the order they were published.
+func (p *Publisher) orderingKey(attributes map[string]string) string {
+	if !p.cfg.MessageOrdering {
+		return ""
+	}
+	return attributes[AttrTenantName]
+}
+
+// supportedSpecialChars are the only non alphanumeric characters allowed in TenantName
+var supportedSpecialChars = []string{"-"}
+
 // validateInstructions is meant to only cover cases not directly embedded in the pubsub avro messages including
-// validate of TenantName, AddlGkeTenantSaRoles
+// validate of TenantName, AddlGkeTenantSaRoles. Config dependent rules use DefaultPublisherConfig.
 func (message TinyHomeInstructions) validateInstructions() error {
-	if len(message.TenantName) > 20 {
-		return fmt.Errorf("tenantName greater than 20 characters")
+	return message.validateInstructionsWith(DefaultPublisherConfig())
+}
+
+// validateInstructionsWith is validateInstructions with the config dependent rules
+// taken from cfg
+func (message TinyHomeInstructions) validateInstructionsWith(cfg PublisherConfig) error {
+	for _, check := range message.instructionChecks(cfg) {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// instructionChecks are the validateInstructions rules in the order they are applied
+func (message TinyHomeInstructions) instructionChecks(cfg PublisherConfig) []func() error {
+	return []func() error{
+		func() error { return message.validateTenantName(cfg.MinTenantNameLength, cfg.MaxTenantNameLength) },
+		func() error { return message.validateDNS1123TenantName(cfg.RequireDNS1123TenantName) },
+		func() error { return message.validateProjectIDTenantName(cfg.RequireProjectIDTenantName) },
+		func() error { return message.validateTenantNameUnused(cfg.ExistingTenantNames) },
+		func() error { return message.validateEnvironment(cfg.Environments) },
+		func() error { return message.validateTopicVersion(cfg.TopicVersion, cfg.EnvironmentTopicVersions) },
+		func() error { return message.validateBusinessUnit(cfg.BusinessUnits) },
+		func() error { return message.validateBusinessUnitPrefix(cfg.BusinessUnitPrefixes) },
+		message.validateDomainFormat,
+		func() error { return message.validateOrganizationFormat(cfg.OrganizationPattern, cfg.OrganizationSlug) },
+		func() error { return message.ValidateOrganizationForEnvironment(cfg.AllowedOrganizations) },
+		func() error { return message.ValidateCasing(cfg.CasingPolicies) },
+		func() error { return message.validateRegion(cfg.Regions) },
+		func() error { return message.validateRegionNotDecommissioned(cfg.DecommissionedRegions) },
+		message.validatePriority,
+		message.validateBreakglassApproval,
+		func() error { return message.ValidateBreakglassPolicy(cfg.BreakglassForbiddenEnvironments) },
+		func() error { return message.validateBreakglassTicket(cfg.BreakglassTicketPattern) },
+		func() error { return message.validateBreakglassWindow(cfg.now()) },
+		func() error { return message.validateBreakglassNotAfter(cfg.BreakglassNotAfter, cfg.now()) },
+		message.validateOwners,
+		func() error { return message.ValidateOwnerAllowed(cfg.AllowedOwners) },
+		func() error { return message.validateOwnerAccepted(cfg.RequireOwnerAcceptance) },
+		func() error { return message.validateCostCenter(cfg.CostCenterPattern) },
+		func() error { return message.ValidateCostCenter(cfg.BusinessUnitCostCenters) },
+		message.validateSaRoles,
+		message.validateIamBindings,
+		func() error { return message.validateIamMemberDomains(cfg.IAMMemberDomains) },
+		func() error { return message.validateIAMMemberConsistency(cfg.IAMMemberConsistency) },
+		func() error { return message.validateIAMMemberLimit(cfg.MaxIAMMembersPerRole) },
+		message.validateLabels,
+		message.validateQuota,
+		func() error { return message.ValidateQuotaCaps(cfg.QuotaCaps) },
+		func() error { return message.ValidateCostCenterBudget(cfg.CostCenterBudgets) },
+	}
+}
+
+// validateTenantName checks TenantName is between minLength and maxLength
+// characters of lower case letters, digits and supportedSpecialChars, starts with a
+// letter and ends with a letter or digit so it is usable in the derived namespace
+func (message TinyHomeInstructions) validateTenantName(minLength, maxLength int) error {
+	if message.TenantName == "" {
+		return &NamingError{Field: "tenantName", Message: "tenantName can not be empty"}
+	}
+
+	if len(message.TenantName) < minLength {
+		return &NamingError{Field: "tenantName", Message: fmt.Sprintf("tenantName less than %d characters", minLength)}
+	}
+
+	if len(message.TenantName) > maxLength {
+		return &NamingError{Field: "tenantName", Message: fmt.Sprintf("tenantName greater than %d characters", maxLength)}
 	}
 
-	supportedSpecialChars := []string{"-"}
 	// tenantName string can only contain lower case letters & supportedSpecialChars
 	for _, r := range message.TenantName {
 		if !unicode.IsLower(r) && unicode.IsLetter(r) {
-			return fmt.Errorf("tenantName supports only lower case characters")
+			return &NamingError{Field: "tenantName", Message: "tenantName supports only lower case characters"}
 		}
 
 		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
 			if !contains(supportedSpecialChars, string(r)) {
-				return fmt.Errorf("tenantName is using unsuported special characters, only supported characters are: %s", supportedSpecialChars)
+				return &NamingError{Field: "tenantName", Message: fmt.Sprintf("tenantName is using unsuported special characters, only supported characters are: %s", supportedSpecialChars)}
 			}
 		}
 	}
 
+	if strings.HasPrefix(message.TenantName, "-") || strings.HasSuffix(message.TenantName, "-") {
+		return &NamingError{Field: "tenantName", Message: "tenantName can not start or end with '-'"}
+	}
+
+	// Namespaces and GCP resource names derived from it must begin with a letter
+	if first := message.TenantName[0]; first < 'a' || first > 'z' {
+		return &NamingError{Field: "tenantName", Message: fmt.Sprintf("tenantName must start with a lower case letter, got %q", message.TenantName[:1])}
+	}
 	return nil
 }
 
-func (messageAttributes *TinyHomeMessageAttributes) validateAttributes() (string, error) {
-	boolVals := []string{"true", "false"}
-	deliveryVals := []string{"galaxy", "manual"}
+// validateRegion allows an empty Region, but when set it must be a known GCP region,
+// one of regions when that is set
+func (message TinyHomeInstructions) validateRegion(regions []string) error {
+	if message.Region != "" && !isKnownRegion(regions, message.Region) {
+		return &NamingError{Field: "region", Message: fmt.Sprintf("region %q is not a known GCP region", message.Region)}
+	}
+	return nil
+}
+
+func (message TinyHomeInstructions) validatePriority() error {
+	if !contains(priorityVals, message.priority()) {
+		return &RoutingError{Field: "priority", Message: fmt.Sprintf("priority %q is not one of: %s", message.Priority, priorityVals)}
+	}
+	return nil
+}
+
+// validateBreakglassTicket requires break-glass requests to reference a ticket
+// matching pattern, so the access is auditable
+func (message TinyHomeInstructions) validateBreakglassTicket(pattern *regexp.Regexp) error {
+	if !message.Breakglass || pattern == nil {
+		return nil
+	}
+
+	if message.BreakglassTicket == "" {
+		return &OwnerError{Field: "breakglassTicket", Message: "breakglassTicket is required when breakglass is requested"}
+	}
+
+	if !pattern.MatchString(message.BreakglassTicket) {
+		return &OwnerError{Field: "breakglassTicket", Message: fmt.Sprintf("breakglassTicket %q does not match %s", message.BreakglassTicket, pattern)}
+	}
+	return nil
+}
+
+// validateBreakglassApproval requires break-glass in prod to be approved by someone
+// other than the tenant owner
+func (message TinyHomeInstructions) validateBreakglassApproval() error {
+	if message.Environment != "prod" || !message.Breakglass {
+		return nil
+	}
+
+	if message.BreakglassApprover == "" {
+		return &OwnerError{Field: "breakglassApprover", Message: "breakglassApprover is required when breakglass is requested in prod"}
+	}
+
+	if _, err := mail.ParseAddress(message.BreakglassApprover); err != nil {
+		return &OwnerError{Field: "breakglassApprover", Message: fmt.Sprintf("breakglassApprover is not a valid email address: %v", err)}
+	}
+
+	if message.BreakglassApprover == message.TenantOwner {
+		return &OwnerError{Field: "breakglassApprover", Message: "breakglassApprover can not be the tenantOwner"}
+	}
+	return nil
+}
+
+func (messageAttributes *TinyHomeMessageAttributes) validateAttributes(cfg PublisherConfig) (string, error) {
+	subscriptionText, err := messageAttributes.subscriptionWith(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	if err := validateCorrelationID(messageAttributes.CorrelationID); err != nil {
+		return "", err
+	}
+
+	if err := validateIdempotencyKey(messageAttributes.IdempotencyKey); err != nil {
+		return "", err
+	}
+	deliveryText := fmt.Sprintf("%s: %s", "message will be delivered to subscription", subscriptionText)
+	return deliveryText, nil
+}
+
+// Subscription validates the attribute values and returns the subscription they route
+// to, one of createGroups, createWorkspace, createTenant, createFlux or deliverEmail,
+// without publishing anything. It is the decision table validateAttributes uses, with
+// the DefaultPublisherConfig delivery sources and DefaultSubscriptionRules.
+func (messageAttributes *TinyHomeMessageAttributes) Subscription() (string, error) {
+	return messageAttributes.subscriptionWith(DefaultPublisherConfig())
+}
+
+// subscriptionWith is Subscription with the delivery sources and decision table in cfg
+func (messageAttributes *TinyHomeMessageAttributes) subscriptionWith(cfg PublisherConfig) (string, error) {
 	// Check to make sure all the values supplied are correct
+	if errs := messageAttributes.valueErrors(cfg.DeliverySources); len(errs) > 0 {
+		return "", errs[0]
+	}
+
+	subscriptionText, ok := messageAttributes.routeSubscription(cfg.SubscriptionRules)
+	if !ok {
+		if err := messageAttributes.staircaseError(); err != nil {
+			return "", err
+		}
+		return "", &RoutingError{Field: "attributes", Message: "message attributes not set for known subscription"}
+	}
+	return subscriptionText, nil
+}
+
+// valueErrors checks every attribute value Subscription routes on and returns a
+// RoutingError for each one that is not allowed. DeliveredFrom must be one of
+// deliverySources.
+func (messageAttributes *TinyHomeMessageAttributes) valueErrors(deliverySources []string) []ValidationError {
+	boolVals := []string{"true", "false"}
+
+	var errs []ValidationError
 	if !contains(boolVals, messageAttributes.GroupsCreated) {
-		return "", fmt.Errorf("message attribute GroupsCreated does not equal true or false")
+		errs = append(errs, &RoutingError{Field: AttrGroupsCreated, Message: "message attribute GroupsCreated does not equal true or false"})
 	}
 
 	if !contains(boolVals, messageAttributes.WorkspaceCreated) {
-		return "", fmt.Errorf("message attribute WorkspaceCreated does not equal true or false")
+		errs = append(errs, &RoutingError{Field: AttrWorkspaceCreated, Message: "message attribute WorkspaceCreated does not equal true or false"})
 	}
 
 	if !contains(boolVals, messageAttributes.TenantCreated) {
-		return "", fmt.Errorf("message attribute TenantCreated does not equal true or false")
+		errs = append(errs, &RoutingError{Field: AttrTenantCreated, Message: "message attribute TenantCreated does not equal true or false"})
 	}
 
 	if !contains(boolVals, messageAttributes.FluxCreated) {
-		return "", fmt.Errorf("message attribute FluxCreated does not equal true or false")
-	}
-
-	if !contains(deliveryVals, messageAttributes.DeliveredFrom) {
-		return "", fmt.Errorf("message attribute DeliveredFrom does not equal galaxy or manual")
-	}
-
-	var subscriptionText string
-	// Messages filtered to the createGroups Subscription
-	if messageAttributes.GroupsCreated == "false" && messageAttributes.WorkspaceCreated == "false" && messageAttributes.TenantCreated == "false" && messageAttributes.FluxCreated == "false" {
-		subscriptionText = "createGroups"
-	} else if messageAttributes.GroupsCreated == "true" && messageAttributes.WorkspaceCreated == "false" && messageAttributes.TenantCreated == "false" && messageAttributes.FluxCreated == "false" {
-		subscriptionText = "createWorkspace"
-	} else if messageAttributes.GroupsCreated == "true" && messageAttributes.WorkspaceCreated == "true" && messageAttributes.TenantCreated == "false" && messageAttributes.FluxCreated == "false" {
-		subscriptionText = "createTenant"
-	} else if messageAttributes.GroupsCreated == "true" && messageAttributes.WorkspaceCreated == "true" && messageAttributes.TenantCreated == "true" && messageAttributes.FluxCreated == "false" {
-		subscriptionText = "createFlux"
-	} else if messageAttributes.GroupsCreated == "true" && messageAttributes.WorkspaceCreated == "true" && messageAttributes.TenantCreated == "true" && messageAttributes.FluxCreated == "true" {
-		//Coming soon
-		subscriptionText = "deliverEmail"
-	} else {
-		return "", fmt.Errorf("message attributes not set for known subscription")
+		errs = append(errs, &RoutingError{Field: AttrFluxCreated, Message: "message attribute FluxCreated does not equal true or false"})
 	}
-	deliveryText := fmt.Sprintf("%s: %s", "message will be delivered to subscription", subscriptionText)
-	return deliveryText, nil
+
+	if !contains(deliverySources, messageAttributes.DeliveredFrom) {
+		errs = append(errs, &RoutingError{Field: AttrDeliveredFrom, Message: fmt.Sprintf("message attribute DeliveredFrom %q is not one of: %s", messageAttributes.DeliveredFrom, strings.Join(deliverySources, ", "))})
+	}
+	return errs
 }
 
 // If slice of array contains the string searched for
@@ -0,0 +1,516 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewPublisherEmulator(t *testing.T) {
+	tests := []struct {
+		name string
+		// connect points NewPublisher at the emulator at addr, with the environment
+		// or with client options, and returns the options
+		connect func(t *testing.T, addr string) []Option
+	}{
+		{
+			name: "PUBSUB_EMULATOR_HOST",
+			connect: func(t *testing.T, addr string) []Option {
+				t.Setenv("PUBSUB_EMULATOR_HOST", addr)
+				return nil
+			},
+		},
+		{
+			name: "client options",
+			connect: func(t *testing.T, addr string) []Option {
+				t.Setenv("PUBSUB_EMULATOR_HOST", "")
+				return []Option{WithClientOptions(emulatorOptions(addr)...)}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := pstest.NewServer()
+			t.Cleanup(func() { srv.Close() })
+			client, err := pubsub.NewClient(context.Background(), DefaultProjectID, emulatorOptions(srv.Addr)...)
+			if err != nil {
+				t.Fatalf("pubsub.NewClient: %v", err)
+			}
+			t.Cleanup(func() { client.Close() })
+			if _, err := client.CreateTopic(context.Background(), DefaultTopicID); err != nil {
+				t.Fatalf("CreateTopic: %v", err)
+			}
+
+			// No credentials are configured in either case
+			p, err := NewPublisher(context.Background(), DefaultPublisherConfig(), tt.connect(t, srv.Addr)...)
+			if err != nil {
+				t.Fatalf("NewPublisher: %v", err)
+			}
+			defer p.Close()
+
+			message := validInstructions()
+			id, err := p.PublishContext(context.Background(), &message, validAttributes())
+			if err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+
+			msg := srv.Message(id)
+			if msg == nil {
+				t.Fatalf("message %s not on the emulator", id)
+			}
+			if got := msg.Attributes[AttrTenantName]; got != message.TenantName {
+				t.Errorf("tenantName attribute = %q, want %q", got, message.TenantName)
+			}
+			if decoded, err := DecodeInstructions(msg.Data); err != nil || decoded.TenantName != message.TenantName {
+				t.Errorf("emulator body decodes to %+v, %v", decoded, err)
+			}
+		})
+	}
+}
+
+func TestPublishedAt(t *testing.T) {
+	pinned := time.Date(2024, 12, 20, 9, 30, 15, 0, time.UTC)
+	tests := []struct {
+		name  string
+		clock func() time.Time
+		// want is the publishedAt attribute, the result's PublishedAt formatted
+		want string
+	}{
+		{name: "pinned clock", clock: func() time.Time { return pinned }, want: "2024-12-20T09:30:15Z"},
+		{
+			name:  "converted to UTC",
+			clock: func() time.Time { return pinned.In(time.FixedZone("EST", -5*60*60)) },
+			want:  "2024-12-20T09:30:15Z",
+		},
+		{
+			name:  "sub-second precision dropped",
+			clock: func() time.Time { return pinned.Add(750 * time.Millisecond) },
+			want:  "2024-12-20T09:30:15Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithClock(tt.clock))
+			message := validInstructions()
+
+			result, err := p.PublishWithResult(context.Background(), &message, validAttributes())
+			if err != nil {
+				t.Fatalf("PublishWithResult: %v", err)
+			}
+			if got := topic.published()[0].Attributes["publishedAt"]; got != tt.want {
+				t.Errorf("publishedAt attribute = %q, want %q", got, tt.want)
+			}
+			if got := result.PublishedAt.Format(time.RFC3339); got != tt.want {
+				t.Errorf("PublishResult PublishedAt = %s, want %s", got, tt.want)
+			}
+			if result.PublishedAt.Location() != time.UTC {
+				t.Errorf("PublishResult PublishedAt location = %s, want UTC", result.PublishedAt.Location())
+			}
+		})
+	}
+}
+
+func TestPublishedAtDefaultClock(t *testing.T) {
+	topic := &fakeTopic{}
+	p := newTestPublisher(t, topic, nil)
+	message := validInstructions()
+
+	before := time.Now().Truncate(time.Second)
+	result, err := p.PublishWithResult(context.Background(), &message, validAttributes())
+	if err != nil {
+		t.Fatalf("PublishWithResult: %v", err)
+	}
+	after := time.Now()
+
+	got, err := time.Parse(time.RFC3339, topic.published()[0].Attributes["publishedAt"])
+	if err != nil {
+		t.Fatalf("publishedAt attribute is not RFC3339: %v", err)
+	}
+	if got.Before(before) || got.After(after) {
+		t.Errorf("publishedAt attribute = %s, want between %s and %s", got, before, after)
+	}
+	if result.PublishedAt.Before(before) || result.PublishedAt.After(after) {
+		t.Errorf("PublishResult PublishedAt = %s, want between %s and %s", result.PublishedAt, before, after)
+	}
+}
+
+// TestPublishConcurrent shares one Publisher between goroutines, run it with -race
+func TestPublishConcurrent(t *testing.T) {
+	const goroutines = 50
+
+	tests := []struct {
+		name string
+		opts []Option
+		// invalid is every how many publishes is given invalid instructions, 0 for none
+		invalid int
+		async   bool
+	}{
+		{name: "PublishContext"},
+		{name: "PublishAsync", async: true},
+		{name: "limited slots", opts: []Option{WithMaxConcurrentPublishes(4)}},
+		{name: "with validation failures", invalid: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			var log bytes.Buffer
+			p := newTestPublisher(t, topic, nil, append(tt.opts, WithPublishLog(&log))...)
+
+			ids := make([]string, goroutines)
+			errs := make([]error, goroutines)
+			var wg sync.WaitGroup
+			for i := 0; i < goroutines; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					// Each goroutine publishes its own instructions, as they are
+					// updated in place
+					message := validInstructions()
+					if tt.invalid > 0 && i%tt.invalid == 0 {
+						message.TenantName = "Not Valid"
+					}
+					if !tt.async {
+						ids[i], errs[i] = p.PublishContext(context.Background(), &message, validAttributes())
+						return
+					}
+					pending, err := p.PublishAsync(context.Background(), &message, validAttributes())
+					if err != nil {
+						errs[i] = err
+						return
+					}
+					result, err := pending.Get(context.Background())
+					if err != nil {
+						errs[i] = err
+						return
+					}
+					ids[i] = result.MessageID
+				}(i)
+			}
+			wg.Wait()
+			if err := p.Flush(context.Background()); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+
+			wantInvalid := 0
+			if tt.invalid > 0 {
+				wantInvalid = (goroutines + tt.invalid - 1) / tt.invalid
+			}
+			seen := map[string]bool{}
+			for i := 0; i < goroutines; i++ {
+				if tt.invalid > 0 && i%tt.invalid == 0 {
+					wantFieldError(t, errs[i], "tenantName")
+					continue
+				}
+				if errs[i] != nil {
+					t.Errorf("publish %d: %v", i, errs[i])
+					continue
+				}
+				if seen[ids[i]] {
+					t.Errorf("message ID %q returned more than once", ids[i])
+				}
+				seen[ids[i]] = true
+			}
+
+			wantPublished := goroutines - wantInvalid
+			if got := len(topic.published()); got != wantPublished {
+				t.Errorf("published %d messages, want %d", got, wantPublished)
+			}
+			stats := p.Stats()
+			if stats.Published != uint64(wantPublished) || stats.ValidationFailures != uint64(wantInvalid) || stats.TransportFailures != 0 {
+				t.Errorf("Stats = %+v, want %d published and %d validation failures", stats, wantPublished, wantInvalid)
+			}
+			if got := stats.BySubscription["createGroups"]; got != uint64(wantPublished) {
+				t.Errorf("createGroups count = %d, want %d", got, wantPublished)
+			}
+			if got := strings.Count(log.String(), "\n"); got != goroutines {
+				t.Errorf("publish log has %d lines, want %d", got, goroutines)
+			}
+		})
+	}
+}
+
+func TestValidateTenantName(t *testing.T) {
+	tests := []struct {
+		name       string
+		tenantName string
+		opts       []Option
+		// wantMessage is the rule reported, empty when the tenant name is valid
+		wantMessage string
+	}{
+		{name: "leading letter", tenantName: "team"},
+		{name: "minimum length", tenantName: "abc"},
+		{name: "under the minimum length", tenantName: "ab", wantMessage: "tenantName less than 3 characters"},
+		{name: "single character", tenantName: "a", wantMessage: "tenantName less than 3 characters"},
+		{name: "empty", tenantName: "", wantMessage: "tenantName can not be empty"},
+		{name: "configured minimum length", tenantName: "abcde", opts: []Option{WithMinTenantNameLength(5)}},
+		{name: "under the configured minimum length", tenantName: "abcd", opts: []Option{WithMinTenantNameLength(5)}, wantMessage: "tenantName less than 5 characters"},
+		{name: "lowered minimum length", tenantName: "a", opts: []Option{WithMinTenantNameLength(1)}},
+		{name: "maximum length", tenantName: strings.Repeat("a", DefaultMaxTenantNameLength)},
+		{name: "over the maximum length", tenantName: strings.Repeat("a", DefaultMaxTenantNameLength+1), wantMessage: "tenantName greater than 20 characters"},
+		{name: "digits after the first letter", tenantName: "team2"},
+		{name: "hyphen and digits inside", tenantName: "t-2b"},
+		{name: "leading digit", tenantName: "2team", wantMessage: `tenantName must start with a lower case letter, got "2"`},
+		{name: "all digits", tenantName: "1234", wantMessage: `tenantName must start with a lower case letter, got "1"`},
+		{name: "leading hyphen", tenantName: "-team", wantMessage: "tenantName can not start or end with '-'"},
+		{name: "trailing hyphen", tenantName: "team-", wantMessage: "tenantName can not start or end with '-'"},
+		{name: "hyphens at both ends", tenantName: "-team-", wantMessage: "tenantName can not start or end with '-'"},
+		{name: "only hyphens", tenantName: "---", wantMessage: "tenantName can not start or end with '-'"},
+		{name: "trailing hyphen at the minimum length", tenantName: "ab-", wantMessage: "tenantName can not start or end with '-'"},
+		{name: "consecutive hyphens inside", tenantName: "team--a"},
+		{name: "leading upper case letter", tenantName: "Team", wantMessage: "tenantName supports only lower case characters"},
+		{name: "underscore", tenantName: "team_a", wantMessage: "unsuported special characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.TenantName = tt.tenantName
+			_, err := message.DryRun(validAttributes(), tt.opts...)
+			if tt.wantMessage == "" {
+				wantFieldError(t, err, "")
+				return
+			}
+			wantFieldError(t, err, "tenantName")
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestNewPublisherAdditionalAttributes(t *testing.T) {
+	tests := []struct {
+		name       string
+		additional map[string]string
+		// wantKeys are the colliding keys named in the error, empty when construction
+		// succeeds
+		wantKeys string
+	}{
+		{name: "custom key", additional: map[string]string{"team": "payments"}},
+		{name: "routing key", additional: map[string]string{AttrDeliveredFrom: "manual"}, wantKeys: "deliveredFrom"},
+		{name: "feature key", additional: map[string]string{"contentEncoding": "gzip", "team": "payments"}, wantKeys: "contentEncoding"},
+		{name: "several keys", additional: map[string]string{"publishedAt": "x", "correlationId": "x"}, wantKeys: "correlationId, publishedAt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, connect := newEmulator(t, DefaultTopicID)
+			p, err := NewPublisher(context.Background(), DefaultPublisherConfig(), connect, WithAdditionalAttributes(tt.additional))
+			if tt.wantKeys == "" {
+				if err != nil {
+					t.Fatalf("NewPublisher: %v", err)
+				}
+				p.Close()
+				return
+			}
+			if err == nil {
+				p.Close()
+				t.Fatal("NewPublisher accepted reserved additional attributes")
+			}
+
+			// The error names the collisions and lists every reserved key
+			if want := "reserved for the publisher: " + tt.wantKeys + " ("; !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not name %s", err, tt.wantKeys)
+			}
+			for _, key := range PublisherAttributeKeys() {
+				if !strings.Contains(err.Error(), key) {
+					t.Errorf("error %q does not list reserved key %s", err, key)
+				}
+			}
+			if !strings.Contains(err.Error(), `any starting with "label."`) {
+				t.Errorf("error %q does not mention the label prefix", err)
+			}
+		})
+	}
+}
+
+func TestRetryAttempt(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		// want is the retryAttempt attribute of each attempt, "" when it is unset
+		want []string
+	}{
+		{name: "first attempt", failures: 0, want: []string{""}},
+		{name: "one retry", failures: 1, want: []string{"", "1"}},
+		{name: "two retries", failures: 2, want: []string{"", "1", "2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+				if n <= tt.failures {
+					return fakeResult{err: status.Error(codes.Unavailable, "try again")}
+				}
+				return fakeResult{id: strconv.Itoa(n)}
+			}}
+			p := newTestPublisher(t, topic, nil, WithRetry(3, time.Millisecond))
+			message := validInstructions()
+
+			result, err := p.PublishWithResult(context.Background(), &message, validAttributes())
+			if err != nil {
+				t.Fatalf("PublishWithResult: %v", err)
+			}
+
+			published := topic.published()
+			if len(published) != len(tt.want) {
+				t.Fatalf("%d attempts, want %d", len(published), len(tt.want))
+			}
+			for i, msg := range published {
+				got, ok := msg.Attributes["retryAttempt"]
+				if got != tt.want[i] || ok != (tt.want[i] != "") {
+					t.Errorf("attempt %d retryAttempt = %q (set %v), want %q", i+1, got, ok, tt.want[i])
+				}
+				// Retries resend the same message with only the attribute added
+				if !bytes.Equal(msg.Data, published[0].Data) || len(msg.Attributes) != len(published[0].Attributes)+min(i, 1) {
+					t.Errorf("attempt %d differs from the first beyond retryAttempt", i+1)
+				}
+			}
+			if _, ok := result.Attributes["retryAttempt"]; ok {
+				t.Errorf("PublishResult attributes include retryAttempt, want the attributes as prepared")
+			}
+		})
+	}
+}
+
+// failFirstPublish is a pstest reactor failing the first Publish RPC with one of the
+// few transient errors the Pub/Sub client does not retry itself, so the failure
+// reaches the Publisher's own retries
+type failFirstPublish struct {
+	mu     sync.Mutex
+	failed bool
+}
+
+func (r *failFirstPublish) React(_ interface{}) (bool, interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failed {
+		return false, nil, nil
+	}
+	r.failed = true
+	return true, nil, status.Error(codes.Internal, "string field contains invalid UTF-8")
+}
+
+func TestRetryAttemptEmulator(t *testing.T) {
+	srv := pstest.NewServer(pstest.ServerReactorOption{FuncName: "Publish", Reactor: &failFirstPublish{}})
+	t.Cleanup(func() { srv.Close() })
+	client, err := pubsub.NewClient(context.Background(), DefaultProjectID, emulatorOptions(srv.Addr)...)
+	if err != nil {
+		t.Fatalf("pubsub.NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if _, err := client.CreateTopic(context.Background(), DefaultTopicID); err != nil {
+		t.Fatalf("CreateTopic: %v", err)
+	}
+
+	p, err := NewPublisher(context.Background(), DefaultPublisherConfig(), WithClientOptions(emulatorOptions(srv.Addr)...), WithRetry(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewPublisher: %v", err)
+	}
+	defer p.Close()
+
+	message := validInstructions()
+	id, err := p.PublishContext(context.Background(), &message, validAttributes())
+	if err != nil {
+		t.Fatalf("PublishContext: %v", err)
+	}
+
+	// Only the retry reached the server
+	if got := len(srv.Messages()); got != 1 {
+		t.Fatalf("%d messages on the emulator, want 1", got)
+	}
+	msg := srv.Message(id)
+	if msg == nil {
+		t.Fatalf("message %s not on the emulator", id)
+	}
+	if got := msg.Attributes["retryAttempt"]; got != "1" {
+		t.Errorf("retryAttempt attribute = %q, want \"1\"", got)
+	}
+}
+
+func TestPublishTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		// deadline is the caller's deadline, some seconds when zero so a missing
+		// timeout fails the test rather than hanging it
+		deadline time.Duration
+		publish  func(ctx context.Context, p *Publisher) error
+		// want is how soon the deadline is hit
+		want time.Duration
+	}{
+		{
+			name:    "PublishContext",
+			timeout: 50 * time.Millisecond,
+			publish: func(ctx context.Context, p *Publisher) error {
+				message := validInstructions()
+				_, err := p.PublishContext(ctx, &message, validAttributes())
+				return err
+			},
+			want: 50 * time.Millisecond,
+		},
+		{
+			name:    "PublishHeartbeat",
+			timeout: 50 * time.Millisecond,
+			publish: func(ctx context.Context, p *Publisher) error {
+				_, err := p.PublishHeartbeat(ctx)
+				return err
+			},
+			want: 50 * time.Millisecond,
+		},
+		{
+			name:     "shorter caller deadline wins",
+			timeout:  5 * time.Second,
+			deadline: 50 * time.Millisecond,
+			publish: func(ctx context.Context, p *Publisher) error {
+				message := validInstructions()
+				_, err := p.PublishContext(ctx, &message, validAttributes())
+				return err
+			},
+			want: 50 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The topic never returns a result, only a deadline ends the publish
+			never := make(chan struct{})
+			topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult { return fakeResult{ready: never} }}
+			p := newTestPublisher(t, topic, nil, WithTimeout(tt.timeout))
+
+			deadline := tt.deadline
+			if deadline == 0 {
+				deadline = 5 * time.Second
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), deadline)
+			defer cancel()
+
+			start := time.Now()
+			err := tt.publish(ctx, p)
+			elapsed := time.Since(start)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("publish error = %v, want context.DeadlineExceeded", err)
+			}
+			if elapsed < tt.want || elapsed > tt.want+time.Second {
+				t.Errorf("publish gave up after %v, want about %v", elapsed, tt.want)
+			}
+			if got := len(topic.published()); got != 1 {
+				t.Errorf("published %d messages, want 1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Package publishertest provides an in-memory tinyhomecommunity.InstructionPublisher
+// for testing code that publishes instructions, without a Pub/Sub client or GCP.
+package publishertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/tdigangi/publisher/pkg/tinyhomecommunity"
+)
+
+// Message is a message recorded by Publisher
+type Message struct {
+	// Instructions are the published instructions, with any generated TenantName and
+	// default Region filled in
+	Instructions tinyhomecommunity.TinyHomeInstructions
+	// Data is the message body exactly as a Publisher with the same options sends it,
+	// compacted, wrapped in a CloudEvents envelope or compressed as configured
+	Data []byte
+	// Attributes are the Pub/Sub attributes a Publisher with the same options sets
+	Attributes map[string]string
+	// Subscription is the subscription the attributes route to
+	Subscription string
+	// MessageID is the fake server ID, "1" for the first message and so on
+	MessageID string
+}
+
+// Publisher is a fake tinyhomecommunity.InstructionPublisher. Every publish runs the
+// same validation as a tinyhomecommunity.Publisher built with the same options, see
+// DryRun, and records valid messages instead of sending them. It is safe for
+// concurrent use.
+type Publisher struct {
+	opts []tinyhomecommunity.Option
+
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+var _ tinyhomecommunity.InstructionPublisher = (*Publisher)(nil)
+
+// NewPublisher returns a fake Publisher validating with opts
+func NewPublisher(opts ...tinyhomecommunity.Option) *Publisher {
+	return &Publisher{opts: opts}
+}
+
+// Publish is PublishContext with context.Background
+func (p *Publisher) Publish(message *tinyhomecommunity.TinyHomeInstructions, messageAttributes *tinyhomecommunity.TinyHomeMessageAttributes) (string, error) {
+	return p.PublishContext(context.Background(), message, messageAttributes)
+}
+
+// PublishContext validates and records the message, returning its fake message ID
+func (p *Publisher) PublishContext(ctx context.Context, message *tinyhomecommunity.TinyHomeInstructions, messageAttributes *tinyhomecommunity.TinyHomeMessageAttributes) (string, error) {
+	result, err := p.PublishWithResult(ctx, message, messageAttributes)
+	if err != nil {
+		return "", err
+	}
+	return result.MessageID, nil
+}
+
+// PublishWithResult validates and records the message. Invalid messages return the
+// validation error, valid ones the error set with SetPublishError, if any, and
+// neither is recorded. Like a real publish, see DryRun, it fills in a generated
+// TenantName and the default Region on message, so tests may see them there too.
+func (p *Publisher) PublishWithResult(ctx context.Context, message *tinyhomecommunity.TinyHomeInstructions, messageAttributes *tinyhomecommunity.TinyHomeMessageAttributes) (*tinyhomecommunity.PublishResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("Publish: %w", err)
+	}
+
+	result, data, err := message.DryRunData(messageAttributes, p.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("Publish: %w", err)
+	}
+
+	// Round trip the instructions so later changes by the caller are not recorded
+	marshaled, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("Publish: marshal: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return nil, fmt.Errorf("Publish: %w", p.err)
+	}
+
+	var instructions tinyhomecommunity.TinyHomeInstructions
+	if err := json.Unmarshal(marshaled, &instructions); err != nil {
+		return nil, fmt.Errorf("Publish: unmarshal: %v", err)
+	}
+
+	result.MessageID = strconv.Itoa(len(p.messages) + 1)
+	p.messages = append(p.messages, Message{
+		Instructions: instructions,
+		Data:         data,
+		Attributes:   result.Attributes,
+		Subscription: result.Subscription,
+		MessageID:    result.MessageID,
+	})
+	return result, nil
+}
+
+// SetPublishError makes every later publish of a valid message fail with err, as a
+// Pub/Sub failure would. A nil err restores successful publishes.
+func (p *Publisher) SetPublishError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+// Messages returns every recorded message in publish order
+func (p *Publisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.messages...)
+}
+
+// LastMessage returns the most recently recorded message, false when there is none
+func (p *Publisher) LastMessage() (Message, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.messages) == 0 {
+		return Message{}, false
+	}
+	return p.messages[len(p.messages)-1], true
+}
+
+// Reset forgets every recorded message and any error set with SetPublishError
+func (p *Publisher) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = nil
+	p.err = nil
+}
@@ -0,0 +1,200 @@
+package publishertest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/tdigangi/publisher/pkg/tinyhomecommunity"
+)
+
+func sampleInstructions() tinyhomecommunity.TinyHomeInstructions {
+	var message tinyhomecommunity.TinyHomeInstructions
+	message.TenantName = "fake-tenant"
+	message.Environment = "dev"
+	message.BusinessUnit = "platform"
+	message.TenantOwner = "owner@example.com"
+	message.TenantOwnerSecondary = "backup@example.com"
+	message.TenantCostCenter = "1234"
+	message.Domain = "example.com"
+	message.Organization = "123456789012"
+	message.Region = "us-central1"
+	message.NsQuota.Requests.Cpu = "1"
+	message.NsQuota.Requests.Memory = "1Gi"
+	message.NsQuota.Limits.Cpu = "2"
+	message.NsQuota.Limits.Memory = "2Gi"
+	return message
+}
+
+func sampleAttributes() *tinyhomecommunity.TinyHomeMessageAttributes {
+	return &tinyhomecommunity.TinyHomeMessageAttributes{
+		GroupsCreated:    "false",
+		WorkspaceCreated: "false",
+		TenantCreated:    "false",
+		FluxCreated:      "false",
+		DeliveredFrom:    "galaxy",
+	}
+}
+
+func TestPublisherRecordsWireFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []tinyhomecommunity.Option
+		// decode returns the instructions JSON from a recorded message
+		decode func(t *testing.T, m Message) []byte
+	}{
+		{
+			name:   "plain json",
+			decode: func(t *testing.T, m Message) []byte { return m.Data },
+		},
+		{
+			name: "gzip",
+			opts: []tinyhomecommunity.Option{tinyhomecommunity.WithCompression(true)},
+			decode: func(t *testing.T, m Message) []byte {
+				if m.Attributes["contentEncoding"] != "gzip" {
+					t.Fatalf("contentEncoding = %q, want gzip", m.Attributes["contentEncoding"])
+				}
+				body, err := tinyhomecommunity.DecompressBody(m.Data, m.Attributes)
+				if err != nil {
+					t.Fatalf("DecompressBody: %v", err)
+				}
+				return body
+			},
+		},
+		{
+			name: "cloudevents",
+			opts: []tinyhomecommunity.Option{tinyhomecommunity.WithCloudEvents("//publisher.test")},
+			decode: func(t *testing.T, m Message) []byte {
+				if m.Attributes["content-type"] != "application/cloudevents+json" {
+					t.Fatalf("content-type = %q, want application/cloudevents+json", m.Attributes["content-type"])
+				}
+				var envelope struct {
+					Source string          `json:"source"`
+					Data   json.RawMessage `json:"data"`
+				}
+				if err := json.Unmarshal(m.Data, &envelope); err != nil {
+					t.Fatalf("unmarshal envelope: %v", err)
+				}
+				if envelope.Source != "//publisher.test" {
+					t.Errorf("source = %q, want //publisher.test", envelope.Source)
+				}
+				return envelope.Data
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPublisher(tt.opts...)
+			message := sampleInstructions()
+			id, err := p.Publish(&message, sampleAttributes())
+			if err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+
+			m, ok := p.LastMessage()
+			if !ok {
+				t.Fatal("no message recorded")
+			}
+			if m.MessageID != id || id != "1" {
+				t.Errorf("MessageID = %q, returned %q, want 1", m.MessageID, id)
+			}
+			if m.Subscription != "createGroups" {
+				t.Errorf("Subscription = %q, want createGroups", m.Subscription)
+			}
+
+			decoded, err := tinyhomecommunity.DecodeInstructions(tt.decode(t, m))
+			if err != nil {
+				t.Fatalf("DecodeInstructions: %v", err)
+			}
+			if decoded.TenantName != message.TenantName {
+				t.Errorf("decoded TenantName = %q, want %q", decoded.TenantName, message.TenantName)
+			}
+		})
+	}
+}
+
+func TestPublisherErrors(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	tests := []struct {
+		name       string
+		mutate     func(m *tinyhomecommunity.TinyHomeInstructions)
+		publishErr error
+		wantErr    error
+	}{
+		{
+			name:    "invalid instructions",
+			mutate:  func(m *tinyhomecommunity.TinyHomeInstructions) { m.TenantOwner = "" },
+			wantErr: tinyhomecommunity.ErrInvalidInstructions,
+		},
+		{
+			name:       "publish error",
+			mutate:     func(m *tinyhomecommunity.TinyHomeInstructions) {},
+			publishErr: errPubSub,
+			wantErr:    errPubSub,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPublisher()
+			p.SetPublishError(tt.publishErr)
+			message := sampleInstructions()
+			tt.mutate(&message)
+
+			if _, err := p.Publish(&message, sampleAttributes()); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Publish error = %v, want %v", err, tt.wantErr)
+			}
+			if got := p.Messages(); len(got) != 0 {
+				t.Errorf("recorded %d messages, want none", len(got))
+			}
+		})
+	}
+}
+
+func TestPublisherFillsDefaults(t *testing.T) {
+	p := NewPublisher(
+		tinyhomecommunity.WithRegionDefaults(map[string]string{"dev": "us-east1"}),
+		tinyhomecommunity.WithNameGenerator(tinyhomecommunity.NameGeneratorFunc(func(tinyhomecommunity.TinyHomeInstructions) (string, error) {
+			return "generated-tenant", nil
+		})),
+	)
+	message := sampleInstructions()
+	message.TenantName = ""
+	message.Region = ""
+
+	if _, err := p.Publish(&message, sampleAttributes()); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	// Like DryRun the caller's instructions are filled in, as is the recorded copy
+	m, _ := p.LastMessage()
+	for _, got := range []tinyhomecommunity.TinyHomeInstructions{message, m.Instructions} {
+		if got.TenantName != "generated-tenant" || got.Region != "us-east1" {
+			t.Errorf("TenantName, Region = %q, %q, want generated-tenant, us-east1", got.TenantName, got.Region)
+		}
+	}
+
+	// Later changes by the caller are not recorded
+	message.TenantName = "changed"
+	if m, _ := p.LastMessage(); m.Instructions.TenantName != "generated-tenant" {
+		t.Errorf("recorded TenantName = %q after the caller changed it", m.Instructions.TenantName)
+	}
+
+	p.Reset()
+	if _, ok := p.LastMessage(); ok {
+		t.Error("LastMessage after Reset found a message")
+	}
+}
+
+func TestPublisherContextDone(t *testing.T) {
+	p := NewPublisher()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	message := sampleInstructions()
+	if _, err := p.PublishContext(ctx, &message, sampleAttributes()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("PublishContext error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,78 @@
+package tinyhomecommunity
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Outcomes reported in PublishLogLine.Outcome
+const (
+	OutcomePublished = "published"
+	OutcomeInvalid   = "invalid"
+	OutcomeFailed    = "failed"
+)
+
+// PublishLogLine is the JSON object written to PublisherConfig.PublishLog once per
+// publish, for log-based metrics. Fields are only ever added, never renamed or
+// removed.
+//
+//	tenant      TenantName, including a generated one
+//	stage       subscription the attributes route to, empty when routing failed
+//	topic       Pub/Sub topic id
+//	messageId   server message ID, empty unless outcome is published
+//	sizeBytes   size of the marshaled instructions
+//	durationMs  milliseconds from the start of validation to the publish result
+//	outcome     published, invalid for validation failures or failed otherwise
+//	error       error text, omitted when published
+type PublishLogLine struct {
+	Tenant     string  `json:"tenant"`
+	Stage      string  `json:"stage"`
+	Topic      string  `json:"topic"`
+	MessageID  string  `json:"messageId"`
+	SizeBytes  int     `json:"sizeBytes"`
+	DurationMs float64 `json:"durationMs"`
+	Outcome    string  `json:"outcome"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// publishOutcome returns the outcome of a publish that returned err
+func publishOutcome(err error) string {
+	var validationErr ValidationError
+	switch {
+	case err == nil:
+		return OutcomePublished
+	case errors.As(err, &validationErr):
+		return OutcomeInvalid
+	default:
+		return OutcomeFailed
+	}
+}
+
+// publishLogMu serializes writes so concurrent publishes never interleave lines
+var publishLogMu sync.Mutex
+
+// writePublishLog completes line from the publish result and writes it to w as a
+// single line of JSON. Nothing is written when w is nil.
+func writePublishLog(w io.Writer, line PublishLogLine, start time.Time, err error) {
+	if w == nil {
+		return
+	}
+
+	line.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	line.Outcome = publishOutcome(err)
+	if err != nil {
+		line.Error = err.Error()
+	}
+
+	b, mErr := json.Marshal(line)
+	if mErr != nil {
+		return
+	}
+
+	publishLogMu.Lock()
+	defer publishLogMu.Unlock()
+	w.Write(append(b, '\n'))
+}
@@ -0,0 +1,109 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestPublishLog(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	tests := []struct {
+		name   string
+		mutate func(m *TinyHomeInstructions)
+		result func(n int, msg *pubsub.Message) publishResult
+		// want are the fields of the line other than durationMs and sizeBytes, with
+		// error only when the publish fails
+		want map[string]interface{}
+		// wantSize is whether sizeBytes is the size of the published body
+		wantSize bool
+	}{
+		{
+			name: "published",
+			want: map[string]interface{}{
+				"tenant": "contract-tenant", "stage": "createGroups", "topic": DefaultTopicID,
+				"messageId": "1", "outcome": OutcomePublished,
+			},
+			wantSize: true,
+		},
+		{
+			name:   "invalid",
+			mutate: func(m *TinyHomeInstructions) { m.TenantName = "Not Valid" },
+			want: map[string]interface{}{
+				"tenant": "Not Valid", "stage": "", "topic": DefaultTopicID,
+				"messageId": "", "outcome": OutcomeInvalid, "error": "tenantName supports only lower case characters",
+			},
+		},
+		{
+			name:   "failed",
+			result: func(int, *pubsub.Message) publishResult { return fakeResult{err: errPubSub} },
+			want: map[string]interface{}{
+				"tenant": "contract-tenant", "stage": "createGroups", "topic": DefaultTopicID,
+				"messageId": "", "outcome": OutcomeFailed, "error": "publish: pubsub unavailable",
+			},
+			wantSize: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			topic := &fakeTopic{result: tt.result}
+			p := newTestPublisher(t, topic, nil, WithPublishLog(&buf))
+			message := validInstructions()
+			if tt.mutate != nil {
+				tt.mutate(&message)
+			}
+			_, publishErr := p.PublishContext(context.Background(), &message, validAttributes())
+			_, wantErr := tt.want["error"]
+			if (publishErr != nil) != wantErr {
+				t.Fatalf("PublishContext error = %v, want error %v", publishErr, wantErr)
+			}
+
+			// Exactly one line of JSON per publish
+			lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n"))
+			if len(lines) != 1 {
+				t.Fatalf("publish log has %d lines, want 1: %s", len(lines), buf.String())
+			}
+			var line map[string]interface{}
+			if err := json.Unmarshal(lines[0], &line); err != nil {
+				t.Fatalf("publish log line %s is not JSON: %v", lines[0], err)
+			}
+
+			// The documented fields, with error only on failure
+			wantKeys := []string{"durationMs", "messageId", "outcome", "sizeBytes", "stage", "tenant", "topic"}
+			if wantErr {
+				wantKeys = append(wantKeys, "error")
+				slices.Sort(wantKeys)
+			}
+			var keys []string
+			for key := range line {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+			if !slices.Equal(keys, wantKeys) {
+				t.Errorf("publish log fields %v, want %v", keys, wantKeys)
+			}
+
+			for key, want := range tt.want {
+				if line[key] != want {
+					t.Errorf("%s = %v, want %v", key, line[key], want)
+				}
+			}
+			if duration, ok := line["durationMs"].(float64); !ok || duration < 0 {
+				t.Errorf("durationMs = %v, want a non-negative number", line["durationMs"])
+			}
+			size, _ := line["sizeBytes"].(float64)
+			if published := topic.published(); tt.wantSize && int(size) != len(published[0].Data) {
+				t.Errorf("sizeBytes = %v, want %d", size, len(published[0].Data))
+			} else if !tt.wantSize && size != 0 {
+				t.Errorf("sizeBytes = %v for a message that was not marshaled", size)
+			}
+		})
+	}
+}
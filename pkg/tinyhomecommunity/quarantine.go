@@ -0,0 +1,55 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// quarantine publishes instructions that failed validation with verr to the
+// QuarantineTopicID topic, as they were when validation failed, with the failure in
+// the validationError attribute. Only ValidationError failures are quarantined and a
+// failure to quarantine is logged, the caller still returns verr.
+func (p *Publisher) quarantine(ctx context.Context, message *TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes, verr error) {
+	var validationErr ValidationError
+	if p.cfg.QuarantineTopicID == "" || !errors.As(verr, &validationErr) {
+		return
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		p.logger().WarnContext(ctx, "invalid message not quarantined", "tenantName", message.TenantName, "error", err)
+		return
+	}
+
+	attributes := map[string]string{
+		"validationError": verr.Error(),
+		"publishedAt":     p.cfg.now().UTC().Format(time.RFC3339),
+	}
+	if len(attributes["validationError"]) > maxAttributeValueBytes {
+		attributes["validationError"] = truncateAttributeValue(attributes["validationError"])
+	}
+
+	// The routing attributes as supplied, which may be what failed validation
+	for key, value := range map[string]string{
+		AttrGroupsCreated:    messageAttributes.GroupsCreated,
+		AttrWorkspaceCreated: messageAttributes.WorkspaceCreated,
+		AttrTenantCreated:    messageAttributes.TenantCreated,
+		AttrFluxCreated:      messageAttributes.FluxCreated,
+		AttrDeliveredFrom:    messageAttributes.DeliveredFrom,
+		AttrTenantName:       message.TenantName,
+	} {
+		if value != "" {
+			attributes[key] = value
+		}
+	}
+
+	id := p.cfg.QuarantineTopicID
+	messageID, err := p.sendTo(ctx, id, p.topicHandle(id), data, attributes)
+	if err != nil {
+		p.logger().WarnContext(ctx, "invalid message not quarantined", "tenantName", message.TenantName, "error", err)
+		return
+	}
+	p.logger().InfoContext(ctx, "quarantined invalid message", "tenantName", message.TenantName, "messageId", messageID, "validationError", attributes["validationError"])
+}
@@ -0,0 +1,148 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestQuarantine(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	tests := []struct {
+		name       string
+		opts       []Option
+		modify     func(*TinyHomeInstructions)
+		attributes *TinyHomeMessageAttributes
+		// topicErr fails publishes to the main topic
+		topicErr error
+		// quarantineErr fails publishes to the quarantine topic
+		quarantineErr  error
+		wantQuarantine bool
+		wantLog        string
+	}{
+		{
+			name:           "invalid instructions",
+			opts:           []Option{WithQuarantineTopic("quarantine")},
+			modify:         func(m *TinyHomeInstructions) { m.TenantName = "Not Valid" },
+			wantQuarantine: true,
+		},
+		{
+			name:           "invalid attributes",
+			opts:           []Option{WithQuarantineTopic("quarantine")},
+			attributes:     &TinyHomeMessageAttributes{GroupsCreated: "yes", WorkspaceCreated: "false", TenantCreated: "false", FluxCreated: "false", DeliveredFrom: "galaxy"},
+			wantQuarantine: true,
+		},
+		{
+			name:   "no quarantine topic",
+			modify: func(m *TinyHomeInstructions) { m.TenantName = "Not Valid" },
+		},
+		{
+			name:     "failed publish of valid instructions",
+			opts:     []Option{WithQuarantineTopic("quarantine")},
+			topicErr: errPubSub,
+		},
+		{
+			name:          "quarantine publish fails",
+			opts:          []Option{WithQuarantineTopic("quarantine")},
+			modify:        func(m *TinyHomeInstructions) { m.TenantName = "Not Valid" },
+			quarantineErr: errPubSub,
+			wantLog:       "invalid message not quarantined",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+			topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+				return fakeResult{id: "1", err: tt.topicErr}
+			}}
+			quarantine := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+				return fakeResult{id: "q1", err: tt.quarantineErr}
+			}}
+			var logs bytes.Buffer
+			opts := append([]Option{WithClock(func() time.Time { return now }), WithLogger(slog.New(slog.NewTextHandler(&logs, nil)))}, tt.opts...)
+			p := newTestPublisher(t, topic, map[string]*fakeTopic{"quarantine": quarantine}, opts...)
+
+			message := validInstructions()
+			if tt.modify != nil {
+				tt.modify(&message)
+			}
+			attributes := validAttributes()
+			if tt.attributes != nil {
+				attributes = tt.attributes
+			}
+			supplied := message
+
+			// The publish fails whether or not the message is quarantined
+			_, err := p.PublishContext(context.Background(), &message, attributes)
+			if err == nil {
+				t.Fatal("PublishContext returned no error")
+			}
+			if tt.topicErr == nil && len(topic.published()) != 0 {
+				t.Errorf("published %d invalid messages to the main topic", len(topic.published()))
+			}
+			if tt.wantLog != "" && !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("logs = %q, want them to contain %q", logs.String(), tt.wantLog)
+			}
+
+			quarantined := quarantine.published()
+			if !tt.wantQuarantine {
+				if tt.quarantineErr == nil && len(quarantined) != 0 {
+					t.Errorf("quarantined %d messages, want none", len(quarantined))
+				}
+				return
+			}
+			if len(quarantined) != 1 {
+				t.Fatalf("quarantined %d messages, want 1", len(quarantined))
+			}
+			msg := quarantined[0]
+
+			// The payload as supplied and the failure are recorded
+			var body TinyHomeInstructions
+			if err := json.Unmarshal(msg.Data, &body); err != nil {
+				t.Fatalf("quarantined body %s: %v", msg.Data, err)
+			}
+			if !reflect.DeepEqual(body, supplied) {
+				t.Errorf("quarantined body = %+v, want the instructions supplied %+v", body, supplied)
+			}
+			validationError := msg.Attributes["validationError"]
+			if validationError == "" || !strings.Contains(err.Error(), validationError) {
+				t.Errorf("validationError attribute = %q, want the failure of %v", validationError, err)
+			}
+			if got := msg.Attributes["publishedAt"]; got != "2024-03-01T12:00:00Z" {
+				t.Errorf("publishedAt attribute = %q, want the clock's time", got)
+			}
+			for key, want := range map[string]string{
+				AttrGroupsCreated:    attributes.GroupsCreated,
+				AttrWorkspaceCreated: attributes.WorkspaceCreated,
+				AttrDeliveredFrom:    attributes.DeliveredFrom,
+				AttrTenantName:       supplied.TenantName,
+			} {
+				if got := msg.Attributes[key]; got != want {
+					t.Errorf("%s attribute = %q, want %q as supplied", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestQuarantineLongError(t *testing.T) {
+	quarantine := &fakeTopic{}
+	p := newTestPublisher(t, &fakeTopic{}, map[string]*fakeTopic{"quarantine": quarantine}, WithQuarantineTopic("quarantine"))
+
+	message := validInstructions()
+	p.quarantine(context.Background(), &message, validAttributes(), &NamingError{Field: "tenantName", Message: strings.Repeat("x", 2*maxAttributeValueBytes)})
+
+	got := quarantine.published()[0].Attributes["validationError"]
+	if len(got) != maxAttributeValueBytes || !strings.HasSuffix(got, truncatedMarker) {
+		t.Errorf("validationError attribute is %d bytes, want it truncated to %d", len(got), maxAttributeValueBytes)
+	}
+}
@@ -0,0 +1,211 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// QuotaCaps are the largest namespace quota values allowed, as Kubernetes resource
+// quantities. They apply to both requests and limits; an empty value is uncapped.
+type QuotaCaps struct {
+	Cpu    string `json:"cpu"`
+	Memory string `json:"memory"`
+}
+
+// quotaField is a single NsQuota value with the json path used to report it
+type quotaField struct {
+	name     string
+	resource string
+	value    string
+}
+
+// quotaFields returns the four NsQuota values in a stable order, requests first
+func (message TinyHomeInstructions) quotaFields() []quotaField {
+	return []quotaField{
+		{name: "nsQuota.requests.cpu", resource: "cpu", value: message.NsQuota.Requests.Cpu},
+		{name: "nsQuota.requests.memory", resource: "memory", value: message.NsQuota.Requests.Memory},
+		{name: "nsQuota.limits.cpu", resource: "cpu", value: message.NsQuota.Limits.Cpu},
+		{name: "nsQuota.limits.memory", resource: "memory", value: message.NsQuota.Limits.Memory},
+	}
+}
+
+// ValidateQuotaCaps checks NsQuota against the caps for the instruction's Environment,
+// so a dev tenant can not request prod sized resources. Environments without caps are
+// unrestricted.
+func (message TinyHomeInstructions) ValidateQuotaCaps(caps map[string]QuotaCaps) error {
+	envCaps, ok := caps[message.Environment]
+	if !ok {
+		return nil
+	}
+
+	for _, field := range message.quotaFields() {
+		limit := envCaps.Cpu
+		if field.resource == "memory" {
+			limit = envCaps.Memory
+		}
+		if limit == "" || field.value == "" {
+			continue
+		}
+
+		capQuantity, err := resource.ParseQuantity(limit)
+		if err != nil {
+			return fmt.Errorf("%s cap %q for environment %s is not a valid quantity: %v", field.resource, limit, message.Environment, err)
+		}
+
+		requested, err := resource.ParseQuantity(field.value)
+		if err != nil {
+			return &QuotaError{Field: field.name, Message: fmt.Sprintf("%s %q is not a valid quantity: %v", field.name, field.value, err)}
+		}
+
+		if requested.Cmp(capQuantity) > 0 {
+			return &QuotaError{
+				Field:   field.name,
+				Message: fmt.Sprintf("%s %s exceeds the %s cap of %s for environment %s", field.name, field.value, field.resource, limit, message.Environment),
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateQuotaCapQuantities checks every value of caps, keyed by environment or cost
+// center, is a valid quantity, naming them as kind in the error
+func validateQuotaCapQuantities(kind string, caps map[string]QuotaCaps) error {
+	keys := make([]string, 0, len(caps))
+	for key := range caps {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range []struct{ resource, limit string }{{"cpu", caps[key].Cpu}, {"memory", caps[key].Memory}} {
+			if value.limit == "" {
+				continue
+			}
+			if _, err := resource.ParseQuantity(value.limit); err != nil {
+				return fmt.Errorf("%s %s %q for %s is not a valid quantity: %v", value.resource, kind, value.limit, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateCostCenterBudget checks the NsQuota requests against the budget of the
+// instruction's TenantCostCenter, enforcing financial quota governance. Cost centers
+// without a budget are unrestricted and an empty budgets map disables the check.
+func (message TinyHomeInstructions) ValidateCostCenterBudget(budgets map[string]QuotaCaps) error {
+	budget, ok := budgets[message.TenantCostCenter]
+	if !ok {
+		return nil
+	}
+
+	// Only requests count against the budget, they are the first two quota fields
+	for _, field := range message.quotaFields()[:2] {
+		limit := budget.Cpu
+		if field.resource == "memory" {
+			limit = budget.Memory
+		}
+		if limit == "" || field.value == "" {
+			continue
+		}
+
+		budgetQuantity, err := resource.ParseQuantity(limit)
+		if err != nil {
+			return fmt.Errorf("%s budget %q for cost center %s is not a valid quantity: %v", field.resource, limit, message.TenantCostCenter, err)
+		}
+
+		requested, err := resource.ParseQuantity(field.value)
+		if err != nil {
+			return &QuotaError{Field: field.name, Message: fmt.Sprintf("%s %q is not a valid quantity: %v", field.name, field.value, err)}
+		}
+
+		if requested.Cmp(budgetQuantity) > 0 {
+			return &QuotaError{
+				Field:   field.name,
+				Message: fmt.Sprintf("%s %s exceeds the %s budget of %s for cost center %s", field.name, field.value, field.resource, limit, message.TenantCostCenter),
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateBurstRatio rejects quotas whose limit is more than maxRatio times the
+// request for the same resource, guarding against noisy neighbors. A maxRatio of 0
+// disables the check and resources without both a request and a limit are skipped.
+func (message TinyHomeInstructions) validateBurstRatio(maxRatio float64) error {
+	if maxRatio == 0 {
+		return nil
+	}
+
+	fields := message.quotaFields()
+	for i, request := range fields[:2] {
+		limit := fields[i+2]
+		if request.value == "" || limit.value == "" {
+			continue
+		}
+
+		requested, err := resource.ParseQuantity(request.value)
+		if err != nil {
+			return &QuotaError{Field: request.name, Message: fmt.Sprintf("%s %q is not a valid quantity: %v", request.name, request.value, err)}
+		}
+
+		limited, err := resource.ParseQuantity(limit.value)
+		if err != nil {
+			return &QuotaError{Field: limit.name, Message: fmt.Sprintf("%s %q is not a valid quantity: %v", limit.name, limit.value, err)}
+		}
+
+		if requested.IsZero() {
+			if !limited.IsZero() {
+				return &QuotaError{Field: limit.name, Message: fmt.Sprintf("%s limit %s is set with a zero request, at most %gx the request is allowed", request.resource, limit.value, maxRatio)}
+			}
+			continue
+		}
+
+		ratio := float64(limited.MilliValue()) / float64(requested.MilliValue())
+		if ratio > maxRatio {
+			return &QuotaError{
+				Field:   limit.name,
+				Message: fmt.Sprintf("%s limit %s is %.3gx the request %s, at most %gx is allowed", request.resource, limit.value, ratio, request.value, maxRatio),
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateQuota checks every NsQuota value parses as a Kubernetes resource quantity
+// and that each limit is at least its request
+func (message TinyHomeInstructions) validateQuota() error {
+	fields := message.quotaFields()
+	quantities := make([]*resource.Quantity, len(fields))
+	for i, field := range fields {
+		if field.value == "" {
+			continue
+		}
+
+		quantity, err := resource.ParseQuantity(field.value)
+		if err != nil {
+			return &QuotaError{Field: field.name, Message: fmt.Sprintf("%s %q is not a valid Kubernetes quantity: %v", field.name, field.value, err)}
+		}
+		quantities[i] = &quantity
+	}
+
+	// Requests are the first two fields and limits the last two
+	for i := range fields[:2] {
+		request, limit := quantities[i], quantities[i+2]
+		if request == nil || limit == nil {
+			continue
+		}
+
+		if limit.Cmp(*request) < 0 {
+			return &QuotaError{
+				Field:   fields[i+2].name,
+				Message: fmt.Sprintf("%s %s is less than %s %s", fields[i+2].name, fields[i+2].value, fields[i].name, fields[i].value),
+			}
+		}
+	}
+	return nil
+}
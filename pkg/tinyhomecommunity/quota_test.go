@@ -0,0 +1,304 @@
+package tinyhomecommunity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuotaCaps(t *testing.T) {
+	caps := map[string]QuotaCaps{
+		"dev":   {Cpu: "2", Memory: "4Gi"},
+		"stage": {Cpu: "8"},
+		"prod":  {Cpu: "32", Memory: "128Gi"},
+	}
+
+	tests := []struct {
+		name        string
+		environment string
+		// cpu and memory are both requested and the limit
+		cpu       string
+		memory    string
+		wantField string
+		// wantMessage are the parts of the error message
+		wantMessage []string
+	}{
+		{name: "dev within caps", environment: "dev", cpu: "2", memory: "4Gi"},
+		{
+			name:        "dev over the cpu cap",
+			environment: "dev",
+			cpu:         "16",
+			memory:      "4Gi",
+			wantField:   "nsQuota.requests.cpu",
+			wantMessage: []string{"nsQuota.requests.cpu 16", "cpu cap of 2", "environment dev"},
+		},
+		{
+			name:        "dev over the memory cap in another unit",
+			environment: "dev",
+			cpu:         "2",
+			memory:      "8192Mi",
+			wantField:   "nsQuota.requests.memory",
+			wantMessage: []string{"nsQuota.requests.memory 8192Mi", "memory cap of 4Gi", "environment dev"},
+		},
+		{name: "prod sized request in prod", environment: "prod", cpu: "16", memory: "64Gi"},
+		{
+			name:        "prod over its cap",
+			environment: "prod",
+			cpu:         "64",
+			memory:      "64Gi",
+			wantField:   "nsQuota.requests.cpu",
+			wantMessage: []string{"cpu cap of 32", "environment prod"},
+		},
+		{name: "stage memory is uncapped", environment: "stage", cpu: "8", memory: "512Gi"},
+		{name: "environment without caps", environment: "test", cpu: "64", memory: "512Gi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.Environment = tt.environment
+			message.NsQuota.Requests.Cpu = tt.cpu
+			message.NsQuota.Requests.Memory = tt.memory
+			message.NsQuota.Limits.Cpu = tt.cpu
+			message.NsQuota.Limits.Memory = tt.memory
+			_, err := message.DryRun(validAttributes(), WithQuotaCaps(caps))
+			wantFieldError(t, err, tt.wantField)
+			for _, want := range tt.wantMessage {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestQuotaCapsValidated(t *testing.T) {
+	tests := []struct {
+		name    string
+		caps    map[string]QuotaCaps
+		wantErr bool
+	}{
+		{name: "valid caps", caps: map[string]QuotaCaps{"dev": {Cpu: "500m", Memory: "1Gi"}}},
+		{name: "invalid cpu cap", caps: map[string]QuotaCaps{"dev": {Cpu: "lots"}}, wantErr: true},
+		{name: "invalid memory cap", caps: map[string]QuotaCaps{"prod": {Memory: "1 GB"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithQuotaCaps(tt.caps))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPublisherWithTopic error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCostCenterBudgets(t *testing.T) {
+	budgets := map[string]QuotaCaps{
+		"1234": {Cpu: "4", Memory: "8Gi"},
+		"5678": {Cpu: "1"},
+	}
+
+	tests := []struct {
+		name       string
+		budgets    map[string]QuotaCaps
+		costCenter string
+		// requests and limits are the cpu and memory requested and limited
+		requests  [2]string
+		limits    [2]string
+		wantField string
+		// wantMessage are the parts of the error message
+		wantMessage []string
+	}{
+		{name: "within the budget", budgets: budgets, costCenter: "1234", requests: [2]string{"2", "4Gi"}, limits: [2]string{"4", "8Gi"}},
+		{name: "at the budget", budgets: budgets, costCenter: "1234", requests: [2]string{"4", "8Gi"}, limits: [2]string{"4", "8Gi"}},
+		{name: "limits over the budget", budgets: budgets, costCenter: "1234", requests: [2]string{"4", "8Gi"}, limits: [2]string{"8", "16Gi"}},
+		{
+			name:        "cpu over the budget",
+			budgets:     budgets,
+			costCenter:  "1234",
+			requests:    [2]string{"6", "4Gi"},
+			limits:      [2]string{"6", "4Gi"},
+			wantField:   "nsQuota.requests.cpu",
+			wantMessage: []string{"cost center 1234", "nsQuota.requests.cpu 6", "cpu budget of 4"},
+		},
+		{
+			name:        "memory over the budget in another unit",
+			budgets:     budgets,
+			costCenter:  "1234",
+			requests:    [2]string{"2", "9000Mi"},
+			limits:      [2]string{"2", "9000Mi"},
+			wantField:   "nsQuota.requests.memory",
+			wantMessage: []string{"cost center 1234", "nsQuota.requests.memory 9000Mi", "memory budget of 8Gi"},
+		},
+		{
+			name:        "millicores over a small budget",
+			budgets:     budgets,
+			costCenter:  "5678",
+			requests:    [2]string{"1500m", "64Gi"},
+			limits:      [2]string{"1500m", "64Gi"},
+			wantField:   "nsQuota.requests.cpu",
+			wantMessage: []string{"cost center 5678", "nsQuota.requests.cpu 1500m", "cpu budget of 1"},
+		},
+		{name: "cost center without a budget", budgets: budgets, costCenter: "9999", requests: [2]string{"64", "512Gi"}, limits: [2]string{"64", "512Gi"}},
+		{name: "empty mapping", costCenter: "1234", requests: [2]string{"64", "512Gi"}, limits: [2]string{"64", "512Gi"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.TenantCostCenter = tt.costCenter
+			message.NsQuota.Requests.Cpu, message.NsQuota.Requests.Memory = tt.requests[0], tt.requests[1]
+			message.NsQuota.Limits.Cpu, message.NsQuota.Limits.Memory = tt.limits[0], tt.limits[1]
+			_, err := message.DryRun(validAttributes(), WithCostCenterBudgets(tt.budgets))
+			wantFieldError(t, err, tt.wantField)
+			for _, want := range tt.wantMessage {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCostCenterBudgetsValidated(t *testing.T) {
+	tests := []struct {
+		name    string
+		budgets map[string]QuotaCaps
+		wantErr bool
+	}{
+		{name: "valid budgets", budgets: map[string]QuotaCaps{"1234": {Cpu: "500m", Memory: "1Gi"}}},
+		{name: "invalid cpu budget", budgets: map[string]QuotaCaps{"1234": {Cpu: "lots"}}, wantErr: true},
+		{name: "invalid memory budget", budgets: map[string]QuotaCaps{"1234": {Memory: "1 GB"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithCostCenterBudgets(tt.budgets))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPublisherWithTopic error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBurstRatio(t *testing.T) {
+	tests := []struct {
+		name string
+		// opts change the default ratio of DefaultMaxBurstRatio
+		opts []Option
+		// requests and limits are the cpu and memory requested and limited
+		requests  [2]string
+		limits    [2]string
+		wantField string
+		// wantMessage are the parts of the error message
+		wantMessage []string
+	}{
+		{name: "within the default ratio", requests: [2]string{"1", "1Gi"}, limits: [2]string{"2", "2Gi"}},
+		{name: "at the default ratio", requests: [2]string{"1", "1Gi"}, limits: [2]string{"4", "4Gi"}},
+		{name: "within the ratio in other units", requests: [2]string{"500m", "512Mi"}, limits: [2]string{"2", "2Gi"}},
+		{
+			name:        "cpu over the default ratio",
+			requests:    [2]string{"1", "1Gi"},
+			limits:      [2]string{"5", "2Gi"},
+			wantField:   "nsQuota.limits.cpu",
+			wantMessage: []string{"cpu limit 5", "5x the request 1", "at most 4x"},
+		},
+		{
+			name:        "memory over the default ratio",
+			requests:    [2]string{"1", "512Mi"},
+			limits:      [2]string{"1", "4Gi"},
+			wantField:   "nsQuota.limits.memory",
+			wantMessage: []string{"memory limit 4Gi", "8x the request 512Mi", "at most 4x"},
+		},
+		{
+			name:        "over a configured ratio",
+			opts:        []Option{WithMaxBurstRatio(1.5)},
+			requests:    [2]string{"1", "1Gi"},
+			limits:      [2]string{"2", "1Gi"},
+			wantField:   "nsQuota.limits.cpu",
+			wantMessage: []string{"cpu limit 2", "2x the request 1", "at most 1.5x"},
+		},
+		{
+			name:        "zero request with a limit",
+			requests:    [2]string{"0", "1Gi"},
+			limits:      [2]string{"1", "1Gi"},
+			wantField:   "nsQuota.limits.cpu",
+			wantMessage: []string{"cpu limit 1 is set with a zero request"},
+		},
+		{name: "disabled", opts: []Option{WithMaxBurstRatio(0)}, requests: [2]string{"1", "1Gi"}, limits: [2]string{"64", "512Gi"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.NsQuota.Requests.Cpu, message.NsQuota.Requests.Memory = tt.requests[0], tt.requests[1]
+			message.NsQuota.Limits.Cpu, message.NsQuota.Limits.Memory = tt.limits[0], tt.limits[1]
+			_, err := message.DryRun(validAttributes(), tt.opts...)
+			wantFieldError(t, err, tt.wantField)
+			for _, want := range tt.wantMessage {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBurstRatioValidated(t *testing.T) {
+	for _, ratio := range []float64{0, 1, DefaultMaxBurstRatio, 10.5} {
+		if _, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithMaxBurstRatio(ratio)); err != nil {
+			t.Errorf("newPublisherWithTopic with ratio %g: %v", ratio, err)
+		}
+	}
+	if _, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithMaxBurstRatio(-1)); err == nil {
+		t.Error("newPublisherWithTopic accepted a negative burst ratio")
+	}
+}
+
+func TestQuotaQuantities(t *testing.T) {
+	tests := []struct {
+		name string
+		// requests and limits are the cpu and memory requested and limited
+		requests  [2]string
+		limits    [2]string
+		wantField string
+		// wantMessage is part of the error message
+		wantMessage string
+	}{
+		{name: "valid", requests: [2]string{"1", "1Gi"}, limits: [2]string{"2", "2Gi"}},
+		{name: "millicores and decimal units", requests: [2]string{"500m", "512M"}, limits: [2]string{"1500m", "1G"}},
+		{name: "limits equal requests", requests: [2]string{"2", "2Gi"}, limits: [2]string{"2", "2Gi"}},
+		{name: "equal in different units", requests: [2]string{"1", "1024Mi"}, limits: [2]string{"1000m", "1Gi"}},
+		{name: "invalid cpu request", requests: [2]string{"500mm", "1Gi"}, limits: [2]string{"1", "1Gi"}, wantField: "nsQuota.requests.cpu", wantMessage: `"500mm" is not a valid Kubernetes quantity`},
+		{name: "invalid memory request", requests: [2]string{"1", "2Gee"}, limits: [2]string{"1", "2Gi"}, wantField: "nsQuota.requests.memory", wantMessage: `"2Gee"`},
+		{name: "invalid cpu limit", requests: [2]string{"1", "1Gi"}, limits: [2]string{"two", "1Gi"}, wantField: "nsQuota.limits.cpu", wantMessage: `"two"`},
+		{name: "invalid memory limit", requests: [2]string{"1", "1Gi"}, limits: [2]string{"1", "1 Gi"}, wantField: "nsQuota.limits.memory", wantMessage: `"1 Gi"`},
+		{
+			name:        "cpu limit below request",
+			requests:    [2]string{"2", "1Gi"},
+			limits:      [2]string{"1500m", "1Gi"},
+			wantField:   "nsQuota.limits.cpu",
+			wantMessage: "nsQuota.limits.cpu 1500m is less than nsQuota.requests.cpu 2",
+		},
+		{
+			name:        "memory limit below request",
+			requests:    [2]string{"1", "1Gi"},
+			limits:      [2]string{"1", "1000Mi"},
+			wantField:   "nsQuota.limits.memory",
+			wantMessage: "nsQuota.limits.memory 1000Mi is less than nsQuota.requests.memory 1Gi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.NsQuota.Requests.Cpu, message.NsQuota.Requests.Memory = tt.requests[0], tt.requests[1]
+			message.NsQuota.Limits.Cpu, message.NsQuota.Limits.Memory = tt.limits[0], tt.limits[1]
+			_, err := message.DryRun(validAttributes())
+			wantFieldError(t, err, tt.wantField)
+			if tt.wantMessage != "" && !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+)
+
+// DefaultRegions are the GCP regions Region is validated against unless WithRegions
+// overrides them
+var DefaultRegions = []string{
+	"africa-south1",
+	"asia-east1", "asia-east2",
+	"asia-northeast1", "asia-northeast2", "asia-northeast3",
+	"asia-south1", "asia-south2",
+	"asia-southeast1", "asia-southeast2",
+	"australia-southeast1", "australia-southeast2",
+	"europe-central2",
+	"europe-north1",
+	"europe-southwest1",
+	"europe-west1", "europe-west2", "europe-west3", "europe-west4", "europe-west6",
+	"europe-west8", "europe-west9", "europe-west10", "europe-west12",
+	"me-central1", "me-central2", "me-west1",
+	"northamerica-northeast1", "northamerica-northeast2",
+	"southamerica-east1", "southamerica-west1",
+	"us-central1",
+	"us-east1", "us-east4", "us-east5",
+	"us-south1",
+	"us-west1", "us-west2", "us-west3", "us-west4",
+}
+
+// isKnownRegion reports whether region is in regions, or in DefaultRegions when
+// regions is empty
+func isKnownRegion(regions []string, region string) bool {
+	if len(regions) > 0 {
+		return contains(regions, region)
+	}
+	return contains(DefaultRegions, region)
+}
+
+// applyRegionDefault sets Region from defaults for the instruction's Environment when
+// Region is empty, and returns the region it applied, if any
+func (message *TinyHomeInstructions) applyRegionDefault(defaults map[string]string) string {
+	if message.Region != "" {
+		return ""
+	}
+	message.Region = defaults[message.Environment]
+	return message.Region
+}
+
+// validateRegionNotDecommissioned rejects a Region in decommissioned, which maps each
+// region being wound down to the regions recommended in its place
+func (message TinyHomeInstructions) validateRegionNotDecommissioned(decommissioned map[string][]string) error {
+	replacements, ok := decommissioned[message.Region]
+	if message.Region == "" || !ok {
+		return nil
+	}
+
+	if len(replacements) == 0 {
+		return &NamingError{Field: "region", Message: fmt.Sprintf("region %q is decommissioned", message.Region)}
+	}
+	return &NamingError{Field: "region", Message: fmt.Sprintf("region %q is decommissioned, use one of: %s", message.Region, replacements)}
+}
@@ -0,0 +1,132 @@
+package tinyhomecommunity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegion(t *testing.T) {
+	tests := []struct {
+		name      string
+		region    string
+		opts      []Option
+		wantField string
+	}{
+		{name: "known region", region: "europe-west1"},
+		{name: "no region", region: ""},
+		{name: "unknown region", region: "mars-north1", wantField: "region"},
+		{name: "region is case sensitive", region: "US-EAST1", wantField: "region"},
+		{name: "custom list adds a region", region: "us-south9", opts: []Option{WithRegions([]string{"us-south9", "us-east1"})}},
+		{name: "custom list restricts regions", region: "europe-west1", opts: []Option{WithRegions([]string{"us-east1"})}, wantField: "region"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.Region = tt.region
+			result, err := message.DryRun(validAttributes(), tt.opts...)
+			wantFieldError(t, err, tt.wantField)
+			if err != nil {
+				return
+			}
+
+			got, ok := result.Attributes["region"]
+			if tt.region == "" {
+				if ok {
+					t.Errorf("region attribute = %q, want none", got)
+				}
+				return
+			}
+			if got != tt.region {
+				t.Errorf("region attribute = %q, want %q", got, tt.region)
+			}
+		})
+	}
+}
+
+func TestRegionDefaults(t *testing.T) {
+	defaults := map[string]string{"dev": "europe-west1", "test": "us-east1", "stage": "mars-north1"}
+
+	tests := []struct {
+		name        string
+		environment string
+		region      string
+		want        string
+		wantField   string
+	}{
+		{name: "dev default", environment: "dev", want: "europe-west1"},
+		{name: "test default", environment: "test", want: "us-east1"},
+		{name: "explicit region wins", environment: "dev", region: "us-central1", want: "us-central1"},
+		{name: "no default for the environment", environment: "prod"},
+		{name: "default is validated", environment: "stage", wantField: "region"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.Environment = tt.environment
+			message.Region = tt.region
+			result, err := message.DryRun(validAttributes(), WithRegionDefaults(defaults))
+			wantFieldError(t, err, tt.wantField)
+			if err != nil {
+				return
+			}
+
+			// The applied region is left on the instructions and reported in the
+			// result's region attribute
+			if message.Region != tt.want {
+				t.Errorf("Region = %q, want %q", message.Region, tt.want)
+			}
+			if got := result.Attributes["region"]; got != tt.want {
+				t.Errorf("region attribute = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecommissionedRegions(t *testing.T) {
+	decommissioned := map[string][]string{
+		"us-west1":     {"us-west2", "us-west4"},
+		"europe-west1": nil,
+	}
+
+	tests := []struct {
+		name           string
+		region         string
+		decommissioned map[string][]string
+		// wantMessage is the error message, empty when the region is allowed
+		wantMessage string
+	}{
+		{name: "active region", region: "us-east1", decommissioned: decommissioned},
+		{name: "no region", decommissioned: decommissioned},
+		{
+			name:           "decommissioned region with replacements",
+			region:         "us-west1",
+			decommissioned: decommissioned,
+			wantMessage:    `region "us-west1" is decommissioned, use one of: [us-west2 us-west4]`,
+		},
+		{
+			name:           "decommissioned region without replacements",
+			region:         "europe-west1",
+			decommissioned: decommissioned,
+			wantMessage:    `region "europe-west1" is decommissioned`,
+		},
+		{name: "no decommissioned regions", region: "us-west1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.Region = tt.region
+			_, err := message.DryRun(validAttributes(), WithDecommissionedRegions(tt.decommissioned))
+			if tt.wantMessage == "" {
+				wantFieldError(t, err, "")
+				return
+			}
+			wantFieldError(t, err, "region")
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
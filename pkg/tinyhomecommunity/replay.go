@@ -0,0 +1,68 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ArchivedMessage is a previously published body and its attributes, as kept by an
+// archive for disaster recovery
+type ArchivedMessage struct {
+	Data       []byte            `json:"data"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// ReplayArchive republishes the messages read from src at no more than rps messages
+// per second, adding a replayed attribute with MarkRepublished and keeping every
+// original attribute, including correlationId. A PublishResult is sent for each
+// message, with Err set when it failed, and the results channel is closed once src
+// is closed or ctx is done.
+func (p *Publisher) ReplayArchive(ctx context.Context, src <-chan ArchivedMessage, rps int) (<-chan PublishResult, error) {
+	if rps < 1 {
+		return nil, fmt.Errorf("ReplayArchive: rps must be at least 1, got %d", rps)
+	}
+
+	results := make(chan PublishResult)
+	go func() {
+		defer close(results)
+
+		ticker := time.NewTicker(time.Second / time.Duration(rps))
+		defer ticker.Stop()
+
+		for {
+			var archived ArchivedMessage
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok := <-src:
+				if !ok {
+					return
+				}
+				archived = m
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			result, err := p.republish(ctx, archived.Data, archived.Attributes, "replayed")
+			if err != nil {
+				result = &PublishResult{
+					TenantName: archived.Attributes[AttrTenantName],
+					Attributes: archived.Attributes,
+					Err:        fmt.Errorf("ReplayArchive: %w", err),
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case results <- *result:
+			}
+		}
+	}()
+	return results, nil
+}
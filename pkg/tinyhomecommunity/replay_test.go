@@ -0,0 +1,165 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// archiveOf publishes n messages with correlation IDs replay-0, replay-1 and so on
+// and returns them as archived
+func archiveOf(t *testing.T, n int) []ArchivedMessage {
+	t.Helper()
+	captured := &fakeTopic{}
+	p := newTestPublisher(t, captured, nil)
+	for i := 0; i < n; i++ {
+		message := validInstructions()
+		attrs := validAttributes()
+		attrs.CorrelationID = fmt.Sprintf("replay-%d", i)
+		if _, err := p.PublishContext(context.Background(), &message, attrs); err != nil {
+			t.Fatalf("PublishContext: %v", err)
+		}
+	}
+
+	var archive []ArchivedMessage
+	for _, msg := range captured.published() {
+		archive = append(archive, ArchivedMessage{Data: msg.Data, Attributes: maps.Clone(msg.Attributes)})
+	}
+	return archive
+}
+
+func TestReplayArchive(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	tests := []struct {
+		name string
+		size int
+		rps  int
+		// mutate changes the archive before it is replayed
+		mutate func(archive []ArchivedMessage)
+		result func(n int, msg *pubsub.Message) publishResult
+		// wantErrs are the errors expected for each result, by index
+		wantErrs map[int]error
+	}{
+		{name: "capped rate", size: 5, rps: 20},
+		{name: "single message", size: 1, rps: 10},
+		{
+			name: "failed publishes are reported",
+			size: 3,
+			rps:  50,
+			result: func(n int, msg *pubsub.Message) publishResult {
+				if n == 2 {
+					return fakeResult{err: errPubSub}
+				}
+				return fakeResult{id: fmt.Sprint(n)}
+			},
+			wantErrs: map[int]error{1: errPubSub},
+		},
+		{
+			name:     "unroutable messages are reported",
+			size:     3,
+			rps:      50,
+			mutate:   func(archive []ArchivedMessage) { archive[0].Attributes[AttrDeliveredFrom] = "nowhere" },
+			wantErrs: map[int]error{0: ErrInvalidAttribute},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := archiveOf(t, tt.size)
+			if tt.mutate != nil {
+				tt.mutate(archive)
+			}
+			src := make(chan ArchivedMessage, len(archive))
+			for _, archived := range archive {
+				src <- archived
+			}
+			close(src)
+
+			topic := &fakeTopic{result: tt.result}
+			p := newTestPublisher(t, topic, nil)
+			start := time.Now()
+			results, err := p.ReplayArchive(context.Background(), src, tt.rps)
+			if err != nil {
+				t.Fatalf("ReplayArchive: %v", err)
+			}
+
+			var got []PublishResult
+			for result := range results {
+				got = append(got, result)
+			}
+			// Each message waits for its own tick
+			if elapsed, min := time.Since(start), time.Duration(tt.size)*time.Second/time.Duration(tt.rps); elapsed < min {
+				t.Errorf("replayed %d messages at %d per second in %v, want at least %v", tt.size, tt.rps, elapsed, min)
+			}
+			if len(got) != tt.size {
+				t.Fatalf("%d results, want %d", len(got), tt.size)
+			}
+
+			for i, result := range got {
+				if wantErr := tt.wantErrs[i]; wantErr != nil {
+					if !errors.Is(result.Err, wantErr) {
+						t.Errorf("result %d error = %v, want %v", i, result.Err, wantErr)
+					}
+					continue
+				}
+				if result.Err != nil {
+					t.Errorf("result %d: %v", i, result.Err)
+					continue
+				}
+				// Results arrive in archive order, keeping the original correlation ID
+				if want := fmt.Sprintf("replay-%d", i); result.CorrelationID != want || result.Attributes["correlationId"] != want {
+					t.Errorf("result %d has correlation ID %q, want %q", i, result.CorrelationID, want)
+				}
+				if result.Attributes["replayed"] != "true" {
+					t.Errorf("result %d attributes %v, want replayed true", i, result.Attributes)
+				}
+			}
+			for _, msg := range topic.published() {
+				if msg.Attributes["replayed"] != "true" {
+					t.Errorf("published attributes %v, want replayed true", msg.Attributes)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayArchiveCancel(t *testing.T) {
+	topic := &fakeTopic{}
+	p := newTestPublisher(t, topic, nil)
+	src := make(chan ArchivedMessage, 1)
+	src <- archiveOf(t, 1)[0]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, err := p.ReplayArchive(ctx, src, 100)
+	if err != nil {
+		t.Fatalf("ReplayArchive: %v", err)
+	}
+	if result := <-results; result.Err != nil {
+		t.Fatalf("first result: %v", result.Err)
+	}
+
+	// src stays open, so only the cancel ends the replay
+	cancel()
+	select {
+	case _, ok := <-results:
+		if ok {
+			t.Error("result received after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("results not closed after cancel")
+	}
+}
+
+func TestReplayArchiveRate(t *testing.T) {
+	p := newTestPublisher(t, &fakeTopic{}, nil)
+	for _, rps := range []int{0, -1} {
+		if _, err := p.ReplayArchive(context.Background(), make(chan ArchivedMessage), rps); err == nil {
+			t.Errorf("ReplayArchive accepted rps %d", rps)
+		}
+	}
+}
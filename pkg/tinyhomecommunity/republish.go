@@ -0,0 +1,64 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+)
+
+// Republish publishes a previously captured body and attributes using a Publisher
+// for the topic selected by opts. See Publisher.Republish.
+func Republish(ctx context.Context, body []byte, attrs map[string]string, opts ...Option) (string, error) {
+	p, err := NewPublisher(ctx, DefaultPublisherConfig(), opts...)
+	if err != nil {
+		return "", fmt.Errorf("Republish: %w", err)
+	}
+	defer p.Close()
+
+	return p.Republish(ctx, body, attrs)
+}
+
+// Republish publishes a previously captured body and attributes, e.g. from a
+// dead-letter subscription or an archive, without re-marshaling the body. The
+// attributes must still route to a known subscription. With MarkRepublished a
+// republished attribute is added so subscribers can tell recovered messages apart.
+func (p *Publisher) Republish(ctx context.Context, body []byte, attrs map[string]string) (string, error) {
+	result, err := p.republish(ctx, body, attrs, "republished")
+	if err != nil {
+		return "", fmt.Errorf("Republish: %w", err)
+	}
+	return result.MessageID, nil
+}
+
+// republish validates the routing attributes of a captured message and publishes it
+// unchanged apart from the marker attribute set to true with MarkRepublished
+func (p *Publisher) republish(ctx context.Context, body []byte, attrs map[string]string, marker string) (*PublishResult, error) {
+	messageAttributes := attributesFromMessage(attrs)
+	attrMessage, err := messageAttributes.validateAttributes(p.cfg)
+	if err != nil {
+		return nil, err
+	}
+	subscription, _ := messageAttributes.subscriptionWith(p.cfg)
+
+	attributes := make(map[string]string, len(attrs)+1)
+	for k, v := range attrs {
+		attributes[k] = v
+	}
+	if p.cfg.MarkRepublished {
+		attributes[marker] = "true"
+	}
+
+	id, err := p.publishMessage(ctx, body, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	p.logger().InfoContext(ctx, "republished message", "tenantName", attributes[AttrTenantName], "messageId", id, marker, p.cfg.MarkRepublished)
+	p.logger().DebugContext(ctx, attrMessage)
+	return &PublishResult{
+		MessageID:     id,
+		Subscription:  subscription,
+		TenantName:    attributes[AttrTenantName],
+		CorrelationID: attributes["correlationId"],
+		Attributes:    attributes,
+	}, nil
+}
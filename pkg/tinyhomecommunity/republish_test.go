@@ -0,0 +1,74 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestRepublish(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		// mutate changes the captured attributes before they are republished
+		mutate     func(attrs map[string]string)
+		wantMarker bool
+		wantErr    error
+	}{
+		{name: "marked by default", wantMarker: true},
+		{name: "marker disabled", opts: []Option{WithMarkRepublished(false)}},
+		{name: "compressed body", opts: []Option{WithCompression(true)}, wantMarker: true},
+		{
+			name:    "unroutable attributes",
+			mutate:  func(attrs map[string]string) { attrs[AttrDeliveredFrom] = "nowhere" },
+			wantErr: ErrInvalidAttribute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Capture a message as published
+			captured := &fakeTopic{}
+			message := validInstructions()
+			if _, err := newTestPublisher(t, captured, nil, tt.opts...).PublishContext(context.Background(), &message, validAttributes()); err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+			original := captured.published()[0]
+			attrs := maps.Clone(original.Attributes)
+			if tt.mutate != nil {
+				tt.mutate(attrs)
+			}
+
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, tt.opts...)
+			id, err := p.Republish(context.Background(), original.Data, attrs)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Republish error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if got := topic.published(); len(got) != 0 {
+					t.Errorf("published %d messages, want none", len(got))
+				}
+				return
+			}
+			if id != "1" {
+				t.Errorf("Republish id = %q, want 1", id)
+			}
+
+			republished := topic.published()[0]
+			if !bytes.Equal(republished.Data, original.Data) {
+				t.Errorf("body changed from %q to %q", original.Data, republished.Data)
+			}
+
+			want := maps.Clone(attrs)
+			if tt.wantMarker {
+				want["republished"] = "true"
+			}
+			if !maps.Equal(republished.Attributes, want) {
+				t.Errorf("attributes = %v, want %v", republished.Attributes, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// ResourceNames are the canonical names of the resources created downstream for a
+// tenant, derived from the instructions so the publisher and all subscribers agree
+type ResourceNames struct {
+	Namespace           string `json:"namespace"`
+	ProjectID           string `json:"projectId"`
+	ServiceAccountID    string `json:"serviceAccountId"`
+	ServiceAccountEmail string `json:"serviceAccountEmail"`
+	// Organization is the organization the project is created under, the numeric ID
+	// or domain from the instructions
+	Organization string `json:"organization"`
+	// Parent is the Resource Manager parent of the project, organizations/ID, when
+	// Organization is a numeric ID. It is empty for a domain, which consumers resolve
+	// to an ID with an organization search.
+	Parent string `json:"parent,omitempty"`
+}
+
+// ResourceNames derives the namespace, project ID and service account names from the
+// TenantName and Environment, and the project's parent from the Organization. Call
+// Validate on the result before relying on the names.
+//
+// The IDs themselves do not include the Organization. It is a numeric ID or a domain,
+// and either would use up most of the 30 characters a GCP ID allows while adding
+// dots that IDs can not contain, so it is carried in Organization and Parent instead.
+func (message TinyHomeInstructions) ResourceNames() ResourceNames {
+	base := fmt.Sprintf("%s-%s", message.TenantName, message.Environment)
+	serviceAccountId := fmt.Sprintf("%s-sa", message.TenantName)
+
+	names := ResourceNames{
+		Namespace:           base,
+		ProjectID:           base,
+		ServiceAccountID:    serviceAccountId,
+		ServiceAccountEmail: fmt.Sprintf("%s@%s.iam.gserviceaccount.com", serviceAccountId, base),
+		Organization:        message.Organization,
+	}
+	if isNumericID(message.Organization) {
+		names.Parent = "organizations/" + message.Organization
+	}
+	return names
+}
+
+// isNumericID reports whether value is a non-empty string of ASCII digits
+func isNumericID(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the derived names against the Kubernetes namespace and GCP project
+// and service account naming rules, and that there is an Organization to create the
+// project under
+func (names ResourceNames) Validate() error {
+	if err := validateDNS1123Label(names.Namespace); err != nil {
+		return &NamingError{Field: "namespace", Message: fmt.Sprintf("namespace %q: %v", names.Namespace, err)}
+	}
+
+	if err := validateGCPId(names.ProjectID); err != nil {
+		return &NamingError{Field: "projectId", Message: fmt.Sprintf("projectId %q: %v", names.ProjectID, err)}
+	}
+
+	if err := validateGCPId(names.ServiceAccountID); err != nil {
+		return &NamingError{Field: "serviceAccountId", Message: fmt.Sprintf("serviceAccountId %q: %v", names.ServiceAccountID, err)}
+	}
+
+	if names.Organization == "" {
+		return &NamingError{Field: "organization", Message: "organization can not be empty, the project is created under it"}
+	}
+
+	return nil
+}
+
+// ValidateAsProjectID checks the project ID derived by ResourceNames follows the GCP
+// project ID rules, which are stricter than the namespace rules, and reports the
+// specific rule broken
+func (message TinyHomeInstructions) ValidateAsProjectID() error {
+	projectId := message.ResourceNames().ProjectID
+	if err := validateGCPId(projectId); err != nil {
+		return &NamingError{Field: "tenantName", Message: fmt.Sprintf("derived projectId %q: %v", projectId, err)}
+	}
+	return nil
+}
+
+// validateProjectIDTenantName applies ValidateAsProjectID when required is set
+func (message TinyHomeInstructions) validateProjectIDTenantName(required bool) error {
+	if !required {
+		return nil
+	}
+	return message.ValidateAsProjectID()
+}
+
+// validateGCPId returns an error naming the specific GCP project or service account
+// ID rule value breaks: 6 to 30 characters of lower case letters, digits and hyphens,
+// starting with a letter and not ending with a hyphen
+func validateGCPId(value string) error {
+	if len(value) < 6 || len(value) > 30 {
+		return fmt.Errorf("must be 6 to 30 characters, got %d", len(value))
+	}
+
+	for _, r := range value {
+		if r > unicode.MaxASCII || (!unicode.IsLower(r) && !unicode.IsDigit(r) && r != '-') {
+			return fmt.Errorf("may only contain lower case letters, digits or '-', found %q", r)
+		}
+	}
+
+	if !unicode.IsLower(rune(value[0])) {
+		return fmt.Errorf("must start with a lower case letter")
+	}
+
+	if value[len(value)-1] == '-' {
+		return fmt.Errorf("can not end with '-'")
+	}
+	return nil
+}
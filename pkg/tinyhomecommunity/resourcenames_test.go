@@ -0,0 +1,247 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResourceNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		tenantName  string
+		environment string
+		want        ResourceNames
+		wantField   string
+	}{
+		{
+			name:        "derived from tenant and environment",
+			tenantName:  "acme",
+			environment: "dev",
+			want: ResourceNames{
+				Namespace:           "acme-dev",
+				ProjectID:           "acme-dev",
+				ServiceAccountID:    "acme-sa",
+				ServiceAccountEmail: "acme-sa@acme-dev.iam.gserviceaccount.com",
+				Organization:        "123456789012",
+				Parent:              "organizations/123456789012",
+			},
+		},
+		{
+			name:        "longest valid project",
+			tenantName:  "abcdefghijklmnopqrstuvwxy",
+			environment: "prod",
+			want: ResourceNames{
+				Namespace:           "abcdefghijklmnopqrstuvwxy-prod",
+				ProjectID:           "abcdefghijklmnopqrstuvwxy-prod",
+				ServiceAccountID:    "abcdefghijklmnopqrstuvwxy-sa",
+				ServiceAccountEmail: "abcdefghijklmnopqrstuvwxy-sa@abcdefghijklmnopqrstuvwxy-prod.iam.gserviceaccount.com",
+				Organization:        "123456789012",
+				Parent:              "organizations/123456789012",
+			},
+		},
+		{
+			name:        "project over 30 characters",
+			tenantName:  "abcdefghijklmnopqrstuvwxyz",
+			environment: "prod",
+			wantField:   "projectId",
+		},
+		{
+			name:        "service account under 6 characters",
+			tenantName:  "ab",
+			environment: "stage",
+			wantField:   "serviceAccountId",
+		},
+		{
+			name:        "upper case is not a namespace",
+			tenantName:  "Acme",
+			environment: "dev",
+			wantField:   "namespace",
+		},
+		{
+			name:        "leading digit is not a project",
+			tenantName:  "1acme",
+			environment: "dev",
+			wantField:   "projectId",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := TinyHomeInstructions{TenantName: tt.tenantName, Environment: tt.environment, Organization: "123456789012"}
+			names := message.ResourceNames()
+
+			err := names.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				if names != tt.want {
+					t.Errorf("ResourceNames() = %+v, want %+v", names, tt.want)
+				}
+				return
+			}
+
+			var namingErr *NamingError
+			if !errors.As(err, &namingErr) || namingErr.Field != tt.wantField {
+				t.Fatalf("Validate error = %v, want a NamingError for %s", err, tt.wantField)
+			}
+			if !errors.Is(err, ErrInvalidInstructions) {
+				t.Errorf("Validate error %v does not match ErrInvalidInstructions", err)
+			}
+		})
+	}
+}
+
+func TestResourceNamesOrganization(t *testing.T) {
+	tests := []struct {
+		name         string
+		organization string
+		wantParent   string
+		wantField    string
+	}{
+		{name: "numeric ID", organization: "123456789012", wantParent: "organizations/123456789012"},
+		{name: "domain", organization: "example.com"},
+		{name: "mixed ID", organization: "1234abc"},
+		{name: "empty", organization: "", wantField: "organization"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := TinyHomeInstructions{TenantName: "acme", Environment: "dev", Organization: tt.organization}
+			names := message.ResourceNames()
+			if names.Organization != tt.organization || names.Parent != tt.wantParent {
+				t.Errorf("Organization, Parent = %q, %q, want %q, %q", names.Organization, names.Parent, tt.organization, tt.wantParent)
+			}
+			wantFieldError(t, names.Validate(), tt.wantField)
+
+			// The IDs stay within the GCP limits whatever the Organization is, and do
+			// not change if a tenant moves between organizations
+			if strings.Contains(names.ProjectID, tt.organization) && tt.organization != "" {
+				t.Errorf("ProjectID %q includes the Organization", names.ProjectID)
+			}
+			if names.ProjectID != "acme-dev" || names.ServiceAccountEmail != "acme-sa@acme-dev.iam.gserviceaccount.com" {
+				t.Errorf("ResourceNames() = %+v, want IDs derived from tenant and environment only", names)
+			}
+		})
+	}
+}
+
+func TestValidateAsProjectID(t *testing.T) {
+	tests := []struct {
+		name        string
+		tenantName  string
+		environment string
+		// wantMessage is the rule reported, empty when the project ID is valid
+		wantMessage string
+	}{
+		{name: "valid", tenantName: "acme", environment: "dev"},
+		{name: "shortest", tenantName: "ab", environment: "dev"},
+		{name: "longest", tenantName: "abcdefghijklmnopqrstuvwxy", environment: "dev"},
+		{name: "too short", tenantName: "a", environment: "dev", wantMessage: "must be 6 to 30 characters, got 5"},
+		{name: "too long", tenantName: "abcdefghijklmnopqrstuvwxyza", environment: "dev", wantMessage: "must be 6 to 30 characters, got 31"},
+		{name: "upper case", tenantName: "Acme", environment: "dev", wantMessage: "may only contain lower case letters, digits or '-', found 'A'"},
+		{name: "underscore", tenantName: "acme_corp", environment: "dev", wantMessage: "found '_'"},
+		{name: "starts with a digit", tenantName: "1acme", environment: "dev", wantMessage: "must start with a lower case letter"},
+		{name: "starts with a hyphen", tenantName: "-acme", environment: "dev", wantMessage: "must start with a lower case letter"},
+		{name: "trailing hyphen", tenantName: "acme", environment: "dev-", wantMessage: "can not end with '-'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.TenantName = tt.tenantName
+			message.Environment = tt.environment
+			err := message.ValidateAsProjectID()
+			if tt.wantMessage == "" {
+				wantFieldError(t, err, "")
+				return
+			}
+			wantFieldError(t, err, "tenantName")
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestProjectIDTenantName(t *testing.T) {
+	tests := []struct {
+		name      string
+		required  bool
+		wantField string
+	}{
+		{name: "required", required: true, wantField: "tenantName"},
+		{name: "not required", required: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A tenant name valid under a raised length limit, whose project ID is
+			// over 30 characters
+			message := validInstructions()
+			message.TenantName = "abcdefghijklmnopqrstuvwxyza"
+			_, err := message.DryRun(validAttributes(), WithMaxTenantNameLength(30), WithProjectIDTenantName(tt.required))
+			wantFieldError(t, err, tt.wantField)
+		})
+	}
+}
+
+func TestNamespaceAttribute(t *testing.T) {
+	long := strings.Repeat("a", 60)
+	tests := []struct {
+		name        string
+		enabled     bool
+		tenantName  string
+		environment string
+		opts        []Option
+		// wantErr is whether the namespace is rejected
+		wantErr bool
+	}{
+		{name: "dev tenant", enabled: true, tenantName: "acme", environment: "dev"},
+		{name: "prod tenant", enabled: true, tenantName: "contract-tenant", environment: "prod"},
+		{name: "disabled", tenantName: "acme", environment: "dev"},
+		{
+			name:        "namespace over 63 characters",
+			enabled:     true,
+			tenantName:  long,
+			environment: "prod",
+			opts:        []Option{WithMaxTenantNameLength(len(long))},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, append(tt.opts, WithNamespaceAttribute(tt.enabled))...)
+			message := validInstructions()
+			message.TenantName, message.Environment = tt.tenantName, tt.environment
+
+			_, err := p.PublishContext(context.Background(), &message, validAttributes())
+			if tt.wantErr {
+				wantFieldError(t, err, "namespace")
+				if got := len(topic.published()); got != 0 {
+					t.Errorf("published %d messages, want none", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+
+			got, ok := topic.published()[0].Attributes["namespace"]
+			if !tt.enabled {
+				if ok {
+					t.Errorf("namespace attribute %q published while disabled", got)
+				}
+				return
+			}
+			// The attribute agrees with the names subscribers derive themselves
+			if want := message.ResourceNames().Namespace; got != want {
+				t.Errorf("namespace attribute = %q, want ResourceNames namespace %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retryableCodes are the gRPC codes a publish is retried on. Every other code, such
+// as InvalidArgument or PermissionDenied, fails immediately.
+var retryableCodes = []codes.Code{
+	codes.Unavailable,
+	codes.DeadlineExceeded,
+	codes.ResourceExhausted,
+	codes.Aborted,
+	codes.Internal,
+}
+
+// isRetryable reports whether err from a publish result is a transient gRPC error
+func isRetryable(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	for _, code := range retryableCodes {
+		if s.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// isOrderingNotSupported reports whether err from a publish result means the topic
+// handle or the topic can not publish with an ordering key
+func isOrderingNotSupported(err error) bool {
+	msg := err.Error()
+	if strings.Contains(msg, "EnableMessageOrdering=false") {
+		return true
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	return (s.Code() == codes.InvalidArgument || s.Code() == codes.FailedPrecondition) && strings.Contains(strings.ToLower(s.Message()), "ordering")
+}
+
+// publishError wraps the final error of a failed publish in a TransportError, also
+// matching ErrOrderingNotSupported when ordering is the cause
+func publishError(topicID string, err error) error {
+	if isOrderingNotSupported(err) {
+		err = fmt.Errorf("%w: topic %s must have message ordering enabled to publish with ordering keys: %w", ErrOrderingNotSupported, topicID, err)
+	}
+	return &TransportError{Op: "publish", Err: err}
+}
+
+// waitBackoff sleeps for backoff, returning false without waiting when ctx is done or
+// its deadline would pass first
+func waitBackoff(ctx context.Context, backoff time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+		return false
+	}
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
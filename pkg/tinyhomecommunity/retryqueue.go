@@ -0,0 +1,70 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"time"
+)
+
+// FailedMessage is a message that could not be published, kept so a background
+// reprocessor can try it again later
+type FailedMessage struct {
+	Data       []byte            `json:"data"`
+	Attributes map[string]string `json:"attributes"`
+	Error      string            `json:"error"`
+	FailedAt   time.Time         `json:"failedAt"`
+}
+
+// RetryQueue receives messages whose publish failed. Implementations may be backed
+// by a channel, a database or any other store a reprocessor can drain.
+type RetryQueue interface {
+	Enqueue(message FailedMessage) error
+}
+
+// enqueueFailed hands a failed message to the RetryQueue of p, if any
+func (p *Publisher) enqueueFailed(data []byte, attributes map[string]string, publishErr error) error {
+	q := p.cfg.RetryQueue
+	if q == nil {
+		return nil
+	}
+
+	err := q.Enqueue(FailedMessage{
+		Data:       data,
+		Attributes: attributes,
+		Error:      publishErr.Error(),
+		FailedAt:   time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("retry queue: %v", err)
+	}
+	return nil
+}
+
+// MemoryRetryQueue is an in-memory RetryQueue backed by a buffered channel
+type MemoryRetryQueue struct {
+	messages chan FailedMessage
+}
+
+// NewMemoryRetryQueue returns a MemoryRetryQueue holding up to size messages
+func NewMemoryRetryQueue(size int) *MemoryRetryQueue {
+	return &MemoryRetryQueue{messages: make(chan FailedMessage, size)}
+}
+
+// Enqueue adds the message to the queue, failing rather than blocking when it is full
+func (q *MemoryRetryQueue) Enqueue(message FailedMessage) error {
+	select {
+	case q.messages <- message:
+		return nil
+	default:
+		return fmt.Errorf("memory retry queue is full")
+	}
+}
+
+// Messages returns the channel a reprocessor reads failed messages from
+func (q *MemoryRetryQueue) Messages() <-chan FailedMessage {
+	return q.messages
+}
+
+// Len returns the number of messages waiting in the queue
+func (q *MemoryRetryQueue) Len() int {
+	return len(q.messages)
+}
@@ -0,0 +1,84 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryQueue(t *testing.T) {
+	tests := []struct {
+		name string
+		// failures is the number of publishes that fail before one succeeds
+		failures     int
+		code         codes.Code
+		wantAttempts int
+		wantQueued   bool
+	}{
+		{"published", 0, codes.OK, 1, false},
+		{"retried then published", 2, codes.Unavailable, 3, false},
+		{"retries exhausted", 5, codes.Unavailable, 3, true},
+		{"permanent failure", 5, codes.PermissionDenied, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{result: func(n int, msg *pubsub.Message) publishResult {
+				if n <= tt.failures {
+					return fakeResult{err: status.Error(tt.code, "publish failed")}
+				}
+				return fakeResult{id: "published-id"}
+			}}
+			queue := NewMemoryRetryQueue(10)
+			p := newTestPublisher(t, topic, nil, WithRetry(3, time.Millisecond), WithRetryQueue(queue))
+
+			message := validInstructions()
+			_, err := p.PublishContext(context.Background(), &message, validAttributes())
+			if got := len(topic.published()); got != tt.wantAttempts {
+				t.Errorf("%d publish attempts, want %d", got, tt.wantAttempts)
+			}
+
+			if !tt.wantQueued {
+				if err != nil {
+					t.Fatalf("PublishContext: %v", err)
+				}
+				if queue.Len() != 0 {
+					t.Errorf("%d messages queued for a successful publish", queue.Len())
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("PublishContext returned no error")
+			}
+			if queue.Len() != 1 {
+				t.Fatalf("%d messages queued, want 1", queue.Len())
+			}
+			failed := <-queue.Messages()
+			sent := topic.published()[0]
+			if string(failed.Data) != string(sent.Data) || failed.Attributes["tenantName"] != sent.Attributes["tenantName"] {
+				t.Errorf("queued message does not match the one published")
+			}
+			if failed.Error == "" || failed.FailedAt.IsZero() {
+				t.Errorf("queued message has Error %q and FailedAt %v, want both set", failed.Error, failed.FailedAt)
+			}
+		})
+	}
+}
+
+func TestMemoryRetryQueueFull(t *testing.T) {
+	queue := NewMemoryRetryQueue(1)
+	if err := queue.Enqueue(FailedMessage{}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := queue.Enqueue(FailedMessage{}); err == nil {
+		t.Fatal("Enqueue on a full queue returned no error")
+	}
+	if queue.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", queue.Len())
+	}
+}
@@ -0,0 +1,125 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// RoleOrderPolicy decides how AddlGkeTenantSaRoles that are unsorted or contain
+// duplicates are handled
+type RoleOrderPolicy int
+
+const (
+	// RoleOrderAsIs publishes the roles in the order given
+	RoleOrderAsIs RoleOrderPolicy = iota
+	// RoleOrderFix sorts and dedupes the roles before publishing, the fixed slice is
+	// left on the instructions
+	RoleOrderFix
+	// RoleOrderStrict rejects roles that are unsorted or contain duplicates
+	RoleOrderStrict
+	// RoleOrderDedupe removes duplicate roles, keeping the first of each in the
+	// order given. The deduped slice is left on the instructions.
+	RoleOrderDedupe
+)
+
+// applyRoleOrderPolicy sorts and dedupes or validates AddlGkeTenantSaRoles per policy
+func (message *TinyHomeInstructions) applyRoleOrderPolicy(policy RoleOrderPolicy) error {
+	switch policy {
+	case RoleOrderFix:
+		message.AddlGkeTenantSaRoles = sortedUnique(message.AddlGkeTenantSaRoles)
+	case RoleOrderStrict:
+		return validateRoleOrder(message.AddlGkeTenantSaRoles)
+	case RoleOrderDedupe:
+		message.AddlGkeTenantSaRoles = unique(message.AddlGkeTenantSaRoles)
+	}
+	return nil
+}
+
+// validateRoleOrder requires roles to be sorted and unique, reporting every duplicate
+func validateRoleOrder(roles []string) error {
+	if err := validateUniqueRoles(roles); err != nil {
+		return err
+	}
+
+	if !sort.StringsAreSorted(roles) {
+		return &IAMError{Field: "addlGkeTenantSaRoles", Message: "addlGkeTenantSaRoles must be sorted"}
+	}
+	return nil
+}
+
+// validateUniqueRoles rejects roles listed more than once, reporting every repeated
+// role
+func validateUniqueRoles(roles []string) error {
+	var duplicates []string
+	seen := map[string]bool{}
+	for _, role := range roles {
+		if seen[role] && !contains(duplicates, role) {
+			duplicates = append(duplicates, role)
+		}
+		seen[role] = true
+	}
+	if len(duplicates) > 0 {
+		return &IAMError{Field: "addlGkeTenantSaRoles", Message: fmt.Sprintf("addlGkeTenantSaRoles has duplicate roles: %s", duplicates)}
+	}
+	return nil
+}
+
+// unique returns a copy of values with duplicates removed, keeping the first of each
+// in order
+func unique(values []string) []string {
+	if values == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// sortedUnique returns a sorted copy of values with duplicates removed
+func sortedUnique(values []string) []string {
+	if values == nil {
+		return nil
+	}
+
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+
+	unique := sorted[:0]
+	for i, v := range sorted {
+		if i == 0 || v != sorted[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+var (
+	// predefinedRolePattern matches predefined roles such as roles/storage.admin
+	predefinedRolePattern = regexp.MustCompile(`^roles/[a-zA-Z0-9_.]+$`)
+	// customRolePattern matches organization and project custom roles
+	customRolePattern = regexp.MustCompile(`^(organizations/[0-9]+|projects/[a-z][-a-z0-9]{4,28}[a-z0-9])/roles/[a-zA-Z0-9_.]{3,64}$`)
+)
+
+// validateSaRoles checks every AddlGkeTenantSaRoles entry is a predefined or custom
+// IAM role name and appears only once, listing every repeated role
+func (message TinyHomeInstructions) validateSaRoles() error {
+	for i, role := range message.AddlGkeTenantSaRoles {
+		field := fmt.Sprintf("addlGkeTenantSaRoles[%d]", i)
+		if role == "" {
+			return &IAMError{Field: field, Message: fmt.Sprintf("%s can not be empty", field)}
+		}
+
+		if !predefinedRolePattern.MatchString(role) && !customRolePattern.MatchString(role) {
+			return &IAMError{Field: field, Message: fmt.Sprintf("%s %q is not an IAM role, expected roles/..., organizations/.../roles/... or projects/.../roles/...", field, role)}
+		}
+	}
+	return validateUniqueRoles(message.AddlGkeTenantSaRoles)
+}
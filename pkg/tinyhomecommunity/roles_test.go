@@ -0,0 +1,175 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRoleOrder(t *testing.T) {
+	const (
+		logWriter    = "roles/logging.logWriter"
+		metricWriter = "roles/monitoring.metricWriter"
+		traceAgent   = "roles/cloudtrace.agent"
+	)
+
+	tests := []struct {
+		name   string
+		policy RoleOrderPolicy
+		roles  []string
+		// want are the roles published and left on the instructions
+		want []string
+		// wantMessage is part of the error, empty when the roles are accepted
+		wantMessage string
+	}{
+		{name: "as is keeps the order", policy: RoleOrderAsIs, roles: []string{metricWriter, logWriter}, want: []string{metricWriter, logWriter}},
+		{name: "as is still rejects duplicates", policy: RoleOrderAsIs, roles: []string{logWriter, logWriter}, wantMessage: "duplicate roles: [" + logWriter + "]"},
+		{name: "fix sorts", policy: RoleOrderFix, roles: []string{metricWriter, traceAgent, logWriter}, want: []string{traceAgent, logWriter, metricWriter}},
+		{name: "fix dedupes", policy: RoleOrderFix, roles: []string{metricWriter, logWriter, metricWriter, logWriter}, want: []string{logWriter, metricWriter}},
+		{name: "fix leaves sorted roles", policy: RoleOrderFix, roles: []string{logWriter, metricWriter}, want: []string{logWriter, metricWriter}},
+		{name: "fix no roles", policy: RoleOrderFix},
+		{name: "strict accepts sorted unique roles", policy: RoleOrderStrict, roles: []string{traceAgent, logWriter}, want: []string{traceAgent, logWriter}},
+		{name: "strict rejects unsorted roles", policy: RoleOrderStrict, roles: []string{metricWriter, logWriter}, wantMessage: "addlGkeTenantSaRoles must be sorted"},
+		{
+			name:        "strict reports every duplicate",
+			policy:      RoleOrderStrict,
+			roles:       []string{logWriter, logWriter, metricWriter, metricWriter, metricWriter},
+			wantMessage: "duplicate roles: [" + logWriter + " " + metricWriter + "]",
+		},
+		{name: "dedupe keeps the first of each", policy: RoleOrderDedupe, roles: []string{metricWriter, logWriter, metricWriter}, want: []string{metricWriter, logWriter}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithRoleOrder(tt.policy))
+			message := validInstructions()
+			message.AddlGkeTenantSaRoles = tt.roles
+			given := slices.Clone(tt.roles)
+
+			_, err := p.PublishContext(context.Background(), &message, validAttributes())
+			if tt.wantMessage != "" {
+				wantFieldError(t, err, "addlGkeTenantSaRoles")
+				if !strings.Contains(err.Error(), tt.wantMessage) {
+					t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+
+			var published TinyHomeInstructions
+			if err := json.Unmarshal(topic.published()[0].Data, &published); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(published.AddlGkeTenantSaRoles, tt.want) {
+				t.Errorf("published roles %v, want %v", published.AddlGkeTenantSaRoles, tt.want)
+			}
+			if !slices.Equal(message.AddlGkeTenantSaRoles, tt.want) {
+				t.Errorf("returned roles %v, want %v", message.AddlGkeTenantSaRoles, tt.want)
+			}
+			// The caller's slice is replaced, not sorted in place
+			if !slices.Equal(tt.roles, given) {
+				t.Errorf("caller's roles changed to %v", tt.roles)
+			}
+		})
+	}
+}
+
+func TestSaRoles(t *testing.T) {
+	tests := []struct {
+		name      string
+		roles     []string
+		wantField string
+		// wantMessage is part of the error message
+		wantMessage string
+	}{
+		{name: "none"},
+		{name: "predefined roles", roles: []string{"roles/logging.logWriter", "roles/storage.objectViewer"}},
+		{name: "organization custom role", roles: []string{"organizations/123456789012/roles/tenantDeployer"}},
+		{name: "project custom role", roles: []string{"projects/tenant-project-1/roles/custom.viewer"}},
+		{name: "empty entry", roles: []string{"roles/viewer", ""}, wantField: "addlGkeTenantSaRoles[1]", wantMessage: "addlGkeTenantSaRoles[1] can not be empty"},
+		{name: "missing prefix", roles: []string{"storage.admin"}, wantField: "addlGkeTenantSaRoles[0]", wantMessage: `"storage.admin" is not an IAM role`},
+		{name: "invalid character", roles: []string{"roles/storage admin"}, wantField: "addlGkeTenantSaRoles[0]", wantMessage: `"roles/storage admin"`},
+		{name: "non-numeric organization", roles: []string{"organizations/acme/roles/tenantDeployer"}, wantField: "addlGkeTenantSaRoles[0]"},
+		{name: "invalid project ID", roles: []string{"projects/Tenant/roles/custom.viewer"}, wantField: "addlGkeTenantSaRoles[0]"},
+		{name: "custom role ID too short", roles: []string{"projects/tenant-project-1/roles/ab"}, wantField: "addlGkeTenantSaRoles[0]"},
+		{name: "bare roles prefix", roles: []string{"roles/"}, wantField: "addlGkeTenantSaRoles[0]"},
+		{
+			name:        "duplicates",
+			roles:       []string{"roles/viewer", "roles/logging.logWriter", "roles/viewer"},
+			wantField:   "addlGkeTenantSaRoles",
+			wantMessage: "duplicate roles: [roles/viewer]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.AddlGkeTenantSaRoles = tt.roles
+			_, err := message.DryRun(validAttributes())
+			wantFieldError(t, err, tt.wantField)
+			if tt.wantMessage != "" && !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestDedupeRoles(t *testing.T) {
+	const (
+		logWriter    = "roles/logging.logWriter"
+		metricWriter = "roles/monitoring.metricWriter"
+	)
+
+	tests := []struct {
+		name string
+		opts []Option
+		// wantPolicy is the RoleOrder the options leave
+		wantPolicy RoleOrderPolicy
+		// want are the roles published, nil when the duplicates are rejected
+		want []string
+	}{
+		{name: "enabled dedupes in order", opts: []Option{WithDedupeRoles(true)}, wantPolicy: RoleOrderDedupe, want: []string{metricWriter, logWriter}},
+		{name: "disabled rejects duplicates", opts: []Option{WithDedupeRoles(false)}, wantPolicy: RoleOrderAsIs},
+		{name: "disabled after enabled", opts: []Option{WithDedupeRoles(true), WithDedupeRoles(false)}, wantPolicy: RoleOrderAsIs},
+		{name: "enabled over another policy", opts: []Option{WithRoleOrder(RoleOrderStrict), WithDedupeRoles(true)}, wantPolicy: RoleOrderDedupe, want: []string{metricWriter, logWriter}},
+		{name: "disabled keeps another policy", opts: []Option{WithRoleOrder(RoleOrderFix), WithDedupeRoles(false)}, wantPolicy: RoleOrderFix, want: []string{logWriter, metricWriter}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultPublisherConfig()
+			for _, opt := range tt.opts {
+				opt(&cfg)
+			}
+			if cfg.RoleOrder != tt.wantPolicy {
+				t.Errorf("RoleOrder = %v, want %v", cfg.RoleOrder, tt.wantPolicy)
+			}
+
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, tt.opts...)
+			message := validInstructions()
+			message.AddlGkeTenantSaRoles = []string{metricWriter, logWriter, metricWriter}
+
+			_, err := p.PublishContext(context.Background(), &message, validAttributes())
+			if tt.want == nil {
+				wantFieldError(t, err, "addlGkeTenantSaRoles")
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+			var published TinyHomeInstructions
+			if err := json.Unmarshal(topic.published()[0].Data, &published); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(published.AddlGkeTenantSaRoles, tt.want) {
+				t.Errorf("published roles %v, want %v", published.AddlGkeTenantSaRoles, tt.want)
+			}
+		})
+	}
+}
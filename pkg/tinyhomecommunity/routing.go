@@ -0,0 +1,93 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+)
+
+// SubscriptionRule routes one combination of the four created attributes to a
+// subscription
+type SubscriptionRule struct {
+	GroupsCreated    bool
+	WorkspaceCreated bool
+	TenantCreated    bool
+	FluxCreated      bool
+	Subscription     string
+}
+
+// DefaultSubscriptionRules is the decision table from the created attributes to the
+// pipeline stage subscriptions. Combinations not listed route nowhere.
+var DefaultSubscriptionRules = []SubscriptionRule{
+	{false, false, false, false, "createGroups"},
+	{true, false, false, false, "createWorkspace"},
+	{true, true, false, false, "createTenant"},
+	{true, true, true, false, "createFlux"},
+	{true, true, true, true, "deliverEmail"},
+}
+
+// routeSubscription returns the subscription of the rule matching the attributes,
+// which must already be valid "true" or "false" values
+func (messageAttributes *TinyHomeMessageAttributes) routeSubscription(rules []SubscriptionRule) (string, bool) {
+	for _, rule := range rules {
+		if messageAttributes.GroupsCreated == boolAttribute(rule.GroupsCreated) &&
+			messageAttributes.WorkspaceCreated == boolAttribute(rule.WorkspaceCreated) &&
+			messageAttributes.TenantCreated == boolAttribute(rule.TenantCreated) &&
+			messageAttributes.FluxCreated == boolAttribute(rule.FluxCreated) {
+			return rule.Subscription, true
+		}
+	}
+	return "", false
+}
+
+// staircaseError explains attributes no rule routes because a later stage is marked
+// created while an earlier one is not, e.g. tenantCreated true with workspaceCreated
+// false. It returns nil when the created attributes are in pipeline order.
+func (messageAttributes *TinyHomeMessageAttributes) staircaseError() error {
+	flags := []struct{ key, value string }{
+		{AttrGroupsCreated, messageAttributes.GroupsCreated},
+		{AttrWorkspaceCreated, messageAttributes.WorkspaceCreated},
+		{AttrTenantCreated, messageAttributes.TenantCreated},
+		{AttrFluxCreated, messageAttributes.FluxCreated},
+	}
+
+	for i, later := range flags {
+		if later.value != "true" {
+			continue
+		}
+		for _, earlier := range flags[:i] {
+			if earlier.value == "false" {
+				return &RoutingError{Field: later.key, Message: fmt.Sprintf("message attribute %s is true but %s is false, a later stage can not be created before an earlier one", later.key, earlier.key)}
+			}
+		}
+	}
+	return nil
+}
+
+// boolAttribute formats b as an attribute value
+func boolAttribute(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// validateSubscriptionRules requires at least one rule, each naming a subscription,
+// and no combination listed twice
+func validateSubscriptionRules(rules []SubscriptionRule) error {
+	if len(rules) == 0 {
+		return fmt.Errorf("at least one subscription rule is required")
+	}
+
+	seen := map[[4]bool]string{}
+	for _, rule := range rules {
+		if rule.Subscription == "" {
+			return fmt.Errorf("subscription rule %+v has no subscription", rule)
+		}
+
+		key := [4]bool{rule.GroupsCreated, rule.WorkspaceCreated, rule.TenantCreated, rule.FluxCreated}
+		if first, ok := seen[key]; ok {
+			return fmt.Errorf("subscription rules %s and %s match the same attributes", first, rule.Subscription)
+		}
+		seen[key] = rule.Subscription
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSubscriptionTruthTable(t *testing.T) {
+	tests := []struct {
+		groups, workspace, tenant, flux bool
+		// want is the subscription, empty when the combination routes nowhere
+		want string
+	}{
+		{false, false, false, false, "createGroups"},
+		{false, false, false, true, ""},
+		{false, false, true, false, ""},
+		{false, false, true, true, ""},
+		{false, true, false, false, ""},
+		{false, true, false, true, ""},
+		{false, true, true, false, ""},
+		{false, true, true, true, ""},
+		{true, false, false, false, "createWorkspace"},
+		{true, false, false, true, ""},
+		{true, false, true, false, ""},
+		{true, false, true, true, ""},
+		{true, true, false, false, "createTenant"},
+		{true, true, false, true, ""},
+		{true, true, true, false, "createFlux"},
+		{true, true, true, true, "deliverEmail"},
+	}
+
+	for _, tt := range tests {
+		attrs := &TinyHomeMessageAttributes{
+			GroupsCreated:    boolAttribute(tt.groups),
+			WorkspaceCreated: boolAttribute(tt.workspace),
+			TenantCreated:    boolAttribute(tt.tenant),
+			FluxCreated:      boolAttribute(tt.flux),
+			DeliveredFrom:    "galaxy",
+		}
+		name := attrs.GroupsCreated + "/" + attrs.WorkspaceCreated + "/" + attrs.TenantCreated + "/" + attrs.FluxCreated
+		t.Run(name, func(t *testing.T) {
+			got, err := attrs.Subscription()
+			if tt.want == "" {
+				// Every combination the table leaves out has a later stage created
+				// before an earlier one
+				if err == nil || !strings.Contains(err.Error(), "a later stage can not be created before an earlier one") {
+					t.Fatalf("Subscription = %q, %v, want a stage order error", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Subscription = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaircaseError(t *testing.T) {
+	tests := []struct {
+		name                            string
+		groups, workspace, tenant, flux string
+		// wantField is the attribute the RoutingError names, empty when the stages
+		// are in order
+		wantField string
+		want      string
+	}{
+		{name: "nothing created", groups: "false", workspace: "false", tenant: "false", flux: "false"},
+		{name: "in order", groups: "true", workspace: "true", tenant: "false", flux: "false"},
+		{name: "all created", groups: "true", workspace: "true", tenant: "true", flux: "true"},
+		{
+			name: "workspace before groups", groups: "false", workspace: "true", tenant: "false", flux: "false",
+			wantField: AttrWorkspaceCreated,
+			want:      "message attribute workspaceCreated is true but groupsCreated is false, a later stage can not be created before an earlier one",
+		},
+		{
+			name: "tenant before workspace", groups: "true", workspace: "false", tenant: "true", flux: "false",
+			wantField: AttrTenantCreated,
+			want:      "message attribute tenantCreated is true but workspaceCreated is false, a later stage can not be created before an earlier one",
+		},
+		{
+			name: "flux alone names the earliest stage missing", groups: "false", workspace: "false", tenant: "false", flux: "true",
+			wantField: AttrFluxCreated,
+			want:      "message attribute fluxCreated is true but groupsCreated is false, a later stage can not be created before an earlier one",
+		},
+		{
+			name: "first out of order stage reported", groups: "true", workspace: "false", tenant: "true", flux: "true",
+			wantField: AttrTenantCreated,
+			want:      "message attribute tenantCreated is true but workspaceCreated is false, a later stage can not be created before an earlier one",
+		},
+		{
+			// Values other than true and false are left to valueErrors
+			name: "invalid earlier value", groups: "yes", workspace: "true", tenant: "false", flux: "false",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attrs := &TinyHomeMessageAttributes{GroupsCreated: tt.groups, WorkspaceCreated: tt.workspace, TenantCreated: tt.tenant, FluxCreated: tt.flux, DeliveredFrom: "galaxy"}
+			err := attrs.staircaseError()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("staircaseError = %v, want nil", err)
+				}
+				return
+			}
+			var routingErr *RoutingError
+			if !errors.As(err, &routingErr) {
+				t.Fatalf("staircaseError = %T %v, want a *RoutingError", err, err)
+			}
+			if routingErr.FieldName() != tt.wantField {
+				t.Errorf("staircaseError field = %q, want %q", routingErr.FieldName(), tt.wantField)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("staircaseError = %q, want %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionRules(t *testing.T) {
+	all := &TinyHomeMessageAttributes{GroupsCreated: "true", WorkspaceCreated: "true", TenantCreated: "true", FluxCreated: "true", DeliveredFrom: "galaxy"}
+	tenant := &TinyHomeMessageAttributes{GroupsCreated: "true", WorkspaceCreated: "true", TenantCreated: "false", FluxCreated: "false", DeliveredFrom: "galaxy"}
+	// A new stage in place of deliverEmail
+	monitoring := append(append([]SubscriptionRule(nil), DefaultSubscriptionRules[:4]...), SubscriptionRule{true, true, true, true, "createMonitoring"})
+
+	tests := []struct {
+		name  string
+		rules []SubscriptionRule
+		attrs *TinyHomeMessageAttributes
+		// want is the subscription, empty when no rule matches
+		want string
+	}{
+		{name: "new stage", rules: monitoring, attrs: all, want: "createMonitoring"},
+		{name: "existing stage", rules: monitoring, attrs: tenant, want: "createTenant"},
+		{name: "no matching rule", rules: DefaultSubscriptionRules[:2], attrs: tenant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPublisher(t, &fakeTopic{}, nil, WithSubscriptionRules(tt.rules))
+			message := validInstructions()
+			message.Domain = "example.com"
+			result, err := p.PublishWithResult(context.Background(), &message, tt.attrs)
+			if tt.want == "" {
+				if err == nil || !strings.Contains(err.Error(), "message attributes not set for known subscription") {
+					t.Fatalf("PublishWithResult error = %v, want the unknown subscription error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishWithResult: %v", err)
+			}
+			if result.Subscription != tt.want {
+				t.Errorf("Subscription = %q, want %q", result.Subscription, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionRulesValidated(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []SubscriptionRule
+		wantErr bool
+	}{
+		{name: "defaults", rules: DefaultSubscriptionRules},
+		{name: "empty", rules: []SubscriptionRule{}, wantErr: true},
+		{name: "no subscription", rules: []SubscriptionRule{{false, false, false, false, ""}}, wantErr: true},
+		{
+			name:    "overlapping rules",
+			rules:   []SubscriptionRule{{false, false, false, false, "createGroups"}, {false, false, false, false, "createOther"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithSubscriptionRules(tt.rules))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPublisherWithTopic error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package tinyhomecommunity
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// quantityPattern matches the Kubernetes resource quantities validateQuota accepts,
+// or an empty value
+const quantityPattern = `^$|^[+-]?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))(([KMGTPE]i)|[numkMGTPE]|([eE][+-]?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))))?$`
+
+// schemaConstraints are the validateInstructions rules of cfg expressed as JSON
+// Schema keywords, keyed by the json path of the property they apply to
+func schemaConstraints(cfg PublisherConfig) map[string]map[string]interface{} {
+	constraints := map[string]map[string]interface{}{
+		"tenantName": {
+			"minLength": cfg.MinTenantNameLength,
+			"maxLength": cfg.MaxTenantNameLength,
+			"pattern":   "^[a-z]([a-z0-9" + strings.Join(supportedSpecialChars, "") + "]*[a-z0-9])?$",
+		},
+		"environment":      {"enum": cfg.Environments},
+		"businessUnit":     {"minLength": 1},
+		"tenantOwner":      {"minLength": 1, "format": "email"},
+		"tenantCostCenter": {"minLength": 1},
+		"organization":     {"minLength": 1},
+		// An empty priority is published as PriorityNormal
+		"priority": {"enum": append([]string{""}, priorityVals...)},
+		"labels": {
+			"maxProperties": maxLabels,
+			"propertyNames": map[string]interface{}{"pattern": fmt.Sprintf("^[a-z][a-z0-9_-]{0,%d}$", maxLabelLength-1)},
+		},
+		"labels{}": {"pattern": fmt.Sprintf("^[a-z0-9_-]{0,%d}$", maxLabelLength)},
+	}
+
+	if len(cfg.BusinessUnits) > 0 {
+		constraints["businessUnit"] = map[string]interface{}{"enum": cfg.BusinessUnits}
+	}
+
+	// TenantOwnerSecondary is optional, but an email address when set
+	constraints["tenantOwnerSecondary"] = map[string]interface{}{
+		"anyOf": []map[string]interface{}{{"const": ""}, {"format": "email"}},
+	}
+
+	if cfg.OwnerDomain != "" {
+		suffix := regexp.QuoteMeta(strings.ToLower(cfg.OwnerDomain)) + "$"
+		constraints["tenantOwner"]["pattern"] = suffix
+		constraints["tenantOwnerSecondary"]["anyOf"] = []map[string]interface{}{{"const": ""}, {"format": "email", "pattern": suffix}}
+	}
+
+	if cfg.CostCenterPattern != nil {
+		constraints["tenantCostCenter"]["pattern"] = cfg.CostCenterPattern.String()
+	}
+
+	if cfg.OrganizationSlug {
+		constraints["organization"]["pattern"] = "^[a-z0-9]+(-[a-z0-9]+)*$"
+	} else if cfg.OrganizationPattern != nil {
+		constraints["organization"]["pattern"] = cfg.OrganizationPattern.String()
+	}
+
+	for _, field := range (TinyHomeInstructions{}).quotaFields() {
+		constraints[field.name] = map[string]interface{}{"pattern": quantityPattern}
+	}
+	return constraints
+}
+
+// InstructionsJSONSchema returns a JSON Schema document describing TinyHomeInstructions.
+// Properties are generated from the struct so the schema follows the wire format, and
+// the validation rules are layered on top from the same limits validateInstructions
+// uses with DefaultPublisherConfig.
+func InstructionsJSONSchema() []byte {
+	return instructionsJSONSchema(DefaultPublisherConfig())
+}
+
+// InstructionsJSONSchemaFor is InstructionsJSONSchema with the rules of the config
+// opts build, such as WithEnvironments or WithMaxTenantNameLength, so it matches a
+// Publisher created with the same opts
+func InstructionsJSONSchemaFor(opts ...Option) ([]byte, error) {
+	cfg, err := newPublisherConfig(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("InstructionsJSONSchemaFor: %v", err)
+	}
+	return instructionsJSONSchema(cfg), nil
+}
+
+func instructionsJSONSchema(cfg PublisherConfig) []byte {
+	schema := jsonSchemaFor(reflect.TypeOf(TinyHomeInstructions{}), "", schemaConstraints(cfg))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "TinyHomeInstructions"
+
+	// The schema is built from static types only, so marshaling can not fail
+	b, _ := json.MarshalIndent(schema, "", "  ")
+	return b
+}
+
+func jsonSchemaFor(t reflect.Type, path string, constraints map[string]map[string]interface{}) map[string]interface{} {
+	schema := map[string]interface{}{}
+
+	switch t.Kind() {
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		schema["type"] = "integer"
+	case reflect.Slice:
+		schema["type"] = "array"
+		schema["items"] = jsonSchemaFor(t.Elem(), path+"[]", constraints)
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = jsonSchemaFor(t.Elem(), path+"{}", constraints)
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" || field.PkgPath != "" {
+				continue
+			}
+
+			propertyPath := name
+			if path != "" {
+				propertyPath = path + "." + name
+			}
+			properties[name] = jsonSchemaFor(field.Type, propertyPath, constraints)
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+	}
+
+	for keyword, value := range constraints[path] {
+		schema[keyword] = value
+	}
+	return schema
+}
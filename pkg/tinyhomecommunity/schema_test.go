@@ -0,0 +1,173 @@
+package tinyhomecommunity
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// schemaNode walks the properties of a decoded JSON Schema by json path, as used by
+// schemaConstraints
+func schemaNode(t *testing.T, schema map[string]interface{}, path string) map[string]interface{} {
+	t.Helper()
+	node := schema
+	for _, name := range strings.Split(path, ".") {
+		for strings.HasSuffix(name, "[]") || strings.HasSuffix(name, "{}") {
+			name = name[:len(name)-2]
+		}
+		properties, _ := node["properties"].(map[string]interface{})
+		next, ok := properties[name].(map[string]interface{})
+		if !ok {
+			t.Fatalf("schema has no property %s", path)
+		}
+		node = next
+	}
+	return node
+}
+
+// checkSchemaNode fails unless node and every node below it has a known type and
+// every pattern compiles
+func checkSchemaNode(t *testing.T, node map[string]interface{}, path string) {
+	t.Helper()
+	switch node["type"] {
+	case "string", "boolean", "integer":
+	case "array":
+		checkSchemaNode(t, node["items"].(map[string]interface{}), path+"[]")
+	case "object":
+		if properties, ok := node["properties"].(map[string]interface{}); ok {
+			for name, property := range properties {
+				checkSchemaNode(t, property.(map[string]interface{}), strings.TrimPrefix(path+"."+name, "."))
+			}
+		} else {
+			checkSchemaNode(t, node["additionalProperties"].(map[string]interface{}), path+"{}")
+		}
+	default:
+		t.Errorf("%s has type %v", path, node["type"])
+	}
+
+	if pattern, ok := node["pattern"].(string); ok {
+		if _, err := regexp.Compile(pattern); err != nil {
+			t.Errorf("%s pattern %q does not compile: %v", path, pattern, err)
+		}
+	}
+}
+
+// jsonFieldPaths returns the json paths of every field of t, nested ones included
+func jsonFieldPaths(t reflect.Type, prefix string) []string {
+	var paths []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || field.PkgPath != "" {
+			continue
+		}
+		path := strings.TrimPrefix(prefix+"."+name, ".")
+		paths = append(paths, path)
+		if field.Type.Kind() == reflect.Struct {
+			paths = append(paths, jsonFieldPaths(field.Type, path)...)
+		}
+	}
+	return paths
+}
+
+func decodeSchema(t *testing.T, b []byte) map[string]interface{} {
+	t.Helper()
+	var schema map[string]interface{}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	return schema
+}
+
+func TestInstructionsJSONSchema(t *testing.T) {
+	schema := decodeSchema(t, InstructionsJSONSchema())
+	if schema["$schema"] != "https://json-schema.org/draft/2020-12/schema" || schema["title"] != "TinyHomeInstructions" {
+		t.Errorf("schema $schema, title = %v, %v", schema["$schema"], schema["title"])
+	}
+	checkSchemaNode(t, schema, "")
+
+	for _, path := range jsonFieldPaths(reflect.TypeOf(TinyHomeInstructions{}), "") {
+		schemaNode(t, schema, path)
+	}
+}
+
+func TestInstructionsJSONSchemaConstraints(t *testing.T) {
+	schema := decodeSchema(t, InstructionsJSONSchema())
+	cfg := DefaultPublisherConfig()
+
+	tests := []struct {
+		path    string
+		keyword string
+		want    interface{}
+	}{
+		{"tenantName", "minLength", float64(cfg.MinTenantNameLength)},
+		{"tenantName", "maxLength", float64(cfg.MaxTenantNameLength)},
+		{"tenantCostCenter", "pattern", DefaultCostCenterPattern.String()},
+		{"nsQuota.requests.cpu", "pattern", quantityPattern},
+		{"tenantOwner", "format", "email"},
+		{"labels", "maxProperties", float64(maxLabels)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.keyword, func(t *testing.T) {
+			if got := schemaNode(t, schema, tt.path)[tt.keyword]; got != tt.want {
+				t.Errorf("%s %s = %v, want %v", tt.path, tt.keyword, got, tt.want)
+			}
+		})
+	}
+
+	enums := []struct {
+		path string
+		want []string
+	}{
+		{"environment", DefaultEnvironments},
+		{"priority", append([]string{""}, priorityVals...)},
+	}
+	for _, tt := range enums {
+		var got []string
+		for _, v := range schemaNode(t, schema, tt.path)["enum"].([]interface{}) {
+			got = append(got, v.(string))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s enum = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+// The tenantName pattern agrees with the tenant name validation
+func TestInstructionsJSONSchemaTenantNamePattern(t *testing.T) {
+	pattern := regexp.MustCompile(schemaNode(t, decodeSchema(t, InstructionsJSONSchema()), "tenantName")["pattern"].(string))
+
+	for _, name := range []string{"acme", "acme-dev", "a1b2c3", "1acme", "-acme", "acme-", "Acme", "acme_dev"} {
+		message := validInstructions()
+		message.TenantName = name
+		_, err := message.DryRun(validAttributes())
+		if valid := err == nil; pattern.MatchString(name) != valid {
+			t.Errorf("tenantName %q: pattern match %v, validation error %v", name, pattern.MatchString(name), err)
+		}
+	}
+}
+
+func TestInstructionsJSONSchemaFor(t *testing.T) {
+	b, err := InstructionsJSONSchemaFor(WithMaxTenantNameLength(30), WithEnvironments([]string{"dev", "prod"}), WithOrganizationSlug(true))
+	if err != nil {
+		t.Fatalf("InstructionsJSONSchemaFor: %v", err)
+	}
+	schema := decodeSchema(t, b)
+
+	if got := schemaNode(t, schema, "tenantName")["maxLength"]; got != float64(30) {
+		t.Errorf("tenantName maxLength = %v, want 30", got)
+	}
+	if got := schemaNode(t, schema, "environment")["enum"]; !reflect.DeepEqual(got, []interface{}{"dev", "prod"}) {
+		t.Errorf("environment enum = %v, want [dev prod]", got)
+	}
+	if got := schemaNode(t, schema, "organization")["pattern"]; got != "^[a-z0-9]+(-[a-z0-9]+)*$" {
+		t.Errorf("organization pattern = %v, want the slug pattern", got)
+	}
+
+	if _, err := InstructionsJSONSchemaFor(WithMaxTenantNameLength(-1)); err == nil {
+		t.Error("InstructionsJSONSchemaFor with an invalid config returned no error")
+	}
+}
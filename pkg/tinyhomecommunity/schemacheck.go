@@ -0,0 +1,166 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// topicSchema is the Pub/Sub schema bound to a topic, if any
+type topicSchema struct {
+	config   *pubsub.SchemaConfig
+	encoding pubsub.SchemaEncoding
+}
+
+// schemaService is the part of the Pub/Sub topic and schema APIs
+// ValidateAgainstSchema depends on, so tests can use an in-memory fake
+type schemaService interface {
+	// topicSchema returns the schema bound to topic id, a zero topicSchema when the
+	// topic has none
+	topicSchema(ctx context.Context, id string) (topicSchema, error)
+	// validateMessage checks data conforms to schema
+	validateMessage(ctx context.Context, data []byte, schema topicSchema) error
+	close() error
+}
+
+// schemaCache holds the schema of each topic ValidateAgainstSchema has looked up
+type schemaCache struct {
+	mu      sync.Mutex
+	service schemaService
+	byTopic map[string]topicSchema
+}
+
+// pubsubSchemaService looks schemas up with the Publisher's client and a schema
+// client created from the same config
+type pubsubSchemaService struct {
+	client       *pubsub.Client
+	schemaClient *pubsub.SchemaClient
+}
+
+func (s pubsubSchemaService) topicSchema(ctx context.Context, id string) (topicSchema, error) {
+	topicConfig, err := s.client.Topic(id).Config(ctx)
+	if err != nil {
+		return topicSchema{}, fmt.Errorf("topic config: %v", err)
+	}
+
+	settings := topicConfig.SchemaSettings
+	if settings == nil {
+		return topicSchema{}, nil
+	}
+
+	// SchemaSettings holds the full resource name projects/{project}/schemas/{schema}
+	schemaId := settings.Schema[strings.LastIndex(settings.Schema, "/")+1:]
+	config, err := s.schemaClient.Schema(ctx, schemaId, pubsub.SchemaViewFull)
+	if err != nil {
+		return topicSchema{}, fmt.Errorf("schema %v: %v", schemaId, err)
+	}
+	return topicSchema{config: config, encoding: settings.Encoding}, nil
+}
+
+func (s pubsubSchemaService) validateMessage(ctx context.Context, data []byte, schema topicSchema) error {
+	_, err := s.schemaClient.ValidateMessageWithConfig(ctx, data, schema.encoding, *schema.config)
+	return err
+}
+
+func (s pubsubSchemaService) close() error {
+	return s.schemaClient.Close()
+}
+
+// ValidateAgainstSchema checks the serialized instructions conform to the Pub/Sub
+// schema bound to the topic they would be published to, so schema drift is caught
+// before publishing rather than as a rejected publish. It validates the message body
+// exactly as a publish would send it, after the same validation, on a copy of the
+// instructions. The schema is fetched once per topic and cached on the Publisher.
+// Topics without a schema always pass.
+func (p *Publisher) ValidateAgainstSchema(ctx context.Context, message *TinyHomeInstructions, messageAttributes *TinyHomeMessageAttributes) error {
+	copied, err := message.clone()
+	if err != nil {
+		return fmt.Errorf("ValidateAgainstSchema: %v", err)
+	}
+
+	prepared, err := p.prepare(ctx, copied, messageAttributes, &PublishLogLine{})
+	if err != nil {
+		return fmt.Errorf("ValidateAgainstSchema: %w", err)
+	}
+
+	service, err := p.schemaService(ctx)
+	if err != nil {
+		return fmt.Errorf("ValidateAgainstSchema: %v", err)
+	}
+
+	schema, err := p.topicSchema(ctx, service, prepared.topicID)
+	if err != nil {
+		return fmt.Errorf("ValidateAgainstSchema: %v", err)
+	}
+	if schema.config == nil {
+		return nil
+	}
+
+	if err := service.validateMessage(ctx, prepared.data, schema); err != nil {
+		return fmt.Errorf("ValidateAgainstSchema: message does not conform to schema %v: %v", schema.config.Name, err)
+	}
+	return nil
+}
+
+// ValidateAgainstSchema is Publisher.ValidateAgainstSchema with a Publisher created
+// from opts as for PublishTinyHomeInstructions. The client, and so the cached schema,
+// is not reused between calls, services that validate often should create a Publisher
+// once and reuse it instead.
+func (message *TinyHomeInstructions) ValidateAgainstSchema(ctx context.Context, messageAttributes *TinyHomeMessageAttributes, opts ...Option) error {
+	p, err := NewPublisher(ctx, DefaultPublisherConfig(), opts...)
+	if err != nil {
+		return fmt.Errorf("ValidateAgainstSchema: %w", err)
+	}
+	defer p.Close()
+
+	return p.ValidateAgainstSchema(ctx, message, messageAttributes)
+}
+
+// schemaService returns the Publisher's schema service, creating the schema client on
+// first use
+func (p *Publisher) schemaService(ctx context.Context) (schemaService, error) {
+	p.schemas.mu.Lock()
+	defer p.schemas.mu.Unlock()
+	if p.schemas.service != nil {
+		return p.schemas.service, nil
+	}
+
+	if p.client == nil {
+		return nil, fmt.Errorf("no Pub/Sub client to look up schemas with")
+	}
+
+	schemaClient, err := pubsub.NewSchemaClient(ctx, p.cfg.ProjectID, p.cfg.ClientOptions...)
+	if err != nil {
+		return nil, &TransportError{Op: "pubsub.NewSchemaClient", Err: err}
+	}
+	p.schemas.service = pubsubSchemaService{client: p.client, schemaClient: schemaClient}
+	return p.schemas.service, nil
+}
+
+// topicSchema returns the cached schema of topic id, looking it up with service on
+// first use. The lock is not held during the lookup, so concurrent first uses may
+// each look it up.
+func (p *Publisher) topicSchema(ctx context.Context, service schemaService, id string) (topicSchema, error) {
+	p.schemas.mu.Lock()
+	schema, ok := p.schemas.byTopic[id]
+	p.schemas.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema, err := service.topicSchema(ctx, id)
+	if err != nil {
+		return topicSchema{}, err
+	}
+
+	p.schemas.mu.Lock()
+	defer p.schemas.mu.Unlock()
+	if p.schemas.byTopic == nil {
+		p.schemas.byTopic = map[string]topicSchema{}
+	}
+	p.schemas.byTopic[id] = schema
+	return schema, nil
+}
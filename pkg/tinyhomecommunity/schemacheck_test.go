@@ -0,0 +1,238 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// fakeSchemaService is an in-memory schemaService with the schemas bound to each
+// topic, recording every lookup and validated message
+type fakeSchemaService struct {
+	mu      sync.Mutex
+	schemas map[string]topicSchema
+	// lookupErr, when set, is returned by every topicSchema lookup
+	lookupErr error
+	// invalid, when set, returns the error validateMessage reports for data
+	invalid   func(data []byte) error
+	lookups   []string
+	validated [][]byte
+}
+
+func (s *fakeSchemaService) topicSchema(ctx context.Context, id string) (topicSchema, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups = append(s.lookups, id)
+	if s.lookupErr != nil {
+		return topicSchema{}, s.lookupErr
+	}
+	return s.schemas[id], nil
+}
+
+func (s *fakeSchemaService) validateMessage(ctx context.Context, data []byte, schema topicSchema) error {
+	s.mu.Lock()
+	s.validated = append(s.validated, data)
+	s.mu.Unlock()
+	if s.invalid != nil {
+		return s.invalid(data)
+	}
+	return nil
+}
+
+func (s *fakeSchemaService) close() error { return nil }
+
+// newSchemaTestPublisher returns a test Publisher looking schemas up with service
+func newSchemaTestPublisher(t *testing.T, service *fakeSchemaService, opts ...Option) *Publisher {
+	t.Helper()
+	p := newTestPublisher(t, &fakeTopic{}, nil, opts...)
+	p.schemas.service = service
+	return p
+}
+
+func TestValidateAgainstSchema(t *testing.T) {
+	instructionsSchema := topicSchema{
+		config:   &pubsub.SchemaConfig{Name: "projects/test/schemas/instructions", Type: pubsub.SchemaAvro},
+		encoding: pubsub.EncodingJSON,
+	}
+	errLookup := errors.New("topic not found")
+	errMismatch := errors.New("missing required field tenantName")
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		mutate  func(m *TinyHomeInstructions)
+		service *fakeSchemaService
+		// wantLookup is the topic whose schema is looked up, none when validation
+		// fails first
+		wantLookup string
+		// wantData checks the data validated against the schema, when one is bound
+		wantData func(t *testing.T, data []byte)
+		wantErr  error
+		// wantMessage are parts of the error message
+		wantMessage []string
+	}{
+		{
+			name:       "topic without a schema",
+			service:    &fakeSchemaService{},
+			wantLookup: DefaultTopicID,
+		},
+		{
+			name:       "conforming message",
+			service:    &fakeSchemaService{schemas: map[string]topicSchema{DefaultTopicID: instructionsSchema}},
+			wantLookup: DefaultTopicID,
+			wantData: func(t *testing.T, data []byte) {
+				if decoded, err := DecodeInstructions(data); err != nil || decoded.TenantName != "contract-tenant" {
+					t.Errorf("validated data %s does not decode to the instructions: %v", data, err)
+				}
+			},
+		},
+		{
+			name:       "validates the compressed bytes sent",
+			opts:       []Option{WithCompression(true)},
+			service:    &fakeSchemaService{schemas: map[string]topicSchema{DefaultTopicID: instructionsSchema}},
+			wantLookup: DefaultTopicID,
+			wantData: func(t *testing.T, data []byte) {
+				if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+					t.Errorf("validated data %q is not gzip", data)
+				}
+			},
+		},
+		{
+			name:       "validates the cloudevents envelope sent",
+			opts:       []Option{WithCloudEvents("//publisher.test")},
+			service:    &fakeSchemaService{schemas: map[string]topicSchema{DefaultTopicID: instructionsSchema}},
+			wantLookup: DefaultTopicID,
+			wantData: func(t *testing.T, data []byte) {
+				if !bytes.Contains(data, []byte(`"source":"//publisher.test"`)) {
+					t.Errorf("validated data %s is not the cloudevents envelope", data)
+				}
+			},
+		},
+		{
+			name:   "per environment topic",
+			opts:   []Option{WithTopicByEnvironment(map[string]string{"prod": "prod-topic"})},
+			mutate: func(m *TinyHomeInstructions) { m.Environment = "prod" },
+			service: &fakeSchemaService{schemas: map[string]topicSchema{
+				DefaultTopicID: instructionsSchema,
+				"prod-topic":   instructionsSchema,
+			}},
+			wantLookup: "prod-topic",
+		},
+		{
+			name: "mismatch",
+			service: &fakeSchemaService{
+				schemas: map[string]topicSchema{DefaultTopicID: instructionsSchema},
+				invalid: func([]byte) error { return errMismatch },
+			},
+			wantLookup:  DefaultTopicID,
+			wantMessage: []string{"does not conform to schema projects/test/schemas/instructions", errMismatch.Error()},
+		},
+		{
+			name:        "lookup error",
+			service:     &fakeSchemaService{lookupErr: errLookup},
+			wantLookup:  DefaultTopicID,
+			wantMessage: []string{errLookup.Error()},
+		},
+		{
+			name:    "invalid instructions",
+			mutate:  func(m *TinyHomeInstructions) { m.TenantOwner = "" },
+			service: &fakeSchemaService{schemas: map[string]topicSchema{DefaultTopicID: instructionsSchema}},
+			wantErr: ErrInvalidInstructions,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newSchemaTestPublisher(t, tt.service, tt.opts...)
+			message := validInstructions()
+			if tt.mutate != nil {
+				tt.mutate(&message)
+			}
+
+			err := p.ValidateAgainstSchema(context.Background(), &message, validAttributes())
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ValidateAgainstSchema error = %v, want %v", err, tt.wantErr)
+				}
+			case len(tt.wantMessage) > 0:
+				if err == nil {
+					t.Fatal("ValidateAgainstSchema succeeded, want an error")
+				}
+				for _, want := range tt.wantMessage {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q does not mention %q", err, want)
+					}
+				}
+			case err != nil:
+				t.Fatalf("ValidateAgainstSchema: %v", err)
+			}
+
+			var wantLookups []string
+			if tt.wantLookup != "" {
+				wantLookups = []string{tt.wantLookup}
+			}
+			if !slices.Equal(tt.service.lookups, wantLookups) {
+				t.Errorf("looked up %v, want %v", tt.service.lookups, wantLookups)
+			}
+
+			wantValidated := 0
+			if tt.service.schemas[tt.wantLookup].config != nil && tt.service.lookupErr == nil {
+				wantValidated = 1
+			}
+			if len(tt.service.validated) != wantValidated {
+				t.Fatalf("validated %d messages, want %d", len(tt.service.validated), wantValidated)
+			}
+			if tt.wantData != nil {
+				tt.wantData(t, tt.service.validated[0])
+			}
+		})
+	}
+}
+
+func TestValidateAgainstSchemaCachesSchema(t *testing.T) {
+	service := &fakeSchemaService{schemas: map[string]topicSchema{
+		DefaultTopicID: {config: &pubsub.SchemaConfig{Name: "instructions"}, encoding: pubsub.EncodingJSON},
+	}}
+	p := newSchemaTestPublisher(t, service, WithTopicByEnvironment(map[string]string{"prod": "prod-topic"}))
+
+	for _, environment := range []string{"dev", "dev", "prod", "prod", "dev"} {
+		message := validInstructions()
+		message.Environment = environment
+		if err := p.ValidateAgainstSchema(context.Background(), &message, validAttributes()); err != nil {
+			t.Fatalf("ValidateAgainstSchema %s: %v", environment, err)
+		}
+	}
+
+	// Each topic is looked up once, including one without a schema
+	if want := []string{DefaultTopicID, "prod-topic"}; !slices.Equal(service.lookups, want) {
+		t.Errorf("looked up %v, want %v", service.lookups, want)
+	}
+	if len(service.validated) != 3 {
+		t.Errorf("validated %d messages, want 3", len(service.validated))
+	}
+
+	// Another Publisher does not share the cache
+	other := newSchemaTestPublisher(t, service)
+	message := validInstructions()
+	if err := other.ValidateAgainstSchema(context.Background(), &message, validAttributes()); err != nil {
+		t.Fatalf("ValidateAgainstSchema: %v", err)
+	}
+	if len(service.lookups) != 3 {
+		t.Errorf("looked up %v, want a lookup by the other Publisher", service.lookups)
+	}
+}
+
+func TestValidateAgainstSchemaWithoutClient(t *testing.T) {
+	p := newTestPublisher(t, &fakeTopic{}, nil)
+	message := validInstructions()
+	if err := p.ValidateAgainstSchema(context.Background(), &message, validAttributes()); err == nil || !strings.Contains(err.Error(), "no Pub/Sub client") {
+		t.Fatalf("ValidateAgainstSchema error = %v, want no Pub/Sub client", err)
+	}
+}
@@ -0,0 +1,66 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ValidateOrganizationSlug checks Organization is a URL safe slug: lower case ASCII
+// letters, digits and single hyphens, not starting or ending with a hyphen
+func (message TinyHomeInstructions) ValidateOrganizationSlug() error {
+	org := message.Organization
+	if org == "" {
+		return &NamingError{Field: "organization", Message: "organization slug can not be empty"}
+	}
+
+	for _, r := range org {
+		if r > unicode.MaxASCII || (!unicode.IsLower(r) && !unicode.IsDigit(r) && r != '-') {
+			return &NamingError{Field: "organization", Message: fmt.Sprintf("organization %q is not a slug, found %q, only lower case letters, digits and '-' are allowed", org, r)}
+		}
+	}
+
+	if strings.HasPrefix(org, "-") || strings.HasSuffix(org, "-") {
+		return &NamingError{Field: "organization", Message: fmt.Sprintf("organization %q is not a slug, it can not start or end with '-'", org)}
+	}
+
+	if strings.Contains(org, "--") {
+		return &NamingError{Field: "organization", Message: fmt.Sprintf("organization %q is not a slug, it can not contain consecutive '-'", org)}
+	}
+	return nil
+}
+
+// validateOrganizationFormat applies ValidateOrganizationSlug when slug is set, and
+// otherwise validateOrganization with pattern
+func (message TinyHomeInstructions) validateOrganizationFormat(pattern *regexp.Regexp, slug bool) error {
+	if slug {
+		return message.ValidateOrganizationSlug()
+	}
+	return message.validateOrganization(pattern)
+}
+
+// SlugifyOrganization rewrites Organization as a slug, for callers that normalize
+// input rather than reject it. See Slugify.
+func (message *TinyHomeInstructions) SlugifyOrganization() {
+	message.Organization = Slugify(message.Organization)
+}
+
+// Slugify lower cases s and replaces every run of characters other than ASCII
+// letters and digits with a single hyphen, trimming hyphens from both ends
+func Slugify(s string) string {
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(s) {
+		if r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingHyphen = false
+			b.WriteRune(r)
+			continue
+		}
+		pendingHyphen = true
+	}
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOrganizationSlug(t *testing.T) {
+	tests := []struct {
+		name         string
+		organization string
+		// wantMessage is part of the error, empty when the slug is valid
+		wantMessage string
+	}{
+		{name: "letters", organization: "acme"},
+		{name: "letters digits and hyphens", organization: "acme-corp-42"},
+		{name: "digits", organization: "123456789012"},
+		{name: "upper case", organization: "Acme", wantMessage: `found 'A'`},
+		{name: "space", organization: "acme corp", wantMessage: `found ' '`},
+		{name: "underscore", organization: "acme_corp", wantMessage: `found '_'`},
+		{name: "slash", organization: "acme/corp", wantMessage: `found '/'`},
+		{name: "non ASCII", organization: "acmé", wantMessage: `found 'é'`},
+		{name: "leading hyphen", organization: "-acme", wantMessage: "can not start or end with '-'"},
+		{name: "trailing hyphen", organization: "acme-", wantMessage: "can not start or end with '-'"},
+		{name: "consecutive hyphens", organization: "acme--corp", wantMessage: "can not contain consecutive '-'"},
+		{name: "empty", organization: "", wantMessage: "can not be empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := validInstructions()
+			message.Organization = tt.organization
+			_, err := message.DryRun(validAttributes(), WithOrganizationSlug(true))
+			if tt.wantMessage == "" {
+				wantFieldError(t, err, "")
+				return
+			}
+			wantFieldError(t, err, "organization")
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "acme", want: "acme"},
+		{in: "Acme Corp", want: "acme-corp"},
+		{in: "  Acme__Corp // 42 ", want: "acme-corp-42"},
+		{in: "--acme--", want: "acme"},
+		{in: "Café Ltd", want: "caf-ltd"},
+		{in: "!!!", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Slugify(tt.in); got != tt.want {
+				t.Errorf("Slugify(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrganizationSlugNormalization(t *testing.T) {
+	tests := []struct {
+		name      string
+		normalize bool
+		// want is the published Organization, empty when the message is rejected
+		want string
+	}{
+		{name: "slugified when normalizing", normalize: true, want: "acme-corp"},
+		{name: "rejected without normalization", normalize: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithOrganizationSlug(true), WithTenantNameNormalization(tt.normalize))
+			message := validInstructions()
+			message.Organization = "Acme Corp"
+
+			_, err := p.PublishContext(context.Background(), &message, validAttributes())
+			if tt.want == "" {
+				wantFieldError(t, err, "organization")
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+			if message.Organization != tt.want {
+				t.Errorf("Organization = %q, want %q", message.Organization, tt.want)
+			}
+			var published TinyHomeInstructions
+			if err := json.Unmarshal(topic.published()[0].Data, &published); err != nil {
+				t.Fatal(err)
+			}
+			if published.Organization != tt.want {
+				t.Errorf("published organization = %q, want %q", published.Organization, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Stage is a pipeline stage, named after the subscription that processes it
+type Stage string
+
+// The pipeline stages in the order they are reached
+const (
+	StageCreateGroups    Stage = "createGroups"
+	StageCreateWorkspace Stage = "createWorkspace"
+	StageCreateTenant    Stage = "createTenant"
+	StageCreateFlux      Stage = "createFlux"
+	StageDeliverEmail    Stage = "deliverEmail"
+)
+
+// subscriptionOrder lists the subscriptions in the order the pipeline reaches them
+var subscriptionOrder = []string{"createGroups", "createWorkspace", "createTenant", "createFlux", "deliverEmail"}
+
+// stageReached reports whether subscription is at or after stage in the pipeline
+func stageReached(subscription, stage string) bool {
+	for _, s := range subscriptionOrder {
+		if s == stage {
+			return true
+		}
+		if s == subscription {
+			return false
+		}
+	}
+	return false
+}
+
+// validateForStage checks the fields the pipeline only needs from a given stage on.
+// Domain can be omitted for createGroups and createWorkspace but tenant creation
+// needs it.
+func (message TinyHomeInstructions) validateForStage(subscription string) error {
+	if stageReached(subscription, "createTenant") {
+		if message.Domain == "" {
+			return &NamingError{Field: "domain", Message: fmt.Sprintf("domain is required for messages routed to %s or later, got %s", "createTenant", subscription)}
+		}
+
+		if err := validateDomain(message.Domain); err != nil {
+			return &NamingError{Field: "domain", Message: fmt.Sprintf("domain %q %v", message.Domain, err)}
+		}
+	}
+	return nil
+}
+
+// validateMinStage rejects messages routed to subscription when it comes before
+// minStage in the pipeline. An empty minStage allows every stage.
+func validateMinStage(subscription string, minStage Stage) error {
+	if minStage == "" || stageReached(subscription, string(minStage)) {
+		return nil
+	}
+	return &RoutingError{Field: "attributes", Message: fmt.Sprintf("message attributes route to %s, before the minimum stage %s of this publisher", subscription, minStage)}
+}
+
+// deprecatedStageWarning returns the warning for messages routed to subscription when
+// it is one of the deprecated stages, or a RoutingError with strict set. It returns
+// "" and nil for every other stage.
+func deprecatedStageWarning(subscription string, deprecated []Stage, strict bool) (string, error) {
+	if !slices.Contains(deprecated, Stage(subscription)) {
+		return "", nil
+	}
+	if strict {
+		return "", &RoutingError{Field: "attributes", Message: fmt.Sprintf("message attributes route to %s, a deprecated stage", subscription)}
+	}
+	return fmt.Sprintf("stage %s is deprecated, migrate callers off it before it is removed", subscription), nil
+}
+
+// validateDomain checks domain is a DNS name of at least two valid labels, such as
+// example.com
+func validateDomain(domain string) error {
+	if len(domain) > 253 {
+		return fmt.Errorf("greater than 253 characters")
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return fmt.Errorf("must include a top level domain")
+	}
+
+	for _, label := range labels {
+		if err := validateDNS1123Label(strings.ToLower(label)); err != nil {
+			return fmt.Errorf("label %q %v", label, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMinStage(t *testing.T) {
+	tests := []struct {
+		name         string
+		minStage     Stage
+		subscription string
+		wantErr      bool
+	}{
+		{name: "below the minimum", minStage: StageCreateTenant, subscription: "createGroups", wantErr: true},
+		{name: "just below the minimum", minStage: StageCreateTenant, subscription: "createWorkspace", wantErr: true},
+		{name: "at the minimum", minStage: StageCreateTenant, subscription: "createTenant"},
+		{name: "after the minimum", minStage: StageCreateTenant, subscription: "createFlux"},
+		{name: "first stage minimum", minStage: StageCreateGroups, subscription: "createGroups"},
+		{name: "no minimum", subscription: "createGroups"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithMinStage(tt.minStage))
+			message := validInstructions()
+
+			_, err := p.PublishContext(context.Background(), &message, attributesFor(t, tt.subscription))
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("PublishContext: %v", err)
+				}
+				if got := len(topic.published()); got != 1 {
+					t.Errorf("published %d messages, want 1", got)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrInvalidAttribute) {
+				t.Fatalf("PublishContext error = %v, want ErrInvalidAttribute", err)
+			}
+			// The error names the computed and minimum stages
+			for _, want := range []string{"route to " + tt.subscription, "minimum stage " + string(tt.minStage)} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+			if got := len(topic.published()); got != 0 {
+				t.Errorf("published %d messages, want none", got)
+			}
+		})
+	}
+}
+
+func TestMinStageValidated(t *testing.T) {
+	tests := []struct {
+		name     string
+		minStage Stage
+		wantErr  bool
+	}{
+		{name: "known stage", minStage: StageCreateFlux},
+		{name: "unset", minStage: ""},
+		{name: "unknown stage", minStage: "createCluster", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithMinStage(tt.minStage))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPublisherWithTopic error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDomainRequiredFromCreateTenant(t *testing.T) {
+	tests := []struct {
+		name         string
+		subscription string
+		domain       string
+		// wantMessage is part of the domain error, empty when valid
+		wantMessage string
+	}{
+		{name: "createGroups without domain", subscription: "createGroups"},
+		{name: "createWorkspace without domain", subscription: "createWorkspace"},
+		{name: "createTenant with domain", subscription: "createTenant", domain: "example.com"},
+		{name: "createFlux with domain", subscription: "createFlux", domain: "tenants.example.com"},
+		{
+			name:         "createTenant without domain",
+			subscription: "createTenant",
+			wantMessage:  "domain is required for messages routed to createTenant or later, got createTenant",
+		},
+		{
+			name:         "createFlux without domain",
+			subscription: "createFlux",
+			wantMessage:  "domain is required for messages routed to createTenant or later, got createFlux",
+		},
+		{name: "createTenant with invalid domain", subscription: "createTenant", domain: "example", wantMessage: "must include a top level domain"},
+		{name: "createFlux with invalid label", subscription: "createFlux", domain: "bad_label.example.com", wantMessage: `label "bad_label"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil)
+			message := validInstructions()
+			message.Domain = tt.domain
+
+			_, err := p.PublishContext(context.Background(), &message, attributesFor(t, tt.subscription))
+			if tt.wantMessage == "" {
+				if err != nil {
+					t.Fatalf("PublishContext: %v", err)
+				}
+				return
+			}
+			wantFieldError(t, err, "domain")
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not mention %q", err, tt.wantMessage)
+			}
+			if got := len(topic.published()); got != 0 {
+				t.Errorf("published %d messages, want none", got)
+			}
+		})
+	}
+}
+
+func TestDeliverEmail(t *testing.T) {
+	tests := []struct {
+		name         string
+		enabled      bool
+		subscription string
+		wantErr      error
+	}{
+		{name: "disabled", subscription: "deliverEmail", wantErr: ErrStageNotImplemented},
+		{name: "enabled", enabled: true, subscription: "deliverEmail"},
+		{name: "other stages while disabled", subscription: "createFlux"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithDeliverEmail(tt.enabled))
+			message := validInstructions()
+
+			_, err := p.PublishContext(context.Background(), &message, attributesFor(t, tt.subscription))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PublishContext error = %v, want %v", err, tt.wantErr)
+			}
+			wantPublished := 1
+			if tt.wantErr != nil {
+				wantPublished = 0
+			}
+			if got := len(topic.published()); got != wantPublished {
+				t.Errorf("published %d messages, want %d", got, wantPublished)
+			}
+		})
+	}
+}
+
+func TestDeprecatedStages(t *testing.T) {
+	deprecated := []Stage{StageCreateWorkspace}
+
+	tests := []struct {
+		name         string
+		strict       bool
+		subscription string
+		wantWarning  bool
+		wantErr      bool
+	}{
+		{name: "current stage", subscription: "createGroups"},
+		{name: "deprecated stage", subscription: "createWorkspace", wantWarning: true},
+		{name: "strict current stage", strict: true, subscription: "createTenant"},
+		{name: "strict deprecated stage", strict: true, subscription: "createWorkspace", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			var logs bytes.Buffer
+			p := newTestPublisher(t, topic, nil,
+				WithDeprecatedStages(deprecated),
+				WithStrictDeprecatedStages(tt.strict),
+				WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+			)
+			message := validInstructions()
+
+			result, err := p.PublishWithResult(context.Background(), &message, attributesFor(t, tt.subscription))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidAttribute) || !strings.Contains(err.Error(), "route to createWorkspace, a deprecated stage") {
+					t.Fatalf("PublishWithResult error = %v, want the deprecated stage rejected", err)
+				}
+				if got := len(topic.published()); got != 0 {
+					t.Errorf("published %d messages, want none", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishWithResult: %v", err)
+			}
+			if got := len(topic.published()); got != 1 {
+				t.Errorf("published %d messages, want 1", got)
+			}
+
+			// The warning is in the result and logged, and absent for current stages
+			const warning = "stage createWorkspace is deprecated"
+			if !tt.wantWarning {
+				if len(result.Warnings) != 0 || strings.Contains(logs.String(), "deprecated") {
+					t.Errorf("warnings %q, logs %q, want none", result.Warnings, logs.String())
+				}
+				return
+			}
+			if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], warning) {
+				t.Errorf("PublishResult Warnings = %q, want %q", result.Warnings, warning)
+			}
+			if !strings.Contains(logs.String(), "level=WARN") || !strings.Contains(logs.String(), warning) {
+				t.Errorf("logs %q do not warn %q", logs.String(), warning)
+			}
+		})
+	}
+}
+
+func TestDeprecatedStagesValidated(t *testing.T) {
+	tests := []struct {
+		name    string
+		stages  []Stage
+		wantErr bool
+	}{
+		{name: "known stages", stages: []Stage{StageCreateGroups, StageDeliverEmail}},
+		{name: "unset"},
+		{name: "unknown stage", stages: []Stage{"createCluster"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), WithDeprecatedStages(tt.stages))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPublisherWithTopic error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
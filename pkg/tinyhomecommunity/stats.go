@@ -0,0 +1,63 @@
+package tinyhomecommunity
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// PublishStats is a snapshot of the cumulative instruction publish counts of a
+// Publisher, see Stats
+type PublishStats struct {
+	// Published is the number of messages the server accepted
+	Published uint64
+	// ValidationFailures is the number of publishes rejected by validation
+	ValidationFailures uint64
+	// TransportFailures is the number of valid messages that were not published,
+	// usually a TransportError or a context that ended first
+	TransportFailures uint64
+	// BySubscription is Published broken down by the subscription messages were
+	// routed to
+	BySubscription map[string]uint64
+}
+
+// publisherStats holds the counters behind Stats
+type publisherStats struct {
+	published          atomic.Uint64
+	validationFailures atomic.Uint64
+	transportFailures  atomic.Uint64
+	// bySubscription maps a subscription to its *atomic.Uint64 count
+	bySubscription sync.Map
+}
+
+// record counts a publish to subscription that finished with outcome
+func (s *publisherStats) record(subscription, outcome string) {
+	switch outcome {
+	case OutcomePublished:
+		s.published.Add(1)
+		count, _ := s.bySubscription.LoadOrStore(subscription, new(atomic.Uint64))
+		count.(*atomic.Uint64).Add(1)
+	case OutcomeInvalid:
+		s.validationFailures.Add(1)
+	default:
+		s.transportFailures.Add(1)
+	}
+}
+
+// Stats returns the cumulative counts of every instruction publish since the
+// Publisher was created, including PublishAsync and PublishBatch, without needing
+// Metrics. Counters are read one at a time, so a snapshot taken during publishes may
+// be off by the publishes in flight.
+func (p *Publisher) Stats() PublishStats {
+	stats := PublishStats{
+		Published:          p.stats.published.Load(),
+		ValidationFailures: p.stats.validationFailures.Load(),
+		TransportFailures:  p.stats.transportFailures.Load(),
+		BySubscription:     map[string]uint64{},
+	}
+
+	p.stats.bySubscription.Range(func(key, value any) bool {
+		stats.BySubscription[key.(string)] = value.(*atomic.Uint64).Load()
+		return true
+	})
+	return stats
+}
@@ -0,0 +1,161 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// AckFunc acks the message when ack is true and nacks it for redelivery otherwise.
+// Call it exactly once per message.
+type AckFunc func(ack bool)
+
+// Subscriber receives and decodes the instructions published by a Publisher
+type Subscriber struct {
+	cfg    PublisherConfig
+	client *pubsub.Client
+	sub    *pubsub.Subscription
+}
+
+// NewSubscriber creates a Pub/Sub client for the project selected by opts and a handle
+// on subscriptionId. WithCompact must match the Publisher so compacted messages can
+// be expanded. Close it when done.
+func NewSubscriber(ctx context.Context, subscriptionId string, opts ...Option) (*Subscriber, error) {
+	cfg, err := newPublisherConfig(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("NewSubscriber: %v", err)
+	}
+
+	if subscriptionId == "" {
+		return nil, fmt.Errorf("NewSubscriber: subscription id can not be empty")
+	}
+
+	client, err := pubsub.NewClient(ctx, cfg.ProjectID, cfg.ClientOptions...)
+	if err != nil {
+		return nil, &TransportError{Op: "pubsub.NewClient", Err: err}
+	}
+
+	return &Subscriber{
+		cfg:    cfg,
+		client: client,
+		sub:    client.Subscription(subscriptionId),
+	}, nil
+}
+
+// Close releases the client
+func (s *Subscriber) Close() error {
+	return s.client.Close()
+}
+
+// Receive pulls messages until ctx is done, decoding each body into
+// TinyHomeInstructions and its attributes into TinyHomeMessageAttributes before
+// handing both to handler. Heartbeat messages carry no instructions and are acked
+// without calling handler. Abort messages are handed to OnAbort instead, and nacked
+// and logged when it is not set so they are never lost. Messages that can not be
+// decoded, including ones with an unsupported schemaVersion, are nacked and logged,
+// they never stop the receive loop.
+func (s *Subscriber) Receive(ctx context.Context, handler func(*TinyHomeInstructions, *TinyHomeMessageAttributes, AckFunc)) error {
+	err := s.sub.Receive(ctx, func(ctx context.Context, m *pubsub.Message) {
+		switch {
+		case m.Attributes["messageType"] == MessageTypeHeartbeat:
+			m.Ack()
+			return
+		case m.Attributes["action"] == ActionAbort:
+			s.receiveAbort(ctx, m)
+			return
+		}
+
+		message, err := s.decode(m)
+		if err != nil {
+			s.cfg.logger().ErrorContext(ctx, "nacking malformed message", "messageId", m.ID, "error", err)
+			m.Nack()
+			return
+		}
+
+		handler(message, attributesFromMessage(m.Attributes), ackFunc(m))
+	})
+	if err != nil {
+		return &TransportError{Op: "receive", Err: err}
+	}
+	return nil
+}
+
+// receiveAbort decodes an abort message and hands it to OnAbort
+func (s *Subscriber) receiveAbort(ctx context.Context, m *pubsub.Message) {
+	if s.cfg.OnAbort == nil {
+		s.cfg.logger().ErrorContext(ctx, "nacking abort message, no OnAbort is set to handle it", "messageId", m.ID, "tenantName", m.Attributes[AttrTenantName])
+		m.Nack()
+		return
+	}
+
+	var body abortMessage
+	if err := json.Unmarshal(m.Data, &body); err != nil {
+		s.cfg.logger().ErrorContext(ctx, "nacking malformed abort message", "messageId", m.ID, "error", err)
+		m.Nack()
+		return
+	}
+
+	s.cfg.OnAbort(Abort{
+		TenantName: body.TenantName,
+		Reason:     body.AbortReason,
+		Attributes: attributesFromMessage(m.Attributes),
+	}, ackFunc(m))
+}
+
+// ackFunc returns the AckFunc of m
+func ackFunc(m *pubsub.Message) AckFunc {
+	return func(ack bool) {
+		if ack {
+			m.Ack()
+		} else {
+			m.Nack()
+		}
+	}
+}
+
+// decode unmarshals a message body in any of the shapes a Publisher writes, after
+// checking its schemaVersion is supported, decompressing it and unwrapping any
+// CloudEvents envelope
+func (s *Subscriber) decode(m *pubsub.Message) (*TinyHomeInstructions, error) {
+	if err := ValidateSchemaVersion(m.Attributes); err != nil {
+		return nil, err
+	}
+
+	data, err := DecompressBody(m.Data, m.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err = unwrapCloudEvent(data, m.Attributes); err != nil {
+		return nil, err
+	}
+
+	if m.Attributes["compact"] == "true" {
+		if s.cfg.CompactDefaults == nil {
+			return nil, fmt.Errorf("compact message received without WithCompact defaults")
+		}
+		return ParseCompact(data, *s.cfg.CompactDefaults)
+	}
+
+	if s.cfg.StrictDecoding {
+		return decodeStrict(data)
+	}
+	return ParseInstructions(data)
+}
+
+// attributesFromMessage rebuilds TinyHomeMessageAttributes from a Pub/Sub attribute
+// map without checking it, see AttributesFromMap
+func attributesFromMessage(attributes map[string]string) *TinyHomeMessageAttributes {
+	return &TinyHomeMessageAttributes{
+		GroupsCreated:    attributes[AttrGroupsCreated],
+		WorkspaceCreated: attributes[AttrWorkspaceCreated],
+		TenantCreated:    attributes[AttrTenantCreated],
+		FluxCreated:      attributes[AttrFluxCreated],
+		DeliveredFrom:    attributes[AttrDeliveredFrom],
+		TenantName:       attributes[AttrTenantName],
+		CorrelationID:    attributes["correlationId"],
+		IdempotencyKey:   attributes["idempotencyKey"],
+	}
+}
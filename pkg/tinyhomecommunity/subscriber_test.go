@@ -0,0 +1,220 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// newTestSubscriber returns a client of a pstest server with the default topic and
+// a Subscriber over opts on a subscription to it
+func newTestSubscriber(t *testing.T, opts ...Option) (*pubsub.Client, *Subscriber, Option) {
+	t.Helper()
+	client, connect := newEmulator(t, DefaultTopicID)
+	if _, err := client.CreateSubscription(context.Background(), "instructions", pubsub.SubscriptionConfig{Topic: client.Topic(DefaultTopicID)}); err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+
+	s, err := NewSubscriber(context.Background(), "instructions", append([]Option{connect}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewSubscriber: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return client, s, connect
+}
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of a Subscriber's logger
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// received records what a Subscriber hands to its handler and OnAbort
+type received struct {
+	mu           sync.Mutex
+	instructions []*TinyHomeInstructions
+	aborts       []Abort
+}
+
+func (r *received) handler(message *TinyHomeInstructions, attrs *TinyHomeMessageAttributes, ack AckFunc) {
+	r.mu.Lock()
+	r.instructions = append(r.instructions, message)
+	r.mu.Unlock()
+	ack(true)
+}
+
+func (r *received) onAbort(abort Abort, ack AckFunc) {
+	r.mu.Lock()
+	r.aborts = append(r.aborts, abort)
+	r.mu.Unlock()
+	ack(true)
+}
+
+func (r *received) counts() (instructions, aborts int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.instructions), len(r.aborts)
+}
+
+// receive runs s.Receive with r.handler until the returned func is called
+func (r *received) receive(t *testing.T, s *Subscriber) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Receive(ctx, r.handler) }()
+	return func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Receive: %v", err)
+		}
+	}
+}
+
+func TestReceiveAbort(t *testing.T) {
+	tests := []struct {
+		name    string
+		onAbort bool
+	}{
+		{name: "handed to OnAbort", onAbort: true},
+		{name: "nacked without OnAbort"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got  received
+				logs syncBuffer
+			)
+			opts := []Option{WithLogger(slog.New(slog.NewTextHandler(&logs, nil)))}
+			if tt.onAbort {
+				opts = append(opts, WithOnAbort(got.onAbort))
+			}
+			_, s, connect := newTestSubscriber(t, opts...)
+
+			p, err := NewPublisher(context.Background(), DefaultPublisherConfig(), connect)
+			if err != nil {
+				t.Fatalf("NewPublisher: %v", err)
+			}
+			defer p.Close()
+			if _, err := p.PublishHeartbeat(context.Background()); err != nil {
+				t.Fatalf("PublishHeartbeat: %v", err)
+			}
+			if _, err := p.PublishAbort(context.Background(), "contract-tenant", "requested by the tenant owner", validAttributes()); err != nil {
+				t.Fatalf("PublishAbort: %v", err)
+			}
+			message := validInstructions()
+			if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+
+			stop := got.receive(t, s)
+			if tt.onAbort {
+				eventually(t, func() bool {
+					instructions, aborts := got.counts()
+					return instructions == 1 && aborts == 1
+				})
+			} else {
+				eventually(t, func() bool {
+					instructions, _ := got.counts()
+					return instructions == 1 && strings.Contains(logs.String(), "nacking abort message")
+				})
+			}
+			stop()
+
+			// Only the instructions reach the handler, never the heartbeat or abort
+			got.mu.Lock()
+			defer got.mu.Unlock()
+			if len(got.instructions) != 1 || got.instructions[0].TenantName != message.TenantName {
+				t.Errorf("handler received %d instructions, want only those for %s", len(got.instructions), message.TenantName)
+			}
+			if !tt.onAbort {
+				return
+			}
+			abort := got.aborts[0]
+			if abort.TenantName != "contract-tenant" || abort.Reason != "requested by the tenant owner" {
+				t.Errorf("OnAbort received %+v, want the abort of contract-tenant", abort)
+			}
+			if abort.Attributes.TenantName != "contract-tenant" {
+				t.Errorf("OnAbort attributes TenantName = %q, want contract-tenant", abort.Attributes.TenantName)
+			}
+		})
+	}
+}
+
+// publishRaw publishes data with attributes straight to the default topic, bypassing
+// the Publisher's validation
+func publishRaw(t *testing.T, client *pubsub.Client, data []byte, attributes map[string]string) {
+	t.Helper()
+	topic := client.Topic(DefaultTopicID)
+	defer topic.Stop()
+	if _, err := topic.Publish(context.Background(), &pubsub.Message{Data: data, Attributes: attributes}).Get(context.Background()); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+}
+
+func TestReceiveStrictDecoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		strict      bool
+		wantHandled bool
+	}{
+		{name: "unknown field nacked when strict", strict: true},
+		{name: "unknown field accepted otherwise", wantHandled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got  received
+				logs syncBuffer
+			)
+			client, s, _ := newTestSubscriber(t, WithStrictDecoding(tt.strict), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+			// A mistyped tenantowner alongside the instructions
+			var body map[string]any
+			data, _ := json.Marshal(validInstructions())
+			if err := json.Unmarshal(data, &body); err != nil {
+				t.Fatal(err)
+			}
+			body["tenantowner"] = "owner@example.com"
+			data, _ = json.Marshal(body)
+			publishRaw(t, client, data, map[string]string{AttrGroupsCreated: "false", AttrWorkspaceCreated: "false", AttrTenantCreated: "false", AttrFluxCreated: "false", AttrDeliveredFrom: "galaxy"})
+
+			stop := got.receive(t, s)
+			eventually(t, func() bool {
+				instructions, _ := got.counts()
+				if tt.wantHandled {
+					return instructions == 1
+				}
+				return strings.Contains(logs.String(), "nacking malformed message")
+			})
+			stop()
+
+			instructions, _ := got.counts()
+			if handled := instructions > 0; handled != tt.wantHandled {
+				t.Errorf("handler called %t, want %t", handled, tt.wantHandled)
+			}
+			if !tt.wantHandled && !strings.Contains(logs.String(), "tenantowner") {
+				t.Errorf("logs = %q, want the nack to name the unknown field", logs.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package tinyhomecommunity
+
+// SliceMerge decides how ApplyTemplateWith combines slice fields
+type SliceMerge int
+
+const (
+	// SliceReplaceEmpty uses the template slice only when the instruction's is empty
+	SliceReplaceEmpty SliceMerge = iota
+	// SliceAppend adds template entries missing from the instruction's slice
+	SliceAppend
+)
+
+// ApplyTemplate fills the zero-valued fields of m from tmpl, such as a business
+// unit's baseline quota and roles, using SliceReplaceEmpty for slices. See
+// ApplyTemplateWith.
+func (m TinyHomeInstructions) ApplyTemplate(tmpl TinyHomeInstructions) TinyHomeInstructions {
+	return m.ApplyTemplateWith(tmpl, SliceReplaceEmpty)
+}
+
+// ApplyTemplateWith fills the zero-valued fields of m from tmpl without overwriting
+// fields m sets, merging slices according to merge. TenantName and Breakglass are
+// never taken from a template since they are specific to a single request. The result
+// is not validated, validate it before publishing.
+func (m TinyHomeInstructions) ApplyTemplateWith(tmpl TinyHomeInstructions, merge SliceMerge) TinyHomeInstructions {
+	fillEmpty(&m.Environment, tmpl.Environment)
+	fillEmpty(&m.BusinessUnit, tmpl.BusinessUnit)
+	fillEmpty(&m.TenantOwner, tmpl.TenantOwner)
+	fillEmpty(&m.TenantOwnerSecondary, tmpl.TenantOwnerSecondary)
+	fillEmpty(&m.TenantCostCenter, tmpl.TenantCostCenter)
+	fillEmpty(&m.Domain, tmpl.Domain)
+	fillEmpty(&m.Organization, tmpl.Organization)
+	fillEmpty(&m.Region, tmpl.Region)
+
+	fillEmpty(&m.NsQuota.Requests.Cpu, tmpl.NsQuota.Requests.Cpu)
+	fillEmpty(&m.NsQuota.Requests.Memory, tmpl.NsQuota.Requests.Memory)
+	fillEmpty(&m.NsQuota.Limits.Cpu, tmpl.NsQuota.Limits.Cpu)
+	fillEmpty(&m.NsQuota.Limits.Memory, tmpl.NsQuota.Limits.Memory)
+
+	m.AddlGkeTenantSaRoles = mergeSlice(m.AddlGkeTenantSaRoles, tmpl.AddlGkeTenantSaRoles, merge)
+	m.AddlGroupIamBindings = mergeBindings(m.AddlGroupIamBindings, tmpl.AddlGroupIamBindings, merge)
+	m.Labels = mergeLabels(m.Labels, tmpl.Labels)
+	return m
+}
+
+// mergeBindings merges the members of each role with the template's according to
+// merge, always returning a new map so neither input is aliased
+func mergeBindings(bindings, tmpl map[string][]string, merge SliceMerge) map[string][]string {
+	if len(bindings) == 0 && len(tmpl) == 0 {
+		return bindings
+	}
+
+	merged := make(map[string][]string, len(bindings)+len(tmpl))
+	for role, members := range bindings {
+		merged[role] = append([]string(nil), members...)
+	}
+	for role, members := range tmpl {
+		merged[role] = mergeSlice(merged[role], members, merge)
+	}
+	return merged
+}
+
+// mergeSlice combines values with the template's according to merge, always
+// returning a new slice so the template is never aliased
+func mergeSlice(values, tmpl []string, merge SliceMerge) []string {
+	if len(values) > 0 && merge == SliceReplaceEmpty {
+		return values
+	}
+
+	merged := append([]string(nil), values...)
+	for _, v := range tmpl {
+		if !contains(merged, v) {
+			merged = append(merged, v)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,161 @@
+package tinyhomecommunity
+
+import (
+	"reflect"
+	"testing"
+)
+
+// baselineTemplate is a business unit baseline with every templated field set
+func baselineTemplate() TinyHomeInstructions {
+	var tmpl TinyHomeInstructions
+	tmpl.TenantName = "template-tenant"
+	tmpl.Breakglass = true
+	tmpl.Environment = "dev"
+	tmpl.BusinessUnit = "platform"
+	tmpl.TenantOwner = "template-owner@example.com"
+	tmpl.TenantOwnerSecondary = "template-backup@example.com"
+	tmpl.TenantCostCenter = "9999"
+	tmpl.Domain = "example.com"
+	tmpl.Organization = "123456789012"
+	tmpl.Region = "us-east1"
+	tmpl.NsQuota.Requests.Cpu = "1"
+	tmpl.NsQuota.Requests.Memory = "1Gi"
+	tmpl.NsQuota.Limits.Cpu = "2"
+	tmpl.NsQuota.Limits.Memory = "2Gi"
+	tmpl.AddlGkeTenantSaRoles = []string{"roles/logging.logWriter", "roles/monitoring.metricWriter"}
+	tmpl.AddlGroupIamBindings = map[string][]string{
+		"roles/viewer": {"group:viewers@example.com"},
+		"roles/editor": {"group:editors@example.com"},
+	}
+	tmpl.Labels = map[string]string{"team": "platform", "tier": "standard"}
+	return tmpl
+}
+
+func TestApplyTemplate(t *testing.T) {
+	tests := []struct {
+		name  string
+		merge SliceMerge
+		// set fills fields of the instructions before the template is applied
+		set func(m *TinyHomeInstructions)
+		// want changes the template to the expected result
+		want func(want *TinyHomeInstructions)
+	}{
+		{
+			name: "empty instructions take every templated field",
+			set:  func(m *TinyHomeInstructions) {},
+			want: func(want *TinyHomeInstructions) {
+				// Request specific fields are never templated
+				want.TenantName = ""
+				want.Breakglass = false
+			},
+		},
+		{
+			name: "set scalars are kept",
+			set: func(m *TinyHomeInstructions) {
+				m.TenantName = "my-tenant"
+				m.TenantOwner = "me@example.com"
+				m.Region = "europe-west1"
+			},
+			want: func(want *TinyHomeInstructions) {
+				want.TenantName = "my-tenant"
+				want.Breakglass = false
+				want.TenantOwner = "me@example.com"
+				want.Region = "europe-west1"
+			},
+		},
+		{
+			name: "quota is filled value by value",
+			set: func(m *TinyHomeInstructions) {
+				m.NsQuota.Requests.Cpu = "500m"
+				m.NsQuota.Limits.Memory = "8Gi"
+			},
+			want: func(want *TinyHomeInstructions) {
+				want.TenantName = ""
+				want.Breakglass = false
+				want.NsQuota.Requests.Cpu = "500m"
+				want.NsQuota.Limits.Memory = "8Gi"
+			},
+		},
+		{
+			name: "replace empty keeps set slices",
+			set: func(m *TinyHomeInstructions) {
+				m.AddlGkeTenantSaRoles = []string{"roles/storage.objectViewer"}
+				m.AddlGroupIamBindings = map[string][]string{"roles/viewer": {"group:mine@example.com"}}
+			},
+			want: func(want *TinyHomeInstructions) {
+				want.TenantName = ""
+				want.Breakglass = false
+				want.AddlGkeTenantSaRoles = []string{"roles/storage.objectViewer"}
+				want.AddlGroupIamBindings = map[string][]string{
+					"roles/viewer": {"group:mine@example.com"},
+					"roles/editor": {"group:editors@example.com"},
+				}
+			},
+		},
+		{
+			name:  "append adds missing template entries",
+			merge: SliceAppend,
+			set: func(m *TinyHomeInstructions) {
+				m.AddlGkeTenantSaRoles = []string{"roles/storage.objectViewer", "roles/logging.logWriter"}
+				m.AddlGroupIamBindings = map[string][]string{"roles/viewer": {"group:mine@example.com"}}
+			},
+			want: func(want *TinyHomeInstructions) {
+				want.TenantName = ""
+				want.Breakglass = false
+				want.AddlGkeTenantSaRoles = []string{"roles/storage.objectViewer", "roles/logging.logWriter", "roles/monitoring.metricWriter"}
+				want.AddlGroupIamBindings = map[string][]string{
+					"roles/viewer": {"group:mine@example.com", "group:viewers@example.com"},
+					"roles/editor": {"group:editors@example.com"},
+				}
+			},
+		},
+		{
+			name: "labels merge with set values winning",
+			set:  func(m *TinyHomeInstructions) { m.Labels = map[string]string{"tier": "premium", "project": "alpha"} },
+			want: func(want *TinyHomeInstructions) {
+				want.TenantName = ""
+				want.Breakglass = false
+				want.Labels = map[string]string{"team": "platform", "tier": "premium", "project": "alpha"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var message TinyHomeInstructions
+			tt.set(&message)
+			tmpl := baselineTemplate()
+
+			got := message.ApplyTemplateWith(tmpl, tt.merge)
+			want := baselineTemplate()
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ApplyTemplateWith =\n%+v\nwant\n%+v", got, want)
+			}
+
+			// Neither the template nor the instructions are changed or aliased
+			if !reflect.DeepEqual(tmpl, baselineTemplate()) {
+				t.Errorf("template changed to %+v", tmpl)
+			}
+			got.AddlGkeTenantSaRoles[0] = "changed"
+			got.AddlGroupIamBindings["roles/editor"][0] = "changed"
+			got.Labels["team"] = "changed"
+			if !reflect.DeepEqual(tmpl, baselineTemplate()) {
+				t.Errorf("result aliases the template %+v", tmpl)
+			}
+		})
+	}
+}
+
+func TestApplyTemplateValidates(t *testing.T) {
+	// A minimal request completed by the baseline passes validation
+	var message TinyHomeInstructions
+	message.TenantName = "templated-tenant"
+	message = message.ApplyTemplate(baselineTemplate())
+	if _, err := message.DryRun(validAttributes()); err != nil {
+		t.Fatalf("DryRun of templated instructions: %v", err)
+	}
+	if got := message.ApplyTemplate(TinyHomeInstructions{}); !reflect.DeepEqual(got, message) {
+		t.Errorf("ApplyTemplate of an empty template changed the instructions to %+v", got)
+	}
+}
@@ -0,0 +1,56 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TenantNameAttributeMode decides what the publish path does with the legacy
+// TinyHomeMessageAttributes.TenantName field
+type TenantNameAttributeMode int
+
+const (
+	// TenantNameAttributeIgnore ignores the field, the tenantName attribute always
+	// comes from the instructions
+	TenantNameAttributeIgnore TenantNameAttributeMode = iota
+	// TenantNameAttributeMatch requires the field to equal the instructions'
+	// TenantName, catching callers that set the two differently
+	TenantNameAttributeMatch
+	// TenantNameAttributeSource publishes the field as the tenantName attribute when
+	// it is set, and fills a blank instructions TenantName from it. It is validated
+	// like TenantName.
+	TenantNameAttributeSource
+)
+
+// applyTenantNameAttributeMode runs before a tenant name is generated, so in source
+// mode the attribute takes precedence over generation
+func (message *TinyHomeInstructions) applyTenantNameAttributeMode(messageAttributes *TinyHomeMessageAttributes, cfg PublisherConfig) error {
+	switch cfg.TenantNameAttribute {
+	case TenantNameAttributeMatch:
+		if messageAttributes.TenantName != message.TenantName {
+			return &NamingError{Field: "tenantName", Message: fmt.Sprintf("tenantName attribute %q does not match instructions tenantName %q", messageAttributes.TenantName, message.TenantName)}
+		}
+	case TenantNameAttributeSource:
+		if messageAttributes.TenantName == "" {
+			return nil
+		}
+		if err := (TinyHomeInstructions{TenantName: messageAttributes.TenantName}).validateTenantName(cfg.MinTenantNameLength, cfg.MaxTenantNameLength); err != nil {
+			return err
+		}
+		if message.TenantName == "" {
+			message.TenantName = messageAttributes.TenantName
+		}
+	}
+	return nil
+}
+
+// normalizeTenantName lowercases TenantName in place and returns a copy of the
+// attributes with their TenantName lowercased, leaving the caller's attributes
+// untouched
+func (message *TinyHomeInstructions) normalizeTenantName(messageAttributes *TinyHomeMessageAttributes) *TinyHomeMessageAttributes {
+	message.TenantName = strings.ToLower(message.TenantName)
+
+	n := *messageAttributes
+	n.TenantName = strings.ToLower(n.TenantName)
+	return &n
+}
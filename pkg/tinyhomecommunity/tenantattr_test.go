@@ -0,0 +1,185 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestTenantNameNormalizationAttribute(t *testing.T) {
+	tests := []struct {
+		name string
+		mode TenantNameAttributeMode
+		// tenantName and attribute are the instructions TenantName and the
+		// TenantName attribute supplied
+		tenantName string
+		attribute  string
+		// want is the normalized tenantName attribute and wantBody the body
+		// TenantName
+		want     string
+		wantBody string
+	}{
+		{name: "ignored attribute", mode: TenantNameAttributeIgnore, tenantName: "Acme-Tenant", attribute: "Other", want: "acme-tenant", wantBody: "Acme-Tenant"},
+		{name: "matching attribute", mode: TenantNameAttributeMatch, tenantName: "Acme-Tenant", attribute: "ACME-tenant", want: "acme-tenant", wantBody: "Acme-Tenant"},
+		{name: "source attribute", mode: TenantNameAttributeSource, attribute: "Acme-Tenant", want: "acme-tenant", wantBody: "acme-tenant"},
+		{name: "already lower case", mode: TenantNameAttributeIgnore, tenantName: "acme-tenant", want: "acme-tenant", wantBody: "acme-tenant"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithTenantNameNormalization(true), WithTenantNameAttribute(tt.mode))
+			message := validInstructions()
+			message.TenantName = tt.tenantName
+			messageAttributes := validAttributes()
+			messageAttributes.TenantName = tt.attribute
+
+			result, err := p.PublishWithResult(context.Background(), &message, messageAttributes)
+			if err != nil {
+				t.Fatalf("PublishWithResult: %v", err)
+			}
+
+			published := topic.published()[0]
+			var body TinyHomeInstructions
+			if err := json.Unmarshal(published.Data, &body); err != nil {
+				t.Fatal(err)
+			}
+			if got := published.Attributes[AttrTenantName]; got != tt.want {
+				t.Errorf("tenantName attribute = %q, want %q", got, tt.want)
+			}
+			if body.TenantName != tt.wantBody {
+				t.Errorf("body tenantName = %q, want %q", body.TenantName, tt.wantBody)
+			}
+			if result.TenantName != tt.want {
+				t.Errorf("result TenantName = %q, want %q", result.TenantName, tt.want)
+			}
+			if messageAttributes.TenantName != tt.attribute {
+				t.Errorf("caller's TenantName attribute changed to %q", messageAttributes.TenantName)
+			}
+		})
+	}
+}
+
+func TestTenantNameAttributeModes(t *testing.T) {
+	tests := []struct {
+		name string
+		mode TenantNameAttributeMode
+		// tenantName and attribute are the instructions TenantName and the
+		// TenantName attribute supplied
+		tenantName string
+		attribute  string
+		// wantAttribute and wantBody are the tenantName attribute and body TenantName
+		// published
+		wantAttribute string
+		wantBody      string
+		wantErr       bool
+	}{
+		{name: "ignore a different attribute", mode: TenantNameAttributeIgnore, tenantName: "contract-tenant", attribute: "other-tenant", wantAttribute: "contract-tenant", wantBody: "contract-tenant"},
+		{name: "ignore an invalid attribute", mode: TenantNameAttributeIgnore, tenantName: "contract-tenant", attribute: "Not Valid", wantAttribute: "contract-tenant", wantBody: "contract-tenant"},
+		{name: "match an equal attribute", mode: TenantNameAttributeMatch, tenantName: "contract-tenant", attribute: "contract-tenant", wantAttribute: "contract-tenant", wantBody: "contract-tenant"},
+		{name: "match a different attribute", mode: TenantNameAttributeMatch, tenantName: "contract-tenant", attribute: "other-tenant", wantErr: true},
+		{name: "match a missing attribute", mode: TenantNameAttributeMatch, tenantName: "contract-tenant", wantErr: true},
+		{name: "source from the attribute", mode: TenantNameAttributeSource, tenantName: "contract-tenant", attribute: "other-tenant", wantAttribute: "other-tenant", wantBody: "contract-tenant"},
+		{name: "source fills a blank tenant name", mode: TenantNameAttributeSource, attribute: "other-tenant", wantAttribute: "other-tenant", wantBody: "other-tenant"},
+		{name: "source without an attribute", mode: TenantNameAttributeSource, tenantName: "contract-tenant", wantAttribute: "contract-tenant", wantBody: "contract-tenant"},
+		{name: "source an invalid attribute", mode: TenantNameAttributeSource, tenantName: "contract-tenant", attribute: "Not Valid", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithTenantNameAttribute(tt.mode))
+			message := validInstructions()
+			message.TenantName = tt.tenantName
+			messageAttributes := validAttributes()
+			messageAttributes.TenantName = tt.attribute
+
+			_, err := p.PublishWithResult(context.Background(), &message, messageAttributes)
+			if tt.wantErr {
+				wantFieldError(t, err, "tenantName")
+				if got := len(topic.published()); got != 0 {
+					t.Errorf("published %d messages, want none", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishWithResult: %v", err)
+			}
+
+			published := topic.published()[0]
+			var body TinyHomeInstructions
+			if err := json.Unmarshal(published.Data, &body); err != nil {
+				t.Fatal(err)
+			}
+			if got := published.Attributes[AttrTenantName]; got != tt.wantAttribute {
+				t.Errorf("tenantName attribute = %q, want %q", got, tt.wantAttribute)
+			}
+			if body.TenantName != tt.wantBody {
+				t.Errorf("body tenantName = %q, want %q", body.TenantName, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestTenantNameNormalization(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalize  bool
+		tenantName string
+		// want is the normalized tenant name published in the tenantName attribute,
+		// empty when the name is rejected
+		want string
+	}{
+		{name: "upper case normalized", normalize: true, tenantName: "ACME-TENANT", want: "acme-tenant"},
+		{name: "mixed case normalized", normalize: true, tenantName: "Acme-Tenant-01", want: "acme-tenant-01"},
+		{name: "lower case unchanged", normalize: true, tenantName: "acme-tenant", want: "acme-tenant"},
+		{name: "invalid characters still rejected", normalize: true, tenantName: "Acme_Tenant"},
+		{name: "too long after normalizing", normalize: true, tenantName: "ACME-TENANT-WITH-A-LONG-NAME"},
+		{name: "upper case rejected when disabled", tenantName: "Acme-Tenant"},
+		{name: "lower case when disabled", tenantName: "acme-tenant", want: "acme-tenant"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, WithTenantNameNormalization(tt.normalize))
+			message := validInstructions()
+			message.TenantName = tt.tenantName
+
+			result, err := p.PublishWithResult(context.Background(), &message, validAttributes())
+			if tt.want == "" {
+				wantFieldError(t, err, "tenantName")
+				if got := len(topic.published()); got != 0 {
+					t.Errorf("published %d messages, want none", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PublishWithResult: %v", err)
+			}
+
+			// The attribute and result carry the normalized name, the body and the
+			// caller's instructions the name as supplied
+			published := topic.published()[0]
+			var body TinyHomeInstructions
+			if err := json.Unmarshal(published.Data, &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.TenantName != tt.tenantName {
+				t.Errorf("body tenantName = %q, want %q", body.TenantName, tt.tenantName)
+			}
+			if message.TenantName != tt.tenantName {
+				t.Errorf("instructions TenantName = %q after publish, want %q", message.TenantName, tt.tenantName)
+			}
+			if got := published.Attributes[AttrTenantName]; got != tt.want {
+				t.Errorf("tenantName attribute = %q, want %q", got, tt.want)
+			}
+			if result.TenantName != tt.want {
+				t.Errorf("result TenantName = %q, want %q", result.TenantName, tt.want)
+			}
+			if hash, _ := body.InstructionHash(); published.Attributes["instructionHash"] != hash {
+				t.Errorf("instructionHash attribute = %s, want the hash of the body %s", published.Attributes["instructionHash"], hash)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// publishResult is the part of *pubsub.PublishResult the Publisher waits on
+type publishResult interface {
+	Get(ctx context.Context) (string, error)
+}
+
+// waitResult waits for result, returning ctx.Err() as soon as ctx is done even if
+// result has not observed the cancellation yet. The message may still be delivered
+// by the server after an early return.
+func waitResult(ctx context.Context, result publishResult) (string, error) {
+	type outcome struct {
+		id  string
+		err error
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		id, err := result.Get(ctx)
+		done <- outcome{id, err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.id, o.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// topicPublisher is the part of *pubsub.Topic the Publisher depends on, so tests can
+// publish through an in-memory fake instead of a real topic
+type topicPublisher interface {
+	Publish(ctx context.Context, msg *pubsub.Message) publishResult
+	ResumePublish(orderingKey string)
+	Flush()
+	Stop()
+}
+
+// pubsubTopic adapts a *pubsub.Topic to topicPublisher
+type pubsubTopic struct {
+	*pubsub.Topic
+}
+
+// newPubsubTopic returns the handle for topic id with cfg's publish settings
+func newPubsubTopic(client *pubsub.Client, id string, cfg PublisherConfig) pubsubTopic {
+	topic := client.Topic(id)
+	topic.EnableMessageOrdering = cfg.MessageOrdering
+	if cfg.PublishSettings != nil {
+		applyPublishSettings(&topic.PublishSettings, *cfg.PublishSettings)
+	}
+	return pubsubTopic{topic}
+}
+
+// applyPublishSettings overrides the fields of dst that are set in settings
+func applyPublishSettings(dst *pubsub.PublishSettings, settings pubsub.PublishSettings) {
+	if settings.DelayThreshold > 0 {
+		dst.DelayThreshold = settings.DelayThreshold
+	}
+	if settings.CountThreshold > 0 {
+		dst.CountThreshold = settings.CountThreshold
+	}
+	if settings.ByteThreshold > 0 {
+		dst.ByteThreshold = settings.ByteThreshold
+	}
+	if settings.NumGoroutines > 0 {
+		dst.NumGoroutines = settings.NumGoroutines
+	}
+	if settings.Timeout > 0 {
+		dst.Timeout = settings.Timeout
+	}
+
+	fcs := settings.FlowControlSettings
+	if fcs.MaxOutstandingMessages != 0 {
+		dst.FlowControlSettings.MaxOutstandingMessages = fcs.MaxOutstandingMessages
+	}
+	if fcs.MaxOutstandingBytes != 0 {
+		dst.FlowControlSettings.MaxOutstandingBytes = fcs.MaxOutstandingBytes
+	}
+	if fcs.LimitExceededBehavior != pubsub.FlowControlIgnore {
+		dst.FlowControlSettings.LimitExceededBehavior = fcs.LimitExceededBehavior
+	}
+}
+
+// validatePublishSettings rejects negative thresholds, batches larger than Pub/Sub
+// accepts and too many goroutines for an ordered topic
+func (cfg PublisherConfig) validatePublishSettings() error {
+	settings := cfg.PublishSettings
+	if settings == nil {
+		return nil
+	}
+
+	if settings.DelayThreshold < 0 || settings.CountThreshold < 0 || settings.ByteThreshold < 0 || settings.NumGoroutines < 0 || settings.Timeout < 0 {
+		return fmt.Errorf("publisher config: publish settings can not be negative")
+	}
+
+	if settings.CountThreshold > pubsub.MaxPublishRequestCount {
+		return fmt.Errorf("publisher config: publish settings count threshold can be at most %d, got %d", pubsub.MaxPublishRequestCount, settings.CountThreshold)
+	}
+
+	if float64(settings.ByteThreshold) > pubsub.MaxPublishRequestBytes {
+		return fmt.Errorf("publisher config: publish settings byte threshold can be at most %d, got %d", int(pubsub.MaxPublishRequestBytes), settings.ByteThreshold)
+	}
+
+	if cfg.MessageOrdering && settings.NumGoroutines > MaxOrderedNumGoroutines {
+		return fmt.Errorf("publisher config: publish settings num goroutines can be at most %d with message ordering, got %d", MaxOrderedNumGoroutines, settings.NumGoroutines)
+	}
+	return nil
+}
+
+func (t pubsubTopic) Publish(ctx context.Context, msg *pubsub.Message) publishResult {
+	return t.Topic.Publish(ctx, msg)
+}
+
+// NewPublisherWithClient is NewPublisher over an already built Pub/Sub client, such as
+// one connected to the emulator or created with custom client options. The client
+// stays owned by the caller, Close stops the topic but does not close the client.
+func NewPublisherWithClient(client *pubsub.Client, cfg PublisherConfig, opts ...Option) (*Publisher, error) {
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("NewPublisherWithClient: %v", err)
+	}
+
+	if client == nil {
+		return nil, fmt.Errorf("NewPublisherWithClient: client can not be nil")
+	}
+
+	return newPublisherWithClient(client, cfg), nil
+}
+
+// newPublisherWithClient returns a Publisher with handles for the TopicID topic, if
+// set, every TopicByEnvironment topic and the QuarantineTopicID and CloudEventsTopicID
+// topics
+func newPublisherWithClient(client *pubsub.Client, cfg PublisherConfig) *Publisher {
+	p := &Publisher{cfg: cfg, client: client}
+	if cfg.TopicID != "" {
+		p.topic = newPubsubTopic(client, cfg.TopicID, cfg)
+	}
+
+	for _, id := range p.topicIDs() {
+		if _, ok := p.topics[id]; ok || id == cfg.TopicID {
+			continue
+		}
+		if p.topics == nil {
+			p.topics = map[string]topicPublisher{}
+		}
+		p.topics[id] = newPubsubTopic(client, id, cfg)
+	}
+	return p
+}
+
+// topicFor returns the id of the topic instructions for environment are published
+// to, its TopicByEnvironment topic or else TopicID
+func (cfg PublisherConfig) topicFor(environment string) (string, error) {
+	if id, ok := cfg.TopicByEnvironment[environment]; ok {
+		return id, nil
+	}
+
+	if cfg.TopicID == "" {
+		return "", &RoutingError{Field: "environment", Message: fmt.Sprintf("environment %q has no topic in TopicByEnvironment and no default topic is configured", environment)}
+	}
+	return cfg.TopicID, nil
+}
+
+// topicHandle returns the handle of topic id, nil when p has none such as for a
+// dry run
+func (p *Publisher) topicHandle(id string) topicPublisher {
+	if id == p.cfg.TopicID {
+		return p.topic
+	}
+	return p.topics[id]
+}
+
+// newPublisherWithTopic returns a Publisher that publishes through topic and has no
+// Pub/Sub client, for tests that inject a fake topicPublisher
+func newPublisherWithTopic(topic topicPublisher, cfg PublisherConfig, opts ...Option) (*Publisher, error) {
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("newPublisherWithTopic: %v", err)
+	}
+
+	return &Publisher{cfg: cfg, topic: topic}, nil
+}
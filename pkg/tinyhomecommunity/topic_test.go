@@ -0,0 +1,174 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// receiveOne returns the first message received on subscription id, failing the test
+// when none arrives within a few seconds
+func receiveOne(t *testing.T, client *pubsub.Client, id string) *pubsub.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var received *pubsub.Message
+	err := client.Subscription(id).Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		msg.Ack()
+		if received == nil {
+			received = msg
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if received == nil {
+		t.Fatalf("no message received on subscription %s", id)
+	}
+	return received
+}
+
+func TestNewPublisherWithClient(t *testing.T) {
+	client, _ := newEmulator(t, DefaultTopicID)
+	p, err := NewPublisherWithClient(client, DefaultPublisherConfig())
+	if err != nil {
+		t.Fatalf("NewPublisherWithClient: %v", err)
+	}
+	defer p.Close()
+
+	tests := []struct {
+		subscription string
+		tenantName   string
+	}{
+		{subscription: "createGroups", tenantName: "groups-tenant"},
+		{subscription: "createWorkspace", tenantName: "workspace-tenant"},
+		{subscription: "createTenant", tenantName: "tenant-tenant"},
+		{subscription: "createFlux", tenantName: "flux-tenant"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.subscription, func(t *testing.T) {
+			// A subscription created now only receives the messages published after it
+			subID := fmt.Sprintf("sub-%d", i)
+			if _, err := client.CreateSubscription(context.Background(), subID, pubsub.SubscriptionConfig{Topic: client.Topic(DefaultTopicID)}); err != nil {
+				t.Fatalf("CreateSubscription: %v", err)
+			}
+
+			message := validInstructions()
+			message.TenantName = tt.tenantName
+			messageAttributes := attributesFor(t, tt.subscription)
+			id, err := p.PublishContext(context.Background(), &message, messageAttributes)
+			if err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+
+			received := receiveOne(t, client, subID)
+			if received.ID != id {
+				t.Errorf("received message %s, want %s", received.ID, id)
+			}
+			if got := received.Attributes[AttrTenantName]; got != tt.tenantName {
+				t.Errorf("tenantName attribute = %q, want %q", got, tt.tenantName)
+			}
+			for key, want := range map[string]string{
+				AttrGroupsCreated:    messageAttributes.GroupsCreated,
+				AttrWorkspaceCreated: messageAttributes.WorkspaceCreated,
+				AttrTenantCreated:    messageAttributes.TenantCreated,
+				AttrFluxCreated:      messageAttributes.FluxCreated,
+			} {
+				if got := received.Attributes[key]; got != want {
+					t.Errorf("%s attribute = %q, want %q", key, got, want)
+				}
+			}
+			decoded, err := DecodeInstructions(received.Data)
+			if err != nil || decoded.TenantName != tt.tenantName {
+				t.Errorf("received body decodes to %+v, %v", decoded, err)
+			}
+		})
+	}
+}
+
+func TestNewPublisherWithClientErrors(t *testing.T) {
+	client, _ := newEmulator(t)
+	tests := []struct {
+		name        string
+		client      *pubsub.Client
+		opts        []Option
+		wantMessage string
+	}{
+		{name: "nil client", wantMessage: "client can not be nil"},
+		{name: "invalid config", client: client, opts: []Option{WithMaxConcurrentPublishes(-1)}, wantMessage: "publisher config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPublisherWithClient(tt.client, DefaultPublisherConfig(), tt.opts...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Fatalf("NewPublisherWithClient error = %v, want one mentioning %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestPublishSettingsValidated(t *testing.T) {
+	_, connect := newEmulator(t, DefaultTopicID)
+	tests := []struct {
+		name     string
+		settings pubsub.PublishSettings
+		ordering bool
+		// wantMessage is part of the error NewPublisher returns, empty when the
+		// settings are accepted
+		wantMessage string
+	}{
+		{name: "zero keeps the defaults"},
+		{name: "within limits", settings: pubsub.PublishSettings{DelayThreshold: 50 * time.Millisecond, CountThreshold: pubsub.MaxPublishRequestCount, ByteThreshold: int(pubsub.MaxPublishRequestBytes)}},
+		{name: "ordered goroutines at the limit", settings: pubsub.PublishSettings{NumGoroutines: MaxOrderedNumGoroutines}, ordering: true},
+		{name: "unordered goroutines over the ordered limit", settings: pubsub.PublishSettings{NumGoroutines: MaxOrderedNumGoroutines + 1}},
+		{name: "negative delay", settings: pubsub.PublishSettings{DelayThreshold: -time.Millisecond}, wantMessage: "publish settings can not be negative"},
+		{name: "negative count", settings: pubsub.PublishSettings{CountThreshold: -1}, wantMessage: "publish settings can not be negative"},
+		{name: "negative bytes", settings: pubsub.PublishSettings{ByteThreshold: -1}, wantMessage: "publish settings can not be negative"},
+		{name: "negative goroutines", settings: pubsub.PublishSettings{NumGoroutines: -1}, wantMessage: "publish settings can not be negative"},
+		{name: "negative timeout", settings: pubsub.PublishSettings{Timeout: -time.Second}, wantMessage: "publish settings can not be negative"},
+		{
+			name:        "count over the request limit",
+			settings:    pubsub.PublishSettings{CountThreshold: pubsub.MaxPublishRequestCount + 1},
+			wantMessage: fmt.Sprintf("count threshold can be at most %d", pubsub.MaxPublishRequestCount),
+		},
+		{
+			name:        "bytes over the request limit",
+			settings:    pubsub.PublishSettings{ByteThreshold: int(pubsub.MaxPublishRequestBytes) + 1},
+			wantMessage: fmt.Sprintf("byte threshold can be at most %d", int(pubsub.MaxPublishRequestBytes)),
+		},
+		{
+			name:        "ordered goroutines over the limit",
+			settings:    pubsub.PublishSettings{NumGoroutines: MaxOrderedNumGoroutines + 1},
+			ordering:    true,
+			wantMessage: fmt.Sprintf("num goroutines can be at most %d with message ordering", MaxOrderedNumGoroutines),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPublisher(context.Background(), DefaultPublisherConfig(), connect, WithPublishSettings(tt.settings), WithMessageOrdering(tt.ordering))
+			if tt.wantMessage == "" {
+				if err != nil {
+					t.Fatalf("NewPublisher: %v", err)
+				}
+				p.Close()
+				return
+			}
+			if err == nil {
+				p.Close()
+				t.Fatalf("NewPublisher accepted %+v", tt.settings)
+			}
+			if !strings.Contains(err.Error(), "publisher config: ") || !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("NewPublisher error = %v, want a config error containing %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
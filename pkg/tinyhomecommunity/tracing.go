@@ -0,0 +1,36 @@
+package tinyhomecommunity
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name spans are recorded under
+const tracerName = "github.com/tdigangi/publisher/pkg/tinyhomecommunity"
+
+// publishSpanName is the span wrapped around validating and publishing instructions
+const publishSpanName = "tinyhome.publish"
+
+// tracerFrom returns the tracer of tp, falling back to the global provider when tp
+// is nil
+func tracerFrom(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+// endSpan records err on span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracer returns the tracer publish spans of p are created with
+func (p *Publisher) tracer() trace.Tracer {
+	return tracerFrom(p.cfg.TracerProvider)
+}
@@ -0,0 +1,244 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingTracerProvider keeps every span its tracers start in memory
+type recordingTracerProvider struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (tp *recordingTracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{tp: tp}
+}
+
+// ended returns the spans that have ended
+func (tp *recordingTracerProvider) ended() []*recordingSpan {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	var spans []*recordingSpan
+	for _, span := range tp.spans {
+		if span.isEnded() {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+type recordingTracer struct {
+	tp *recordingTracerProvider
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &recordingSpan{tp: t.tp, name: name, attributes: map[attribute.Key]attribute.Value{}}
+	cfg := trace.NewSpanStartConfig(opts...)
+	span.SetAttributes(cfg.Attributes()...)
+	t.tp.mu.Lock()
+	t.tp.spans = append(t.tp.spans, span)
+	t.tp.mu.Unlock()
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// recordingSpan is a span of a recordingTracerProvider
+type recordingSpan struct {
+	tp *recordingTracerProvider
+
+	mu         sync.Mutex
+	name       string
+	attributes map[attribute.Key]attribute.Value
+	errs       []error
+	status     codes.Code
+	ended      bool
+}
+
+func (s *recordingSpan) End(options ...trace.SpanEndOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+func (s *recordingSpan) AddEvent(name string, options ...trace.EventOption) {}
+
+func (s *recordingSpan) IsRecording() bool { return true }
+
+func (s *recordingSpan) RecordError(err error, options ...trace.EventOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, err)
+}
+
+func (s *recordingSpan) SpanContext() trace.SpanContext { return trace.SpanContext{} }
+
+func (s *recordingSpan) SetStatus(code codes.Code, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = code
+}
+
+func (s *recordingSpan) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, attr := range kv {
+		s.attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordingSpan) TracerProvider() trace.TracerProvider { return s.tp }
+
+func (s *recordingSpan) isEnded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ended
+}
+
+func TestPublishSpan(t *testing.T) {
+	errPubSub := errors.New("pubsub unavailable")
+	tests := []struct {
+		name         string
+		subscription string
+		mutate       func(m *TinyHomeInstructions)
+		result       func(n int, msg *pubsub.Message) publishResult
+		// wantAttributes are the span attributes, with message_size checked separately
+		wantAttributes map[attribute.Key]string
+		wantErr        error
+	}{
+		{
+			name:         "published",
+			subscription: "createGroups",
+			wantAttributes: map[attribute.Key]string{
+				"tinyhome.topic":       DefaultTopicID,
+				"tinyhome.tenant_name": "contract-tenant",
+				"tinyhome.stage":       "createGroups",
+				"tinyhome.message_id":  "1",
+			},
+		},
+		{
+			name:         "published to a later stage",
+			subscription: "createFlux",
+			wantAttributes: map[attribute.Key]string{
+				"tinyhome.topic":       DefaultTopicID,
+				"tinyhome.tenant_name": "contract-tenant",
+				"tinyhome.stage":       "createFlux",
+				"tinyhome.message_id":  "1",
+			},
+		},
+		{
+			name:         "publish failure",
+			subscription: "createGroups",
+			result:       func(int, *pubsub.Message) publishResult { return fakeResult{err: errPubSub} },
+			wantAttributes: map[attribute.Key]string{
+				"tinyhome.topic":       DefaultTopicID,
+				"tinyhome.tenant_name": "contract-tenant",
+				"tinyhome.stage":       "createGroups",
+			},
+			wantErr: errPubSub,
+		},
+		{
+			name:         "validation failure",
+			subscription: "createGroups",
+			mutate:       func(m *TinyHomeInstructions) { m.TenantName = "Not Valid" },
+			wantAttributes: map[attribute.Key]string{
+				"tinyhome.topic":       DefaultTopicID,
+				"tinyhome.tenant_name": "Not Valid",
+			},
+			wantErr: ErrInvalidTenantName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := &recordingTracerProvider{}
+			topic := &fakeTopic{result: tt.result}
+			p := newTestPublisher(t, topic, nil, WithTracerProvider(tp))
+			message := validInstructions()
+			if tt.mutate != nil {
+				tt.mutate(&message)
+			}
+
+			_, err := p.PublishContext(context.Background(), &message, attributesFor(t, tt.subscription))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PublishContext error = %v, want %v", err, tt.wantErr)
+			}
+
+			spans := tp.ended()
+			if len(spans) != 1 {
+				t.Fatalf("%d spans ended, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.name != "tinyhome.publish" {
+				t.Errorf("span name = %q, want tinyhome.publish", span.name)
+			}
+			for key, want := range tt.wantAttributes {
+				if got := span.attributes[key].Emit(); got != want {
+					t.Errorf("span attribute %s = %q, want %q", key, got, want)
+				}
+			}
+
+			size, sized := span.attributes["tinyhome.message_size"]
+			if published := topic.published(); len(published) > 0 {
+				if want := int64(len(published[0].Data)); size.AsInt64() != want {
+					t.Errorf("span attribute tinyhome.message_size = %s, want %s", size.Emit(), strconv.FormatInt(want, 10))
+				}
+			} else if sized {
+				t.Errorf("span attribute tinyhome.message_size = %s for a message that was not published", size.Emit())
+			}
+
+			if tt.wantErr == nil {
+				if len(span.errs) != 0 || span.status == codes.Error {
+					t.Errorf("span recorded errors %v with status %v, want none", span.errs, span.status)
+				}
+				return
+			}
+			if len(span.errs) != 1 || !errors.Is(span.errs[0], tt.wantErr) {
+				t.Errorf("span recorded errors %v, want %v", span.errs, tt.wantErr)
+			}
+			if span.status != codes.Error {
+				t.Errorf("span status = %v, want Error", span.status)
+			}
+		})
+	}
+}
+
+func TestPublishSpanGlobalProvider(t *testing.T) {
+	global := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(global) })
+	tp := &recordingTracerProvider{}
+	otel.SetTracerProvider(tp)
+
+	// A Publisher without a TracerProvider uses the global one, a configured one
+	// takes its place
+	p := newTestPublisher(t, &fakeTopic{}, nil)
+	configured := &recordingTracerProvider{}
+	withProvider := newTestPublisher(t, &fakeTopic{}, nil, WithTracerProvider(configured))
+	for _, p := range []*Publisher{p, withProvider} {
+		message := validInstructions()
+		if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+			t.Fatalf("PublishContext: %v", err)
+		}
+	}
+
+	if got := len(tp.ended()); got != 1 {
+		t.Errorf("global provider ended %d spans, want 1", got)
+	}
+	if got := len(configured.ended()); got != 1 {
+		t.Errorf("configured provider ended %d spans, want 1", got)
+	}
+}
@@ -0,0 +1,193 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ValidateUpdate checks next is an allowed update of prev. The tenant name,
+// environment and organization are fixed once created and namespace quota may only
+// grow; owners, roles and bindings may change freely. Every disallowed mutation is
+// reported.
+func ValidateUpdate(prev, next TinyHomeInstructions) error {
+	var mutations []string
+
+	if prev.TenantName != next.TenantName {
+		mutations = append(mutations, fmt.Sprintf("tenantName can not change from %q to %q", prev.TenantName, next.TenantName))
+	}
+
+	if prev.Environment != next.Environment {
+		mutations = append(mutations, fmt.Sprintf("environment can not change from %q to %q", prev.Environment, next.Environment))
+	}
+
+	if prev.Organization != next.Organization {
+		mutations = append(mutations, fmt.Sprintf("organization can not change from %q to %q", prev.Organization, next.Organization))
+	}
+
+	prevQuota := prev.quotaFields()
+	for i, field := range next.quotaFields() {
+		if decreased, err := quotaDecreased(prevQuota[i].value, field.value); err != nil {
+			mutations = append(mutations, fmt.Sprintf("%s: %v", field.name, err))
+		} else if decreased {
+			mutations = append(mutations, fmt.Sprintf("%s can not decrease from %s to %s", field.name, prevQuota[i].value, field.value))
+		}
+	}
+
+	if len(mutations) > 0 {
+		return fmt.Errorf("update not allowed: %s", strings.Join(mutations, "; "))
+	}
+	return nil
+}
+
+// quotaDecreased reports whether next is a smaller quantity than prev. A quota that
+// was never set can be set to anything, but a set quota can not be removed.
+func quotaDecreased(prev, next string) (bool, error) {
+	if prev == "" {
+		return false, nil
+	}
+	if next == "" {
+		return true, nil
+	}
+
+	prevQuantity, err := resource.ParseQuantity(prev)
+	if err != nil {
+		return false, fmt.Errorf("previous value %q is not a valid quantity: %v", prev, err)
+	}
+
+	nextQuantity, err := resource.ParseQuantity(next)
+	if err != nil {
+		return false, fmt.Errorf("%q is not a valid quantity: %v", next, err)
+	}
+
+	return nextQuantity.Cmp(prevQuantity) < 0, nil
+}
+
+// FieldChange is a single field that differs between two versions of instructions.
+// Destructive changes remove or replace something downstream already provisioned.
+type FieldChange struct {
+	Field       string `json:"field"`
+	Old         string `json:"old"`
+	New         string `json:"new"`
+	Destructive bool   `json:"destructive"`
+}
+
+// UpdatePlan describes what publishing next over prev will change, for showing a
+// confirmation before an update is published
+type UpdatePlan struct {
+	Changes  []FieldChange `json:"changes"`
+	Warnings []string      `json:"warnings"`
+}
+
+// HasDestructiveChanges reports whether any change in the plan is destructive
+func (plan UpdatePlan) HasDestructiveChanges() bool {
+	for _, change := range plan.Changes {
+		if change.Destructive {
+			return true
+		}
+	}
+	return false
+}
+
+// identityFields name the resources downstream, so changing them once set is
+// destructive
+var identityFields = []string{"tenantName", "environment", "organization", "domain", "region"}
+
+// planField is a scalar field compared by PlanUpdate
+type planField struct {
+	name  string
+	value string
+}
+
+// planFields returns the scalar fields PlanUpdate compares, in a stable order
+func (message TinyHomeInstructions) planFields() []planField {
+	return []planField{
+		{name: "tenantName", value: message.TenantName},
+		{name: "environment", value: message.Environment},
+		{name: "businessUnit", value: message.BusinessUnit},
+		{name: "tenantOwner", value: message.TenantOwner},
+		{name: "tenantOwnerSecondary", value: message.TenantOwnerSecondary},
+		{name: "tenantCostCenter", value: message.TenantCostCenter},
+		{name: "domain", value: message.Domain},
+		{name: "organization", value: message.Organization},
+		{name: "region", value: message.Region},
+		{name: "priority", value: message.Priority},
+		{name: "breakglass", value: fmt.Sprint(message.Breakglass)},
+		{name: "breakglassWindow", value: message.BreakglassWindow},
+		{name: "breakglassApprover", value: message.BreakglassApprover},
+		{name: "breakglassTicket", value: message.BreakglassTicket},
+		{name: "ownerAccepted", value: fmt.Sprint(message.OwnerAccepted)},
+	}
+}
+
+// PlanUpdate compares prev and next field by field, classifying each change as
+// additive or destructive, and warns about anything ValidateUpdate or validation of
+// next would reject
+func PlanUpdate(prev, next TinyHomeInstructions) UpdatePlan {
+	var plan UpdatePlan
+
+	prevFields := prev.planFields()
+	for i, field := range next.planFields() {
+		old := prevFields[i].value
+		if old == field.value {
+			continue
+		}
+		cleared := field.value == ""
+		replaced := old != "" && contains(identityFields, field.name)
+		plan.Changes = append(plan.Changes, FieldChange{Field: field.name, Old: old, New: field.value, Destructive: cleared || replaced})
+	}
+
+	prevQuota := prev.quotaFields()
+	for i, field := range next.quotaFields() {
+		old := prevQuota[i].value
+		if old == field.value {
+			continue
+		}
+		decreased, err := quotaDecreased(old, field.value)
+		plan.Changes = append(plan.Changes, FieldChange{Field: field.name, Old: old, New: field.value, Destructive: decreased || err != nil})
+	}
+
+	plan.Changes = append(plan.Changes, sliceChanges("addlGkeTenantSaRoles", prev.AddlGkeTenantSaRoles, next.AddlGkeTenantSaRoles)...)
+
+	prevBindings, nextBindings := prev.iamBindings(), next.iamBindings()
+	var roles []string
+	for role := range prevBindings {
+		roles = append(roles, role)
+	}
+	for role := range nextBindings {
+		if _, ok := prevBindings[role]; !ok {
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+	for _, role := range roles {
+		plan.Changes = append(plan.Changes, sliceChanges("addlGroupIamBindings."+role, prevBindings[role], nextBindings[role])...)
+	}
+
+	if err := ValidateUpdate(prev, next); err != nil {
+		plan.Warnings = append(plan.Warnings, err.Error())
+	}
+	if err := next.validateInstructions(); err != nil {
+		plan.Warnings = append(plan.Warnings, err.Error())
+	}
+	return plan
+}
+
+// sliceChanges reports entries added to a slice field as additive and entries removed
+// from it as destructive
+func sliceChanges(field string, prev, next []string) []FieldChange {
+	var changes []FieldChange
+	for _, v := range next {
+		if !contains(prev, v) {
+			changes = append(changes, FieldChange{Field: field, New: v})
+		}
+	}
+	for _, v := range prev {
+		if !contains(next, v) {
+			changes = append(changes, FieldChange{Field: field, Old: v, Destructive: true})
+		}
+	}
+	return changes
+}
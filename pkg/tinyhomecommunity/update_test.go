@@ -0,0 +1,191 @@
+package tinyhomecommunity
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(next *TinyHomeInstructions)
+		// wantMutations are parts of the error, one for each disallowed mutation
+		wantMutations []string
+	}{
+		{name: "no change", mutate: func(*TinyHomeInstructions) {}},
+		{
+			name: "owners change",
+			mutate: func(next *TinyHomeInstructions) {
+				next.TenantOwner = "new-owner@example.com"
+				next.TenantOwnerSecondary = "new-backup@example.com"
+			},
+		},
+		{
+			name: "roles and bindings change",
+			mutate: func(next *TinyHomeInstructions) {
+				next.AddlGkeTenantSaRoles = nil
+				next.AddlGroupIamBindings = map[string][]string{"roles/editor": {"group:editors@example.com"}}
+			},
+		},
+		{
+			name: "quota grows",
+			mutate: func(next *TinyHomeInstructions) {
+				next.NsQuota.Requests.Cpu = "1500m"
+				next.NsQuota.Limits.Memory = "4Gi"
+			},
+		},
+		{
+			name:   "quota unchanged in another unit",
+			mutate: func(next *TinyHomeInstructions) { next.NsQuota.Requests.Memory = "1024Mi" },
+		},
+		{
+			name:          "environment changes",
+			mutate:        func(next *TinyHomeInstructions) { next.Environment = "prod" },
+			wantMutations: []string{`environment can not change from "dev" to "prod"`},
+		},
+		{
+			name:          "organization changes",
+			mutate:        func(next *TinyHomeInstructions) { next.Organization = "999999999999" },
+			wantMutations: []string{`organization can not change from "123456789012" to "999999999999"`},
+		},
+		{
+			name:          "tenant name changes",
+			mutate:        func(next *TinyHomeInstructions) { next.TenantName = "renamed-tenant" },
+			wantMutations: []string{`tenantName can not change from "contract-tenant" to "renamed-tenant"`},
+		},
+		{
+			name:          "quota shrinks",
+			mutate:        func(next *TinyHomeInstructions) { next.NsQuota.Limits.Cpu = "500m" },
+			wantMutations: []string{"nsQuota.limits.cpu can not decrease from 2 to 500m"},
+		},
+		{
+			name:          "quota removed",
+			mutate:        func(next *TinyHomeInstructions) { next.NsQuota.Requests.Memory = "" },
+			wantMutations: []string{"nsQuota.requests.memory can not decrease from 1Gi to "},
+		},
+		{
+			name:          "quota invalid",
+			mutate:        func(next *TinyHomeInstructions) { next.NsQuota.Requests.Cpu = "lots" },
+			wantMutations: []string{`nsQuota.requests.cpu: "lots" is not a valid quantity`},
+		},
+		{
+			name: "every disallowed mutation reported",
+			mutate: func(next *TinyHomeInstructions) {
+				next.Environment = "prod"
+				next.Organization = "999999999999"
+				next.NsQuota.Requests.Cpu = "100m"
+			},
+			wantMutations: []string{"environment can not change", "organization can not change", "nsQuota.requests.cpu can not decrease"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev, next := validInstructions(), validInstructions()
+			tt.mutate(&next)
+
+			err := ValidateUpdate(prev, next)
+			if len(tt.wantMutations) == 0 {
+				if err != nil {
+					t.Fatalf("ValidateUpdate: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("ValidateUpdate allowed the update")
+			}
+			for _, want := range tt.wantMutations {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+			if got := strings.Count(err.Error(), ";") + 1; got != len(tt.wantMutations) {
+				t.Errorf("error %q reports %d mutations, want %d", err, got, len(tt.wantMutations))
+			}
+		})
+	}
+}
+
+func TestPlanUpdate(t *testing.T) {
+	tests := []struct {
+		name        string
+		mutate      func(next *TinyHomeInstructions)
+		wantChanges []FieldChange
+		// wantWarning is part of the only warning, empty when there are none
+		wantWarning string
+	}{
+		{name: "no change", mutate: func(*TinyHomeInstructions) {}},
+		{
+			name:        "owner replaced",
+			mutate:      func(next *TinyHomeInstructions) { next.TenantOwner = "new-owner@example.com" },
+			wantChanges: []FieldChange{{Field: "tenantOwner", Old: "owner@example.com", New: "new-owner@example.com"}},
+		},
+		{
+			name:        "identity field replaced",
+			mutate:      func(next *TinyHomeInstructions) { next.Region = "europe-west1" },
+			wantChanges: []FieldChange{{Field: "region", Old: "us-central1", New: "europe-west1", Destructive: true}},
+		},
+		{
+			name:        "field cleared",
+			mutate:      func(next *TinyHomeInstructions) { next.TenantOwnerSecondary = "" },
+			wantChanges: []FieldChange{{Field: "tenantOwnerSecondary", Old: "backup@example.com", Destructive: true}},
+		},
+		{
+			name:        "quota grows",
+			mutate:      func(next *TinyHomeInstructions) { next.NsQuota.Limits.Memory = "4Gi" },
+			wantChanges: []FieldChange{{Field: "nsQuota.limits.memory", Old: "2Gi", New: "4Gi"}},
+		},
+		{
+			name:        "quota shrinks",
+			mutate:      func(next *TinyHomeInstructions) { next.NsQuota.Limits.Cpu = "1500m" },
+			wantChanges: []FieldChange{{Field: "nsQuota.limits.cpu", Old: "2", New: "1500m", Destructive: true}},
+			wantWarning: "nsQuota.limits.cpu can not decrease",
+		},
+		{
+			name: "roles and bindings changed",
+			mutate: func(next *TinyHomeInstructions) {
+				next.AddlGkeTenantSaRoles = []string{"roles/monitoring.metricWriter"}
+				next.AddlGroupIamBindings = map[string][]string{"roles/editor": {"group:editors@example.com"}}
+			},
+			wantChanges: []FieldChange{
+				{Field: "addlGkeTenantSaRoles", New: "roles/monitoring.metricWriter"},
+				{Field: "addlGkeTenantSaRoles", Old: "roles/logging.logWriter", Destructive: true},
+				{Field: "addlGroupIamBindings.roles/editor", New: "group:editors@example.com"},
+				{Field: "addlGroupIamBindings.roles/viewer", Old: "group:tenant@example.com", Destructive: true},
+			},
+		},
+		{
+			name:        "environment changed",
+			mutate:      func(next *TinyHomeInstructions) { next.Environment = "prod" },
+			wantChanges: []FieldChange{{Field: "environment", Old: "dev", New: "prod", Destructive: true}},
+			wantWarning: `environment can not change from "dev" to "prod"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev, next := validInstructions(), validInstructions()
+			tt.mutate(&next)
+
+			plan := PlanUpdate(prev, next)
+			if !slices.Equal(plan.Changes, tt.wantChanges) {
+				t.Errorf("changes %+v, want %+v", plan.Changes, tt.wantChanges)
+			}
+			wantDestructive := slices.ContainsFunc(tt.wantChanges, func(c FieldChange) bool { return c.Destructive })
+			if got := plan.HasDestructiveChanges(); got != wantDestructive {
+				t.Errorf("HasDestructiveChanges = %v, want %v", got, wantDestructive)
+			}
+
+			if tt.wantWarning == "" {
+				if len(plan.Warnings) != 0 {
+					t.Errorf("warnings %q, want none", plan.Warnings)
+				}
+				return
+			}
+			if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], tt.wantWarning) {
+				t.Errorf("warnings %q, want one mentioning %q", plan.Warnings, tt.wantWarning)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package tinyhomecommunity
+
+import (
+	"errors"
+)
+
+// ValidateAll runs every instruction and attribute check instead of stopping at the
+// first failure, so a request with several problems can be fixed in one pass. It
+// returns nil or a ValidationErrors holding each failure. The publish path keeps
+// failing fast. Config dependent checks use the DefaultPublisherConfig values.
+func (message TinyHomeInstructions) ValidateAll(messageAttributes *TinyHomeMessageAttributes) error {
+	cfg := DefaultPublisherConfig()
+	var errs ValidationErrors
+	add := func(err error) {
+		var validationErr ValidationError
+		if errors.As(err, &validationErr) {
+			errs = append(errs, validationErr)
+		}
+	}
+
+	valueErrs := messageAttributes.valueErrors(cfg.DeliverySources)
+	errs = append(errs, valueErrs...)
+	add(validateCorrelationID(messageAttributes.CorrelationID))
+
+	// The decision table and stage rules are only meaningful once every value is valid
+	subscription := ""
+	if len(valueErrs) == 0 {
+		var err error
+		subscription, err = messageAttributes.subscriptionWith(cfg)
+		add(err)
+	}
+
+	for _, check := range message.instructionChecks(cfg) {
+		add(check())
+	}
+	add(message.validateEntryCount(cfg.MaxInstructionEntries, cfg.AdditionalAttributes))
+	if subscription != "" {
+		add(message.validateForStage(subscription))
+		add(validateMinStage(subscription, cfg.MinStage))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
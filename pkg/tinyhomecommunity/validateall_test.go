@@ -0,0 +1,86 @@
+package tinyhomecommunity
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestValidateAll(t *testing.T) {
+	createTenant := attributesFor(t, "createTenant")
+	tests := []struct {
+		name       string
+		mutate     func(m *TinyHomeInstructions, a *TinyHomeMessageAttributes)
+		wantFields []string
+	}{
+		{name: "valid", mutate: func(*TinyHomeInstructions, *TinyHomeMessageAttributes) {}},
+		{
+			name:       "one problem",
+			mutate:     func(m *TinyHomeInstructions, a *TinyHomeMessageAttributes) { m.TenantName = "Bad Name" },
+			wantFields: []string{"tenantName"},
+		},
+		{
+			name: "instruction and attribute problems",
+			mutate: func(m *TinyHomeInstructions, a *TinyHomeMessageAttributes) {
+				m.TenantName = "Bad Name"
+				m.Environment = "staging-eu"
+				a.DeliveredFrom = "email"
+			},
+			wantFields: []string{AttrDeliveredFrom, "tenantName", "environment"},
+		},
+		{
+			name: "invalid attribute values skip routing",
+			mutate: func(m *TinyHomeInstructions, a *TinyHomeMessageAttributes) {
+				a.GroupsCreated = "yes"
+				a.CorrelationID = "not valid"
+			},
+			wantFields: []string{AttrGroupsCreated, "correlationId"},
+		},
+		{
+			name: "stage rules once routed",
+			mutate: func(m *TinyHomeInstructions, a *TinyHomeMessageAttributes) {
+				*a = *createTenant
+				m.Domain = ""
+				m.TenantOwner = ""
+			},
+			wantFields: []string{"tenantOwner", "domain"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, messageAttributes := validInstructions(), validAttributes()
+			tt.mutate(&message, messageAttributes)
+
+			err := message.ValidateAll(messageAttributes)
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Fatalf("ValidateAll: %v", err)
+				}
+				return
+			}
+
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("ValidateAll error = %v, want ValidationErrors", err)
+			}
+			var fields []string
+			for _, e := range errs {
+				fields = append(fields, e.FieldName())
+			}
+			if !slices.Equal(fields, tt.wantFields) {
+				t.Errorf("failed fields %v, want %v", fields, tt.wantFields)
+			}
+			// Each failure is reachable through the aggregate
+			var first ValidationError
+			if !errors.As(err, &first) || first.FieldName() != tt.wantFields[0] {
+				t.Errorf("errors.As found %v, want the %s failure", first, tt.wantFields[0])
+			}
+
+			// Publishing still fails fast on a single error
+			if _, err := message.DryRun(messageAttributes); errors.As(err, &errs) {
+				t.Errorf("DryRun error = %v, want the first failure only", err)
+			}
+		})
+	}
+}
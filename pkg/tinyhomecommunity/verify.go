@@ -0,0 +1,74 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// PublishAndVerify publishes the instructions and then synchronously pulls from
+// subscriptionId until the published message is received, matched by its
+// correlationId attribute, or timeout elapses. A correlation ID is generated when
+// messageAttributes has none, as the message ID a subscriber sees need not be the
+// one the publish returned. timeout bounds the publish as well as the pull. The
+// verified message is acked and returned.
+//
+// This is heavyweight and meant for low volume onboarding where confirmation matters
+// more than throughput. The subscription should be dedicated to verification, such
+// as one filtered on the correlationId attribute: other messages pulled while
+// waiting are left unacked, so they are redelivered once their ack deadline passes
+// after the pull ends, rather than nacked into a redelivery loop. Pulling any also
+// delays the return by a few seconds, while the client lets go of their lease. The
+// subscription is looked up in the project selected by opts.
+func (message *TinyHomeInstructions) PublishAndVerify(messageAttributes *TinyHomeMessageAttributes, subscriptionId string, timeout time.Duration, opts ...Option) (*pubsub.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	opts = append(opts, WithCorrelationIDGeneration(true))
+	p, err := NewPublisher(ctx, DefaultPublisherConfig(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("PublishAndVerify: %w", err)
+	}
+	defer p.Close()
+
+	result, err := p.publish(ctx, message, messageAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("PublishAndVerify: %w", err)
+	}
+	correlationID := result.CorrelationID
+
+	sub := p.client.Subscription(subscriptionId)
+	sub.ReceiveSettings.Synchronous = true
+	// Unacked messages are not extended, so the pull can end without waiting for
+	// other messages to expire and they return to the subscription on its deadline
+	sub.ReceiveSettings.MaxExtension = -1
+
+	var (
+		mu       sync.Mutex
+		verified *pubsub.Message
+	)
+	err = sub.Receive(ctx, func(_ context.Context, m *pubsub.Message) {
+		if m.Attributes["correlationId"] != correlationID {
+			return
+		}
+
+		m.Ack()
+		mu.Lock()
+		verified = m
+		mu.Unlock()
+		cancel()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("PublishAndVerify: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if verified == nil {
+		return nil, fmt.Errorf("PublishAndVerify: message with correlationId %v not received on subscription %v within %v", correlationID, subscriptionId, timeout)
+	}
+	return verified, nil
+}
@@ -0,0 +1,79 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+func TestPublishAndVerify(t *testing.T) {
+	tests := []struct {
+		name          string
+		correlationID string
+		// foreign messages are published to the topic before the verified one
+		foreign int
+	}{
+		{"generated correlation id", "", 0},
+		{"supplied correlation id", "onboarding-42", 0},
+		{"other messages on the subscription", "", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, emulator := newEmulator(t, DefaultTopicID)
+			ctx := context.Background()
+			topic := client.Topic(DefaultTopicID)
+			defer topic.Stop()
+			if _, err := client.CreateSubscription(ctx, "verify", pubsub.SubscriptionConfig{Topic: topic}); err != nil {
+				t.Fatalf("CreateSubscription: %v", err)
+			}
+
+			for i := 0; i < tt.foreign; i++ {
+				foreign := topic.Publish(ctx, &pubsub.Message{Data: []byte("{}"), Attributes: map[string]string{"correlationId": "someone-else"}})
+				if _, err := foreign.Get(ctx); err != nil {
+					t.Fatalf("publish foreign message: %v", err)
+				}
+			}
+
+			message := validInstructions()
+			messageAttributes := validAttributes()
+			messageAttributes.CorrelationID = tt.correlationID
+			verified, err := message.PublishAndVerify(messageAttributes, "verify", 20*time.Second, emulator)
+			if err != nil {
+				t.Fatalf("PublishAndVerify: %v", err)
+			}
+
+			correlationID := verified.Attributes["correlationId"]
+			if correlationID == "" || correlationID == "someone-else" {
+				t.Fatalf("verified message has correlationId %q", correlationID)
+			}
+			if tt.correlationID != "" && correlationID != tt.correlationID {
+				t.Errorf("verified correlationId = %q, want %q", correlationID, tt.correlationID)
+			}
+			if decoded, err := DecodeInstructions(verified.Data); err != nil || decoded.TenantName != message.TenantName {
+				t.Errorf("verified body decodes to %+v, %v", decoded, err)
+			}
+		})
+	}
+}
+
+func TestPublishAndVerifyTimeout(t *testing.T) {
+	client, emulator := newEmulator(t, DefaultTopicID)
+	// The subscription is on another topic, so the message never arrives on it
+	other, err := client.CreateTopic(context.Background(), "other")
+	if err != nil {
+		t.Fatalf("CreateTopic: %v", err)
+	}
+	if _, err := client.CreateSubscription(context.Background(), "verify", pubsub.SubscriptionConfig{Topic: other}); err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+
+	message := validInstructions()
+	_, err = message.PublishAndVerify(validAttributes(), "verify", 500*time.Millisecond, emulator)
+	if err == nil || !strings.Contains(err.Error(), "not received on subscription verify") {
+		t.Fatalf("PublishAndVerify error = %v, want the message not received", err)
+	}
+}
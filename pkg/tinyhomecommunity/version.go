@@ -0,0 +1,43 @@
+package tinyhomecommunity
+
+import "fmt"
+
+// SchemaVersion is the version of the TinyHomeInstructions payload this package
+// publishes, sent as the schemaVersion attribute. It changes whenever the payload
+// changes in a way consumers must know about to decode it.
+const SchemaVersion = "1"
+
+// SupportedSchemaVersions are the schemaVersion values this package can decode
+var SupportedSchemaVersions = []string{SchemaVersion}
+
+// PublisherVersion is the build version of the publisher, attached to every message
+// as the publisherVersion attribute. Set it at build time with
+//
+//	go build -ldflags "-X github.com/tdigangi/publisher/pkg/tinyhomecommunity.PublisherVersion=$(git rev-parse --short HEAD)"
+//
+// or assign it directly to override the build value. WithPublisherVersion overrides
+// it for a single Publisher.
+var PublisherVersion string
+
+// publisherVersion returns PublisherVersion, or "unknown" when it was never set
+func publisherVersion() string {
+	if PublisherVersion == "" {
+		return "unknown"
+	}
+	return PublisherVersion
+}
+
+// ValidateSchemaVersion checks the schemaVersion attribute of a received message is
+// one of SupportedSchemaVersions. Messages without one predate the attribute and are
+// accepted as the first version.
+func ValidateSchemaVersion(attributes map[string]string) error {
+	version, ok := attributes["schemaVersion"]
+	if !ok {
+		return nil
+	}
+
+	if !contains(SupportedSchemaVersions, version) {
+		return &RoutingError{Field: "schemaVersion", Message: fmt.Sprintf("schemaVersion %q is not one of the supported versions: %s", version, SupportedSchemaVersions)}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestPublisherVersionAttribute(t *testing.T) {
+	tests := []struct {
+		name string
+		// build is the PublisherVersion set at build time
+		build string
+		opts  []Option
+		want  string
+	}{
+		{name: "unset", want: "unknown"},
+		{name: "build version", build: "abc1234", want: "abc1234"},
+		{name: "config override", build: "abc1234", opts: []Option{WithPublisherVersion("v1.2.3")}, want: "v1.2.3"},
+		{name: "config override without build version", opts: []Option{WithPublisherVersion("v1.2.3")}, want: "v1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := PublisherVersion
+			t.Cleanup(func() { PublisherVersion = saved })
+			PublisherVersion = tt.build
+
+			topic := &fakeTopic{}
+			p := newTestPublisher(t, topic, nil, tt.opts...)
+			message := validInstructions()
+			if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+				t.Fatalf("PublishContext: %v", err)
+			}
+
+			if got := topic.published()[0].Attributes["publisherVersion"]; got != tt.want {
+				t.Errorf("publisherVersion = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes map[string]string
+		wantErr    bool
+	}{
+		{name: "current version", attributes: map[string]string{"schemaVersion": SchemaVersion}},
+		{name: "no version", attributes: map[string]string{}},
+		{name: "no attributes"},
+		{name: "unsupported version", attributes: map[string]string{"schemaVersion": "2"}, wantErr: true},
+		{name: "empty version", attributes: map[string]string{"schemaVersion": ""}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSchemaVersion(tt.attributes)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("ValidateSchemaVersion: %v", err)
+				}
+				return
+			}
+			var routingErr *RoutingError
+			if !errors.As(err, &routingErr) || routingErr.FieldName() != "schemaVersion" {
+				t.Fatalf("ValidateSchemaVersion = %v, want a RoutingError for schemaVersion", err)
+			}
+			if !strings.Contains(err.Error(), "is not one of the supported versions") {
+				t.Errorf("ValidateSchemaVersion = %v, want it to list the supported versions", err)
+			}
+		})
+	}
+}
+
+func TestReceiveSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		wantHandled bool
+	}{
+		{name: "supported version handled", version: SchemaVersion, wantHandled: true},
+		{name: "unsupported version nacked", version: "2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got  received
+				logs syncBuffer
+			)
+			client, s, _ := newTestSubscriber(t, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+			data, _ := json.Marshal(validInstructions())
+			publishRaw(t, client, data, map[string]string{AttrGroupsCreated: "false", AttrWorkspaceCreated: "false", AttrTenantCreated: "false", AttrFluxCreated: "false", AttrDeliveredFrom: "galaxy", "schemaVersion": tt.version})
+
+			stop := got.receive(t, s)
+			eventually(t, func() bool {
+				instructions, _ := got.counts()
+				if tt.wantHandled {
+					return instructions == 1
+				}
+				return strings.Contains(logs.String(), "nacking malformed message")
+			})
+			stop()
+
+			instructions, _ := got.counts()
+			if handled := instructions > 0; handled != tt.wantHandled {
+				t.Errorf("handler called %t, want %t", handled, tt.wantHandled)
+			}
+			if !tt.wantHandled && !strings.Contains(logs.String(), "schemaVersion") {
+				t.Errorf("logs = %q, want the nack to name the schemaVersion", logs.String())
+			}
+		})
+	}
+}
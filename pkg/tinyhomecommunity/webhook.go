@@ -0,0 +1,124 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultWebhookTimeout bounds each WebhookURL request when WebhookTimeout is not set
+const DefaultWebhookTimeout = 5 * time.Second
+
+// webhookPayload is the JSON body POSTed to WebhookURL after a publish
+type webhookPayload struct {
+	MessageID            string            `json:"messageId"`
+	Subscription         string            `json:"subscription"`
+	TenantName           string            `json:"tenantName"`
+	CorrelationID        string            `json:"correlationId,omitempty"`
+	IdempotencyKey       string            `json:"idempotencyKey,omitempty"`
+	Attributes           map[string]string `json:"attributes"`
+	PublishedAt          time.Time         `json:"publishedAt"`
+	Warnings             []string          `json:"warnings,omitempty"`
+	CloudEventsMessageID string            `json:"cloudEventsMessageId,omitempty"`
+	// Body is the published message data, base64 encoded, with WebhookIncludeBody
+	Body []byte `json:"body,omitempty"`
+}
+
+// WebhookError reports a published message whose WebhookURL notification failed
+// under StrictWebhook. The message was published, MessageID is its ID.
+type WebhookError struct {
+	MessageID string
+	Err       error
+}
+
+func (e *WebhookError) Error() string {
+	return fmt.Sprintf("message published as %s, webhook failed: %v", e.MessageID, e.Err)
+}
+
+func (e *WebhookError) Unwrap() error { return e.Err }
+
+// notifyWebhook POSTs result, and data with WebhookIncludeBody, to WebhookURL when
+// set. A failure is logged and ignored unless StrictWebhook is set.
+func (p *Publisher) notifyWebhook(ctx context.Context, result *PublishResult, data []byte) error {
+	if p.cfg.WebhookURL == "" {
+		return nil
+	}
+
+	err := p.postWebhook(ctx, result, data)
+	if err == nil {
+		return nil
+	}
+	if p.cfg.StrictWebhook {
+		return &WebhookError{MessageID: result.MessageID, Err: err}
+	}
+	p.logger().WarnContext(ctx, "publish webhook failed", "messageId", result.MessageID, "error", err)
+	return nil
+}
+
+// postWebhook sends one webhook request, bounded by WebhookTimeout
+func (p *Publisher) postWebhook(ctx context.Context, result *PublishResult, data []byte) error {
+	payload := webhookPayload{
+		MessageID:            result.MessageID,
+		Subscription:         result.Subscription,
+		TenantName:           result.TenantName,
+		CorrelationID:        result.CorrelationID,
+		IdempotencyKey:       result.IdempotencyKey,
+		Attributes:           result.Attributes,
+		PublishedAt:          result.PublishedAt,
+		Warnings:             result.Warnings,
+		CloudEventsMessageID: result.CloudEventsMessageID,
+	}
+	if p.cfg.WebhookIncludeBody {
+		payload.Body = data
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal webhook payload: %v", err)
+	}
+
+	timeout := p.cfg.WebhookTimeout
+	if timeout == 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := p.cfg.WebhookClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// validateWebhookURL checks WebhookURL is an absolute http or https URL
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("webhook URL: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook URL %q must be an absolute http or https URL", raw)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package tinyhomecommunity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		delay  time.Duration
+		opts   []Option
+		// wantErr is set when the publish fails with a WebhookError, wantDeadline
+		// when that wraps context.DeadlineExceeded
+		wantErr      bool
+		wantDeadline bool
+		wantLog      string
+	}{
+		{name: "notified", status: http.StatusOK},
+		{name: "notified with body", status: http.StatusNoContent, opts: []Option{WithWebhookIncludeBody(true)}},
+		{
+			name:    "failure logged",
+			status:  http.StatusInternalServerError,
+			wantLog: "500 Internal Server Error",
+		},
+		{
+			name:    "failure under strict",
+			status:  http.StatusBadGateway,
+			opts:    []Option{WithStrictWebhook(true)},
+			wantErr: true,
+		},
+		{
+			name:         "timeout under strict",
+			status:       http.StatusOK,
+			delay:        time.Second,
+			opts:         []Option{WithStrictWebhook(true), WithWebhookTimeout(20 * time.Millisecond)},
+			wantErr:      true,
+			wantDeadline: true,
+		},
+		{
+			name:    "timeout logged",
+			status:  http.StatusOK,
+			delay:   time.Second,
+			opts:    []Option{WithWebhookTimeout(20 * time.Millisecond)},
+			wantLog: "publish webhook failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				requests []*http.Request
+				payloads []map[string]any
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var payload map[string]any
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Errorf("decode webhook payload: %v", err)
+				}
+				mu.Lock()
+				requests = append(requests, r)
+				payloads = append(payloads, payload)
+				mu.Unlock()
+
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			var logs bytes.Buffer
+			topic := &fakeTopic{}
+			opts := append([]Option{
+				WithWebhook(server.URL),
+				WithWebhookClient(server.Client()),
+				WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+			}, tt.opts...)
+			p := newTestPublisher(t, topic, nil, opts...)
+
+			message := validInstructions()
+			result, err := p.PublishWithResult(context.Background(), &message, validAttributes())
+			if tt.wantErr {
+				var webhookErr *WebhookError
+				if !errors.As(err, &webhookErr) {
+					t.Fatalf("PublishWithResult error = %v, want a WebhookError", err)
+				}
+				if webhookErr.MessageID != "1" {
+					t.Errorf("WebhookError.MessageID = %q, want 1", webhookErr.MessageID)
+				}
+				if errors.Is(err, context.DeadlineExceeded) != tt.wantDeadline {
+					t.Errorf("PublishWithResult error = %v, want context.DeadlineExceeded %t", err, tt.wantDeadline)
+				}
+			} else if err != nil {
+				t.Fatalf("PublishWithResult: %v", err)
+			}
+			if len(topic.published()) != 1 {
+				t.Fatalf("published %d messages, want 1 whatever the webhook returns", len(topic.published()))
+			}
+			if tt.wantLog != "" && !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("logs = %q, want them to contain %q", logs.String(), tt.wantLog)
+			}
+
+			eventually(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(requests) == 1
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if requests[0].Method != http.MethodPost || requests[0].Header.Get("Content-Type") != "application/json" {
+				t.Errorf("webhook request %s with Content-Type %q, want a POST of application/json", requests[0].Method, requests[0].Header.Get("Content-Type"))
+			}
+			payload := payloads[0]
+			if payload["messageId"] != "1" || payload["subscription"] != "createGroups" || payload["tenantName"] != message.TenantName {
+				t.Errorf("webhook payload = %v, want message 1 to createGroups for %s", payload, message.TenantName)
+			}
+			if result != nil && payload["idempotencyKey"] != result.IdempotencyKey {
+				t.Errorf("webhook payload idempotencyKey = %v, want %s", payload["idempotencyKey"], result.IdempotencyKey)
+			}
+
+			_, hasBody := payload["body"]
+			if wantBody := p.cfg.WebhookIncludeBody; hasBody != wantBody {
+				t.Errorf("webhook payload has body = %t, want %t", hasBody, wantBody)
+			}
+			if hasBody {
+				var body []byte
+				raw, _ := json.Marshal(payload["body"])
+				if err := json.Unmarshal(raw, &body); err != nil {
+					t.Fatalf("webhook payload body: %v", err)
+				}
+				if !bytes.Equal(body, topic.published()[0].Data) {
+					t.Errorf("webhook payload body = %s, want the published data %s", body, topic.published()[0].Data)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookNotSet(t *testing.T) {
+	topic := &fakeTopic{}
+	p := newTestPublisher(t, topic, nil, WithWebhookClient(&http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("webhook request to %s with no WebhookURL", r.URL)
+		return nil, errors.New("unexpected request")
+	})}))
+
+	message := validInstructions()
+	if _, err := p.PublishContext(context.Background(), &message, validAttributes()); err != nil {
+		t.Fatalf("PublishContext: %v", err)
+	}
+}
+
+func TestWebhookConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{name: "https URL", opts: []Option{WithWebhook("https://hooks.example.com/published")}},
+		{name: "http URL", opts: []Option{WithWebhook("http://localhost:8080/")}},
+		{name: "relative URL", opts: []Option{WithWebhook("/published")}, wantErr: true},
+		{name: "other scheme", opts: []Option{WithWebhook("ftp://hooks.example.com/")}, wantErr: true},
+		{name: "unparseable URL", opts: []Option{WithWebhook("https://hooks example.com/%zz")}, wantErr: true},
+		{name: "negative timeout", opts: []Option{WithWebhook("https://hooks.example.com/"), WithWebhookTimeout(-time.Second)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPublisherWithTopic(&fakeTopic{}, DefaultPublisherConfig(), tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPublisherWithTopic error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// roundTripperFunc is an http.RoundTripper calling itself
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
@@ -0,0 +1,52 @@
+package tinyhomecommunity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stringField is a string field of the instructions and its json name
+type stringField struct {
+	name  string
+	value *string
+}
+
+// whitespaceFields returns the string fields checked for leading and trailing
+// whitespace
+func (message *TinyHomeInstructions) whitespaceFields() []stringField {
+	return []stringField{
+		{"tenantName", &message.TenantName},
+		{"environment", &message.Environment},
+		{"businessUnit", &message.BusinessUnit},
+		{"tenantOwner", &message.TenantOwner},
+		{"tenantOwnerSecondary", &message.TenantOwnerSecondary},
+		{"tenantCostCenter", &message.TenantCostCenter},
+		{"domain", &message.Domain},
+		{"organization", &message.Organization},
+		{"region", &message.Region},
+		{"priority", &message.Priority},
+		{"breakglassWindow", &message.BreakglassWindow},
+		{"breakglassApprover", &message.BreakglassApprover},
+		{"breakglassTicket", &message.BreakglassTicket},
+	}
+}
+
+// validateWhitespace rejects a whitespaceFields value with leading or trailing
+// whitespace, such as a pasted trailing newline, which would break exact matches
+// downstream
+func (message *TinyHomeInstructions) validateWhitespace() error {
+	for _, field := range message.whitespaceFields() {
+		if *field.value != strings.TrimSpace(*field.value) {
+			return &NamingError{Field: field.name, Message: fmt.Sprintf("%s %q has leading or trailing whitespace", field.name, *field.value)}
+		}
+	}
+	return nil
+}
+
+// trimWhitespace trims leading and trailing whitespace from every whitespaceFields
+// value in place
+func (message *TinyHomeInstructions) trimWhitespace() {
+	for _, field := range message.whitespaceFields() {
+		*field.value = strings.TrimSpace(*field.value)
+	}
+}
@@ -0,0 +1,87 @@
+package tinyhomecommunity
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWhitespace(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		pad   func(value string) string
+	}{
+		{name: "trailing newline", field: "tenantName", pad: func(v string) string { return v + "\n" }},
+		{name: "leading space", field: "environment", pad: func(v string) string { return " " + v }},
+		{name: "both sides", field: "businessUnit", pad: func(v string) string { return "\t" + v + " " }},
+		{name: "trailing tab", field: "tenantOwner", pad: func(v string) string { return v + "\t" }},
+		{name: "carriage return", field: "tenantCostCenter", pad: func(v string) string { return v + "\r\n" }},
+		{name: "leading newline", field: "domain", pad: func(v string) string { return "\n" + v }},
+		{name: "trailing space", field: "organization", pad: func(v string) string { return v + " " }},
+	}
+
+	for _, tt := range tests {
+		for _, trim := range []bool{false, true} {
+			name := tt.name + " rejected"
+			if trim {
+				name = tt.name + " trimmed"
+			}
+			t.Run(name, func(t *testing.T) {
+				topic := &fakeTopic{}
+				p := newTestPublisher(t, topic, nil, WithTrimWhitespace(trim))
+
+				want := validInstructions()
+				message := validInstructions()
+				for _, field := range message.whitespaceFields() {
+					if field.name == tt.field {
+						*field.value = tt.pad(*field.value)
+					}
+				}
+
+				_, err := p.PublishContext(context.Background(), &message, validAttributes())
+				if !trim {
+					wantFieldError(t, err, tt.field)
+					if err != nil && !strings.Contains(err.Error(), "leading or trailing whitespace") {
+						t.Errorf("PublishContext error = %v, want it to report the whitespace", err)
+					}
+					if got := len(topic.published()); got != 0 {
+						t.Errorf("published %d messages, want none", got)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("PublishContext: %v", err)
+				}
+
+				// The trimmed value is published and left on the instructions
+				var body TinyHomeInstructions
+				if err := json.Unmarshal(topic.published()[0].Data, &body); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(body, want) {
+					t.Errorf("published %+v, want %+v", body, want)
+				}
+				if !reflect.DeepEqual(message, want) {
+					t.Errorf("instructions after publish %+v, want %+v", message, want)
+				}
+			})
+		}
+	}
+}
+
+func TestWhitespaceInside(t *testing.T) {
+	// Only leading and trailing whitespace is trimmed, inner spaces are left for the
+	// field's own validation
+	message := validInstructions()
+	message.BusinessUnit = " platform team "
+	message.trimWhitespace()
+	if message.BusinessUnit != "platform team" {
+		t.Errorf("trimmed businessUnit = %q, want %q", message.BusinessUnit, "platform team")
+	}
+	if err := message.validateWhitespace(); err != nil {
+		t.Errorf("validateWhitespace after trimming: %v", err)
+	}
+}